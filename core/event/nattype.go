@@ -16,3 +16,15 @@ type EvtNATDeviceTypeChanged struct {
 	// how they impact Connectivity and Hole Punching.
 	NatDeviceType network.NATDeviceType
 }
+
+// EvtNATMappingsChanged is an event struct to be emitted when the analysis of
+// the NAT mappings of our listen addresses changes.
+//
+// Unlike EvtNATDeviceTypeChanged, this event is emitted irrespective of the
+// AutoNAT Reachability. Consumers should check the Confidence of a mapping
+// before acting on it.
+type EvtNATMappingsChanged struct {
+	// Mappings has one entry per listen address that peers observed,
+	// sorted by local address.
+	Mappings []network.NATMapping
+}
@@ -0,0 +1,68 @@
+package event
+
+import (
+	"net/netip"
+	"time"
+)
+
+// PortMappingChange is the kind of change of a port mapping on a NAT gateway.
+type PortMappingChange int
+
+const (
+	// PortMappingCreated means that the gateway granted the mapping, for the
+	// first time or after it failed.
+	PortMappingCreated PortMappingChange = iota
+	// PortMappingRenewed means that the gateway renewed the mapping.
+	PortMappingRenewed
+	// PortMappingFailed means that the gateway didn't grant a mapping we don't
+	// have yet.
+	PortMappingFailed
+	// PortMappingLost means that the gateway didn't renew an established
+	// mapping.
+	PortMappingLost
+	// PortMappingRemapped means that the gateway renewed the mapping, but with
+	// a different external port. Addresses using the previous external port
+	// are no longer reachable.
+	PortMappingRemapped
+)
+
+func (c PortMappingChange) String() string {
+	switch c {
+	case PortMappingCreated:
+		return "created"
+	case PortMappingRenewed:
+		return "renewed"
+	case PortMappingFailed:
+		return "failed"
+	case PortMappingLost:
+		return "lost"
+	case PortMappingRemapped:
+		return "remapped"
+	default:
+		return "unknown"
+	}
+}
+
+// EvtPortMappingChanged is emitted when we create, renew, fail to create or
+// lose a port mapping on the NAT gateway, or when the gateway changes its
+// external port. It is only emitted if port mapping
+// is enabled, see the NATPortMap option.
+type EvtPortMappingChanged struct {
+	Change PortMappingChange
+	// GatewayType is the port mapping protocol of the gateway, e.g.
+	// "UPNP (IG1-IP1)", "NAT-PMP" or "PCP".
+	GatewayType string
+	// Protocol is the transport protocol, "tcp" or "udp".
+	Protocol string
+	// InternalPort is the mapped local port.
+	InternalPort int
+	// ExternalAddr is the external address of the mapping. It is not valid
+	// when the mapping failed or was lost.
+	ExternalAddr netip.AddrPort
+	// Lease is the lifetime of the mapping, 0 if the gateway only granted a
+	// permanent mapping.
+	Lease time.Duration
+	// Error is the error returned by the gateway when the mapping failed or
+	// was lost.
+	Error error
+}
@@ -0,0 +1,17 @@
+package event
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// EvtRelayGoingAway is emitted when a relay notifies us that it is going away,
+// e.g. to restart. Only relays we hold a reservation with send this
+// notification.
+type EvtRelayGoingAway struct {
+	// Relay is the relay that is going away.
+	Relay peer.ID
+	// Downtime is the expected downtime of the relay, 0 if unknown.
+	Downtime time.Duration
+}
@@ -3,6 +3,8 @@ package network
 import (
 	"context"
 	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
 )
 
 // DialPeerTimeout is the default timeout for a single call to `DialPeer`. When
@@ -15,6 +17,7 @@ type dialPeerTimeoutCtxKey struct{}
 type forceDirectDialCtxKey struct{}
 type allowLimitedConnCtxKey struct{}
 type simConnectCtxKey struct{ isClient bool }
+type extraDialAddrsCtxKey struct{}
 
 var noDial = noDialCtxKey{}
 var forceDirectDial = forceDirectDialCtxKey{}
@@ -62,6 +65,31 @@ func GetSimultaneousConnect(ctx context.Context) (simconnect bool, isClient bool
 	return false, false, ""
 }
 
+type extraDialAddrs struct {
+	addrs   []ma.Multiaddr
+	stagger time.Duration
+}
+
+// WithExtraDialAddrs constructs a new context with an option that instructs the
+// network to dial addrs in addition to the addresses of the peer in the
+// peerstore, one every stagger, without adding them to the peerstore. It's meant
+// for guesses of the peer's addresses, like the ports predicted when hole
+// punching.
+// EXPERIMENTAL
+func WithExtraDialAddrs(ctx context.Context, addrs []ma.Multiaddr, stagger time.Duration) context.Context {
+	return context.WithValue(ctx, extraDialAddrsCtxKey{}, extraDialAddrs{addrs: addrs, stagger: stagger})
+}
+
+// GetExtraDialAddrs returns the extra addresses to dial set in the context,
+// and the interval between their dials.
+// EXPERIMENTAL
+func GetExtraDialAddrs(ctx context.Context) (addrs []ma.Multiaddr, stagger time.Duration) {
+	if v, ok := ctx.Value(extraDialAddrsCtxKey{}).(extraDialAddrs); ok {
+		return v.addrs, v.stagger
+	}
+	return nil, 0
+}
+
 // WithNoDial constructs a new context with an option that instructs the network
 // to not attempt a new dial when opening a stream.
 func WithNoDial(ctx context.Context, reason string) context.Context {
@@ -1,5 +1,7 @@
 package network
 
+import ma "github.com/multiformats/go-multiaddr"
+
 // NATDeviceType indicates the type of the NAT device.
 type NATDeviceType int
 
@@ -56,3 +58,72 @@ func (n NATTransportProtocol) String() string {
 		return "unrecognized"
 	}
 }
+
+// NATMappingBehavior describes how a NAT device chooses the external port of a
+// mapping.
+type NATMappingBehavior int
+
+const (
+	// NATMappingUnknown indicates that the mapping behavior is unknown.
+	NATMappingUnknown NATMappingBehavior = iota
+	// NATMappingPortPreserving indicates that the NAT device keeps the local
+	// port as the external port.
+	NATMappingPortPreserving
+	// NATMappingConsistent indicates that the NAT device maps the local port
+	// to the same external port, different from the local port, irrespective
+	// of the destination.
+	NATMappingConsistent
+	// NATMappingRandom indicates that the NAT device maps the local port to
+	// a different external port for different destinations.
+	NATMappingRandom
+)
+
+func (b NATMappingBehavior) String() string {
+	switch b {
+	case 0:
+		return "Unknown"
+	case 1:
+		return "PortPreserving"
+	case 2:
+		return "Consistent"
+	case 3:
+		return "Random"
+	default:
+		return "unrecognized"
+	}
+}
+
+// NATMapping is the result of the analysis of the external addresses observed
+// by peers for one of our listen addresses.
+type NATMapping struct {
+	// LocalAddr is the thin waist form of the listen address,
+	// e.g. /ip4/192.168.1.2/udp/4001.
+	LocalAddr ma.Multiaddr
+	// TransportProtocol is the transport protocol of LocalAddr.
+	TransportProtocol NATTransportProtocol
+	// IPv6 is true when LocalAddr is an IPv6 address.
+	IPv6 bool
+	// DeviceType is the type of the NAT device for LocalAddr.
+	DeviceType NATDeviceType
+	// Behavior is the mapping behavior of the NAT device for LocalAddr.
+	Behavior NATMappingBehavior
+	// ExternalAddr is the external thin waist address reported by most
+	// observers. It is nil if there are no observations.
+	ExternalAddr ma.Multiaddr
+	// ObservedAddrs are all the external thin waist addresses reported by
+	// observers, sorted. Behind an endpoint dependent NAT, they are the
+	// external ports the NAT device allocated for different destinations.
+	ObservedAddrs []ma.Multiaddr
+	// Observations is the number of observations. An observer reporting
+	// different external addresses is counted once per address.
+	Observations int
+	// Confidence is the fraction of the observations that reported
+	// ExternalAddr, between 0 and 1.
+	Confidence float64
+	// Hairpinning is true when we recently saw several connections going out
+	// and coming back in through the external address of the NAT device.
+	Hairpinning bool
+	// CGNAT is true when LocalAddr or ExternalAddr is in the shared address
+	// space (100.64.0.0/10), i.e. when we are behind a carrier grade NAT.
+	CGNAT bool
+}
@@ -0,0 +1,95 @@
+package peerstore
+
+import (
+	"errors"
+	"net/netip"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// Query selects peers in a peerstore. A peer matches a query if it matches all
+// of the criteria that are set.
+type Query struct {
+	// Protocols selects the peers supporting all of the protocols.
+	Protocols []protocol.ID
+	// AddrPrefix selects the peers with an IP address in the prefix.
+	AddrPrefix netip.Prefix
+	// Transport selects the peers with an address that contains the
+	// multiaddr protocol, e.g. "tcp", "quic-v1" or "webtransport". If
+	// AddrPrefix is set too, a single address must match both.
+	Transport string
+	// MetadataKey selects the peers with a metadata value under the key.
+	MetadataKey string
+	// SeenAfter selects the peers that were last seen after the time.
+	SeenAfter time.Time
+	// Limit is the maximum number of peers returned. 0 means no limit.
+	Limit int
+}
+
+// Querier is implemented by peerstores that can be queried for peers.
+type Querier interface {
+	// QueryPeers returns the peers that match the query, ordered by peer ID.
+	QueryPeers(q Query) (peer.IDSlice, error)
+}
+
+// GetQuerier is a helper to "upcast" a Peerstore to a Querier using a type
+// assertion. If the given Peerstore is also a Querier, it will be returned,
+// and the ok return value will be true. Returns (nil, false) if the Peerstore
+// is not a Querier.
+func GetQuerier(ps Peerstore) (q Querier, ok bool) {
+	q, ok = ps.(Querier)
+	return q, ok
+}
+
+// Validate checks that the query is well formed.
+func (q *Query) Validate() error {
+	if q.AddrPrefix.IsValid() && q.AddrPrefix != q.AddrPrefix.Masked() {
+		return errors.New("address prefix is not masked")
+	}
+	if q.Transport != "" && ma.ProtocolWithName(q.Transport).Code == 0 {
+		return errors.New("unknown transport protocol: " + q.Transport)
+	}
+	if q.Limit < 0 {
+		return errors.New("negative limit")
+	}
+	return nil
+}
+
+// HasAddrCriteria returns whether the query selects peers by their addresses.
+func (q *Query) HasAddrCriteria() bool {
+	return q.AddrPrefix.IsValid() || q.Transport != ""
+}
+
+// MatchesAddr returns whether the address matches the address criteria of the
+// query.
+func (q *Query) MatchesAddr(a ma.Multiaddr) bool {
+	if q.AddrPrefix.IsValid() {
+		ip, err := manet.ToIP(a)
+		if err != nil {
+			return false
+		}
+		addr, ok := netip.AddrFromSlice(ip)
+		if !ok || !q.AddrPrefix.Contains(addr.Unmap()) {
+			return false
+		}
+	}
+	if q.Transport != "" {
+		code := ma.ProtocolWithName(q.Transport).Code
+		found := false
+		for _, c := range a {
+			if c.Code() == code {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
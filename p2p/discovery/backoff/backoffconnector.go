@@ -7,6 +7,8 @@ import (
 
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
 
 	lru "github.com/hashicorp/golang-lru/v2"
 )
@@ -17,7 +19,13 @@ type BackoffConnector struct {
 	host       host.Host
 	connTryDur time.Duration
 	backoff    BackoffFactory
-	mux        sync.Mutex
+	mux        sync.Mutex // guards cache and closed
+	closed     bool
+
+	// ctx is canceled by Close, refCount tracks the dial goroutines
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
 }
 
 // NewBackoffConnector creates a utility to connect to peers, but only if we have not recently tried connecting to them already
@@ -30,14 +38,28 @@ func NewBackoffConnector(h host.Host, cacheSize int, connectionTryDuration time.
 		return nil, err
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	return &BackoffConnector{
 		cache:      cache,
 		host:       h,
 		connTryDur: connectionTryDuration,
 		backoff:    backoff,
+		ctx:        ctx,
+		ctxCancel:  cancel,
 	}, nil
 }
 
+// Close cancels the pending connection attempts and waits for them to return.
+// Connect doesn't connect to any peer after Close.
+func (c *BackoffConnector) Close() error {
+	c.mux.Lock()
+	c.closed = true
+	c.mux.Unlock()
+	c.ctxCancel()
+	c.refCount.Wait()
+	return nil
+}
+
 type connCacheData struct {
 	nextTry time.Time
 	strat   BackoffStrategy
@@ -59,6 +81,10 @@ func (c *BackoffConnector) Connect(ctx context.Context, peerCh <-chan peer.AddrI
 			}
 
 			c.mux.Lock()
+			if c.closed {
+				c.mux.Unlock()
+				return
+			}
 			var cachedPeer *connCacheData
 			if tv, ok := c.cache.Get(pi.ID); ok {
 				now := time.Now()
@@ -73,12 +99,17 @@ func (c *BackoffConnector) Connect(ctx context.Context, peerCh <-chan peer.AddrI
 				cachedPeer.nextTry = time.Now().Add(cachedPeer.strat.Delay())
 				c.cache.Add(pi.ID, cachedPeer)
 			}
+			c.refCount.Add(1)
 			c.mux.Unlock()
 
 			go func(pi peer.AddrInfo) {
+				defer c.refCount.Done()
 				ctx, cancel := context.WithTimeout(ctx, c.connTryDur)
 				defer cancel()
+				stop := context.AfterFunc(c.ctx, cancel)
+				defer stop()
 
+				pstore.AddAddrsWithSource(c.host.Peerstore(), pi.ID, pi.Addrs, peerstore.TempAddrTTL, pstore.AddrSourceDiscovery)
 				err := c.host.Connect(ctx, pi)
 				if err != nil {
 					log.Debugf("Error connecting to pubsub peer %s: %s", pi.ID, err.Error())
@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
 	bhost "github.com/libp2p/go-libp2p/p2p/host/blank"
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
 
 	"github.com/stretchr/testify/require"
@@ -94,3 +96,50 @@ func TestBackoffConnector(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 	require.Len(t, primary.Network().Peers(), len(hosts)-2, "wrong number of connections")
 }
+
+func TestBackoffConnectorAddrSource(t *testing.T) {
+	hosts := getNetHosts(t, 2)
+	bc, err := NewBackoffConnector(hosts[0], 10, time.Minute, NewFixedBackoff(time.Minute))
+	require.NoError(t, err)
+
+	bc.Connect(context.Background(), loadCh(hosts[1:]))
+	require.Eventually(t, func() bool { return len(hosts[0].Network().Peers()) == 1 }, 3*time.Second, 10*time.Millisecond)
+
+	tracker, ok := hosts[0].Peerstore().(pstore.AddrTracker)
+	require.True(t, ok)
+	for _, a := range hosts[1].Addrs() {
+		stats, ok := tracker.AddrStats(hosts[1].ID(), a)
+		require.True(t, ok, "missing stats for %s", a)
+		require.Equal(t, pstore.AddrSourceDiscovery, stats.Source)
+	}
+}
+
+// blockingDialHost blocks dials until their context is done.
+type blockingDialHost struct {
+	host.Host
+	dialing  chan peer.ID
+	returned atomic.Int32
+}
+
+func (h *blockingDialHost) Connect(ctx context.Context, ai peer.AddrInfo) error {
+	h.dialing <- ai.ID
+	<-ctx.Done()
+	h.returned.Add(1)
+	return ctx.Err()
+}
+
+func TestBackoffConnectorClose(t *testing.T) {
+	hosts := getNetHosts(t, 3)
+	h := &blockingDialHost{Host: hosts[0], dialing: make(chan peer.ID, 2)}
+	bc, err := NewBackoffConnector(h, 10, time.Minute, NewFixedBackoff(time.Minute))
+	require.NoError(t, err)
+
+	bc.Connect(context.Background(), loadCh(hosts[1:2]))
+	<-h.dialing
+	require.NoError(t, bc.Close())
+	require.Equal(t, int32(1), h.returned.Load(), "Close should wait for the dials")
+
+	// no dials after Close
+	bc.Connect(context.Background(), loadCh(hosts[2:]))
+	require.Empty(t, h.dialing)
+}
@@ -0,0 +1,363 @@
+// Package bootstrap implements a discovery.Discoverer that serves a list of
+// bootstrap peers, read from a static list, a file or a dnsaddr TXT record,
+// and a Connector that maintains connections to a minimum number of them.
+//
+// Peer files are JSON, plain text or a small subset of TOML, see ParsePeers.
+// Applications that keep their peers in a larger TOML configuration can decode
+// them themselves and use NewStaticSource.
+package bootstrap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("bootstrap")
+
+const (
+	// DefaultDNSRefreshInterval is the interval at which dnsaddr sources are
+	// resolved again.
+	DefaultDNSRefreshInterval = 10 * time.Minute
+	// DefaultFilePollInterval is the interval at which file sources check
+	// whether their file changed.
+	DefaultFilePollInterval = 10 * time.Second
+
+	maxDNSAddrRecursion = 4
+	maxDNSAddrs         = 1000
+)
+
+// Source is a source of bootstrap peers.
+type Source interface {
+	// Peers returns the bootstrap peers.
+	Peers(ctx context.Context) ([]peer.AddrInfo, error)
+}
+
+type staticSource []peer.AddrInfo
+
+// NewStaticSource returns a Source that serves a fixed list of peers.
+func NewStaticSource(peers ...peer.AddrInfo) Source {
+	return staticSource(peers)
+}
+
+func (s staticSource) Peers(context.Context) ([]peer.AddrInfo, error) {
+	return s, nil
+}
+
+// ParsePeers parses a list of peers. It accepts a JSON array of multiaddrs
+// ending with /p2p/<peer ID>, a JSON array of peer.AddrInfo, a TOML document
+// with a peers array of such multiaddrs, or one multiaddr per line. In the
+// last format, empty lines and lines starting with # are ignored. Addresses of
+// the same peer are merged.
+//
+// Only a small subset of TOML is supported: the document must only contain
+// the top-level peers key, e.g.
+//
+//	# bootstrap peers
+//	peers = [
+//	  "/ip4/1.2.3.4/tcp/4001/p2p/12D3KooW...",
+//	  '/dnsaddr/bootstrap.example.com/p2p/12D3KooW...',
+//	]
+//
+// with comments, basic and literal strings and trailing commas. Tables, other
+// keys and multi-line strings are rejected.
+func ParsePeers(b []byte) ([]peer.AddrInfo, error) {
+	var strs []string
+	b = bytes.TrimSpace(b)
+	switch {
+	case bytes.HasPrefix(b, []byte("[")):
+		if err := json.Unmarshal(b, &strs); err != nil {
+			var infos []peer.AddrInfo
+			if err := json.Unmarshal(b, &infos); err != nil {
+				return nil, fmt.Errorf("invalid JSON peer list: %w", err)
+			}
+			return mergeAddrInfos(nil, make(map[peer.ID]int), infos), nil
+		}
+	case isTOML(b):
+		var err error
+		strs, err = parseTOMLPeers(string(b))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		sc := bufio.NewScanner(bytes.NewReader(b))
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			strs = append(strs, line)
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	}
+	addrs := make([]ma.Multiaddr, 0, len(strs))
+	for _, s := range strs {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, a)
+	}
+	return peer.AddrInfosFromP2pAddrs(addrs...)
+}
+
+// mergeAddrInfos appends peers to res, merging the addresses of peers that are
+// already in res. idx maps the peers in res to their index.
+func mergeAddrInfos(res []peer.AddrInfo, idx map[peer.ID]int, peers []peer.AddrInfo) []peer.AddrInfo {
+	for _, ai := range peers {
+		i, ok := idx[ai.ID]
+		if !ok {
+			idx[ai.ID] = len(res)
+			res = append(res, peer.AddrInfo{ID: ai.ID, Addrs: append([]ma.Multiaddr(nil), ai.Addrs...)})
+			continue
+		}
+		for _, a := range ai.Addrs {
+			if !ma.Contains(res[i].Addrs, a) {
+				res[i].Addrs = append(res[i].Addrs, a)
+			}
+		}
+	}
+	return res
+}
+
+// FileSource is a Source that reads the peers from a file, in one of the
+// formats accepted by ParsePeers. It polls the file and reads it again when it
+// changes. If the file can't be read or parsed, the peers of the last
+// successful read are used.
+type FileSource struct {
+	path     string
+	interval time.Duration
+
+	mx      sync.Mutex
+	modTime time.Time
+	size    int64
+	peers   []peer.AddrInfo
+	loaded  bool
+	err     error // error of the last read
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
+}
+
+var _ Source = (*FileSource)(nil)
+
+// NewFileSource returns a FileSource that reads the peers from path and checks
+// it for changes every interval, DefaultFilePollInterval if 0. The caller must
+// call Close to stop polling.
+func NewFileSource(path string, interval time.Duration) *FileSource {
+	if interval == 0 {
+		interval = DefaultFilePollInterval
+	}
+	s := &FileSource{path: path, interval: interval}
+	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
+	s.reload()
+	s.refCount.Add(1)
+	go s.background()
+	return s
+}
+
+// Close stops polling the file.
+func (s *FileSource) Close() error {
+	s.ctxCancel()
+	s.refCount.Wait()
+	return nil
+}
+
+func (s *FileSource) background() {
+	defer s.refCount.Done()
+
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.reload()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// Peers returns the peers of the last successful read of the file. It fails if
+// the file was never read successfully.
+func (s *FileSource) Peers(context.Context) ([]peer.AddrInfo, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if !s.loaded {
+		return nil, s.err
+	}
+	return s.peers, nil
+}
+
+// reload reads the file if it changed since the last successful read. It is
+// only called from NewFileSource and the background loop, so reads don't
+// race.
+func (s *FileSource) reload() {
+	fi, err := os.Stat(s.path)
+	if err == nil {
+		s.mx.Lock()
+		unchanged := s.loaded && fi.ModTime().Equal(s.modTime) && fi.Size() == s.size
+		s.mx.Unlock()
+		if unchanged {
+			return
+		}
+	}
+	var peers []peer.AddrInfo
+	if err == nil {
+		var b []byte
+		if b, err = os.ReadFile(s.path); err == nil {
+			peers, err = ParsePeers(b)
+		}
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if err != nil {
+		if s.loaded && s.err == nil {
+			log.Warnf("failed to reload bootstrap peers from %s: %s", s.path, err)
+		}
+		s.err = err
+		return
+	}
+	log.Debugf("loaded %d bootstrap peers from %s", len(peers), s.path)
+	s.peers = peers
+	s.modTime = fi.ModTime()
+	s.size = fi.Size()
+	s.loaded = true
+	s.err = nil
+}
+
+type dnsaddrSource struct {
+	resolver network.MultiaddrDNSResolver
+	addr     ma.Multiaddr
+	refresh  time.Duration
+
+	mx      sync.Mutex
+	peers   []peer.AddrInfo
+	updated time.Time
+
+	// for tests
+	now func() time.Time
+}
+
+// NewDNSAddrSource returns a Source that resolves the /dnsaddr multiaddr addr,
+// e.g. /dnsaddr/bootstrap.libp2p.io, with the resolver. It is resolved again
+// after the refresh interval, DefaultDNSRefreshInterval if 0. If it can't be
+// resolved, the peers of the last successful resolution are used.
+func NewDNSAddrSource(resolver network.MultiaddrDNSResolver, addr ma.Multiaddr, refresh time.Duration) (Source, error) {
+	if resolver == nil {
+		return nil, errors.New("no resolver")
+	}
+	if first, _ := ma.SplitFirst(addr); first == nil || first.Protocol().Code != ma.P_DNSADDR {
+		return nil, fmt.Errorf("not a dnsaddr multiaddr: %s", addr)
+	}
+	if refresh == 0 {
+		refresh = DefaultDNSRefreshInterval
+	}
+	return &dnsaddrSource{
+		resolver: resolver,
+		addr:     addr,
+		refresh:  refresh,
+		now:      time.Now,
+	}, nil
+}
+
+func (s *dnsaddrSource) Peers(ctx context.Context) ([]peer.AddrInfo, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	now := s.now()
+	if !s.updated.IsZero() && now.Sub(s.updated) < s.refresh {
+		return s.peers, nil
+	}
+	addrs, err := s.resolver.ResolveDNSAddr(ctx, "", s.addr, maxDNSAddrRecursion, maxDNSAddrs)
+	if err == nil {
+		var peers []peer.AddrInfo
+		peers, err = peer.AddrInfosFromP2pAddrs(addrs...)
+		if err == nil {
+			s.peers = peers
+			s.updated = now
+			return peers, nil
+		}
+	}
+	if s.updated.IsZero() {
+		return nil, err
+	}
+	log.Warnf("failed to resolve bootstrap peers from %s: %s", s.addr, err)
+	return s.peers, nil
+}
+
+// Discovery is a discovery.Discoverer that serves the bootstrap peers of its
+// sources, regardless of the namespace.
+type Discovery struct {
+	sources []Source
+}
+
+var _ discovery.Discoverer = (*Discovery)(nil)
+
+// NewDiscovery creates a Discovery that serves the peers of the sources.
+func NewDiscovery(sources ...Source) *Discovery {
+	return &Discovery{sources: sources}
+}
+
+// Peers returns the peers of all sources, merging the addresses of peers
+// found in several sources. It only fails if all sources fail.
+func (d *Discovery) Peers(ctx context.Context) ([]peer.AddrInfo, error) {
+	var res []peer.AddrInfo
+	idx := make(map[peer.ID]int)
+	var errs []error
+	for _, s := range d.sources {
+		peers, err := s.Peers(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		res = mergeAddrInfos(res, idx, peers)
+	}
+	if len(errs) > 0 {
+		if len(errs) == len(d.sources) {
+			return nil, errors.Join(errs...)
+		}
+		log.Warnf("failed to get bootstrap peers: %s", errors.Join(errs...))
+	}
+	return res, nil
+}
+
+// FindPeers returns the bootstrap peers. The namespace is ignored.
+func (d *Discovery) FindPeers(ctx context.Context, _ string, opts ...discovery.Option) (<-chan peer.AddrInfo, error) {
+	var options discovery.Options
+	if err := options.Apply(opts...); err != nil {
+		return nil, err
+	}
+	peers, err := d.Peers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if options.Limit > 0 && len(peers) > options.Limit {
+		peers = peers[:options.Limit]
+	}
+	ch := make(chan peer.AddrInfo, len(peers))
+	for _, ai := range peers {
+		ch <- ai
+	}
+	close(ch)
+	return ch, nil
+}
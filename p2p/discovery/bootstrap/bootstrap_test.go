@@ -0,0 +1,262 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/p2p/discovery/backoff"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	"github.com/stretchr/testify/require"
+)
+
+func randomPeers(t *testing.T, n int) []peer.AddrInfo {
+	t.Helper()
+	peers := make([]peer.AddrInfo, n)
+	for i := range peers {
+		id, err := test.RandPeerID()
+		require.NoError(t, err)
+		peers[i] = peer.AddrInfo{ID: id, Addrs: []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/4001")}}
+	}
+	return peers
+}
+
+func p2pAddr(t *testing.T, ai peer.AddrInfo) string {
+	t.Helper()
+	addrs, err := peer.AddrInfoToP2pAddrs(&ai)
+	require.NoError(t, err)
+	return addrs[0].String()
+}
+
+func TestParsePeers(t *testing.T) {
+	peers := randomPeers(t, 2)
+	a0, a1 := p2pAddr(t, peers[0]), p2pAddr(t, peers[1])
+
+	for name, in := range map[string]string{
+		"multiaddrs": `["` + a0 + `", "` + a1 + `"]`,
+		"addr infos": `[{"ID": "` + peers[0].ID.String() + `", "Addrs": ["/ip4/1.2.3.4/tcp/4001"]},
+			{"ID": "` + peers[1].ID.String() + `", "Addrs": ["/ip4/1.2.3.4/tcp/4001"]}]`,
+		"text": "# bootstrap peers\n" + a0 + "\n\n  " + a1 + "\n",
+		"toml": "# bootstrap peers\npeers = [\n  \"" + a0 + "\", # first\n  '" + a1 + "',\n]\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			res, err := ParsePeers([]byte(in))
+			require.NoError(t, err)
+			require.ElementsMatch(t, peers, res)
+		})
+	}
+
+	for name, in := range map[string]string{
+		"invalid JSON":      `["` + a0 + `"`,
+		"invalid multiaddr": "/ip4/1.2.3.4/tcp",
+		"missing peer ID":   "/ip4/1.2.3.4/tcp/4001",
+		"TOML other key":    `peers = ["` + a0 + `"]` + "\nother = 1",
+		"TOML table":        "peers = [\"" + a0 + "\"]\n[bootstrap]",
+		"TOML not a string": "peers = [1]",
+		"TOML unterminated": `peers = ["` + a0,
+		"TOML no separator": `peers = ["` + a0 + `" "` + a1 + `"]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParsePeers([]byte(in))
+			require.Error(t, err)
+		})
+	}
+
+	t.Run("duplicate addr infos", func(t *testing.T) {
+		res, err := ParsePeers([]byte(`[{"ID": "` + peers[0].ID.String() + `", "Addrs": ["/ip4/1.2.3.4/tcp/4001"]},
+			{"ID": "` + peers[0].ID.String() + `", "Addrs": ["/ip4/1.2.3.4/tcp/4001", "/ip4/1.2.3.4/udp/4001/quic-v1"]}]`))
+		require.NoError(t, err)
+		require.Equal(t, []peer.AddrInfo{{
+			ID:    peers[0].ID,
+			Addrs: []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/4001"), ma.StringCast("/ip4/1.2.3.4/udp/4001/quic-v1")},
+		}}, res)
+	})
+}
+
+func TestFileSource(t *testing.T) {
+	peers := randomPeers(t, 2)
+	path := filepath.Join(t.TempDir(), "peers")
+	s := NewFileSource(path, 10*time.Millisecond)
+	defer s.Close()
+
+	_, err := s.Peers(context.Background())
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	write := func(data string, mtime time.Time) {
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+		// don't depend on the resolution of the file system timestamps
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	hasPeers := func(peers []peer.AddrInfo) func() bool {
+		return func() bool {
+			res, err := s.Peers(context.Background())
+			if err != nil || len(res) != len(peers) {
+				return false
+			}
+			for _, ai := range peers {
+				if !slices.ContainsFunc(res, func(r peer.AddrInfo) bool { return r.ID == ai.ID }) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	now := time.Now()
+	write(p2pAddr(t, peers[0]), now)
+	require.Eventually(t, hasPeers(peers[:1]), time.Second, 10*time.Millisecond)
+
+	write(p2pAddr(t, peers[0])+"\n"+p2pAddr(t, peers[1]), now.Add(time.Second))
+	require.Eventually(t, hasPeers(peers), time.Second, 10*time.Millisecond)
+
+	// keep the last good list if the file becomes invalid or is removed
+	write("invalid", now.Add(2*time.Second))
+	require.Eventually(t, func() bool {
+		s.mx.Lock()
+		defer s.mx.Unlock()
+		return s.err != nil
+	}, time.Second, 10*time.Millisecond)
+	res, err := s.Peers(context.Background())
+	require.NoError(t, err)
+	require.ElementsMatch(t, peers, res)
+	require.NoError(t, os.Remove(path))
+	require.Eventually(t, func() bool {
+		s.mx.Lock()
+		defer s.mx.Unlock()
+		return errors.Is(s.err, os.ErrNotExist)
+	}, time.Second, 10*time.Millisecond)
+	res, err = s.Peers(context.Background())
+	require.NoError(t, err)
+	require.ElementsMatch(t, peers, res)
+}
+
+func TestDNSAddrSource(t *testing.T) {
+	peers := randomPeers(t, 2)
+	mockResolver := &madns.MockResolver{TXT: map[string][]string{
+		"_dnsaddr.bootstrap.example.com": {
+			"dnsaddr=" + p2pAddr(t, peers[0]),
+			"dnsaddr=/dnsaddr/other.example.com",
+		},
+		"_dnsaddr.other.example.com": {"dnsaddr=" + p2pAddr(t, peers[1])},
+	}}
+	resolver, err := madns.NewResolver(madns.WithDefaultResolver(mockResolver))
+	require.NoError(t, err)
+
+	_, err = NewDNSAddrSource(swarm.ResolverFromMaDNS{Resolver: resolver}, ma.StringCast("/dns/bootstrap.example.com"), 0)
+	require.Error(t, err)
+	src, err := NewDNSAddrSource(swarm.ResolverFromMaDNS{Resolver: resolver}, ma.StringCast("/dnsaddr/bootstrap.example.com"), time.Minute)
+	require.NoError(t, err)
+	s := src.(*dnsaddrSource)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	res, err := s.Peers(context.Background())
+	require.NoError(t, err)
+	require.ElementsMatch(t, peers, res)
+
+	// the result is cached until the refresh interval elapses
+	mockResolver.TXT["_dnsaddr.bootstrap.example.com"] = []string{"dnsaddr=" + p2pAddr(t, peers[0])}
+	res, err = s.Peers(context.Background())
+	require.NoError(t, err)
+	require.ElementsMatch(t, peers, res)
+
+	now = now.Add(time.Minute)
+	res, err = s.Peers(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, peers[:1], res)
+
+	// keep the last good list if the records become invalid
+	mockResolver.TXT["_dnsaddr.bootstrap.example.com"] = []string{"dnsaddr=/ip4/1.2.3.4/tcp/4001"}
+	now = now.Add(time.Minute)
+	res, err = s.Peers(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, peers[:1], res)
+}
+
+type errSource struct{}
+
+func (errSource) Peers(context.Context) ([]peer.AddrInfo, error) {
+	return nil, errors.New("failed")
+}
+
+func TestDiscovery(t *testing.T) {
+	peers := randomPeers(t, 3)
+	other := peer.AddrInfo{ID: peers[0].ID, Addrs: []ma.Multiaddr{ma.StringCast("/ip4/5.6.7.8/tcp/4001")}}
+	d := NewDiscovery(NewStaticSource(peers[:2]...), errSource{}, NewStaticSource(other, peers[2]))
+
+	ch, err := d.FindPeers(context.Background(), "ignored")
+	require.NoError(t, err)
+	var res []peer.AddrInfo
+	for ai := range ch {
+		res = append(res, ai)
+	}
+	require.Len(t, res, 3)
+	require.Equal(t, peers[0].ID, res[0].ID)
+	require.ElementsMatch(t, append(peers[0].Addrs, other.Addrs...), res[0].Addrs)
+	require.Equal(t, peers[1:], res[1:])
+	// the sources aren't modified
+	require.Len(t, peers[0].Addrs, 1)
+
+	ch, err = d.FindPeers(context.Background(), "", discovery.Limit(2))
+	require.NoError(t, err)
+	require.Len(t, ch, 2)
+
+	_, err = NewDiscovery(errSource{}).FindPeers(context.Background(), "")
+	require.Error(t, err)
+}
+
+func TestConnector(t *testing.T) {
+	mn := mocknet.New()
+	defer mn.Close()
+
+	h, err := mn.GenPeer()
+	require.NoError(t, err)
+	var peers []peer.AddrInfo
+	for range 5 {
+		bh, err := mn.GenPeer()
+		require.NoError(t, err)
+		peers = append(peers, peer.AddrInfo{ID: bh.ID(), Addrs: bh.Addrs()})
+	}
+	require.NoError(t, mn.LinkAll())
+
+	c, err := NewConnector(h, NewDiscovery(NewStaticSource(peers...)),
+		WithMinPeers(2),
+		WithInterval(100*time.Millisecond),
+		WithBackoff(backoff.NewFixedBackoff(time.Millisecond)),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.Eventually(t, func() bool { return c.Connected() >= 2 }, 5*time.Second, 10*time.Millisecond)
+	// we only connect to the missing peers
+	time.Sleep(300 * time.Millisecond)
+	require.Len(t, h.Network().Peers(), 2)
+
+	// reconnect when the bootstrap peers disconnect
+	for _, ai := range peers {
+		require.NoError(t, h.Network().ClosePeer(ai.ID))
+	}
+	require.Eventually(t, func() bool { return c.Connected() >= 2 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestConnectorOptions(t *testing.T) {
+	mn := mocknet.New()
+	defer mn.Close()
+	h, err := mn.GenPeer()
+	require.NoError(t, err)
+
+	for _, opt := range []ConnectorOption{WithMinPeers(0), WithInterval(0), WithBackoff(nil)} {
+		_, err := NewConnector(h, NewDiscovery(), opt)
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,227 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/discovery/backoff"
+)
+
+const (
+	// DefaultMinPeers is the default minimum number of bootstrap peers the
+	// Connector stays connected to.
+	DefaultMinPeers = 4
+	// DefaultInterval is the default interval at which the Connector checks
+	// the number of connected bootstrap peers.
+	DefaultInterval = 30 * time.Second
+
+	connectTimeout  = 10 * time.Second
+	connectorCache  = 256
+	findPeerTimeout = time.Minute
+)
+
+// ConnectorOption is an option for NewConnector.
+type ConnectorOption func(*Connector) error
+
+// WithMinPeers sets the minimum number of bootstrap peers to stay connected
+// to. It defaults to DefaultMinPeers.
+func WithMinPeers(n int) ConnectorOption {
+	return func(c *Connector) error {
+		if n <= 0 {
+			return errors.New("minimum number of peers must be positive")
+		}
+		c.minPeers = n
+		return nil
+	}
+}
+
+// WithInterval sets the interval at which the number of connected bootstrap
+// peers is checked. It defaults to DefaultInterval. The peers are also checked
+// whenever a bootstrap peer disconnects.
+func WithInterval(d time.Duration) ConnectorOption {
+	return func(c *Connector) error {
+		if d <= 0 {
+			return errors.New("interval must be positive")
+		}
+		c.interval = d
+		return nil
+	}
+}
+
+// WithBackoff sets the backoff strategy used between connection attempts to
+// the same peer. It defaults to an exponential backoff from 1s to 5min.
+func WithBackoff(b backoff.BackoffFactory) ConnectorOption {
+	return func(c *Connector) error {
+		if b == nil {
+			return errors.New("nil backoff factory")
+		}
+		c.backoff = b
+		return nil
+	}
+}
+
+// Connector maintains connections to a minimum number of the bootstrap peers
+// returned by a discovery.Discoverer, usually a Discovery. It only connects
+// to more peers when fewer than the minimum are connected, and backs off from
+// peers it failed to connect to.
+type Connector struct {
+	host     host.Host
+	disc     discovery.Discoverer
+	minPeers int
+	interval time.Duration
+	backoff  backoff.BackoffFactory
+
+	connector *backoff.BackoffConnector
+	sub       event.Subscription
+
+	mx    sync.Mutex
+	peers map[peer.ID]struct{}
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
+}
+
+// NewConnector creates a Connector and starts connecting to the bootstrap
+// peers found by d. Close stops it.
+func NewConnector(h host.Host, d discovery.Discoverer, opts ...ConnectorOption) (*Connector, error) {
+	c := &Connector{
+		host:     h,
+		disc:     d,
+		minPeers: DefaultMinPeers,
+		interval: DefaultInterval,
+		backoff: backoff.NewExponentialBackoff(time.Second, 5*time.Minute, backoff.FullJitter,
+			time.Second, 2, 0, rand.NewSource(time.Now().UnixNano())),
+		peers: make(map[peer.ID]struct{}),
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	c.connector, err = backoff.NewBackoffConnector(h, connectorCache, connectTimeout, c.backoff)
+	if err != nil {
+		return nil, err
+	}
+	c.sub, err = h.EventBus().Subscribe(new(event.EvtPeerConnectednessChanged))
+	if err != nil {
+		c.connector.Close()
+		return nil, err
+	}
+
+	c.ctx, c.ctxCancel = context.WithCancel(context.Background())
+	c.refCount.Add(1)
+	go c.background()
+	return c, nil
+}
+
+// Close stops the Connector and waits for its pending connection attempts.
+// It doesn't close the existing connections.
+func (c *Connector) Close() error {
+	c.ctxCancel()
+	c.refCount.Wait()
+	c.connector.Close()
+	return c.sub.Close()
+}
+
+// Connected returns the number of connected bootstrap peers.
+func (c *Connector) Connected() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.connectedLocked()
+}
+
+func (c *Connector) connectedLocked() int {
+	var n int
+	for p := range c.peers {
+		if c.host.Network().Connectedness(p) == network.Connected {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *Connector) background() {
+	defer c.refCount.Done()
+
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+
+	c.connect()
+	for {
+		select {
+		case <-t.C:
+		case e, ok := <-c.sub.Out():
+			if !ok {
+				return
+			}
+			evt := e.(event.EvtPeerConnectednessChanged)
+			if evt.Connectedness == network.Connected || !c.isBootstrapPeer(evt.Peer) {
+				continue
+			}
+		case <-c.ctx.Done():
+			return
+		}
+		c.connect()
+	}
+}
+
+func (c *Connector) isBootstrapPeer(p peer.ID) bool {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	_, ok := c.peers[p]
+	return ok
+}
+
+// connect finds the bootstrap peers and, if fewer than the minimum are
+// connected, tries to connect to as many random peers as are missing.
+func (c *Connector) connect() {
+	ctx, cancel := context.WithTimeout(c.ctx, findPeerTimeout)
+	defer cancel()
+	ch, err := c.disc.FindPeers(ctx, "")
+	if err != nil {
+		log.Warnf("failed to find bootstrap peers: %s", err)
+		return
+	}
+	var candidates []peer.AddrInfo
+	peers := make(map[peer.ID]struct{})
+	for ai := range ch {
+		if ai.ID == c.host.ID() {
+			continue
+		}
+		peers[ai.ID] = struct{}{}
+		if c.host.Network().Connectedness(ai.ID) != network.Connected {
+			candidates = append(candidates, ai)
+		}
+	}
+
+	c.mx.Lock()
+	c.peers = peers
+	missing := c.minPeers - c.connectedLocked()
+	c.mx.Unlock()
+	if missing <= 0 || len(candidates) == 0 {
+		return
+	}
+
+	log.Debugf("connected to %d bootstrap peers, connecting to more", c.minPeers-missing)
+	rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	// The BackoffConnector skips the peers that are backing off, so we may
+	// connect to fewer peers than are missing. The next check tries others.
+	candidates = candidates[:min(missing, len(candidates))]
+	out := make(chan peer.AddrInfo, len(candidates))
+	for _, ai := range candidates {
+		out <- ai
+	}
+	close(out)
+	c.connector.Connect(c.ctx, out)
+}
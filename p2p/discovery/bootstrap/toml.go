@@ -0,0 +1,140 @@
+package bootstrap
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// isTOML reports whether b is a TOML peer list, i.e. whether its first line
+// that isn't empty or a comment assigns the peers key.
+func isTOML(b []byte) bool {
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rest, ok := strings.CutPrefix(line, "peers")
+		return ok && strings.HasPrefix(strings.TrimLeft(rest, " \t"), "=")
+	}
+	return false
+}
+
+// parseTOMLPeers parses the subset of TOML accepted by ParsePeers: a document
+// whose only key is a top-level peers array of strings. Comments, basic and
+// literal strings, arrays spanning several lines and trailing commas are
+// supported. Tables, other keys, other types and multi-line strings are
+// rejected.
+func parseTOMLPeers(s string) ([]string, error) {
+	p := &tomlParser{s: s}
+	p.skipSpace(true)
+	if !p.consume("peers") {
+		return nil, p.errorf("expected the peers key")
+	}
+	p.skipSpace(false)
+	if !p.consume("=") {
+		return nil, p.errorf(`expected "="`)
+	}
+	p.skipSpace(false)
+	if !p.consume("[") {
+		return nil, p.errorf("expected an array")
+	}
+	var res []string
+	for {
+		p.skipSpace(true)
+		if p.consume("]") {
+			break
+		}
+		str, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, str)
+		p.skipSpace(true)
+		if p.consume("]") {
+			break
+		}
+		if !p.consume(",") {
+			return nil, p.errorf(`expected "," or "]"`)
+		}
+	}
+	p.skipSpace(true)
+	if p.i < len(p.s) {
+		return nil, p.errorf("only the peers key is supported")
+	}
+	return res, nil
+}
+
+type tomlParser struct {
+	s string
+	i int
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	line := strings.Count(p.s[:p.i], "\n") + 1
+	return fmt.Errorf("invalid TOML peer list at line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) consume(prefix string) bool {
+	if !strings.HasPrefix(p.s[p.i:], prefix) {
+		return false
+	}
+	p.i += len(prefix)
+	return true
+}
+
+// skipSpace skips whitespace and comments, and newlines if newlines is set.
+func (p *tomlParser) skipSpace(newlines bool) {
+	for p.i < len(p.s) {
+		switch c := p.s[p.i]; {
+		case c == ' ' || c == '\t':
+			p.i++
+		case newlines && (c == '\n' || c == '\r'):
+			p.i++
+		case c == '#':
+			if end := strings.IndexByte(p.s[p.i:], '\n'); end >= 0 {
+				p.i += end
+			} else {
+				p.i = len(p.s)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// string parses a basic or a literal string.
+func (p *tomlParser) string() (string, error) {
+	rest := p.s[p.i:]
+	if strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, "'''") {
+		return "", p.errorf("multi-line strings are not supported")
+	}
+	if rest == "" || (rest[0] != '"' && rest[0] != '\'') {
+		return "", p.errorf("expected a string")
+	}
+	quote := rest[0]
+	for j := 1; j < len(rest); j++ {
+		switch rest[j] {
+		case '\n':
+			return "", p.errorf("unterminated string")
+		case '\\':
+			if quote == '"' {
+				j++
+			}
+		case quote:
+			p.i += j + 1
+			if quote == '\'' {
+				return rest[1:j], nil
+			}
+			str, err := strconv.Unquote(rest[:j+1])
+			if err != nil {
+				return "", p.errorf("invalid string %s", rest[:j+1])
+			}
+			return str, nil
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
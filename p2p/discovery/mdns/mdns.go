@@ -3,13 +3,17 @@ package mdns
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"math/rand"
+	"slices"
 	"strings"
 	"sync"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 
 	"github.com/libp2p/zeroconf/v2"
 
@@ -22,6 +26,9 @@ const (
 	ServiceName   = "_p2p._udp"
 	mdnsDomain    = "local"
 	dnsaddrPrefix = "dnsaddr="
+
+	dnsaddrKey   = "dnsaddr"
+	namespaceKey = "ns"
 )
 
 var log = logging.Logger("mdns")
@@ -35,49 +42,132 @@ type Notifee interface {
 	HandlePeerFound(peer.AddrInfo)
 }
 
+// MetadataNotifee can be implemented by a Notifee to be passed the namespaces
+// and metadata announced by the peers. HandlePeerFoundWithMetadata is then
+// called instead of HandlePeerFound.
+type MetadataNotifee interface {
+	HandlePeerFoundWithMetadata(PeerInfo)
+}
+
+// PeerInfo is a peer found via mDNS.
+type PeerInfo struct {
+	peer.AddrInfo
+	// Namespaces are the namespaces announced by the peer.
+	Namespaces []string
+	// Metadata are the key/value pairs announced by the peer.
+	Metadata map[string]string
+}
+
+// Option is an option for the mDNS service.
+type Option func(*mdnsService)
+
+// WithNamespaces announces the namespaces, e.g. application names, in the TXT
+// records. The notifee is then only notified of peers that announce at least
+// one of the namespaces, so that unrelated applications using the same service
+// name are ignored.
+func WithNamespaces(namespaces ...string) Option {
+	return func(s *mdnsService) {
+		s.namespaces = append(s.namespaces, namespaces...)
+	}
+}
+
+// WithMetadata announces the key/value pairs, e.g. the supported protocols, in
+// the TXT records. Keys must not be empty, contain '=' or be one of the keys
+// used by the service, "dnsaddr" and "ns".
+func WithMetadata(md map[string]string) Option {
+	return func(s *mdnsService) {
+		if s.metadata == nil {
+			s.metadata = make(map[string]string, len(md))
+		}
+		for k, v := range md {
+			s.metadata[k] = v
+		}
+	}
+}
+
+// WithPeerFilter sets a function that decides whether the notifee is notified
+// of a peer. It is called after the namespace filtering.
+func WithPeerFilter(f func(PeerInfo) bool) Option {
+	return func(s *mdnsService) {
+		s.filter = f
+	}
+}
+
 type mdnsService struct {
 	host        host.Host
 	serviceName string
 	peerName    string
+	namespaces  []string
+	metadata    map[string]string
+	filter      func(PeerInfo) bool
 
 	// The context is canceled when Close() is called.
 	ctx       context.Context
 	ctxCancel context.CancelFunc
 
-	resolverWG sync.WaitGroup
-	server     *zeroconf.Server
+	refCount sync.WaitGroup
+
+	serverMx sync.Mutex
+	server   *zeroconf.Server
+	// txts and ips are the records announced by the server
+	txts []string
+	ips  []string
 
 	notifee Notifee
 }
 
-func NewMdnsService(host host.Host, serviceName string, notifee Notifee) *mdnsService {
+func NewMdnsService(host host.Host, serviceName string, notifee Notifee, opts ...Option) *mdnsService {
 	if serviceName == "" {
 		serviceName = ServiceName
 	}
 	s := &mdnsService{
 		host:        host,
 		serviceName: serviceName,
-		peerName:    randomString(32 + rand.Intn(32)), // generate a random string between 32 and 63 characters long
+		peerName:    randomPeerName(),
 		notifee:     notifee,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
 	return s
 }
 
 func (s *mdnsService) Start() error {
+	for k := range s.metadata {
+		if k == "" || strings.Contains(k, "=") || k == dnsaddrKey || k == namespaceKey {
+			return fmt.Errorf("invalid metadata key %q", k)
+		}
+	}
+	for _, ns := range s.namespaces {
+		if ns == "" {
+			return errors.New("empty namespace")
+		}
+	}
+	// subscribe before starting the server, so we don't miss address changes
+	sub, err := s.host.EventBus().Subscribe(new(event.EvtLocalAddressesUpdated), eventbus.Name("mdns"))
+	if err != nil {
+		return err
+	}
 	if err := s.startServer(); err != nil {
+		sub.Close()
 		return err
 	}
 	s.startResolver(s.ctx)
+	s.refCount.Add(1)
+	go s.reannounce(sub)
 	return nil
 }
 
 func (s *mdnsService) Close() error {
 	s.ctxCancel()
+	s.refCount.Wait()
+	s.serverMx.Lock()
+	defer s.serverMx.Unlock()
 	if s.server != nil {
 		s.server.Shutdown()
+		s.server = nil
 	}
-	s.resolverWG.Wait()
 	return nil
 }
 
@@ -109,30 +199,56 @@ func (s *mdnsService) getIPs(addrs []ma.Multiaddr) ([]string, error) {
 	return ips, nil
 }
 
-func (s *mdnsService) startServer() error {
+// records returns the TXT records and the IP addresses to announce.
+func (s *mdnsService) records() (txts []string, ips []string, err error) {
 	interfaceAddrs, err := s.host.Network().InterfaceListenAddresses()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	addrs, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{
 		ID:    s.host.ID(),
 		Addrs: interfaceAddrs,
 	})
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	var txts []string
 	for _, addr := range addrs {
 		if manet.IsThinWaist(addr) { // don't announce circuit addresses
 			txts = append(txts, dnsaddrPrefix+addr.String())
 		}
 	}
+	for _, ns := range s.namespaces {
+		txts = append(txts, namespaceKey+"="+ns)
+	}
+	keys := make([]string, 0, len(s.metadata))
+	for k := range s.metadata {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		txts = append(txts, k+"="+s.metadata[k])
+	}
 
-	ips, err := s.getIPs(addrs)
+	ips, err = s.getIPs(addrs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return txts, ips, nil
+}
+
+func (s *mdnsService) startServer() error {
+	txts, ips, err := s.records()
 	if err != nil {
 		return err
 	}
+	s.serverMx.Lock()
+	defer s.serverMx.Unlock()
+	return s.registerLocked(txts, ips)
+}
 
+// registerLocked registers the service with the given records. It must be
+// called with the serverMx held.
+func (s *mdnsService) registerLocked(txts, ips []string) error {
 	server, err := zeroconf.RegisterProxy(
 		s.peerName,
 		s.serviceName,
@@ -147,51 +263,136 @@ func (s *mdnsService) startServer() error {
 		return err
 	}
 	s.server = server
+	s.txts = txts
+	s.ips = ips
 	return nil
 }
 
-func (s *mdnsService) startResolver(ctx context.Context) {
-	s.resolverWG.Add(2)
-	entryChan := make(chan *zeroconf.ServiceEntry, 1000)
-	go func() {
-		defer s.resolverWG.Done()
-		for entry := range entryChan {
-			// We only care about the TXT records.
-			// Ignore A, AAAA and PTR.
-			addrs := make([]ma.Multiaddr, 0, len(entry.Text)) // assume that all TXT records are dnsaddrs
-			for _, s := range entry.Text {
-				if !strings.HasPrefix(s, dnsaddrPrefix) {
-					log.Debug("missing dnsaddr prefix")
-					continue
-				}
-				addr, err := ma.NewMultiaddr(s[len(dnsaddrPrefix):])
-				if err != nil {
-					log.Debugf("failed to parse multiaddr: %s", err)
-					continue
-				}
-				addrs = append(addrs, addr)
+// reannounce re-registers the service when our addresses change, so that
+// peers learn about the new addresses.
+func (s *mdnsService) reannounce(sub event.Subscription) {
+	defer s.refCount.Done()
+	defer sub.Close()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case _, ok := <-sub.Out():
+			if !ok {
+				return
+			}
+		}
+		txts, ips, err := s.records()
+		if err != nil {
+			log.Debugf("failed to get mdns records: %s", err)
+			continue
+		}
+		s.serverMx.Lock()
+		if !slices.Equal(txts, s.txts) || !slices.Equal(ips, s.ips) {
+			log.Debug("addresses changed, re-announcing")
+			if s.server != nil {
+				s.server.Shutdown()
+				s.server = nil
 			}
-			infos, err := peer.AddrInfosFromP2pAddrs(addrs...)
+			// Browsers only report an instance once, until they receive its
+			// goodbye. Use a new instance name, so that the new records are
+			// reported even if the goodbye got lost.
+			s.peerName = randomPeerName()
+			if err := s.registerLocked(txts, ips); err != nil {
+				log.Errorf("failed to re-announce mdns service: %s", err)
+			}
+		}
+		s.serverMx.Unlock()
+	}
+}
+
+// parseTXTs parses the TXT records of a peer. It returns the announced
+// addresses, namespaces and metadata.
+func parseTXTs(txts []string) (addrs []ma.Multiaddr, namespaces []string, md map[string]string) {
+	addrs = make([]ma.Multiaddr, 0, len(txts)) // assume that most TXT records are dnsaddrs
+	for _, txt := range txts {
+		k, v, ok := strings.Cut(txt, "=")
+		if !ok || k == "" {
+			log.Debugf("ignoring invalid TXT record %q", txt)
+			continue
+		}
+		switch k {
+		case dnsaddrKey:
+			addr, err := ma.NewMultiaddr(v)
 			if err != nil {
-				log.Debugf("failed to get peer info: %s", err)
+				log.Debugf("failed to parse multiaddr: %s", err)
 				continue
 			}
-			for _, info := range infos {
-				if info.ID == s.host.ID() {
-					continue
-				}
-				go s.notifee.HandlePeerFound(info)
+			addrs = append(addrs, addr)
+		case namespaceKey:
+			namespaces = append(namespaces, v)
+		default:
+			if md == nil {
+				md = make(map[string]string)
 			}
+			md[k] = v
+		}
+	}
+	return addrs, namespaces, md
+}
+
+// accept returns whether the notifee should be notified of the peer.
+func (s *mdnsService) accept(pi PeerInfo) bool {
+	if pi.ID == s.host.ID() {
+		return false
+	}
+	if len(s.namespaces) > 0 && !slices.ContainsFunc(pi.Namespaces, func(ns string) bool {
+		return slices.Contains(s.namespaces, ns)
+	}) {
+		return false
+	}
+	return s.filter == nil || s.filter(pi)
+}
+
+func (s *mdnsService) handleEntry(entry *zeroconf.ServiceEntry) {
+	// We only care about the TXT records.
+	// Ignore A, AAAA and PTR.
+	addrs, namespaces, md := parseTXTs(entry.Text)
+	infos, err := peer.AddrInfosFromP2pAddrs(addrs...)
+	if err != nil {
+		log.Debugf("failed to get peer info: %s", err)
+		return
+	}
+	for _, info := range infos {
+		pi := PeerInfo{AddrInfo: info, Namespaces: namespaces, Metadata: md}
+		if !s.accept(pi) {
+			continue
+		}
+		if mn, ok := s.notifee.(MetadataNotifee); ok {
+			go mn.HandlePeerFoundWithMetadata(pi)
+		} else {
+			go s.notifee.HandlePeerFound(info)
+		}
+	}
+}
+
+func (s *mdnsService) startResolver(ctx context.Context) {
+	s.refCount.Add(2)
+	entryChan := make(chan *zeroconf.ServiceEntry, 1000)
+	go func() {
+		defer s.refCount.Done()
+		for entry := range entryChan {
+			s.handleEntry(entry)
 		}
 	}()
 	go func() {
-		defer s.resolverWG.Done()
+		defer s.refCount.Done()
 		if err := zeroconf.Browse(ctx, s.serviceName, mdnsDomain, entryChan); err != nil {
 			log.Debugf("zeroconf browsing failed: %s", err)
 		}
 	}()
 }
 
+// randomPeerName generates a random string between 32 and 63 characters long.
+func randomPeerName() string {
+	return randomString(32 + rand.Intn(32))
+}
+
 func randomString(l int) string {
 	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
 	s := make([]byte, 0, l)
@@ -1,28 +1,37 @@
 package mdns
 
 import (
+	"slices"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
 
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func setupMDNS(t *testing.T, notifee Notifee) peer.ID {
+func setupMDNS(t *testing.T, notifee Notifee, opts ...Option) peer.ID {
 	t.Helper()
-	host, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	return setupMDNSHost(t, notifee, opts...).ID()
+}
+
+func setupMDNSHost(t *testing.T, notifee Notifee, opts ...Option) host.Host {
+	t.Helper()
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
 	require.NoError(t, err)
-	s := NewMdnsService(host, "", notifee)
+	s := NewMdnsService(h, "", notifee, opts...)
 	require.NoError(t, s.Start())
 	t.Cleanup(func() {
-		host.Close()
+		h.Close()
 		s.Close()
 	})
-	return host.ID()
+	return h
 }
 
 type notif struct {
@@ -38,6 +47,25 @@ func (n *notif) HandlePeerFound(info peer.AddrInfo) {
 	n.mutex.Unlock()
 }
 
+type metadataNotif struct {
+	notif
+	peers []PeerInfo
+}
+
+var _ MetadataNotifee = &metadataNotif{}
+
+func (n *metadataNotif) HandlePeerFoundWithMetadata(pi PeerInfo) {
+	n.mutex.Lock()
+	n.peers = append(n.peers, pi)
+	n.mutex.Unlock()
+}
+
+func (n *metadataNotif) GetPeerInfos() []PeerInfo {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return slices.Clone(n.peers)
+}
+
 func (n *notif) GetPeers() []peer.AddrInfo {
 	n.mutex.Lock()
 	defer n.mutex.Unlock()
@@ -96,3 +124,101 @@ func TestOtherDiscovery(t *testing.T) {
 		"expected peers to find each other",
 	)
 }
+
+func TestParseTXTs(t *testing.T) {
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/4001/p2p/QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupNKC")
+	addrs, namespaces, md := parseTXTs([]string{
+		"dnsaddr=" + addr.String(),
+		"dnsaddr=invalid",
+		"ns=foo",
+		"ns=bar",
+		"protocols=/chat/1.0.0,/files/1.0.0",
+		"empty=",
+		"novalue",
+		"=nokey",
+	})
+	require.Len(t, addrs, 1)
+	require.True(t, addr.Equal(addrs[0]))
+	require.Equal(t, []string{"foo", "bar"}, namespaces)
+	require.Equal(t, map[string]string{"protocols": "/chat/1.0.0,/files/1.0.0", "empty": ""}, md)
+}
+
+func TestInvalidOptions(t *testing.T) {
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h.Close()
+	for _, opt := range []Option{
+		WithMetadata(map[string]string{"dnsaddr": "foo"}),
+		WithMetadata(map[string]string{"ns": "foo"}),
+		WithMetadata(map[string]string{"a=b": "foo"}),
+		WithNamespaces(""),
+	} {
+		s := NewMdnsService(h, "", &notif{}, opt)
+		require.Error(t, s.Start())
+		require.NoError(t, s.Close())
+	}
+}
+
+func TestNamespacesAndMetadata(t *testing.T) {
+	n := &metadataNotif{}
+	setupMDNS(t, n, WithNamespaces("foo"))
+	bar := setupMDNS(t, &notif{}, WithNamespaces("bar", "foo"), WithMetadata(map[string]string{"protocols": "/chat/1.0.0"}))
+	filtered := setupMDNS(t, &notif{}, WithNamespaces("foo"), WithMetadata(map[string]string{"role": "ignored"}))
+	other := setupMDNS(t, &notif{}, WithNamespaces("other"))
+	legacy := setupMDNS(t, &notif{})
+
+	// a peer that filters all peers
+	filteringNotif := &notif{}
+	setupMDNS(t, filteringNotif, WithPeerFilter(func(PeerInfo) bool { return false }))
+
+	// a peer that filters on the metadata
+	n2 := &metadataNotif{}
+	setupMDNS(t, n2, WithNamespaces("foo"), WithPeerFilter(func(pi PeerInfo) bool { return pi.Metadata["role"] != "ignored" }))
+
+	require.Eventually(t, func() bool {
+		return slices.ContainsFunc(n.GetPeerInfos(), func(pi PeerInfo) bool { return pi.ID == bar }) &&
+			slices.ContainsFunc(n.GetPeerInfos(), func(pi PeerInfo) bool { return pi.ID == filtered }) &&
+			slices.ContainsFunc(n2.GetPeerInfos(), func(pi PeerInfo) bool { return pi.ID == bar })
+	}, 25*time.Second, 5*time.Millisecond)
+
+	for _, pi := range n.GetPeerInfos() {
+		require.NotEqual(t, other, pi.ID)
+		require.NotEqual(t, legacy, pi.ID)
+		if pi.ID == bar {
+			require.ElementsMatch(t, []string{"bar", "foo"}, pi.Namespaces)
+			require.Equal(t, map[string]string{"protocols": "/chat/1.0.0"}, pi.Metadata)
+		}
+	}
+	for _, pi := range n2.GetPeerInfos() {
+		require.NotEqual(t, filtered, pi.ID)
+	}
+	require.Empty(t, n.GetPeers(), "HandlePeerFound shouldn't be called for a MetadataNotifee")
+	require.Empty(t, filteringNotif.GetPeers())
+}
+
+func TestReannounceOnAddressChange(t *testing.T) {
+	n := &notif{}
+	setupMDNS(t, n, WithNamespaces("reannounce"))
+	h := setupMDNSHost(t, &notif{}, WithNamespaces("reannounce"))
+
+	oldAddr := h.Addrs()[0]
+	hasAddr := func(addr ma.Multiaddr) bool {
+		for _, info := range n.GetPeers() {
+			if info.ID == h.ID() && slices.ContainsFunc(info.Addrs, addr.Equal) {
+				return true
+			}
+		}
+		return false
+	}
+	require.Eventually(t, func() bool { return hasAddr(oldAddr) }, 25*time.Second, 5*time.Millisecond)
+
+	require.NoError(t, h.Network().Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0")))
+	var newAddr ma.Multiaddr
+	for _, addr := range h.Network().ListenAddresses() {
+		if manet.IsThinWaist(addr) && !addr.Equal(oldAddr) {
+			newAddr = addr
+		}
+	}
+	require.NotNil(t, newAddr)
+	require.Eventually(t, func() bool { return hasAddr(newAddr) }, 25*time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,294 @@
+package rendezvous
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/p2p/discovery/rendezvous/pb"
+	"github.com/libp2p/go-msgio/pbio"
+)
+
+var errNoSignedPeerRecord = errors.New("can't create a signed peer record without the host's private key")
+
+// responseTypes are the types of the responses to the requests. There is no
+// response to unregister requests.
+var responseTypes = map[pb.Message_MessageType]pb.Message_MessageType{
+	pb.Message_REGISTER: pb.Message_REGISTER_RESPONSE,
+	pb.Message_DISCOVER: pb.Message_DISCOVER_RESPONSE,
+}
+
+// Discovery is a discovery.Discovery that registers with and discovers peers
+// from a rendezvous point. The host must be able to connect to the rendezvous
+// point, i.e. the addresses of the rendezvous point must be in the peerstore.
+//
+// Discovery caches the peers discovered in each namespace, and uses cookies
+// so that the rendezvous point only returns the registrations that are new
+// since the last FindPeers call for the namespace. Since cookies don't tell
+// which peers unregistered, Discovery drops its cache and queries the
+// namespace without a cookie every CacheRefreshInterval. Until then, peers
+// that unregistered are returned until their registration would have
+// expired.
+type Discovery struct {
+	host host.Host
+	rp   peer.ID
+
+	mx     sync.Mutex // guards caches, but not their contents
+	caches map[string]*discoveryCache
+}
+
+var _ discovery.Discovery = (*Discovery)(nil)
+
+type discoveryCache struct {
+	// mx is not held during the requests to the rendezvous point
+	mx        sync.Mutex
+	cookie    []byte
+	refreshed time.Time // when the namespace was last queried without a cookie
+	peers     map[peer.ID]cachedPeer
+	// sent and applied are the sequence numbers of the last request sent and
+	// of the last response applied, so that the response to an older request
+	// doesn't replace the cookie of a newer one
+	sent, applied uint64
+}
+
+type cachedPeer struct {
+	ai     peer.AddrInfo
+	expire time.Time
+}
+
+// NewDiscovery creates a Discovery that uses the rendezvous point rp.
+func NewDiscovery(h host.Host, rp peer.ID) *Discovery {
+	return &Discovery{
+		host:   h,
+		rp:     rp,
+		caches: make(map[string]*discoveryCache),
+	}
+}
+
+// Advertise registers the host in the namespace ns. It returns the TTL of the
+// registration granted by the rendezvous point.
+func (d *Discovery) Advertise(ctx context.Context, ns string, opts ...discovery.Option) (time.Duration, error) {
+	var options discovery.Options
+	if err := options.Apply(opts...); err != nil {
+		return 0, err
+	}
+
+	rec, err := d.signedPeerRecord()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := d.request(ctx, &pb.Message{
+		Type: pb.Message_REGISTER,
+		Register: &pb.Message_Register{
+			Ns:               ns,
+			SignedPeerRecord: rec,
+			Ttl:              uint64(options.Ttl / time.Second),
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	rr := res.GetRegisterResponse()
+	if rr.GetStatus() != pb.Message_OK {
+		return 0, Error{Status: rr.GetStatus(), Text: rr.GetStatusText()}
+	}
+	return time.Duration(rr.GetTtl()) * time.Second, nil
+}
+
+// signedPeerRecord returns the signed peer record of the host. If the host
+// doesn't maintain one, e.g. because signed peer records are disabled, it
+// creates one from the host addresses.
+func (d *Discovery) signedPeerRecord() ([]byte, error) {
+	if cab, ok := peerstore.GetCertifiedAddrBook(d.host.Peerstore()); ok {
+		if env := cab.GetPeerRecord(d.host.ID()); env != nil {
+			return env.Marshal()
+		}
+	}
+	key := d.host.Peerstore().PrivKey(d.host.ID())
+	if key == nil {
+		return nil, errNoSignedPeerRecord
+	}
+	env, err := record.Seal(peer.PeerRecordFromAddrInfo(peer.AddrInfo{ID: d.host.ID(), Addrs: d.host.Addrs()}), key)
+	if err != nil {
+		return nil, err
+	}
+	return env.Marshal()
+}
+
+// Unregister removes the registration of the host in the namespace ns.
+func (d *Discovery) Unregister(ctx context.Context, ns string) error {
+	_, err := d.request(ctx, &pb.Message{
+		Type:       pb.Message_UNREGISTER,
+		Unregister: &pb.Message_Unregister{Ns: ns},
+	})
+	return err
+}
+
+// FindPeers discovers the peers registered in the namespace ns. An empty
+// namespace discovers the peers registered in any namespace.
+func (d *Discovery) FindPeers(ctx context.Context, ns string, opts ...discovery.Option) (<-chan peer.AddrInfo, error) {
+	var options discovery.Options
+	if err := options.Apply(opts...); err != nil {
+		return nil, err
+	}
+
+	cache := d.cache(ns)
+	cache.mx.Lock()
+	now := time.Now()
+	cookie := cache.cookie
+	if now.Sub(cache.refreshed) >= CacheRefreshInterval {
+		cookie = nil
+	}
+	cache.sent++
+	seq := cache.sent
+	cache.mx.Unlock()
+
+	res, err := d.discover(ctx, ns, options.Limit, cookie)
+	var rerr Error
+	if cookie != nil && errors.As(err, &rerr) && rerr.Status == pb.Message_E_INVALID_COOKIE {
+		// the rendezvous point may have lost its state, start over
+		cookie = nil
+		res, err = d.discover(ctx, ns, options.Limit, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mx.Lock()
+	defer cache.mx.Unlock()
+	if seq > cache.applied {
+		cache.applied = seq
+		if cookie == nil {
+			clear(cache.peers)
+			cache.refreshed = now
+		}
+		cache.cookie = res.GetCookie()
+	}
+
+	cab, _ := peerstore.GetCertifiedAddrBook(d.host.Peerstore())
+	for _, reg := range res.GetRegistrations() {
+		env, rec, err := record.ConsumeEnvelope(reg.GetSignedPeerRecord(), peer.PeerRecordEnvelopeDomain)
+		if err != nil {
+			log.Debugf("ignoring registration with invalid signed peer record: %s", err)
+			continue
+		}
+		pr, ok := rec.(*peer.PeerRecord)
+		if !ok {
+			continue
+		}
+		if pr.PeerID == d.host.ID() {
+			continue
+		}
+		ttl := time.Duration(reg.GetTtl()) * time.Second
+		if cab != nil {
+			if _, err := cab.ConsumePeerRecord(env, ttl); err != nil {
+				log.Debugf("failed to store signed peer record of %s: %s", pr.PeerID, err)
+			}
+		}
+		// with an empty namespace, a peer may be registered in several namespaces
+		if cp, ok := cache.peers[pr.PeerID]; ok && cp.expire.After(now.Add(ttl)) {
+			continue
+		}
+		cache.peers[pr.PeerID] = cachedPeer{
+			ai:     peer.AddrInfo{ID: pr.PeerID, Addrs: pr.Addrs},
+			expire: now.Add(ttl),
+		}
+	}
+
+	ch := make(chan peer.AddrInfo, len(cache.peers))
+	for p, cp := range cache.peers {
+		if cp.expire.Before(now) {
+			delete(cache.peers, p)
+			continue
+		}
+		if options.Limit > 0 && len(ch) >= options.Limit {
+			continue
+		}
+		ch <- cp.ai
+	}
+	close(ch)
+	return ch, nil
+}
+
+// cache returns the cache of the namespace ns, creating it if necessary.
+func (d *Discovery) cache(ns string) *discoveryCache {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	cache, ok := d.caches[ns]
+	if !ok {
+		cache = &discoveryCache{peers: make(map[peer.ID]cachedPeer)}
+		d.caches[ns] = cache
+	}
+	return cache
+}
+
+func (d *Discovery) discover(ctx context.Context, ns string, limit int, cookie []byte) (*pb.Message_DiscoverResponse, error) {
+	res, err := d.request(ctx, &pb.Message{
+		Type: pb.Message_DISCOVER,
+		Discover: &pb.Message_Discover{
+			Ns:     ns,
+			Limit:  uint64(max(limit, 0)),
+			Cookie: cookie,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	dr := res.GetDiscoverResponse()
+	if dr.GetStatus() != pb.Message_OK {
+		return nil, Error{Status: dr.GetStatus(), Text: dr.GetStatusText()}
+	}
+	return dr, nil
+}
+
+// request sends req to the rendezvous point and reads the response, if any.
+func (d *Discovery) request(ctx context.Context, req *pb.Message) (*pb.Message, error) {
+	s, err := d.host.NewStream(ctx, d.rp, ProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	if err := s.Scope().SetService(ServiceName); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	if err := s.Scope().ReserveMemory(maxMsgSize, network.ReservationPriorityAlways); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	defer s.Scope().ReleaseMemory(maxMsgSize)
+
+	deadline := time.Now().Add(streamTimeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	s.SetDeadline(deadline)
+
+	if err := pbio.NewDelimitedWriter(s).WriteMsg(req); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	resType, ok := responseTypes[req.GetType()]
+	if !ok {
+		return nil, nil
+	}
+	var res pb.Message
+	if err := pbio.NewDelimitedReader(s, maxMsgSize).ReadMsg(&res); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	if res.GetType() != resType {
+		s.Reset()
+		return nil, fmt.Errorf("unexpected response type %s", res.GetType())
+	}
+	return &res, nil
+}
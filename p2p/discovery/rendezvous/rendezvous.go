@@ -0,0 +1,63 @@
+// Package rendezvous implements the rendezvous protocol, a lightweight
+// registry that peers register with under namespaces and that other peers
+// query to discover them.
+//
+// The Service is the rendezvous point, and Discovery is a client that
+// implements discovery.Discovery, so that it can be used with the backoff
+// discovery and discovery/util.Advertise.
+//
+// See https://github.com/libp2p/specs/blob/master/rendezvous/README.md.
+package rendezvous
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/discovery/rendezvous/pb"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("rendezvous")
+
+const (
+	// ProtocolID is the protocol ID of the rendezvous protocol.
+	ProtocolID = protocol.ID("/rendezvous/1.0.0")
+	// ServiceName is the name of the rendezvous service for the resource manager.
+	ServiceName = "libp2p.rendezvous"
+
+	// DefaultTTL is the TTL of registrations that don't specify one.
+	DefaultTTL = 2 * time.Hour
+	// MinTTL is the minimum TTL of registrations.
+	MinTTL = 2 * time.Minute
+	// MaxTTL is the default maximum TTL of registrations.
+	MaxTTL = 72 * time.Hour
+	// MaxNamespaceLength is the maximum length of a namespace.
+	MaxNamespaceLength = 255
+	// MaxPeerRecordSize is the maximum size of the signed peer record of a
+	// registration.
+	MaxPeerRecordSize = 8 << 10
+	// MaxDiscoverLimit is the maximum number of registrations returned in a
+	// single discover response.
+	MaxDiscoverLimit = 1000
+	// CacheRefreshInterval is the interval at which Discovery refreshes the
+	// peers it cached for a namespace, dropping the peers that unregistered.
+	CacheRefreshInterval = 10 * time.Minute
+
+	maxMsgSize    = 1 << 20
+	streamTimeout = time.Minute
+)
+
+// Error is an error response of the rendezvous point.
+type Error struct {
+	Status pb.Message_ResponseStatus
+	Text   string
+}
+
+func (e Error) Error() string {
+	if e.Text == "" {
+		return fmt.Sprintf("rendezvous error: %s", e.Status)
+	}
+	return fmt.Sprintf("rendezvous error: %s: %s", e.Status, e.Text)
+}
@@ -0,0 +1,318 @@
+package rendezvous
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/p2p/discovery/backoff"
+	"github.com/libp2p/go-libp2p/p2p/discovery/rendezvous/pb"
+	"github.com/libp2p/go-libp2p/p2p/discovery/util"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-msgio/pbio"
+	"github.com/stretchr/testify/require"
+)
+
+func getHosts(t *testing.T, n int) []host.Host {
+	t.Helper()
+	mn, err := mocknet.FullMeshConnected(n)
+	require.NoError(t, err)
+	t.Cleanup(func() { mn.Close() })
+	return mn.Hosts()
+}
+
+func peerIDs(infos []peer.AddrInfo) []peer.ID {
+	ids := make([]peer.ID, 0, len(infos))
+	for _, ai := range infos {
+		ids = append(ids, ai.ID)
+	}
+	return ids
+}
+
+func signedPeerRecord(t *testing.T, h host.Host) []byte {
+	t.Helper()
+	b, err := NewDiscovery(h, "").signedPeerRecord()
+	require.NoError(t, err)
+	return b
+}
+
+func registrationPeer(t *testing.T, reg *pb.Message_Register) peer.ID {
+	t.Helper()
+	_, rec, err := record.ConsumeEnvelope(reg.GetSignedPeerRecord(), peer.PeerRecordEnvelopeDomain)
+	require.NoError(t, err)
+	return rec.(*peer.PeerRecord).PeerID
+}
+
+func TestRegisterAndDiscover(t *testing.T) {
+	hosts := getHosts(t, 4)
+	svc, err := NewService(hosts[0])
+	require.NoError(t, err)
+	defer svc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	clients := make([]*Discovery, 0, 3)
+	for _, h := range hosts[1:] {
+		clients = append(clients, NewDiscovery(h, hosts[0].ID()))
+	}
+
+	ttl, err := clients[0].Advertise(ctx, "foo")
+	require.NoError(t, err)
+	require.Equal(t, DefaultTTL, ttl)
+	ttl, err = clients[1].Advertise(ctx, "foo", discovery.TTL(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, ttl)
+	_, err = clients[2].Advertise(ctx, "bar")
+	require.NoError(t, err)
+
+	infos, err := util.FindPeers(ctx, clients[2], "foo")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []peer.ID{hosts[1].ID(), hosts[2].ID()}, peerIDs(infos))
+	for _, ai := range infos {
+		require.NotEmpty(t, ai.Addrs)
+		require.ElementsMatch(t, hosts[2].Peerstore().Addrs(ai.ID), ai.Addrs)
+	}
+
+	// we don't discover ourselves
+	infos, err = util.FindPeers(ctx, clients[0], "foo")
+	require.NoError(t, err)
+	require.Equal(t, []peer.ID{hosts[2].ID()}, peerIDs(infos))
+
+	// the empty namespace discovers all peers
+	infos, err = util.FindPeers(ctx, clients[0], "")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []peer.ID{hosts[2].ID(), hosts[3].ID()}, peerIDs(infos))
+
+	infos, err = util.FindPeers(ctx, clients[2], "foo", discovery.Limit(1))
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+
+	require.NoError(t, clients[1].Unregister(ctx, "foo"))
+	// the client returns the cached peers until it refreshes its cache
+	infos, err = util.FindPeers(ctx, clients[2], "foo")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []peer.ID{hosts[1].ID(), hosts[2].ID()}, peerIDs(infos))
+	require.Eventually(t, func() bool {
+		clients[2].caches["foo"].refreshed = time.Now().Add(-CacheRefreshInterval)
+		infos, err := util.FindPeers(ctx, clients[2], "foo")
+		require.NoError(t, err)
+		return slices.Equal([]peer.ID{hosts[1].ID()}, peerIDs(infos))
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestBackoffDiscovery(t *testing.T) {
+	hosts := getHosts(t, 3)
+	svc, err := NewService(hosts[0])
+	require.NoError(t, err)
+	defer svc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	util.Advertise(ctx, NewDiscovery(hosts[1], hosts[0].ID()), "foo")
+
+	d, err := backoff.NewBackoffDiscovery(NewDiscovery(hosts[2], hosts[0].ID()), backoff.NewFixedBackoff(10*time.Millisecond))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		// the backoff discovery only returns peers found while the request
+		// is ongoing up to the limit
+		infos, err := util.FindPeers(ctx, d, "foo", discovery.Limit(10))
+		require.NoError(t, err)
+		return slices.Equal([]peer.ID{hosts[1].ID()}, peerIDs(infos))
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestCookies(t *testing.T) {
+	hosts := getHosts(t, 4)
+	svc, err := NewService(hosts[0])
+	require.NoError(t, err)
+	defer svc.Close()
+	ctx := context.Background()
+
+	d := NewDiscovery(hosts[1], hosts[0].ID())
+	_, err = d.Advertise(ctx, "foo")
+	require.NoError(t, err)
+	res := svc.handleDiscover(&pb.Message_Discover{Ns: "foo"})
+	require.Equal(t, pb.Message_OK, res.GetStatus())
+	require.Len(t, res.GetRegistrations(), 1)
+	cookie := res.GetCookie()
+
+	// only the new registrations are returned with the cookie
+	res = svc.handleDiscover(&pb.Message_Discover{Ns: "foo", Cookie: cookie})
+	require.Empty(t, res.GetRegistrations())
+	_, err = NewDiscovery(hosts[2], hosts[0].ID()).Advertise(ctx, "foo")
+	require.NoError(t, err)
+	_, err = NewDiscovery(hosts[2], hosts[0].ID()).Advertise(ctx, "bar")
+	require.NoError(t, err)
+	res = svc.handleDiscover(&pb.Message_Discover{Ns: "foo", Cookie: cookie})
+	require.Len(t, res.GetRegistrations(), 1)
+	require.Equal(t, hosts[2].ID(), registrationPeer(t, res.GetRegistrations()[0]))
+
+	// paging with the limit
+	_, err = NewDiscovery(hosts[3], hosts[0].ID()).Advertise(ctx, "foo")
+	require.NoError(t, err)
+	res = svc.handleDiscover(&pb.Message_Discover{Ns: "foo", Limit: 2})
+	require.Len(t, res.GetRegistrations(), 2)
+	res = svc.handleDiscover(&pb.Message_Discover{Ns: "foo", Limit: 2, Cookie: res.GetCookie()})
+	require.Len(t, res.GetRegistrations(), 1)
+	require.Equal(t, hosts[3].ID(), registrationPeer(t, res.GetRegistrations()[0]))
+
+	// cookies are only valid for their namespace
+	res = svc.handleDiscover(&pb.Message_Discover{Ns: "bar", Cookie: cookie})
+	require.Equal(t, pb.Message_E_INVALID_COOKIE, res.GetStatus())
+	res = svc.handleDiscover(&pb.Message_Discover{Ns: "foo", Cookie: []byte("foo")})
+	require.Equal(t, pb.Message_E_INVALID_COOKIE, res.GetStatus())
+
+	// the client caches the peers it discovered
+	infos, err := util.FindPeers(ctx, d, "foo")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []peer.ID{hosts[2].ID(), hosts[3].ID()}, peerIDs(infos))
+	require.NotEmpty(t, d.caches["foo"].cookie)
+	infos, err = util.FindPeers(ctx, d, "foo")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []peer.ID{hosts[2].ID(), hosts[3].ID()}, peerIDs(infos))
+
+	// and starts over when its cookie is invalid
+	d.caches["foo"].cookie = makeCookie("foo", 1000)
+	infos, err = util.FindPeers(ctx, d, "foo")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []peer.ID{hosts[2].ID(), hosts[3].ID()}, peerIDs(infos))
+
+	// peers that unregistered are dropped when the client refreshes its cache
+	svc.handleUnregister(hosts[3].ID(), &pb.Message_Unregister{Ns: "foo"})
+	infos, err = util.FindPeers(ctx, d, "foo")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []peer.ID{hosts[2].ID(), hosts[3].ID()}, peerIDs(infos))
+	d.caches["foo"].refreshed = time.Now().Add(-CacheRefreshInterval)
+	infos, err = util.FindPeers(ctx, d, "foo")
+	require.NoError(t, err)
+	require.Equal(t, []peer.ID{hosts[2].ID()}, peerIDs(infos))
+}
+
+func TestFindPeersConcurrentNamespaces(t *testing.T) {
+	hosts := getHosts(t, 2)
+	// the rendezvous point doesn't answer the queries of the namespace slow
+	// until released
+	received := make(chan struct{}, 1)
+	release := make(chan struct{})
+	defer close(release)
+	hosts[0].SetStreamHandler(ProtocolID, func(s network.Stream) {
+		defer s.Close()
+		var req pb.Message
+		if err := pbio.NewDelimitedReader(s, maxMsgSize).ReadMsg(&req); err != nil {
+			s.Reset()
+			return
+		}
+		if req.GetDiscover().GetNs() == "slow" {
+			received <- struct{}{}
+			<-release
+		}
+		pbio.NewDelimitedWriter(s).WriteMsg(&pb.Message{
+			Type:             pb.Message_DISCOVER_RESPONSE,
+			DiscoverResponse: &pb.Message_DiscoverResponse{Status: pb.Message_OK},
+		})
+	})
+
+	d := NewDiscovery(hosts[1], hosts[0].ID())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.FindPeers(ctx, "slow")
+	<-received
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := d.FindPeers(ctx, "fast")
+		errCh <- err
+	}()
+	select {
+	case err := <-errCh:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("a pending query of another namespace shouldn't block FindPeers")
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	hosts := getHosts(t, 3)
+	svc, err := NewService(hosts[0], WithMaxTTL(time.Hour), WithMaxRegistrationsPerPeer(2), WithMaxRegistrationsPerNamespace(1))
+	require.NoError(t, err)
+	defer svc.Close()
+
+	p := hosts[1].ID()
+	rec := signedPeerRecord(t, hosts[1])
+	register := func(p peer.ID, ns string, rec []byte, ttl time.Duration) pb.Message_ResponseStatus {
+		return svc.handleRegister(p, &pb.Message_Register{Ns: ns, SignedPeerRecord: rec, Ttl: uint64(ttl / time.Second)}).GetStatus()
+	}
+
+	require.Equal(t, pb.Message_E_INVALID_NAMESPACE, register(p, "", rec, 0))
+	require.Equal(t, pb.Message_E_INVALID_NAMESPACE, register(p, string(make([]byte, MaxNamespaceLength+1)), rec, 0))
+	require.Equal(t, pb.Message_E_INVALID_TTL, register(p, "foo", rec, 0), "the default TTL exceeds the max TTL")
+	require.Equal(t, pb.Message_E_INVALID_TTL, register(p, "foo", rec, time.Second))
+	require.Equal(t, pb.Message_E_INVALID_TTL, register(p, "foo", rec, 2*time.Hour))
+	require.Equal(t, pb.Message_E_INVALID_TTL, register(p, "foo", rec, time.Duration(1<<62)))
+	require.Equal(t, pb.Message_E_INVALID_SIGNED_PEER_RECORD, register(p, "foo", []byte("foobar"), time.Hour))
+	require.Equal(t, pb.Message_E_INVALID_SIGNED_PEER_RECORD, register(hosts[2].ID(), "foo", rec, time.Hour))
+
+	require.Equal(t, pb.Message_OK, register(p, "foo", rec, time.Hour))
+	// refreshing a registration doesn't count against the limits
+	require.Equal(t, pb.Message_OK, register(p, "foo", rec, time.Hour))
+	require.Equal(t, pb.Message_E_UNAVAILABLE, register(hosts[2].ID(), "foo", signedPeerRecord(t, hosts[2]), time.Hour))
+	require.Equal(t, pb.Message_OK, register(p, "foo/bar", rec, time.Hour))
+	require.Equal(t, pb.Message_E_NOT_AUTHORIZED, register(p, "baz", rec, time.Hour))
+
+	// the error is passed to the client
+	_, err = NewDiscovery(hosts[1], hosts[0].ID()).Advertise(context.Background(), "baz", discovery.TTL(time.Hour))
+	require.ErrorIs(t, err, Error{Status: pb.Message_E_NOT_AUTHORIZED, Text: "too many registrations"})
+
+	svc.handleUnregister(p, &pb.Message_Unregister{Ns: "foo"})
+	require.Equal(t, pb.Message_OK, register(p, "baz", rec, time.Hour))
+	require.Equal(t, pb.Message_OK, register(hosts[2].ID(), "foo", signedPeerRecord(t, hosts[2]), time.Hour))
+}
+
+func TestPersistence(t *testing.T) {
+	hosts := getHosts(t, 3)
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	svc, err := NewService(hosts[0], WithDatastore(d))
+	require.NoError(t, err)
+
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+	require.Equal(t, pb.Message_OK, svc.handleRegister(hosts[1].ID(), &pb.Message_Register{
+		Ns:               "foo",
+		SignedPeerRecord: signedPeerRecord(t, hosts[1]),
+		Ttl:              uint64(MinTTL / time.Second),
+	}).GetStatus())
+	require.Equal(t, pb.Message_OK, svc.handleRegister(hosts[2].ID(), &pb.Message_Register{
+		Ns:               "foo",
+		SignedPeerRecord: signedPeerRecord(t, hosts[2]),
+	}).GetStatus())
+	cookie := svc.handleDiscover(&pb.Message_Discover{Ns: "foo"}).GetCookie()
+	require.NoError(t, svc.Close())
+
+	svc, err = NewService(hosts[0], WithDatastore(d))
+	require.NoError(t, err)
+	defer svc.Close()
+	svc.now = func() time.Time { return now.Add(time.Hour) }
+	require.Equal(t, uint64(2), svc.counter)
+	require.Len(t, svc.peers, 2)
+
+	// the cookie is still valid, and the expired registration is dropped
+	res := svc.handleDiscover(&pb.Message_Discover{Ns: "foo", Cookie: cookie})
+	require.Equal(t, pb.Message_OK, res.GetStatus())
+	require.Empty(t, res.GetRegistrations())
+	res = svc.handleDiscover(&pb.Message_Discover{Ns: "foo"})
+	require.Len(t, res.GetRegistrations(), 1)
+	require.Equal(t, hosts[2].ID(), registrationPeer(t, res.GetRegistrations()[0]))
+	require.Equal(t, uint64((DefaultTTL-time.Hour)/time.Second), res.GetRegistrations()[0].GetTtl())
+	require.Len(t, svc.peers, 1)
+	require.Len(t, svc.namespaces, 1)
+	require.Len(t, svc.namespaces["foo"], 1)
+}
@@ -0,0 +1,564 @@
+package rendezvous
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/p2p/discovery/rendezvous/pb"
+	"github.com/libp2p/go-msgio/pbio"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+var (
+	registrationsKey = ds.NewKey("/rendezvous/registrations")
+	counterKey       = ds.NewKey("/rendezvous/counter")
+)
+
+// nsEncoding encodes namespaces in datastore keys, since they can contain
+// slashes.
+var nsEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// gcInterval is the interval at which expired registrations are deleted.
+var gcInterval = time.Minute
+
+// Option is an option for the rendezvous Service.
+type Option func(*Service) error
+
+// WithDatastore stores the registrations in the datastore, so that they
+// survive restarts of the rendezvous point. By default, the registrations are
+// kept in memory.
+func WithDatastore(d ds.Datastore) Option {
+	return func(s *Service) error {
+		s.ds = d
+		return nil
+	}
+}
+
+// WithMaxTTL sets the maximum TTL of registrations. It defaults to MaxTTL.
+func WithMaxTTL(ttl time.Duration) Option {
+	return func(s *Service) error {
+		if ttl < MinTTL {
+			return fmt.Errorf("max TTL must be at least %s", MinTTL)
+		}
+		s.maxTTL = ttl
+		return nil
+	}
+}
+
+// WithMaxRegistrationsPerPeer limits the number of namespaces a peer can be
+// registered in. It defaults to 1000.
+func WithMaxRegistrationsPerPeer(n int) Option {
+	return func(s *Service) error {
+		if n <= 0 {
+			return errors.New("max registrations per peer must be positive")
+		}
+		s.maxRegistrationsPerPeer = n
+		return nil
+	}
+}
+
+// WithMaxRegistrationsPerNamespace limits the number of peers registered in a
+// namespace. It defaults to 10000.
+func WithMaxRegistrationsPerNamespace(n int) Option {
+	return func(s *Service) error {
+		if n <= 0 {
+			return errors.New("max registrations per namespace must be positive")
+		}
+		s.maxRegistrationsPerNamespace = n
+		return nil
+	}
+}
+
+// registration is the datastore record of a registration.
+type registration struct {
+	Record  []byte
+	Expire  int64 // Unix time in nanoseconds
+	Counter uint64
+}
+
+// indexedRegistration is the part of a registration that is kept in memory,
+// so that discover requests only read the registrations they return from the
+// datastore.
+type indexedRegistration struct {
+	counter uint64
+	expire  int64 // Unix time in nanoseconds
+}
+
+// Service is a rendezvous point. Peers register with it under namespaces,
+// and discover the peers registered in a namespace.
+type Service struct {
+	host host.Host
+	ds   ds.Datastore
+
+	maxTTL                       time.Duration
+	maxRegistrationsPerPeer      int
+	maxRegistrationsPerNamespace int
+
+	// mx guards the datastore writes and the fields below
+	mx sync.Mutex
+	// counter is the sequence number of the last registration, used for
+	// cookies
+	counter uint64
+	// peers are the namespaces each peer is registered in
+	peers map[peer.ID]map[string]struct{}
+	// namespaces are the registrations in each namespace
+	namespaces map[string]map[peer.ID]indexedRegistration
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
+
+	// for tests
+	now func() time.Time
+}
+
+// NewService creates a rendezvous point and sets its stream handler on the
+// host.
+func NewService(h host.Host, opts ...Option) (*Service, error) {
+	s := &Service{
+		host:                         h,
+		maxTTL:                       MaxTTL,
+		maxRegistrationsPerPeer:      1000,
+		maxRegistrationsPerNamespace: 10000,
+		peers:                        make(map[peer.ID]map[string]struct{}),
+		namespaces:                   make(map[string]map[peer.ID]indexedRegistration),
+		now:                          time.Now,
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	if s.ds == nil {
+		s.ds = dssync.MutexWrap(ds.NewMapDatastore())
+	}
+	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
+	if err := s.load(); err != nil {
+		s.ctxCancel()
+		return nil, fmt.Errorf("failed to load registrations: %w", err)
+	}
+
+	s.refCount.Add(1)
+	go s.background()
+	h.SetStreamHandler(ProtocolID, s.handleStream)
+	return s, nil
+}
+
+// Close removes the stream handler and stops the service.
+func (s *Service) Close() error {
+	s.host.RemoveStreamHandler(ProtocolID)
+	s.ctxCancel()
+	s.refCount.Wait()
+	return nil
+}
+
+func registrationKey(ns string, p peer.ID) ds.Key {
+	return namespaceKey(ns).ChildString(p.String())
+}
+
+func namespaceKey(ns string) ds.Key {
+	return registrationsKey.ChildString(nsEncoding.EncodeToString([]byte(ns)))
+}
+
+func parseRegistrationKey(k ds.Key) (ns string, p peer.ID, err error) {
+	nsb, err := nsEncoding.DecodeString(k.Parent().BaseNamespace())
+	if err != nil {
+		return "", "", err
+	}
+	p, err = peer.Decode(k.BaseNamespace())
+	if err != nil {
+		return "", "", err
+	}
+	return string(nsb), p, nil
+}
+
+// load restores the counter and the indexes of the registrations, deleting
+// the expired registrations.
+func (s *Service) load() error {
+	v, err := s.ds.Get(s.ctx, counterKey)
+	switch {
+	case err == nil:
+		if len(v) != 8 {
+			return errors.New("invalid counter")
+		}
+		s.counter = binary.BigEndian.Uint64(v)
+	case !errors.Is(err, ds.ErrNotFound):
+		return err
+	}
+
+	return s.forEachRegistration(func(ns string, p peer.ID, reg *registration) error {
+		s.addToIndex(ns, p, indexedRegistration{counter: reg.Counter, expire: reg.Expire})
+		s.counter = max(s.counter, reg.Counter)
+		return nil
+	})
+}
+
+// forEachRegistration calls f for the unexpired registrations, deleting the
+// expired ones and the invalid ones. It must be called with the
+// mx held, or before the service is started.
+func (s *Service) forEachRegistration(f func(ns string, p peer.ID, reg *registration) error) error {
+	res, err := s.ds.Query(s.ctx, query.Query{Prefix: registrationsKey.String()})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	now := s.now()
+	var expired []ds.Key
+	defer func() {
+		for _, k := range expired {
+			if err := s.ds.Delete(s.ctx, k); err != nil {
+				log.Errorf("failed to delete registration: %s", err)
+			}
+		}
+	}()
+	for e := range res.Next() {
+		if e.Error != nil {
+			return e.Error
+		}
+		k := ds.RawKey(e.Key)
+		ns, p, err := parseRegistrationKey(k)
+		if err != nil {
+			log.Debugf("deleting registration with invalid key %s", k)
+			expired = append(expired, k)
+			continue
+		}
+		var reg registration
+		if err := json.Unmarshal(e.Value, &reg); err != nil {
+			log.Debugf("deleting invalid registration for %s: %s", p, err)
+			s.removeFromIndex(ns, p)
+			expired = append(expired, k)
+			continue
+		}
+		if time.Unix(0, reg.Expire).Before(now) {
+			s.removeFromIndex(ns, p)
+			expired = append(expired, k)
+			continue
+		}
+		if err := f(ns, p, &reg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) addToIndex(ns string, p peer.ID, reg indexedRegistration) {
+	nss, ok := s.peers[p]
+	if !ok {
+		nss = make(map[string]struct{})
+		s.peers[p] = nss
+	}
+	nss[ns] = struct{}{}
+	regs, ok := s.namespaces[ns]
+	if !ok {
+		regs = make(map[peer.ID]indexedRegistration)
+		s.namespaces[ns] = regs
+	}
+	regs[p] = reg
+}
+
+func (s *Service) removeFromIndex(ns string, p peer.ID) {
+	nss, ok := s.peers[p]
+	if !ok {
+		return
+	}
+	if _, ok := nss[ns]; !ok {
+		return
+	}
+	delete(nss, ns)
+	if len(nss) == 0 {
+		delete(s.peers, p)
+	}
+	delete(s.namespaces[ns], p)
+	if len(s.namespaces[ns]) == 0 {
+		delete(s.namespaces, ns)
+	}
+}
+
+func (s *Service) background() {
+	defer s.refCount.Done()
+	t := time.NewTicker(gcInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.mx.Lock()
+			err := s.forEachRegistration(func(string, peer.ID, *registration) error { return nil })
+			s.mx.Unlock()
+			if err != nil {
+				log.Errorf("failed to delete expired registrations: %s", err)
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Service) handleStream(str network.Stream) {
+	if err := str.Scope().SetService(ServiceName); err != nil {
+		log.Debugf("failed to attach stream to %s service: %s", ServiceName, err)
+		str.Reset()
+		return
+	}
+	if err := str.Scope().ReserveMemory(maxMsgSize, network.ReservationPriorityAlways); err != nil {
+		log.Debugf("failed to reserve memory for stream: %s", err)
+		str.Reset()
+		return
+	}
+	defer str.Scope().ReleaseMemory(maxMsgSize)
+
+	p := str.Conn().RemotePeer()
+	r := pbio.NewDelimitedReader(str, maxMsgSize)
+	w := pbio.NewDelimitedWriter(str)
+	// clients can send several requests on the same stream
+	for {
+		str.SetDeadline(time.Now().Add(streamTimeout))
+		var req pb.Message
+		if err := r.ReadMsg(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				str.Close()
+			} else {
+				log.Debugf("failed to read request from %s: %s", p, err)
+				str.Reset()
+			}
+			return
+		}
+
+		var res *pb.Message
+		switch req.GetType() {
+		case pb.Message_REGISTER:
+			res = &pb.Message{
+				Type:             pb.Message_REGISTER_RESPONSE,
+				RegisterResponse: s.handleRegister(p, req.GetRegister()),
+			}
+		case pb.Message_UNREGISTER:
+			s.handleUnregister(p, req.GetUnregister())
+			// there is no response to unregister requests
+			continue
+		case pb.Message_DISCOVER:
+			res = &pb.Message{
+				Type:             pb.Message_DISCOVER_RESPONSE,
+				DiscoverResponse: s.handleDiscover(req.GetDiscover()),
+			}
+		default:
+			log.Debugf("invalid message type %s from %s", req.GetType(), p)
+			str.Reset()
+			return
+		}
+		if err := w.WriteMsg(res); err != nil {
+			log.Debugf("failed to write response to %s: %s", p, err)
+			str.Reset()
+			return
+		}
+	}
+}
+
+func registerError(status pb.Message_ResponseStatus, text string) *pb.Message_RegisterResponse {
+	return &pb.Message_RegisterResponse{Status: status, StatusText: text}
+}
+
+func validNamespace(ns string) bool {
+	return ns != "" && len(ns) <= MaxNamespaceLength
+}
+
+func (s *Service) handleRegister(p peer.ID, req *pb.Message_Register) *pb.Message_RegisterResponse {
+	ns := req.GetNs()
+	if !validNamespace(ns) {
+		return registerError(pb.Message_E_INVALID_NAMESPACE, "invalid namespace")
+	}
+	ttl := DefaultTTL
+	if req.GetTtl() != 0 {
+		ttl = time.Duration(min(req.GetTtl(), uint64(s.maxTTL/time.Second)+1)) * time.Second
+	}
+	if ttl < MinTTL || ttl > s.maxTTL {
+		return registerError(pb.Message_E_INVALID_TTL, fmt.Sprintf("TTL must be between %s and %s", MinTTL, s.maxTTL))
+	}
+	if len(req.GetSignedPeerRecord()) > MaxPeerRecordSize {
+		return registerError(pb.Message_E_INVALID_SIGNED_PEER_RECORD, "signed peer record too large")
+	}
+	_, rec, err := record.ConsumeEnvelope(req.GetSignedPeerRecord(), peer.PeerRecordEnvelopeDomain)
+	if err != nil {
+		return registerError(pb.Message_E_INVALID_SIGNED_PEER_RECORD, "invalid signed peer record")
+	}
+	if pr, ok := rec.(*peer.PeerRecord); !ok || pr.PeerID != p {
+		return registerError(pb.Message_E_INVALID_SIGNED_PEER_RECORD, "signed peer record doesn't match the peer")
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if _, ok := s.peers[p][ns]; !ok {
+		if len(s.peers[p]) >= s.maxRegistrationsPerPeer {
+			return registerError(pb.Message_E_NOT_AUTHORIZED, "too many registrations")
+		}
+		if len(s.namespaces[ns]) >= s.maxRegistrationsPerNamespace {
+			return registerError(pb.Message_E_UNAVAILABLE, "namespace is full")
+		}
+	}
+
+	counter := s.counter + 1
+	expire := s.now().Add(ttl).UnixNano()
+	b, err := json.Marshal(registration{
+		Record:  req.GetSignedPeerRecord(),
+		Expire:  expire,
+		Counter: counter,
+	})
+	if err != nil {
+		log.Errorf("failed to marshal registration: %s", err)
+		return registerError(pb.Message_E_INTERNAL_ERROR, "internal error")
+	}
+	var cb [8]byte
+	binary.BigEndian.PutUint64(cb[:], counter)
+	if err := s.ds.Put(s.ctx, counterKey, cb[:]); err != nil {
+		log.Errorf("failed to store counter: %s", err)
+		return registerError(pb.Message_E_INTERNAL_ERROR, "internal error")
+	}
+	s.counter = counter
+	if err := s.ds.Put(s.ctx, registrationKey(ns, p), b); err != nil {
+		log.Errorf("failed to store registration: %s", err)
+		return registerError(pb.Message_E_INTERNAL_ERROR, "internal error")
+	}
+	s.addToIndex(ns, p, indexedRegistration{counter: counter, expire: expire})
+	log.Debugf("registered %s in %q for %s", p, ns, ttl)
+	return &pb.Message_RegisterResponse{Status: pb.Message_OK, Ttl: uint64(ttl / time.Second)}
+}
+
+func (s *Service) handleUnregister(p peer.ID, req *pb.Message_Unregister) {
+	ns := req.GetNs()
+	if !validNamespace(ns) {
+		return
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if err := s.ds.Delete(s.ctx, registrationKey(ns, p)); err != nil {
+		log.Errorf("failed to delete registration: %s", err)
+		return
+	}
+	s.removeFromIndex(ns, p)
+	log.Debugf("unregistered %s from %q", p, ns)
+}
+
+func discoverError(status pb.Message_ResponseStatus, text string) *pb.Message_DiscoverResponse {
+	return &pb.Message_DiscoverResponse{Status: status, StatusText: text}
+}
+
+// A cookie is the counter of the last registration returned to the client,
+// followed by the namespace.
+func makeCookie(ns string, counter uint64) []byte {
+	return append(binary.BigEndian.AppendUint64(nil, counter), ns...)
+}
+
+func parseCookie(ns string, cookie []byte) (counter uint64, ok bool) {
+	if len(cookie) < 8 || !bytes.Equal(cookie[8:], []byte(ns)) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(cookie), true
+}
+
+type discoveredRegistration struct {
+	ns      string
+	p       peer.ID
+	counter uint64
+}
+
+func (s *Service) handleDiscover(req *pb.Message_Discover) *pb.Message_DiscoverResponse {
+	ns := req.GetNs()
+	if ns != "" && !validNamespace(ns) {
+		return discoverError(pb.Message_E_INVALID_NAMESPACE, "invalid namespace")
+	}
+	limit := int(min(req.GetLimit(), MaxDiscoverLimit))
+	if limit == 0 {
+		limit = MaxDiscoverLimit
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	var since uint64
+	if len(req.GetCookie()) > 0 {
+		var ok bool
+		since, ok = parseCookie(ns, req.GetCookie())
+		if !ok || since > s.counter {
+			return discoverError(pb.Message_E_INVALID_COOKIE, "invalid cookie")
+		}
+	}
+
+	// Find the registrations to return in the index, and only read those
+	// from the datastore.
+	now := s.now()
+	var regs, expired []discoveredRegistration
+	collect := func(ns string, nsRegs map[peer.ID]indexedRegistration) {
+		for p, r := range nsRegs {
+			switch {
+			case time.Unix(0, r.expire).Before(now):
+				expired = append(expired, discoveredRegistration{ns: ns, p: p})
+			case r.counter > since:
+				regs = append(regs, discoveredRegistration{ns: ns, p: p, counter: r.counter})
+			}
+		}
+	}
+	if ns != "" {
+		collect(ns, s.namespaces[ns])
+	} else {
+		for ns, nsRegs := range s.namespaces {
+			collect(ns, nsRegs)
+		}
+	}
+	for _, r := range expired {
+		if err := s.ds.Delete(s.ctx, registrationKey(r.ns, r.p)); err != nil {
+			log.Errorf("failed to delete registration: %s", err)
+			continue
+		}
+		s.removeFromIndex(r.ns, r.p)
+	}
+	slices.SortFunc(regs, func(a, b discoveredRegistration) int {
+		return cmp.Compare(a.counter, b.counter)
+	})
+
+	// if we return all registrations, the next request only needs the ones
+	// registered after this one
+	last := s.counter
+	if len(regs) > limit {
+		regs = regs[:limit]
+		last = regs[limit-1].counter
+	}
+	res := &pb.Message_DiscoverResponse{
+		Status:        pb.Message_OK,
+		Registrations: make([]*pb.Message_Register, 0, len(regs)),
+		Cookie:        makeCookie(ns, last),
+	}
+	for _, r := range regs {
+		b, err := s.ds.Get(s.ctx, registrationKey(r.ns, r.p))
+		if err != nil {
+			log.Errorf("failed to get registration: %s", err)
+			return discoverError(pb.Message_E_INTERNAL_ERROR, "internal error")
+		}
+		var reg registration
+		if err := json.Unmarshal(b, &reg); err != nil {
+			log.Errorf("failed to unmarshal registration: %s", err)
+			return discoverError(pb.Message_E_INTERNAL_ERROR, "internal error")
+		}
+		ttl := time.Unix(0, reg.Expire).Sub(now)
+		res.Registrations = append(res.Registrations, &pb.Message_Register{
+			Ns:               r.ns,
+			SignedPeerRecord: reg.Record,
+			Ttl:              uint64(max(ttl/time.Second, 1)),
+		})
+	}
+	return res
+}
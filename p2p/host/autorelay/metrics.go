@@ -263,6 +263,10 @@ func (mt *metricsTracer) ScheduledWorkUpdated(scheduledWork *scheduledWorkTimes)
 
 	*tags = append(*tags, "old candidate check")
 	scheduledWorkTime.WithLabelValues(*tags...).Set(float64(scheduledWork.nextOldCandidateCheck.Unix()))
+	*tags = (*tags)[:0]
+
+	*tags = append(*tags, "relay swap")
+	scheduledWorkTime.WithLabelValues(*tags...).Set(float64(scheduledWork.nextSwap.Unix()))
 }
 
 func (mt *metricsTracer) DesiredReservations(cnt int) {
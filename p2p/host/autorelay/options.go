@@ -42,6 +42,8 @@ type config struct {
 	setMinCandidates bool
 	// see WithMetricsTracer
 	metricsTracer MetricsTracer
+	// see WithRelaySwapInterval
+	swapInterval time.Duration
 }
 
 var defaultConfig = config{
@@ -231,3 +233,15 @@ func WithMetricsTracer(mt MetricsTracer) Option {
 		return nil
 	}
 }
+
+// WithRelaySwapInterval sets the interval at which we check whether a candidate
+// scores sufficiently better than the worst relay we have a reservation with,
+// in which case we obtain a reservation with the candidate and drop the worst
+// relay. Relays are scored by RTT, advertised limits, load and reservation
+// stability. Swapping relays is disabled by default, and with a zero interval.
+func WithRelaySwapInterval(d time.Duration) Option {
+	return func(c *config) error {
+		c.swapInterval = d
+		return nil
+	}
+}
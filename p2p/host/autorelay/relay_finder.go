@@ -2,12 +2,14 @@ package autorelay
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"math/rand"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/sync/errgroup"
@@ -31,8 +33,9 @@ const protoIDv2 = circuitv2_proto.ProtoIDv2Hop
 // we call it a candidate, and consider using it as a relay.
 //
 // Relay: Out of the list candidates, the ones we have a reservation with.
-// Currently, we just randomly select a candidate, but we can employ more sophisticated
-// selection strategies here (e.g. by facotring in the RTT).
+// We select the candidates with the best score (see scoreRelay), and, if
+// enabled with WithRelaySwapInterval, periodically swap the worst relay for a
+// sufficiently better candidate.
 
 const (
 	rsvpRefreshInterval = time.Minute
@@ -40,8 +43,14 @@ const (
 
 	autorelayTag  = "autorelay"
 	maxRelayAddrs = 100
+
+	// minScoreImprovement is the score difference by which a candidate must
+	// beat the worst relay to replace it
+	minScoreImprovement = 0.1
 )
 
+var errRelayDisconnected = errors.New("disconnected from relay")
+
 type candidate struct {
 	added           time.Time
 	supportsRelayV2 bool
@@ -66,7 +75,10 @@ type relayFinder struct {
 	candidateMx                sync.Mutex
 	candidates                 map[peer.ID]*candidate
 	backoff                    map[peer.ID]time.Time
+	goingAway                  map[peer.ID]time.Time // relays that announced a downtime, until it ends
+	stats                      map[peer.ID]*relayStats
 	maybeConnectToRelayTrigger chan struct{} // cap: 1
+	maybeSwapRelayTrigger      chan struct{} // cap: 1
 	// Any time _something_ happens that might cause us to need new candidates.
 	// This could be
 	// * the disconnection of a relay
@@ -79,6 +91,11 @@ type relayFinder struct {
 	relayMx sync.Mutex
 	relays  map[peer.ID]*circuitv2.Reservation
 
+	// endpointDependentNAT is set when all our NAT mappings are endpoint
+	// dependent. Relayed connections are then unlikely to be upgraded by hole
+	// punching, and we prefer relays that don't limit them.
+	endpointDependentNAT atomic.Bool
+
 	circuitAddrs []ma.Multiaddr
 
 	// A channel that triggers a run of `runScheduledWork`.
@@ -107,8 +124,11 @@ func newRelayFinder(host host.Host, conf *config) (*relayFinder, error) {
 		peerSource:                 conf.peerSource,
 		candidates:                 make(map[peer.ID]*candidate),
 		backoff:                    make(map[peer.ID]time.Time),
+		goingAway:                  make(map[peer.ID]time.Time),
+		stats:                      make(map[peer.ID]*relayStats),
 		candidateFound:             make(chan struct{}, 1),
 		maybeConnectToRelayTrigger: make(chan struct{}, 1),
+		maybeSwapRelayTrigger:      make(chan struct{}, 1),
 		maybeRequestNewCandidates:  make(chan struct{}, 1),
 		triggerRunScheduledWork:    make(chan struct{}, 1),
 		relays:                     make(map[peer.ID]*circuitv2.Reservation),
@@ -124,6 +144,7 @@ type scheduledWorkTimes struct {
 	nextBackoff                 time.Time
 	nextOldCandidateCheck       time.Time
 	nextAllowedCallToPeerSource time.Time
+	nextSwap                    time.Time
 }
 
 func (rf *relayFinder) cleanupDisconnectedPeers(ctx context.Context) {
@@ -158,6 +179,7 @@ func (rf *relayFinder) cleanupDisconnectedPeers(ctx context.Context) {
 			rf.relayMx.Unlock()
 
 			if push {
+				rf.recordReservation(evt.Peer, nil, errRelayDisconnected)
 				rf.notifyRelayReservationUpdated()
 				rf.metricsTracer.ReservationEnded(1)
 			}
@@ -165,6 +187,75 @@ func (rf *relayFinder) cleanupDisconnectedPeers(ctx context.Context) {
 	}
 }
 
+// handleRelaysGoingAway drops the reservations with relays that announced
+// they are going away, and backs off from them for the announced downtime.
+func (rf *relayFinder) handleRelaysGoingAway(ctx context.Context) {
+	sub, err := rf.host.EventBus().Subscribe(new(event.EvtRelayGoingAway), eventbus.Name("autorelay (relay finder)"))
+	if err != nil {
+		log.Error("failed to subscribe to the EvtRelayGoingAway")
+		return
+	}
+	defer sub.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-sub.Out():
+			if !ok {
+				return
+			}
+			evt := ev.(event.EvtRelayGoingAway)
+
+			rf.candidateMx.Lock()
+			rf.removeCandidate(evt.Relay)
+			rf.setGoingAway(evt.Relay, evt.Downtime, rf.conf.clock.Now())
+			rf.candidateMx.Unlock()
+
+			push := false
+			rf.relayMx.Lock()
+			if rf.usingRelay(evt.Relay) {
+				log.Debugw("relay going away", "id", evt.Relay, "downtime", evt.Downtime)
+				delete(rf.relays, evt.Relay)
+				rf.notifyMaybeConnectToRelay()
+				rf.notifyMaybeNeedNewCandidates()
+				push = true
+			}
+			rf.relayMx.Unlock()
+
+			if push {
+				rf.host.ConnManager().Unprotect(evt.Relay, autorelayTag)
+				rf.notifyRelayReservationUpdated()
+				rf.metricsTracer.ReservationEnded(1)
+			}
+		}
+	}
+}
+
+// handleNATMappings tracks whether all our NAT mappings are endpoint
+// dependent.
+func (rf *relayFinder) handleNATMappings(ctx context.Context) {
+	sub, err := rf.host.EventBus().Subscribe(new(event.EvtNATMappingsChanged), eventbus.Name("autorelay (relay finder)"))
+	if err != nil {
+		log.Error("failed to subscribe to the EvtNATMappingsChanged")
+		return
+	}
+	defer sub.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-sub.Out():
+			if !ok {
+				return
+			}
+			mappings := ev.(event.EvtNATMappingsChanged).Mappings
+			rf.endpointDependentNAT.Store(len(mappings) > 0 && !slices.ContainsFunc(mappings, func(m network.NATMapping) bool {
+				return m.DeviceType != network.NATDeviceTypeSymmetric
+			}))
+		}
+	}
+}
+
 func (rf *relayFinder) background(ctx context.Context) {
 	peerSourceRateLimiter := make(chan struct{}, 1)
 	rf.refCount.Add(1)
@@ -185,6 +276,10 @@ func (rf *relayFinder) background(ctx context.Context) {
 
 	// This is the least frequent event. It's our fallback timer if we don't have any other work to do.
 	leastFrequentInterval := rf.conf.minInterval
+	swapInterval := rf.conf.swapInterval
+	if swapInterval <= 0 {
+		swapInterval = leastFrequentInterval
+	}
 	// Check if leastFrequentInterval is 0 to avoid busy looping
 	if rf.conf.backoff > leastFrequentInterval || leastFrequentInterval == 0 {
 		leastFrequentInterval = rf.conf.backoff
@@ -202,12 +297,15 @@ func (rf *relayFinder) background(ctx context.Context) {
 		nextBackoff:                 now.Add(rf.conf.backoff),
 		nextOldCandidateCheck:       now.Add(rf.conf.maxCandidateAge),
 		nextAllowedCallToPeerSource: now.Add(-time.Second), // allow immediately
+		nextSwap:                    now.Add(swapInterval),
 	}
 
 	workTimer := rf.conf.clock.InstantTimer(rf.runScheduledWork(ctx, now, scheduledWork, peerSourceRateLimiter))
 	defer workTimer.Stop()
 
 	go rf.cleanupDisconnectedPeers(ctx)
+	go rf.handleRelaysGoingAway(ctx)
+	go rf.handleNATMappings(ctx)
 
 	// update addrs on starting the relay finder.
 	rf.updateAddrs()
@@ -290,6 +388,11 @@ func (rf *relayFinder) runScheduledWork(ctx context.Context, now time.Time, sche
 		scheduledWork.nextOldCandidateCheck = rf.clearOldCandidates(now)
 	}
 
+	if rf.conf.swapInterval > 0 && now.After(scheduledWork.nextSwap) {
+		scheduledWork.nextSwap = now.Add(rf.conf.swapInterval)
+		rf.notifyMaybeSwapRelay()
+	}
+
 	if now.After(scheduledWork.nextAllowedCallToPeerSource) {
 		select {
 		case peerSourceRateLimiter <- struct{}{}:
@@ -316,6 +419,9 @@ func (rf *relayFinder) runScheduledWork(ctx context.Context, now time.Time, sche
 	if scheduledWork.nextOldCandidateCheck.Before(nextTime) {
 		nextTime = scheduledWork.nextOldCandidateCheck
 	}
+	if rf.conf.swapInterval > 0 && scheduledWork.nextSwap.Before(nextTime) {
+		nextTime = scheduledWork.nextSwap
+	}
 	if nextTime.Equal(now) {
 		// Only happens in CI with a mock clock
 		nextTime = nextTime.Add(1) // avoids an infinite loop
@@ -347,6 +453,7 @@ func (rf *relayFinder) clearOldCandidates(now time.Time) time.Time {
 			rf.removeCandidate(id)
 		}
 	}
+	rf.clearOldStats(now)
 	if deleted {
 		rf.notifyMaybeNeedNewCandidates()
 	}
@@ -354,6 +461,31 @@ func (rf *relayFinder) clearOldCandidates(now time.Time) time.Time {
 	return nextTime
 }
 
+// setGoingAway avoids the relay until the end of its announced downtime. If
+// the downtime is unknown, the relay is put on backoff.
+// Must be called with the candidateMx held.
+func (rf *relayFinder) setGoingAway(id peer.ID, downtime time.Duration, now time.Time) {
+	if downtime <= 0 {
+		rf.backoff[id] = now
+		return
+	}
+	// The relay is worth trying again once it's back, even if we obtained a
+	// reservation with it recently.
+	delete(rf.backoff, id)
+	rf.goingAway[id] = now.Add(downtime)
+}
+
+// isOnBackoff returns whether we should skip the relay, because we recently
+// tried to obtain a reservation with it or because it's going away.
+// Must be called with the candidateMx held.
+func (rf *relayFinder) isOnBackoff(id peer.ID, now time.Time) bool {
+	if _, ok := rf.backoff[id]; ok {
+		return true
+	}
+	until, ok := rf.goingAway[id]
+	return ok && now.Before(until)
+}
+
 // clearBackoff clears old backoff entries from the map. Returns the next time
 // to run this function.
 func (rf *relayFinder) clearBackoff(now time.Time) time.Time {
@@ -372,6 +504,15 @@ func (rf *relayFinder) clearBackoff(now time.Time) time.Time {
 			delete(rf.backoff, id)
 		}
 	}
+	for id, until := range rf.goingAway {
+		if until.After(now) {
+			if until.Before(nextTime) {
+				nextTime = until
+			}
+		} else {
+			delete(rf.goingAway, id)
+		}
+	}
 
 	return nextTime
 }
@@ -422,10 +563,10 @@ func (rf *relayFinder) findNodes(ctx context.Context, peerSourceRateLimiter <-ch
 			log.Debugw("found node", "id", pi.ID)
 			rf.candidateMx.Lock()
 			numCandidates := len(rf.candidates)
-			backoffStart, isOnBackoff := rf.backoff[pi.ID]
+			isOnBackoff := rf.isOnBackoff(pi.ID, rf.conf.clock.Now())
 			rf.candidateMx.Unlock()
 			if isOnBackoff {
-				log.Debugw("skipping node that we recently failed to obtain a reservation with", "id", pi.ID, "last attempt", rf.conf.clock.Since(backoffStart))
+				log.Debugw("skipping node that we recently failed to obtain a reservation with, or that is going away", "id", pi.ID)
 				continue
 			}
 			if numCandidates >= rf.conf.maxCandidates {
@@ -462,6 +603,13 @@ func (rf *relayFinder) notifyMaybeNeedNewCandidates() {
 	}
 }
 
+func (rf *relayFinder) notifyMaybeSwapRelay() {
+	select {
+	case rf.maybeSwapRelayTrigger <- struct{}{}:
+	default:
+	}
+}
+
 func (rf *relayFinder) notifyNewCandidate() {
 	select {
 	case rf.candidateFound <- struct{}{}:
@@ -488,9 +636,9 @@ func (rf *relayFinder) handleNewNode(ctx context.Context, pi peer.AddrInfo) (add
 		return false
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Second)
 	defer cancel()
-	supportsV2, err := rf.tryNode(ctx, pi)
+	supportsV2, err := rf.tryNode(tctx, pi)
 	if err != nil {
 		log.Debugf("node %s not accepted as a candidate: %s", pi.ID, err)
 		if err == errProtocolNotSupported {
@@ -512,6 +660,16 @@ func (rf *relayFinder) handleNewNode(ctx context.Context, pi peer.AddrInfo) (add
 		supportsRelayV2: supportsV2,
 	})
 	rf.candidateMx.Unlock()
+
+	// Measure the RTT of the candidate for scoring, unless we know it
+	// already. Until then, it is scored like a relay with the reference RTT.
+	if rf.host.Peerstore().LatencyEWMA(pi.ID) == 0 {
+		rf.refCount.Add(1)
+		go func() {
+			defer rf.refCount.Done()
+			rf.measureRTT(ctx, pi.ID)
+		}()
+	}
 	return true
 }
 
@@ -577,6 +735,8 @@ func (rf *relayFinder) handleNewCandidates(ctx context.Context) {
 			return
 		case <-rf.maybeConnectToRelayTrigger:
 			rf.maybeConnectToRelay(ctx)
+		case <-rf.maybeSwapRelayTrigger:
+			rf.maybeSwapRelay(ctx)
 		}
 	}
 }
@@ -620,6 +780,7 @@ func (rf *relayFinder) maybeConnectToRelay(ctx context.Context) {
 			continue
 		}
 		rsvp, err := rf.connectToRelay(ctx, cand)
+		rf.recordReservation(id, rsvp, err)
 		if err != nil {
 			log.Debugw("failed to connect to relay", "peer", id, "error", err)
 			rf.notifyMaybeNeedNewCandidates()
@@ -704,6 +865,7 @@ func (rf *relayFinder) refreshReservations(ctx context.Context, now time.Time) b
 
 func (rf *relayFinder) refreshRelayReservation(ctx context.Context, p peer.ID) error {
 	rsvp, err := circuitv2.Reserve(ctx, rf.host, peer.AddrInfo{ID: p})
+	rf.recordReservation(p, rsvp, err)
 
 	rf.relayMx.Lock()
 	if err != nil {
@@ -759,14 +921,39 @@ func (rf *relayFinder) selectCandidates() []*candidate {
 		}
 	}
 
-	// TODO: better relay selection strategy; this just selects random relays,
-	// but we should probably use ping latency as the selection metric
+	// shuffle first, so that candidates with equal scores are picked randomly
 	rand.Shuffle(len(candidates), func(i, j int) {
 		candidates[i], candidates[j] = candidates[j], candidates[i]
 	})
+	scores := make(map[peer.ID]float64, len(candidates))
+	for _, cand := range candidates {
+		// we only know the limits of the candidates we had a reservation
+		// with, so don't score them
+		scores[cand.ai.ID] = rf.score(cand.ai.ID, nil, true, false)
+	}
+	slices.SortStableFunc(candidates, func(a, b *candidate) int {
+		return cmp.Compare(scores[b.ai.ID], scores[a.ai.ID])
+	})
+	if rf.endpointDependentNAT.Load() {
+		// we won't be able to hole punch the relayed connections, so try the
+		// relays known to limit them last
+		slices.SortStableFunc(candidates, func(a, b *candidate) int {
+			return cmp.Compare(rf.isLimited(a.ai.ID), rf.isLimited(b.ai.ID))
+		})
+	}
 	return candidates
 }
 
+// isLimited returns 1 if relay p limited the relayed connections in the last
+// reservation we obtained with it, and 0 otherwise. It must be called with the
+// candidateMx held.
+func (rf *relayFinder) isLimited(p peer.ID) int {
+	if s := rf.stats[p]; s != nil && s.limit != nil && (s.limit.duration > 0 || s.limit.data > 0) {
+		return 1
+	}
+	return 0
+}
+
 func (rf *relayFinder) Start() error {
 	rf.ctxCancelMx.Lock()
 	defer rf.ctxCancelMx.Unlock()
@@ -0,0 +1,99 @@
+package autorelay
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	blhost "github.com/libp2p/go-libp2p/p2p/host/blank"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoingAwayBackoff(t *testing.T) {
+	rf := &relayFinder{
+		conf:      &config{clock: RealClock{}, backoff: time.Hour},
+		backoff:   make(map[peer.ID]time.Time),
+		goingAway: make(map[peer.ID]time.Time),
+	}
+	restarting := peer.ID("restarting")
+	unknown := peer.ID("unknown")
+	now := time.Now()
+
+	// we recently obtained a reservation with the relay
+	rf.backoff[restarting] = now.Add(-time.Minute)
+	rf.setGoingAway(restarting, 10*time.Second, now)
+	rf.setGoingAway(unknown, 0, now)
+	require.True(t, rf.isOnBackoff(restarting, now))
+	require.True(t, rf.isOnBackoff(unknown, now))
+
+	// the relay is retried once it's back, not after the backoff
+	require.Equal(t, now.Add(10*time.Second), rf.clearBackoff(now))
+	later := now.Add(11 * time.Second)
+	require.False(t, rf.isOnBackoff(restarting, later))
+	require.True(t, rf.isOnBackoff(unknown, later))
+	rf.clearBackoff(later)
+	require.NotContains(t, rf.goingAway, restarting)
+}
+
+func TestSelectCandidatesEndpointDependentNAT(t *testing.T) {
+	h := blhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h.Close()
+	rf := &relayFinder{
+		host:       h,
+		conf:       &config{clock: RealClock{}, maxCandidateAge: time.Hour},
+		candidates: make(map[peer.ID]*candidate),
+		stats:      make(map[peer.ID]*relayStats),
+	}
+	// the fast relay limits relayed connections, the slow one doesn't
+	fast := peer.ID("fast")
+	slow := peer.ID("slow")
+	h.Peerstore().RecordLatency(fast, time.Millisecond)
+	h.Peerstore().RecordLatency(slow, 300*time.Millisecond)
+	rf.stats[fast] = &relayStats{reservations: 1, limit: &relayLimit{duration: 2 * time.Minute, data: 1 << 17}}
+	rf.stats[slow] = &relayStats{reservations: 1, limit: &relayLimit{}}
+	for _, p := range []peer.ID{fast, slow} {
+		rf.candidates[p] = &candidate{added: time.Now(), supportsRelayV2: true, ai: peer.AddrInfo{ID: p}}
+	}
+	ids := func() []peer.ID {
+		var res []peer.ID
+		for _, c := range rf.selectCandidates() {
+			res = append(res, c.ai.ID)
+		}
+		return res
+	}
+	require.Equal(t, []peer.ID{fast, slow}, ids())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rf.handleNATMappings(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	em, err := h.EventBus().Emitter(new(event.EvtNATMappingsChanged), eventbus.Stateful)
+	require.NoError(t, err)
+	defer em.Close()
+
+	// behind a symmetric NAT, relays that limit relayed connections come last
+	require.NoError(t, em.Emit(event.EvtNATMappingsChanged{Mappings: []network.NATMapping{
+		{TransportProtocol: network.NATTransportUDP, DeviceType: network.NATDeviceTypeSymmetric},
+		{TransportProtocol: network.NATTransportTCP, DeviceType: network.NATDeviceTypeSymmetric},
+	}}))
+	require.Eventually(t, rf.endpointDependentNAT.Load, time.Second, 10*time.Millisecond)
+	require.Equal(t, []peer.ID{slow, fast}, ids())
+
+	require.NoError(t, em.Emit(event.EvtNATMappingsChanged{Mappings: []network.NATMapping{
+		{TransportProtocol: network.NATTransportUDP, DeviceType: network.NATDeviceTypeSymmetric},
+		{TransportProtocol: network.NATTransportTCP, DeviceType: network.NATDeviceTypeCone},
+	}}))
+	require.Eventually(t, func() bool { return !rf.endpointDependentNAT.Load() }, time.Second, 10*time.Millisecond)
+	require.Equal(t, []peer.ID{fast, slow}, ids())
+}
@@ -0,0 +1,274 @@
+package autorelay
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	circuitv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/client"
+	pbv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/pb"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+)
+
+const (
+	// rttReference is the RTT that halves the RTT score.
+	rttReference = 100 * time.Millisecond
+	// limitDurationReference and limitDataReference are the relayed connection
+	// limits for which the limit score is maximal.
+	limitDurationReference = 10 * time.Minute
+	limitDataReference     = 1 << 20
+
+	pingTimeout = 5 * time.Second
+
+	// weights of the score components
+	rttWeight       = 0.4
+	limitWeight     = 0.2
+	loadWeight      = 0.2
+	stabilityWeight = 0.2
+)
+
+// relayStats are the observations about a relay used to score it.
+type relayStats struct {
+	// reservations is the number of successful reservations and refreshes
+	reservations int
+	// failures is the number of failed reservations and refreshes, and of
+	// reservations lost due to disconnection
+	failures int
+	// overloaded is the number of reservations refused by the relay, e.g. due
+	// to resource limits, since the last successful reservation
+	overloaded int
+	// load is the load reported by the relay in the last reservation, nil if
+	// it didn't report it
+	load *circuitv2.RelayLoad
+	// limit is the limit of relayed connections advertised by the relay in
+	// the last reservation, nil if we never obtained one
+	limit *relayLimit
+	// updated is the time of the last observation
+	updated time.Time
+}
+
+// stabilityScore is the fraction of successful reservations, assuming one
+// success and one failure prior to any observation.
+func (s *relayStats) stabilityScore() float64 {
+	return float64(s.reservations+1) / float64(s.reservations+s.failures+2)
+}
+
+// loadScore is 1 for an idle relay that accepts reservations. It decreases
+// with the utilisation the relay reported in the last reservation, and with
+// the number of reservations it refused.
+func (s *relayStats) loadScore() float64 {
+	score := 1 / float64(1+s.overloaded)
+	if s.load != nil {
+		score *= 1 - s.load.Utilization()
+	}
+	return score
+}
+
+// relayLimit is the limit of relayed connections advertised in a reservation.
+// Zero values mean no limit.
+type relayLimit struct {
+	duration time.Duration
+	data     uint64
+}
+
+func reservationLimit(rsvp *circuitv2.Reservation) *relayLimit {
+	return &relayLimit{duration: rsvp.LimitDuration, data: rsvp.LimitData}
+}
+
+// rttScore scores the round trip time to a relay between 0 and 1. Unknown RTTs
+// score like rttReference.
+func rttScore(rtt time.Duration) float64 {
+	if rtt <= 0 {
+		rtt = rttReference
+	}
+	return 1 / (1 + float64(rtt)/float64(rttReference))
+}
+
+// limitScore scores the limits of relayed connections between 0 and 1.
+func limitScore(l *relayLimit) float64 {
+	d := 1.0
+	if l.duration > 0 {
+		d = min(float64(l.duration)/float64(limitDurationReference), 1)
+	}
+	b := 1.0
+	if l.data > 0 {
+		b = min(float64(l.data)/float64(limitDataReference), 1)
+	}
+	return (d + b) / 2
+}
+
+// scoreRelay returns the quality score of a relay between 0 and 1, higher is
+// better. If the limit of the relay isn't known, it isn't scored. Scores with
+// and without the limit, or with and without the load, must not be compared
+// with each other, as a relay can't be assumed to offer better or worse
+// limits or load than another.
+func scoreRelay(rtt time.Duration, stats *relayStats) float64 {
+	if stats == nil {
+		stats = &relayStats{}
+	}
+	score := rttWeight*rttScore(rtt) +
+		loadWeight*stats.loadScore() +
+		stabilityWeight*stats.stabilityScore()
+	if stats.limit == nil {
+		return score / (rttWeight + loadWeight + stabilityWeight)
+	}
+	return score + limitWeight*limitScore(stats.limit)
+}
+
+// score returns the quality score of relay p, see scoreRelay. rsvp is the
+// reservation we hold with p, if any, whose load and limit are scored. The
+// load and the limit are left out unless withLoad and withLimit are set.
+// It must be called with the candidateMx held.
+func (rf *relayFinder) score(p peer.ID, rsvp *circuitv2.Reservation, withLoad, withLimit bool) float64 {
+	var s relayStats
+	if stats := rf.stats[p]; stats != nil {
+		s = *stats
+	}
+	if rsvp != nil {
+		s.load = rsvp.Load
+		s.limit = reservationLimit(rsvp)
+	}
+	if !withLoad {
+		s.load = nil
+	}
+	if !withLimit {
+		s.limit = nil
+	}
+	return scoreRelay(rf.host.Peerstore().LatencyEWMA(p), &s)
+}
+
+// recordReservation records the outcome of a reservation attempt with relay p.
+// rsvp is the reservation obtained on success.
+func (rf *relayFinder) recordReservation(p peer.ID, rsvp *circuitv2.Reservation, err error) {
+	rf.candidateMx.Lock()
+	defer rf.candidateMx.Unlock()
+	s, ok := rf.stats[p]
+	if !ok {
+		s = &relayStats{}
+		rf.stats[p] = s
+	}
+	s.updated = rf.conf.clock.Now()
+	if err == nil {
+		s.reservations++
+		s.overloaded = 0
+		if rsvp != nil {
+			s.load = rsvp.Load
+			s.limit = reservationLimit(rsvp)
+		}
+		return
+	}
+	var rerr circuitv2.ReservationError
+	if errors.As(err, &rerr) {
+		switch rerr.Status {
+		case pbv2.Status_RESOURCE_LIMIT_EXCEEDED, pbv2.Status_RESERVATION_REFUSED:
+			s.overloaded++
+		}
+	}
+	s.failures++
+}
+
+// clearOldStats removes the stats of relays that we haven't observed for
+// longer than the maximum candidate age. It must be called with the
+// candidateMx held.
+func (rf *relayFinder) clearOldStats(now time.Time) {
+	for p, s := range rf.stats {
+		if _, ok := rf.candidates[p]; ok {
+			continue
+		}
+		if s.updated.Add(rf.conf.maxCandidateAge).Before(now) {
+			delete(rf.stats, p)
+		}
+	}
+}
+
+// measureRTT pings a candidate once, recording the RTT in the peerstore.
+func (rf *relayFinder) measureRTT(ctx context.Context, p peer.ID) {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	res := <-ping.Ping(ctx, rf.host, p)
+	if res.Error != nil {
+		log.Debugw("failed to measure relay RTT", "peer", p, "error", res.Error)
+	}
+}
+
+// selectSwap returns the worst of the relays, and the best candidate if it
+// scores sufficiently better. The worst relay is the one with the worst score,
+// including the load and the limit of the reservation we hold with it. It is
+// compared with the candidate without its load or limit, unless the candidate
+// reported them in a previous reservation.
+// It must be called with the candidateMx held.
+func (rf *relayFinder) selectSwap(relays map[peer.ID]*circuitv2.Reservation) (worst peer.ID, best *candidate) {
+	var cand *candidate
+	for _, c := range rf.selectCandidates() {
+		if _, ok := relays[c.ai.ID]; !ok && c.supportsRelayV2 {
+			// candidates are sorted by score
+			cand = c
+			break
+		}
+	}
+	if cand == nil {
+		return "", nil
+	}
+	worstScore := 2.0
+	for p, rsvp := range relays {
+		if s := rf.score(p, rsvp, true, true); s < worstScore {
+			worst, worstScore = p, s
+		}
+	}
+
+	cstats := rf.stats[cand.ai.ID]
+	knownLoad := cstats != nil && cstats.load != nil
+	knownLimit := cstats != nil && cstats.limit != nil
+	if rf.score(cand.ai.ID, nil, knownLoad, knownLimit) >= rf.score(worst, relays[worst], knownLoad, knownLimit)+minScoreImprovement {
+		best = cand
+	}
+	return worst, best
+}
+
+// maybeSwapRelay replaces the worst relay we have a reservation with by the
+// best candidate, if the candidate scores sufficiently better. The reservation
+// with the candidate is obtained before the worst relay is dropped, so that
+// we keep the desired number of relays.
+func (rf *relayFinder) maybeSwapRelay(ctx context.Context) {
+	rf.relayMx.Lock()
+	if len(rf.relays) < rf.conf.desiredRelays {
+		rf.relayMx.Unlock()
+		return
+	}
+	relays := make(map[peer.ID]*circuitv2.Reservation, len(rf.relays))
+	for p, rsvp := range rf.relays {
+		relays[p] = rsvp
+	}
+	rf.relayMx.Unlock()
+
+	rf.candidateMx.Lock()
+	worst, best := rf.selectSwap(relays)
+	rf.candidateMx.Unlock()
+	if best == nil {
+		return
+	}
+
+	rsvp, err := rf.connectToRelay(ctx, best)
+	rf.recordReservation(best.ai.ID, rsvp, err)
+	rf.metricsTracer.ReservationRequestFinished(false, err)
+	if err != nil {
+		log.Debugw("failed to connect to better relay", "peer", best.ai.ID, "error", err)
+		rf.notifyMaybeNeedNewCandidates()
+		return
+	}
+	log.Debugw("swapping relay", "old", worst, "new", best.ai.ID)
+	rf.relayMx.Lock()
+	rf.relays[best.ai.ID] = rsvp
+	_, dropped := rf.relays[worst]
+	delete(rf.relays, worst)
+	rf.relayMx.Unlock()
+
+	rf.host.ConnManager().Protect(best.ai.ID, autorelayTag)
+	if dropped {
+		rf.host.ConnManager().Unprotect(worst, autorelayTag)
+		rf.metricsTracer.ReservationEnded(1)
+	}
+	rf.notifyMaybeNeedNewCandidates()
+	rf.notifyRelayReservationUpdated()
+}
@@ -0,0 +1,143 @@
+package autorelay
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	blhost "github.com/libp2p/go-libp2p/p2p/host/blank"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+	circuitv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/client"
+	pbv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScoreRelay(t *testing.T) {
+	// lower RTT scores better
+	require.Greater(t, scoreRelay(20*time.Millisecond, nil), scoreRelay(300*time.Millisecond, nil))
+	// unknown RTT scores like the reference RTT
+	require.Equal(t, scoreRelay(rttReference, nil), scoreRelay(0, nil))
+
+	// more generous limits score better
+	unlimited := &relayStats{limit: &relayLimit{}}
+	limited := &relayStats{limit: &relayLimit{duration: 2 * time.Minute, data: 1 << 17}}
+	require.Greater(t, scoreRelay(rttReference, unlimited), scoreRelay(rttReference, limited))
+
+	// stable relays score better
+	stable := &relayStats{reservations: 10}
+	unstable := &relayStats{reservations: 2, failures: 8}
+	require.Greater(t, scoreRelay(rttReference, stable), scoreRelay(rttReference, unstable))
+
+	// loaded relays score worse
+	loaded := &relayStats{overloaded: 3}
+	require.Greater(t, scoreRelay(rttReference, nil), scoreRelay(rttReference, loaded))
+	idle := &relayStats{load: &circuitv2.RelayLoad{Reservations: 1, MaxReservations: 128}}
+	busy := &relayStats{load: &circuitv2.RelayLoad{Reservations: 120, MaxReservations: 128, Circuits: 10}}
+	require.Greater(t, scoreRelay(rttReference, idle), scoreRelay(rttReference, busy))
+	// relays that don't report their load score like idle relays
+	require.InDelta(t, scoreRelay(rttReference, nil), scoreRelay(rttReference, idle), 0.01)
+
+	// scores are between 0 and 1
+	for _, s := range []float64{
+		scoreRelay(time.Nanosecond, &relayStats{reservations: 1000, limit: &relayLimit{}}),
+		scoreRelay(time.Hour, &relayStats{failures: 1000, overloaded: 1000, limit: limited.limit}),
+		scoreRelay(time.Nanosecond, &relayStats{reservations: 1000}),
+		scoreRelay(time.Hour, &relayStats{failures: 1000, overloaded: 1000}),
+	} {
+		require.GreaterOrEqual(t, s, 0.0)
+		require.LessOrEqual(t, s, 1.0)
+	}
+}
+
+func TestRecordReservation(t *testing.T) {
+	rf := &relayFinder{
+		conf:       &config{clock: RealClock{}, maxCandidateAge: time.Minute},
+		candidates: make(map[peer.ID]*candidate),
+		stats:      make(map[peer.ID]*relayStats),
+	}
+	p := peer.ID("relay")
+
+	rf.recordReservation(p, nil, circuitv2.ReservationError{Status: pbv2.Status_RESERVATION_REFUSED})
+	rf.recordReservation(p, nil, errors.New("connection failed"))
+	require.Equal(t, &relayStats{failures: 2, overloaded: 1, updated: rf.stats[p].updated}, rf.stats[p])
+
+	load := &circuitv2.RelayLoad{Reservations: 10, MaxReservations: 128}
+	rf.recordReservation(p, &circuitv2.Reservation{Load: load, LimitDuration: time.Minute, LimitData: 1 << 17}, nil)
+	require.Equal(t, 1, rf.stats[p].reservations)
+	require.Zero(t, rf.stats[p].overloaded)
+	require.Equal(t, load, rf.stats[p].load)
+	require.Equal(t, &relayLimit{duration: time.Minute, data: 1 << 17}, rf.stats[p].limit)
+
+	rf.clearOldStats(time.Now())
+	require.Contains(t, rf.stats, p)
+	rf.clearOldStats(time.Now().Add(2 * time.Minute))
+	require.NotContains(t, rf.stats, p)
+}
+
+func TestSelectSwap(t *testing.T) {
+	h := blhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h.Close()
+	rf := &relayFinder{
+		host:       h,
+		conf:       &config{clock: RealClock{}, maxCandidateAge: time.Hour},
+		candidates: make(map[peer.ID]*candidate),
+		stats:      make(map[peer.ID]*relayStats),
+	}
+	relay := peer.ID("relay")
+	cand := peer.ID("candidate")
+	for _, p := range []peer.ID{relay, cand} {
+		h.Peerstore().RecordLatency(p, 50*time.Millisecond)
+		rf.stats[p] = &relayStats{reservations: 1}
+	}
+	rf.candidates[cand] = &candidate{added: time.Now(), supportsRelayV2: true, ai: peer.AddrInfo{ID: cand}}
+
+	// the relay has the default limits and is moderately loaded, which
+	// doesn't make an equally good candidate better
+	relays := map[peer.ID]*circuitv2.Reservation{
+		relay: {
+			LimitDuration: 2 * time.Minute,
+			LimitData:     1 << 17,
+			Load:          &circuitv2.RelayLoad{Reservations: 64, MaxReservations: 128},
+		},
+	}
+	rf.stats[relay].load = relays[relay].Load
+	worst, best := rf.selectSwap(relays)
+	require.Equal(t, relay, worst)
+	require.Nil(t, best)
+
+	// a candidate with a much better RTT is
+	fast := peer.ID("fast")
+	h.Peerstore().RecordLatency(fast, time.Millisecond)
+	rf.stats[fast] = &relayStats{reservations: 1}
+	rf.candidates[fast] = &candidate{added: time.Now(), supportsRelayV2: true, ai: peer.AddrInfo{ID: fast}}
+	_, best = rf.selectSwap(relays)
+	require.NotNil(t, best)
+	require.Equal(t, fast, best.ai.ID)
+	delete(rf.candidates, fast)
+
+	// a candidate that reported a lower load than the relay is
+	relays[relay].Load = &circuitv2.RelayLoad{Reservations: 120, MaxReservations: 128}
+	rf.stats[cand].load = &circuitv2.RelayLoad{Reservations: 1, MaxReservations: 128}
+	_, best = rf.selectSwap(relays)
+	require.NotNil(t, best)
+	require.Equal(t, cand, best.ai.ID)
+	relays[relay].Load = rf.stats[cand].load
+
+	// and so is a candidate that advertised more generous limits
+	rf.stats[cand].limit = &relayLimit{}
+	_, best = rf.selectSwap(relays)
+	require.NotNil(t, best)
+	require.Equal(t, cand, best.ai.ID)
+	rf.stats[cand].limit = reservationLimit(relays[relay])
+	_, best = rf.selectSwap(relays)
+	require.Nil(t, best)
+
+	// the worst relay is picked with the limits of our reservations
+	other := peer.ID("other")
+	h.Peerstore().RecordLatency(other, 50*time.Millisecond)
+	rf.stats[other] = &relayStats{reservations: 1}
+	relays[other] = &circuitv2.Reservation{Load: relays[relay].Load}
+	worst, _ = rf.selectSwap(relays)
+	require.Equal(t, relay, worst)
+}
@@ -231,6 +231,11 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	var natmgr NATManager
 	if opts.NATManager != nil {
 		natmgr = opts.NATManager(h.Network())
+		if nmgr, ok := natmgr.(interface{ SetEventBus(event.Bus) error }); ok {
+			if err := nmgr.SetEventBus(h.eventbus); err != nil {
+				return nil, fmt.Errorf("failed to create port mapping event emitter: %w", err)
+			}
+		}
 	}
 	var tfl func(ma.Multiaddr) transport.Transport
 	if s, ok := h.Network().(interface {
@@ -850,6 +855,17 @@ func (h *BasicHost) Reachability() network.Reachability {
 	return *h.addressManager.hostReachability.Load()
 }
 
+// NATStatus returns the status of the port mappings on the NAT gateway. It
+// returns false if port mapping is disabled, or if the NATManager doesn't
+// implement NATStatusReporter.
+func (h *BasicHost) NATStatus() (NATStatus, bool) {
+	sr, ok := h.addressManager.natManager.(NATStatusReporter)
+	if !ok {
+		return NATStatus{}, false
+	}
+	return sr.Status(), true
+}
+
 // Close shuts down the Host's services (network, etc).
 func (h *BasicHost) Close() error {
 	h.closeSync.Do(func() {
@@ -139,7 +139,7 @@ func TestProtocolHandlerEvents(t *testing.T) {
 	// to the test.
 	isIdentify := func(evt event.EvtLocalProtocolsUpdated) bool {
 		for _, p := range evt.Added {
-			if p == identify.ID || p == identify.IDPush {
+			if p == identify.ID || p == identify.IDPush || p == identify.IDDelta {
 				return true
 			}
 		}
@@ -319,6 +319,59 @@ func assertWait(t *testing.T, c chan protocol.ID, exp protocol.ID) {
 	}
 }
 
+type eventNATManager struct {
+	mockNatManager
+	bus event.Bus
+}
+
+func (m *eventNATManager) SetEventBus(bus event.Bus) error {
+	m.bus = bus
+	return nil
+}
+
+func TestCustomNATManagerEventBus(t *testing.T) {
+	nmgr := &eventNATManager{}
+	h, err := NewHost(swarmt.GenSwarm(t), &HostOpts{
+		NATManager: func(network.Network) NATManager { return nmgr },
+	})
+	require.NoError(t, err)
+	defer h.Close()
+	require.Equal(t, h.EventBus(), nmgr.bus)
+}
+
+type statusNATManager struct {
+	mockNatManager
+	status NATStatus
+}
+
+func (m *statusNATManager) Status() NATStatus { return m.status }
+
+func TestHostNATStatus(t *testing.T) {
+	h, err := NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h.Close()
+	_, ok := h.NATStatus()
+	require.False(t, ok, "port mapping is disabled")
+
+	h, err = NewHost(swarmt.GenSwarm(t), &HostOpts{
+		NATManager: func(network.Network) NATManager { return &mockNatManager{} },
+	})
+	require.NoError(t, err)
+	defer h.Close()
+	_, ok = h.NATStatus()
+	require.False(t, ok, "the NATManager doesn't report its status")
+
+	status := NATStatus{GatewayType: "PCP"}
+	h, err = NewHost(swarmt.GenSwarm(t), &HostOpts{
+		NATManager: func(network.Network) NATManager { return &statusNATManager{status: status} },
+	})
+	require.NoError(t, err)
+	defer h.Close()
+	s, ok := h.NATStatus()
+	require.True(t, ok)
+	require.Equal(t, status, s)
+}
+
 func TestHostProtoPreference(t *testing.T) {
 	h1, h2 := getHostPair(t)
 	defer h1.Close()
@@ -338,6 +391,7 @@ func TestHostProtoPreference(t *testing.T) {
 
 	// Prevent pushing identify information so this test works.
 	h1.RemoveStreamHandler(identify.IDPush)
+	h1.RemoveStreamHandler(identify.IDDelta)
 
 	h2.SetStreamHandler(protoOld, handler)
 
@@ -415,6 +469,7 @@ func TestHostProtoPreknowledge(t *testing.T) {
 
 	// Prevent pushing identify information so this test actually _uses_ the super protocol.
 	h1.RemoveStreamHandler(identify.IDPush)
+	h1.RemoveStreamHandler(identify.IDDelta)
 
 	h2pi := h2.Peerstore().PeerInfo(h2.ID())
 	// Filter to only 1 address so that we don't have to think about parallel
@@ -2,6 +2,7 @@ package basichost
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net"
 	"net/netip"
@@ -9,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/network"
 	inat "github.com/libp2p/go-libp2p/p2p/net/nat"
 
@@ -19,12 +21,39 @@ import (
 // NATManager is a simple interface to manage NAT devices.
 // It listens Listen and ListenClose notifications from the network.Network,
 // and tries to obtain port mappings for those.
+//
+// A NATManager may also implement:
+//   - NATStatusReporter, to report the status of its port mappings.
+//   - SetEventBus(event.Bus) error, to emit EvtPortMappingChanged events.
+//     The host calls it once, right after constructing the NATManager.
+//
+// The default NATManager implements both.
 type NATManager interface {
 	GetMapping(ma.Multiaddr) ma.Multiaddr
 	HasDiscoveredNAT() bool
 	io.Closer
 }
 
+// NATStatusReporter is implemented by a NATManager that reports the status of
+// its port mappings.
+type NATStatusReporter interface {
+	Status() NATStatus
+}
+
+var (
+	_ NATStatusReporter                         = (*natManager)(nil)
+	_ interface{ SetEventBus(event.Bus) error } = (*natManager)(nil)
+)
+
+// NATStatus is a snapshot of the port mappings on the NAT gateway.
+type NATStatus struct {
+	// GatewayType is the port mapping protocol of the gateway, e.g. "NAT-PMP".
+	// It is empty if no gateway was discovered.
+	GatewayType string
+	// Mappings are the port mappings we requested from the gateway.
+	Mappings []inat.Mapping
+}
+
 // NewNATManager creates a NAT manager.
 func NewNATManager(net network.Network) NATManager {
 	return newNATManager(net)
@@ -35,6 +64,11 @@ type entry struct {
 	port     int
 }
 
+type pinholeEntry struct {
+	protocol string
+	addr     netip.AddrPort
+}
+
 type nat interface {
 	AddMapping(ctx context.Context, protocol string, port int) error
 	RemoveMapping(ctx context.Context, protocol string, port int) error
@@ -42,8 +76,27 @@ type nat interface {
 	io.Closer
 }
 
+// natMappingsReporter is implemented by a nat that reports the status of its
+// mappings. It's not part of the nat interface since mockgen can't generate
+// a mock for it: the generated import of the nat package would clash with
+// the interface name.
+type natMappingsReporter interface {
+	Type() string
+	Mappings() []inat.Mapping
+}
+
+var _ natMappingsReporter = (*inat.NAT)(nil)
+
+// natPinholer is implemented by a nat that opens IPv6 firewall pinholes.
+type natPinholer interface {
+	AddPinhole(ctx context.Context, protocol string, addr netip.AddrPort) error
+	RemovePinhole(ctx context.Context, protocol string, addr netip.AddrPort) error
+}
+
+var _ natPinholer = (*inat.NAT)(nil)
+
 // so we can mock it in tests
-var discoverNAT = func(ctx context.Context) (nat, error) { return inat.DiscoverNAT(ctx) }
+var discoverNAT = func(ctx context.Context, opts ...inat.Option) (nat, error) { return inat.DiscoverNAT(ctx, opts...) }
 
 // natManager takes care of adding + removing port mappings to the nat.
 // Initialized with the host if it has a NATPortMap option enabled.
@@ -56,9 +109,15 @@ type natManager struct {
 	natMx sync.RWMutex
 	nat   nat
 
+	emitterMx sync.RWMutex
+	emitter   event.Emitter // nil until SetEventBus is called
+
 	syncFlag chan struct{} // cap: 1
 
 	tracked map[entry]bool // the bool is only used in doSync and has no meaning outside of that function
+	// trackedPinholes are the pinholes of our public IPv6 addresses, the bool
+	// is only used in syncPinholes
+	trackedPinholes map[pinholeEntry]bool
 
 	refCount  sync.WaitGroup
 	ctx       context.Context
@@ -68,22 +127,51 @@ type natManager struct {
 func newNATManager(net network.Network) *natManager {
 	ctx, cancel := context.WithCancel(context.Background())
 	nmgr := &natManager{
-		net:       net,
-		syncFlag:  make(chan struct{}, 1),
-		ctx:       ctx,
-		ctxCancel: cancel,
-		tracked:   make(map[entry]bool),
+		net:             net,
+		syncFlag:        make(chan struct{}, 1),
+		ctx:             ctx,
+		ctxCancel:       cancel,
+		tracked:         make(map[entry]bool),
+		trackedPinholes: make(map[pinholeEntry]bool),
 	}
 	nmgr.refCount.Add(1)
 	go nmgr.background(ctx)
 	return nmgr
 }
 
+// SetEventBus makes the natManager emit an EvtPortMappingChanged event on the
+// bus when a port mapping changes.
+func (nmgr *natManager) SetEventBus(bus event.Bus) error {
+	em, err := bus.Emitter(new(event.EvtPortMappingChanged))
+	if err != nil {
+		return err
+	}
+	nmgr.emitterMx.Lock()
+	nmgr.emitter = em
+	nmgr.emitterMx.Unlock()
+	return nil
+}
+
+func (nmgr *natManager) emitMappingChanged(evt event.EvtPortMappingChanged) {
+	log.Debugw("port mapping changed", "change", evt.Change, "protocol", evt.Protocol, "port", evt.InternalPort, "error", evt.Error)
+	nmgr.emitterMx.RLock()
+	defer nmgr.emitterMx.RUnlock()
+	if nmgr.emitter != nil {
+		nmgr.emitter.Emit(evt)
+	}
+}
+
 // Close closes the natManager, closing the underlying nat
 // and unregistering from network events.
 func (nmgr *natManager) Close() error {
 	nmgr.ctxCancel()
 	nmgr.refCount.Wait()
+	nmgr.emitterMx.Lock()
+	defer nmgr.emitterMx.Unlock()
+	if nmgr.emitter != nil {
+		nmgr.emitter.Close()
+		nmgr.emitter = nil
+	}
 	return nil
 }
 
@@ -93,6 +181,19 @@ func (nmgr *natManager) HasDiscoveredNAT() bool {
 	return nmgr.nat != nil
 }
 
+func (nmgr *natManager) Status() NATStatus {
+	nmgr.natMx.RLock()
+	defer nmgr.natMx.RUnlock()
+	sr, ok := nmgr.nat.(natMappingsReporter)
+	if !ok {
+		return NATStatus{}
+	}
+	return NATStatus{
+		GatewayType: sr.Type(),
+		Mappings:    sr.Mappings(),
+	}
+}
+
 func (nmgr *natManager) background(ctx context.Context) {
 	defer nmgr.refCount.Done()
 
@@ -107,7 +208,7 @@ func (nmgr *natManager) background(ctx context.Context) {
 
 	discoverCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
-	natInstance, err := discoverNAT(discoverCtx)
+	natInstance, err := discoverNAT(discoverCtx, inat.WithMappingEventHandler(nmgr.emitMappingChanged))
 	if err != nil {
 		log.Info("DiscoverNAT error:", err)
 		return
@@ -213,6 +314,78 @@ func (nmgr *natManager) doSync() {
 		}
 		nmgr.tracked[e] = false
 	}
+
+	if p, ok := nmgr.nat.(natPinholer); ok {
+		nmgr.syncPinholes(p)
+	}
+}
+
+// syncPinholes opens a firewall pinhole for each of our public IPv6 listen
+// addresses, and closes the pinholes of the addresses we stopped listening on.
+// IPv6 addresses don't need a port mapping, but the gateway may still drop
+// inbound connections to them.
+func (nmgr *natManager) syncPinholes(p natPinholer) {
+	for e := range nmgr.trackedPinholes {
+		nmgr.trackedPinholes[e] = false
+	}
+	addrs, err := nmgr.net.InterfaceListenAddresses()
+	if err != nil {
+		log.Debugf("failed to get interface listen addresses: %s", err)
+		return
+	}
+	var newPinholes []pinholeEntry
+	for _, maddr := range addrs {
+		if !manet.IsPublicAddr(maddr) {
+			continue
+		}
+		maIP, rest := ma.SplitFirst(maddr)
+		if maIP == nil || maIP.Protocol().Code != ma.P_IP6 {
+			continue
+		}
+		ip, ok := netip.AddrFromSlice(maIP.RawValue())
+		if !ok || ip.Is4In6() {
+			continue
+		}
+		proto, _ := ma.SplitFirst(rest)
+		if proto == nil {
+			continue
+		}
+		var protocol string
+		switch proto.Protocol().Code {
+		case ma.P_TCP:
+			protocol = "tcp"
+		case ma.P_UDP:
+			protocol = "udp"
+		default:
+			continue
+		}
+		port, err := strconv.ParseUint(proto.Value(), 10, 16)
+		if err != nil {
+			continue
+		}
+		e := pinholeEntry{protocol: protocol, addr: netip.AddrPortFrom(ip, uint16(port))}
+		if _, ok := nmgr.trackedPinholes[e]; ok {
+			nmgr.trackedPinholes[e] = true
+		} else {
+			newPinholes = append(newPinholes, e)
+		}
+	}
+
+	for e, v := range nmgr.trackedPinholes {
+		if !v {
+			p.RemovePinhole(nmgr.ctx, e.protocol, e.addr)
+			delete(nmgr.trackedPinholes, e)
+		}
+	}
+	for _, e := range newPinholes {
+		if err := p.AddPinhole(nmgr.ctx, e.protocol, e.addr); err != nil {
+			if errors.Is(err, inat.ErrPinholesUnsupported) {
+				return
+			}
+			log.Errorf("failed to open a pinhole for %s %s: %s", e.protocol, e.addr, err)
+		}
+		nmgr.trackedPinholes[e] = false
+	}
 }
 
 func (nmgr *natManager) GetMapping(addr ma.Multiaddr) ma.Multiaddr {
@@ -10,6 +10,10 @@ import (
 
 	ma "github.com/multiformats/go-multiaddr"
 
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	inat "github.com/libp2p/go-libp2p/p2p/net/nat"
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
 
 	"go.uber.org/mock/gomock"
@@ -20,7 +24,7 @@ func setupMockNAT(t *testing.T) (mockNAT *MockNAT, reset func()) {
 	ctrl := gomock.NewController(t)
 	mockNAT = NewMockNAT(ctrl)
 	origDiscoverNAT := discoverNAT
-	discoverNAT = func(_ context.Context) (nat, error) { return mockNAT, nil }
+	discoverNAT = func(_ context.Context, _ ...inat.Option) (nat, error) { return mockNAT, nil }
 	return mockNAT, func() {
 		discoverNAT = origDiscoverNAT
 		ctrl.Finish()
@@ -61,6 +65,54 @@ func TestMapping(t *testing.T) {
 	require.Equal(t, "/ip4/1.2.3.4/udp/4321/quic-v1/webtransport", m.GetMapping(ma.StringCast("/ip4/0.0.0.0/udp/1234/quic-v1/webtransport")).String())
 }
 
+type statusNAT struct {
+	*MockNAT
+	typ      string
+	mappings []inat.Mapping
+}
+
+func (n *statusNAT) Type() string             { return n.typ }
+func (n *statusNAT) Mappings() []inat.Mapping { return n.mappings }
+
+func TestNATStatus(t *testing.T) {
+	mockNAT, reset := setupMockNAT(t)
+	defer reset()
+
+	sw := swarmt.GenSwarm(t)
+	defer sw.Close()
+	m := newNATManager(sw)
+	bus := eventbus.NewBus()
+	sub, err := bus.Subscribe(new(event.EvtPortMappingChanged))
+	require.NoError(t, err)
+	defer sub.Close()
+	require.NoError(t, m.SetEventBus(bus))
+	require.Eventually(t, m.HasDiscoveredNAT, time.Second, time.Millisecond)
+
+	mappings := []inat.Mapping{{
+		Protocol:     "tcp",
+		InternalPort: 1234,
+		ExternalAddr: netip.AddrPortFrom(netip.AddrFrom4([4]byte{1, 2, 3, 4}), 4321),
+		Lease:        inat.MappingDuration,
+	}}
+	require.Equal(t, NATStatus{}, m.Status(), "the mock doesn't report its status")
+	m.natMx.Lock()
+	m.nat = &statusNAT{MockNAT: mockNAT, typ: "NAT-PMP", mappings: mappings}
+	m.natMx.Unlock()
+	require.Equal(t, NATStatus{GatewayType: "NAT-PMP", Mappings: mappings}, m.Status())
+
+	evt := event.EvtPortMappingChanged{Change: event.PortMappingLost, Protocol: "tcp", InternalPort: 1234}
+	m.emitMappingChanged(evt)
+	select {
+	case e := <-sub.Out():
+		require.Equal(t, evt, e)
+	case <-time.After(time.Second):
+		t.Fatal("expected a port mapping event")
+	}
+
+	mockNAT.EXPECT().Close()
+	require.NoError(t, m.Close())
+}
+
 func TestAddAndRemoveListeners(t *testing.T) {
 	mockNAT, reset := setupMockNAT(t)
 	defer reset()
@@ -106,3 +158,62 @@ func TestAddAndRemoveListeners(t *testing.T) {
 	mockNAT.EXPECT().RemoveMapping(gomock.Any(), "tcp", 1234).MaxTimes(1)
 	mockNAT.EXPECT().Close().MaxTimes(1)
 }
+
+type pinholeNAT struct {
+	*MockNAT
+	pinholes map[pinholeEntry]bool
+}
+
+func (n *pinholeNAT) AddPinhole(_ context.Context, protocol string, addr netip.AddrPort) error {
+	n.pinholes[pinholeEntry{protocol: protocol, addr: addr}] = true
+	return nil
+}
+
+func (n *pinholeNAT) RemovePinhole(_ context.Context, protocol string, addr netip.AddrPort) error {
+	delete(n.pinholes, pinholeEntry{protocol: protocol, addr: addr})
+	return nil
+}
+
+type listenAddrsNetwork struct {
+	network.Network
+	addrs []ma.Multiaddr
+}
+
+func (n *listenAddrsNetwork) ListenAddresses() []ma.Multiaddr { return n.addrs }
+func (n *listenAddrsNetwork) InterfaceListenAddresses() ([]ma.Multiaddr, error) {
+	return n.addrs, nil
+}
+
+func TestPinholes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockNAT := NewMockNAT(ctrl)
+	mockNAT.EXPECT().AddMapping(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	mockNAT.EXPECT().RemoveMapping(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	n := &pinholeNAT{MockNAT: mockNAT, pinholes: make(map[pinholeEntry]bool)}
+	net := &listenAddrsNetwork{addrs: []ma.Multiaddr{
+		ma.StringCast("/ip4/1.2.3.4/tcp/4001"),
+		ma.StringCast("/ip6/fe80::1/tcp/4001"),
+		ma.StringCast("/ip6/2001:4860::1/tcp/4001"),
+		ma.StringCast("/ip6/2001:4860::1/udp/4001/quic-v1"),
+	}}
+	m := &natManager{
+		net:             net,
+		nat:             n,
+		tracked:         make(map[entry]bool),
+		trackedPinholes: make(map[pinholeEntry]bool),
+		ctx:             context.Background(),
+	}
+
+	m.doSync()
+	require.Equal(t, map[pinholeEntry]bool{
+		{protocol: "tcp", addr: netip.MustParseAddrPort("[2001:4860::1]:4001")}: true,
+		{protocol: "udp", addr: netip.MustParseAddrPort("[2001:4860::1]:4001")}: true,
+	}, n.pinholes)
+
+	// close the pinhole of the address we stopped listening on
+	net.addrs = net.addrs[:3]
+	m.doSync()
+	require.Equal(t, map[pinholeEntry]bool{
+		{protocol: "tcp", addr: netip.MustParseAddrPort("[2001:4860::1]:4001")}: true,
+	}, n.pinholes)
+}
@@ -150,8 +150,56 @@ func (w *wildcardSub) Name() string {
 }
 
 type namedSink struct {
-	name string
-	ch   chan interface{}
+	name   string
+	ch     chan interface{}
+	policy OverflowPolicy
+	filter func(interface{}) bool
+}
+
+func newNamedSink(ch chan interface{}, settings *subSettings) *namedSink {
+	return &namedSink{ch: ch, name: settings.name, policy: settings.policy, filter: settings.filter}
+}
+
+// queue queues the event on the sink without blocking, applying the filter and
+// the overflow policy of the sink. It returns false if the channel is full and
+// the policy is to block, in which case the caller has to block.
+func (s *namedSink) queue(evt interface{}, metricsTracer MetricsTracer) bool {
+	if s.filter != nil && !s.filter(evt) {
+		if smt, ok := metricsTracer.(SubscriptionMetricsTracer); ok {
+			smt.SubscriberEventFiltered(s.name)
+		}
+		return true
+	}
+
+	// Sending metrics before sending on channel allows us to
+	// record channel full events before blocking
+	sendSubscriberMetrics(metricsTracer, s)
+
+	for {
+		select {
+		case s.ch <- evt:
+			return true
+		default:
+		}
+		switch s.policy {
+		case DropNewest:
+			if smt, ok := metricsTracer.(SubscriptionMetricsTracer); ok {
+				smt.SubscriberEventDropped(s.name)
+			}
+			return true
+		case DropOldest:
+			select {
+			case <-s.ch:
+				if smt, ok := metricsTracer.(SubscriptionMetricsTracer); ok {
+					smt.SubscriberEventDropped(s.name)
+				}
+			default:
+				// the subscriber consumed an event in the meantime
+			}
+		default:
+			return false
+		}
+	}
 }
 
 type sub struct {
@@ -210,7 +258,8 @@ func (s *sub) Close() error {
 var _ event.Subscription = (*sub)(nil)
 
 // Subscribe creates new subscription. Failing to drain the channel will cause
-// publishers to get blocked. CancelFunc is guaranteed to return after last send
+// publishers to get blocked, unless the subscription uses one of the drop
+// overflow policies. CancelFunc is guaranteed to return after last send
 // to the channel
 func (b *basicBus) Subscribe(evtTypes interface{}, opts ...event.SubscriptionOpt) (_ event.Subscription, err error) {
 	settings := newSubSettings()
@@ -220,14 +269,21 @@ func (b *basicBus) Subscribe(evtTypes interface{}, opts ...event.SubscriptionOpt
 		}
 	}
 
+	if settings.policy != Block && settings.buffer == 0 {
+		return nil, fmt.Errorf("overflow policy %s requires a buffered subscription", settings.policy)
+	}
+
 	if evtTypes == event.WildcardSubscription {
+		if settings.replay > 0 {
+			return nil, fmt.Errorf("wildcard subscriptions don't support replay")
+		}
 		out := &wildcardSub{
 			ch:            make(chan interface{}, settings.buffer),
 			w:             b.wildcard,
 			metricsTracer: b.metricsTracer,
 			name:          settings.name,
 		}
-		b.wildcard.addSink(&namedSink{ch: out.ch, name: out.name})
+		b.wildcard.addSink(newNamedSink(out.ch, &settings))
 		return out, nil
 	}
 
@@ -261,20 +317,35 @@ func (b *basicBus) Subscribe(evtTypes interface{}, opts ...event.SubscriptionOpt
 
 	for i, etyp := range types {
 		typ := reflect.TypeOf(etyp)
+		sink := newNamedSink(out.ch, &settings)
 
 		b.withNode(typ.Elem(), func(n *node) {
-			n.sinks = append(n.sinks, &namedSink{ch: out.ch, name: out.name})
+			n.sinks = append(n.sinks, sink)
 			out.nodes[i] = n
 			if b.metricsTracer != nil {
 				b.metricsTracer.AddSubscriber(typ.Elem())
 			}
 		}, func(n *node) {
+			if settings.replay > 0 && len(n.replay) > 0 {
+				evts := n.replay[max(0, len(n.replay)-settings.replay):]
+				for _, evt := range evts {
+					if !sink.queue(evt, b.metricsTracer) {
+						out.ch <- evt
+					}
+					if smt, ok := b.metricsTracer.(SubscriptionMetricsTracer); ok {
+						smt.SubscriberEventReplayed(sink.name)
+					}
+				}
+				return
+			}
 			if n.keepLast {
 				l := n.last
 				if l == nil {
 					return
 				}
-				out.ch <- l
+				if !sink.queue(l, b.metricsTracer) {
+					out.ch <- l
+				}
 			}
 		})
 	}
@@ -313,6 +384,7 @@ func (b *basicBus) Emitter(evtType interface{}, opts ...event.EmitterOpt) (e eve
 	b.withNode(typ, func(n *node) {
 		n.nEmitters.Add(1)
 		n.keepLast = n.keepLast || settings.makeStateful
+		n.replaySize = max(n.replaySize, settings.replayBuffer)
 		e = &emitter{n: n, typ: typ, dropper: b.tryDropNode, w: b.wildcard, metricsTracer: b.metricsTracer}
 	}, nil)
 	return
@@ -382,14 +454,7 @@ func (n *wildcardNode) emit(evt interface{}) {
 
 	n.RLock()
 	for _, sink := range n.sinks {
-
-		// Sending metrics before sending on channel allows us to
-		// record channel full events before blocking
-		sendSubscriberMetrics(n.metricsTracer, sink)
-
-		select {
-		case sink.ch <- evt:
-		default:
+		if !sink.queue(evt, n.metricsTracer) {
 			slowConsumerTimer := emitAndLogError(n.slowConsumerTimer, wildcardType, evt, sink)
 			defer func() {
 				n.Lock()
@@ -413,6 +478,10 @@ type node struct {
 	keepLast bool
 	last     interface{}
 
+	// replay holds the last replaySize events, oldest first
+	replaySize int
+	replay     []interface{}
+
 	sinks         []*namedSink
 	metricsTracer MetricsTracer
 
@@ -436,15 +505,16 @@ func (n *node) emit(evt interface{}) {
 	if n.keepLast {
 		n.last = evt
 	}
+	if n.replaySize > 0 {
+		n.replay = append(n.replay, evt)
+		if len(n.replay) > n.replaySize {
+			n.replay[0] = nil
+			n.replay = n.replay[1:]
+		}
+	}
 
 	for _, sink := range n.sinks {
-
-		// Sending metrics before sending on channel allows us to
-		// record channel full events before blocking
-		sendSubscriberMetrics(n.metricsTracer, sink)
-		select {
-		case sink.ch <- evt:
-		default:
+		if !sink.queue(evt, n.metricsTracer) {
 			n.slowConsumerTimer = emitAndLogError(n.slowConsumerTimer, n.typ, evt, sink)
 		}
 	}
@@ -52,12 +52,39 @@ var (
 		},
 		[]string{"subscriber_name"},
 	)
+	subscriberEventDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "subscriber_event_dropped",
+			Help:      "Event dropped by the overflow policy of the subscriber",
+		},
+		[]string{"subscriber_name"},
+	)
+	subscriberEventFiltered = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "subscriber_event_filtered",
+			Help:      "Event rejected by the filter of the subscriber",
+		},
+		[]string{"subscriber_name"},
+	)
+	subscriberEventReplayed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "subscriber_event_replayed",
+			Help:      "Event replayed to the subscriber",
+		},
+		[]string{"subscriber_name"},
+	)
 	collectors = []prometheus.Collector{
 		eventsEmitted,
 		totalSubscribers,
 		subscriberQueueLength,
 		subscriberQueueFull,
 		subscriberEventQueued,
+		subscriberEventDropped,
+		subscriberEventFiltered,
+		subscriberEventReplayed,
 	}
 )
 
@@ -83,9 +110,27 @@ type MetricsTracer interface {
 	SubscriberEventQueued(name string)
 }
 
+// SubscriptionMetricsTracer tracks the metrics of the subscription options. It
+// is optional: the eventbus uses it if its MetricsTracer implements it.
+type SubscriptionMetricsTracer interface {
+	// SubscriberEventDropped counts the events dropped by the overflow policy
+	// of the subscriber
+	SubscriberEventDropped(name string)
+
+	// SubscriberEventFiltered counts the events rejected by the filter of the
+	// subscriber
+	SubscriberEventFiltered(name string)
+
+	// SubscriberEventReplayed counts the events replayed to the subscriber
+	SubscriberEventReplayed(name string)
+}
+
 type metricsTracer struct{}
 
-var _ MetricsTracer = &metricsTracer{}
+var (
+	_ MetricsTracer             = &metricsTracer{}
+	_ SubscriptionMetricsTracer = &metricsTracer{}
+)
 
 type metricsTracerSetting struct {
 	reg prometheus.Registerer
@@ -162,3 +207,27 @@ func (m *metricsTracer) SubscriberEventQueued(name string) {
 	*tags = append(*tags, name)
 	subscriberEventQueued.WithLabelValues(*tags...).Inc()
 }
+
+func (m *metricsTracer) SubscriberEventDropped(name string) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, name)
+	subscriberEventDropped.WithLabelValues(*tags...).Inc()
+}
+
+func (m *metricsTracer) SubscriberEventFiltered(name string) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, name)
+	subscriberEventFiltered.WithLabelValues(*tags...).Inc()
+}
+
+func (m *metricsTracer) SubscriberEventReplayed(name string) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, name)
+	subscriberEventReplayed.WithLabelValues(*tags...).Inc()
+}
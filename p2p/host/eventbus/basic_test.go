@@ -529,6 +529,106 @@ func TestStateful(t *testing.T) {
 	}
 }
 
+func TestOverflowPolicies(t *testing.T) {
+	bus := NewBus()
+	em, err := bus.Emitter(new(EventB))
+	require.NoError(t, err)
+	defer em.Close()
+
+	oldest, err := bus.Subscribe(new(EventB), BufSize(2), Policy(DropOldest))
+	require.NoError(t, err)
+	defer oldest.Close()
+	newest, err := bus.Subscribe(new(EventB), BufSize(2), Policy(DropNewest))
+	require.NoError(t, err)
+	defer newest.Close()
+
+	// none of the subscribers is consumed, so Emit would block with the default policy
+	for i := 0; i < 5; i++ {
+		require.NoError(t, em.Emit(EventB(i)))
+	}
+
+	require.Equal(t, EventB(3), <-oldest.Out())
+	require.Equal(t, EventB(4), <-oldest.Out())
+	require.Equal(t, EventB(0), <-newest.Out())
+	require.Equal(t, EventB(1), <-newest.Out())
+
+	_, err = bus.Subscribe(new(EventB), BufSize(0), Policy(DropOldest))
+	require.Error(t, err)
+	_, err = bus.Subscribe(new(EventB), Policy(OverflowPolicy(42)))
+	require.Error(t, err)
+}
+
+func TestFilter(t *testing.T) {
+	bus := NewBus()
+	em, err := bus.Emitter(new(EventB))
+	require.NoError(t, err)
+	defer em.Close()
+
+	even := func(evt interface{}) bool { return evt.(EventB)%2 == 0 }
+	sub, err := bus.Subscribe(new(EventB), BufSize(2), Filter(even))
+	require.NoError(t, err)
+	defer sub.Close()
+	wsub, err := bus.Subscribe(event.WildcardSubscription, BufSize(2), Filter(even))
+	require.NoError(t, err)
+	defer wsub.Close()
+
+	// filtered events don't take space in the buffer
+	for i := 0; i < 4; i++ {
+		require.NoError(t, em.Emit(EventB(i)))
+	}
+	for _, s := range []event.Subscription{sub, wsub} {
+		require.Equal(t, EventB(0), <-s.Out())
+		require.Equal(t, EventB(2), <-s.Out())
+		select {
+		case evt := <-s.Out():
+			t.Fatalf("didn't expect an event: %v", evt)
+		default:
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	bus := NewBus()
+	em, err := bus.Emitter(new(EventB), ReplayBuffer(3), Stateful)
+	require.NoError(t, err)
+	defer em.Close()
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, em.Emit(EventB(i)))
+	}
+
+	sub, err := bus.Subscribe(new(EventB), Replay(10))
+	require.NoError(t, err)
+	defer sub.Close()
+	for i := 2; i < 5; i++ {
+		require.Equal(t, EventB(i), <-sub.Out())
+	}
+
+	sub2, err := bus.Subscribe(new(EventB), Replay(2), Filter(func(evt interface{}) bool { return evt.(EventB) != 4 }))
+	require.NoError(t, err)
+	defer sub2.Close()
+	require.Equal(t, EventB(3), <-sub2.Out())
+
+	// without Replay, only the last event of the stateful emitter is delivered
+	sub3, err := bus.Subscribe(new(EventB))
+	require.NoError(t, err)
+	defer sub3.Close()
+	require.Equal(t, EventB(4), <-sub3.Out())
+
+	require.NoError(t, em.Emit(EventB(5)))
+	for _, s := range []event.Subscription{sub, sub2, sub3} {
+		require.Equal(t, EventB(5), <-s.Out())
+		select {
+		case evt := <-s.Out():
+			t.Fatalf("didn't expect an event: %v", evt)
+		default:
+		}
+	}
+
+	_, err = bus.Subscribe(event.WildcardSubscription, Replay(1))
+	require.Error(t, err)
+}
+
 func TestCloseBlocking(t *testing.T) {
 	bus := NewBus()
 	em, err := bus.Emitter(new(EventB))
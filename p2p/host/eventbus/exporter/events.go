@@ -0,0 +1,229 @@
+package exporter
+
+import (
+	"reflect"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus/exporter/pb"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// SchemaVersion is the version of the schema of exported events.
+const SchemaVersion = 1
+
+// Stable names of the exported event types.
+const (
+	TypePeerConnectednessChanged    = "peer_connectedness_changed"
+	TypePeerIdentificationCompleted = "peer_identification_completed"
+	TypePeerIdentificationFailed    = "peer_identification_failed"
+	TypePeerProtocolsUpdated        = "peer_protocols_updated"
+	TypeLocalProtocolsUpdated       = "local_protocols_updated"
+	TypeLocalAddressesUpdated       = "local_addresses_updated"
+	TypeLocalReachabilityChanged    = "local_reachability_changed"
+	TypeHostReachableAddrsChanged   = "host_reachable_addrs_changed"
+	TypeNATDeviceTypeChanged        = "nat_device_type_changed"
+	TypeAutoRelayAddrsUpdated       = "auto_relay_addrs_updated"
+	TypeNATMappingsChanged          = "nat_mappings_changed"
+	TypeRelayGoingAway              = "relay_going_away"
+	TypePortMappingChanged          = "port_mapping_changed"
+)
+
+var typeNames = map[reflect.Type]string{
+	reflect.TypeFor[event.EvtPeerConnectednessChanged]():    TypePeerConnectednessChanged,
+	reflect.TypeFor[event.EvtPeerIdentificationCompleted](): TypePeerIdentificationCompleted,
+	reflect.TypeFor[event.EvtPeerIdentificationFailed]():    TypePeerIdentificationFailed,
+	reflect.TypeFor[event.EvtPeerProtocolsUpdated]():        TypePeerProtocolsUpdated,
+	reflect.TypeFor[event.EvtLocalProtocolsUpdated]():       TypeLocalProtocolsUpdated,
+	reflect.TypeFor[event.EvtLocalAddressesUpdated]():       TypeLocalAddressesUpdated,
+	reflect.TypeFor[event.EvtLocalReachabilityChanged]():    TypeLocalReachabilityChanged,
+	reflect.TypeFor[event.EvtHostReachableAddrsChanged]():   TypeHostReachableAddrsChanged,
+	reflect.TypeFor[event.EvtNATDeviceTypeChanged]():        TypeNATDeviceTypeChanged,
+	reflect.TypeFor[event.EvtAutoRelayAddrsUpdated]():       TypeAutoRelayAddrsUpdated,
+	reflect.TypeFor[event.EvtNATMappingsChanged]():          TypeNATMappingsChanged,
+	reflect.TypeFor[event.EvtRelayGoingAway]():              TypeRelayGoingAway,
+	reflect.TypeFor[event.EvtPortMappingChanged]():          TypePortMappingChanged,
+}
+
+// TypeName returns the stable name of the type of the event, and false if the
+// event can't be exported.
+func TypeName(evt interface{}) (string, bool) {
+	name, ok := typeNames[reflect.TypeOf(evt)]
+	return name, ok
+}
+
+func addrStrings(addrs []ma.Multiaddr) []string {
+	if len(addrs) == 0 {
+		return nil
+	}
+	s := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		s = append(s, a.String())
+	}
+	return s
+}
+
+func addrString(a ma.Multiaddr) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+func protocolStrings(protos []protocol.ID) []string {
+	if len(protos) == 0 {
+		return nil
+	}
+	s := make([]string, 0, len(protos))
+	for _, p := range protos {
+		s = append(s, string(p))
+	}
+	return s
+}
+
+func addrActionString(a event.AddrAction) string {
+	switch a {
+	case event.Added:
+		return "added"
+	case event.Maintained:
+		return "maintained"
+	case event.Removed:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+func updatedAddrs(addrs []event.UpdatedAddress) []*pb.UpdatedAddress {
+	if len(addrs) == 0 {
+		return nil
+	}
+	res := make([]*pb.UpdatedAddress, 0, len(addrs))
+	for _, a := range addrs {
+		res = append(res, &pb.UpdatedAddress{Address: addrString(a.Address), Action: addrActionString(a.Action)})
+	}
+	return res
+}
+
+func natMappings(mappings []network.NATMapping) []*pb.NATMapping {
+	if len(mappings) == 0 {
+		return nil
+	}
+	res := make([]*pb.NATMapping, 0, len(mappings))
+	for _, m := range mappings {
+		res = append(res, &pb.NATMapping{
+			LocalAddr:         addrString(m.LocalAddr),
+			TransportProtocol: m.TransportProtocol.String(),
+			Ipv6:              m.IPv6,
+			DeviceType:        m.DeviceType.String(),
+			Behavior:          m.Behavior.String(),
+			ExternalAddr:      addrString(m.ExternalAddr),
+			Observations:      uint32(m.Observations),
+			Confidence:        m.Confidence,
+			Hairpinning:       m.Hairpinning,
+			Cgnat:             m.CGNAT,
+		})
+	}
+	return res
+}
+
+// toPB converts the event to its exported form, without the header fields.
+func toPB(evt interface{}) (*pb.Event, bool) {
+	name, ok := TypeName(evt)
+	if !ok {
+		return nil, false
+	}
+	res := &pb.Event{Version: SchemaVersion, Type: name}
+	switch e := evt.(type) {
+	case event.EvtPeerConnectednessChanged:
+		res.Payload = &pb.Event_PeerConnectednessChanged{PeerConnectednessChanged: &pb.PeerConnectednessChanged{
+			Peer:          e.Peer.String(),
+			Connectedness: e.Connectedness.String(),
+		}}
+	case event.EvtPeerIdentificationCompleted:
+		res.Payload = &pb.Event_PeerIdentificationCompleted{PeerIdentificationCompleted: &pb.PeerIdentificationCompleted{
+			Peer:            e.Peer.String(),
+			ListenAddrs:     addrStrings(e.ListenAddrs),
+			Protocols:       protocolStrings(e.Protocols),
+			AgentVersion:    e.AgentVersion,
+			ProtocolVersion: e.ProtocolVersion,
+			ObservedAddr:    addrString(e.ObservedAddr),
+		}}
+	case event.EvtPeerIdentificationFailed:
+		var reason string
+		if e.Reason != nil {
+			reason = e.Reason.Error()
+		}
+		res.Payload = &pb.Event_PeerIdentificationFailed{PeerIdentificationFailed: &pb.PeerIdentificationFailed{
+			Peer:   e.Peer.String(),
+			Reason: reason,
+		}}
+	case event.EvtPeerProtocolsUpdated:
+		res.Payload = &pb.Event_PeerProtocolsUpdated{PeerProtocolsUpdated: &pb.PeerProtocolsUpdated{
+			Peer:    e.Peer.String(),
+			Added:   protocolStrings(e.Added),
+			Removed: protocolStrings(e.Removed),
+		}}
+	case event.EvtLocalProtocolsUpdated:
+		res.Payload = &pb.Event_LocalProtocolsUpdated{LocalProtocolsUpdated: &pb.LocalProtocolsUpdated{
+			Added:   protocolStrings(e.Added),
+			Removed: protocolStrings(e.Removed),
+		}}
+	case event.EvtLocalAddressesUpdated:
+		res.Payload = &pb.Event_LocalAddressesUpdated{LocalAddressesUpdated: &pb.LocalAddressesUpdated{
+			Diffs:   e.Diffs,
+			Current: updatedAddrs(e.Current),
+			Removed: updatedAddrs(e.Removed),
+		}}
+	case event.EvtLocalReachabilityChanged:
+		res.Payload = &pb.Event_LocalReachabilityChanged{LocalReachabilityChanged: &pb.LocalReachabilityChanged{
+			Reachability: e.Reachability.String(),
+		}}
+	case event.EvtHostReachableAddrsChanged:
+		res.Payload = &pb.Event_HostReachableAddrsChanged{HostReachableAddrsChanged: &pb.HostReachableAddrsChanged{
+			Reachable:   addrStrings(e.Reachable),
+			Unreachable: addrStrings(e.Unreachable),
+			Unknown:     addrStrings(e.Unknown),
+		}}
+	case event.EvtNATDeviceTypeChanged:
+		res.Payload = &pb.Event_NatDeviceTypeChanged{NatDeviceTypeChanged: &pb.NATDeviceTypeChanged{
+			TransportProtocol: e.TransportProtocol.String(),
+			NatDeviceType:     e.NatDeviceType.String(),
+		}}
+	case event.EvtAutoRelayAddrsUpdated:
+		res.Payload = &pb.Event_AutoRelayAddrsUpdated{AutoRelayAddrsUpdated: &pb.AutoRelayAddrsUpdated{
+			RelayAddrs: addrStrings(e.RelayAddrs),
+		}}
+	case event.EvtNATMappingsChanged:
+		res.Payload = &pb.Event_NatMappingsChanged{NatMappingsChanged: &pb.NATMappingsChanged{
+			Mappings: natMappings(e.Mappings),
+		}}
+	case event.EvtRelayGoingAway:
+		res.Payload = &pb.Event_RelayGoingAway{RelayGoingAway: &pb.RelayGoingAway{
+			Relay:    e.Relay.String(),
+			Downtime: int64(e.Downtime),
+		}}
+	case event.EvtPortMappingChanged:
+		var externalAddr, errStr string
+		if e.ExternalAddr.IsValid() {
+			externalAddr = e.ExternalAddr.String()
+		}
+		if e.Error != nil {
+			errStr = e.Error.Error()
+		}
+		res.Payload = &pb.Event_PortMappingChanged{PortMappingChanged: &pb.PortMappingChanged{
+			Change:       e.Change.String(),
+			GatewayType:  e.GatewayType,
+			Protocol:     e.Protocol,
+			InternalPort: uint32(e.InternalPort),
+			ExternalAddr: externalAddr,
+			Lease:        int64(e.Lease),
+			Error:        errStr,
+		}}
+	default:
+		return nil, false
+	}
+	return res, true
+}
@@ -0,0 +1,146 @@
+// Package exporter streams the events of an event bus to external sinks, for
+// consumption by observability pipelines.
+//
+// Events are converted to the versioned schema defined in pb/exporter.proto,
+// and identified by stable type names that don't depend on Go type names.
+// Events of types that aren't part of the schema are not exported.
+package exporter
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("eventbus-exporter")
+
+const defaultBufSize = 256
+
+// Exporter subscribes to all events of a bus, and writes them to a sink.
+//
+// Exporting never blocks the emitters: when the sink doesn't keep up, the
+// oldest pending events are dropped.
+type Exporter struct {
+	sink    Sink
+	bufSize int
+	types   map[string]struct{}
+	now     func() time.Time
+
+	sub event.Subscription
+	seq uint64
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	done      chan struct{}
+}
+
+// Option configures an Exporter.
+type Option func(*Exporter) error
+
+// WithBufferSize sets the number of events that are queued for the sink.
+func WithBufferSize(n int) Option {
+	return func(e *Exporter) error {
+		if n <= 0 {
+			return errors.New("buffer size must be positive")
+		}
+		e.bufSize = n
+		return nil
+	}
+}
+
+// WithEventTypes restricts the exported events to the given types, see the
+// Type constants.
+func WithEventTypes(types ...string) Option {
+	return func(e *Exporter) error {
+		e.types = make(map[string]struct{}, len(types))
+		for _, t := range types {
+			e.types[t] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// New creates an Exporter writing the events of bus to sink. The sink is
+// closed when the Exporter is closed.
+func New(bus event.Bus, sink Sink, opts ...Option) (*Exporter, error) {
+	e := &Exporter{
+		sink:    sink,
+		bufSize: defaultBufSize,
+		now:     time.Now,
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		if err := opt(e); err != nil {
+			return nil, err
+		}
+	}
+
+	sub, err := bus.Subscribe(event.WildcardSubscription,
+		eventbus.Name("exporter"),
+		eventbus.BufSize(e.bufSize),
+		eventbus.Policy(eventbus.DropOldest),
+		eventbus.Filter(e.exported),
+	)
+	if err != nil {
+		return nil, err
+	}
+	e.sub = sub
+
+	go e.loop()
+	return e, nil
+}
+
+func (e *Exporter) exported(evt interface{}) bool {
+	name, ok := TypeName(evt)
+	if !ok {
+		return false
+	}
+	if e.types != nil {
+		_, ok = e.types[name]
+	}
+	return ok
+}
+
+func (e *Exporter) loop() {
+	defer close(e.done)
+
+	for {
+		select {
+		case evt := <-e.sub.Out():
+			e.export(evt)
+		case <-e.closing:
+			return
+		}
+	}
+}
+
+func (e *Exporter) export(evt interface{}) {
+	pbEvt, ok := toPB(evt)
+	if !ok {
+		return
+	}
+	e.seq++
+	pbEvt.Seq = e.seq
+	pbEvt.Timestamp = e.now().UnixNano()
+	if err := e.sink.WriteEvent(pbEvt); err != nil {
+		log.Debugw("failed to export event", "type", pbEvt.Type, "error", err)
+	}
+}
+
+// Close stops exporting events and closes the sink.
+func (e *Exporter) Close() error {
+	var err error
+	e.closeOnce.Do(func() {
+		close(e.closing)
+		<-e.done
+		e.sub.Close()
+		err = e.sink.Close()
+	})
+	return err
+}
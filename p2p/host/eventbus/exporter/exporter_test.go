@@ -0,0 +1,245 @@
+package exporter
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus/exporter/pb"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+type chanSink chan *pb.Event
+
+func (s chanSink) WriteEvent(evt *pb.Event) error {
+	s <- evt
+	return nil
+}
+
+func (s chanSink) Close() error { return nil }
+
+type unknownEvent struct{}
+
+func emit(t *testing.T, bus event.Bus, evt interface{}) {
+	t.Helper()
+	em, err := bus.Emitter(reflect.New(reflect.TypeOf(evt)).Interface())
+	require.NoError(t, err)
+	defer em.Close()
+	require.NoError(t, em.Emit(evt))
+}
+
+func TestExporter(t *testing.T) {
+	bus := eventbus.NewBus()
+	sink := make(chanSink, 10)
+	e, err := New(bus, sink)
+	require.NoError(t, err)
+	defer e.Close()
+
+	emit(t, bus, unknownEvent{})
+	emit(t, bus, event.EvtPeerConnectednessChanged{Peer: peer.ID("foo"), Connectedness: network.Connected})
+	emit(t, bus, event.EvtLocalAddressesUpdated{
+		Diffs:   true,
+		Current: []event.UpdatedAddress{{Address: ma.StringCast("/ip4/1.2.3.4/tcp/1"), Action: event.Added}},
+	})
+
+	evt := <-sink
+	require.Equal(t, uint32(SchemaVersion), evt.Version)
+	require.Equal(t, TypePeerConnectednessChanged, evt.Type)
+	require.Equal(t, uint64(1), evt.Seq)
+	require.NotZero(t, evt.Timestamp)
+	require.Equal(t, peer.ID("foo").String(), evt.GetPeerConnectednessChanged().Peer)
+	require.Equal(t, "Connected", evt.GetPeerConnectednessChanged().Connectedness)
+
+	evt = <-sink
+	require.Equal(t, TypeLocalAddressesUpdated, evt.Type)
+	require.Equal(t, uint64(2), evt.Seq)
+	require.Equal(t, []*pb.UpdatedAddress{{Address: "/ip4/1.2.3.4/tcp/1", Action: "added"}}, evt.GetLocalAddressesUpdated().Current)
+}
+
+func TestExporterEventTypes(t *testing.T) {
+	bus := eventbus.NewBus()
+	sink := make(chanSink, 10)
+	e, err := New(bus, sink, WithEventTypes(TypeLocalReachabilityChanged))
+	require.NoError(t, err)
+	defer e.Close()
+
+	emit(t, bus, event.EvtPeerConnectednessChanged{Peer: peer.ID("foo"), Connectedness: network.Connected})
+	emit(t, bus, event.EvtLocalReachabilityChanged{Reachability: network.ReachabilityPublic})
+
+	evt := <-sink
+	require.Equal(t, TypeLocalReachabilityChanged, evt.Type)
+	require.Equal(t, "Public", evt.GetLocalReachabilityChanged().Reachability)
+}
+
+func TestExporterCloseClosesSink(t *testing.T) {
+	bus := eventbus.NewBus()
+	path := filepath.Join(t.TempDir(), "events")
+	sink, err := NewFileSink(path, JSON)
+	require.NoError(t, err)
+	e, err := New(bus, sink)
+	require.NoError(t, err)
+	require.NoError(t, e.Close())
+	require.Error(t, sink.WriteEvent(&pb.Event{}))
+}
+
+// allEventTypes are the event types defined in core/event. New event types
+// must be added here, and to the exporter.
+var allEventTypes = []interface{}{
+	event.EvtLocalAddressesUpdated{},
+	event.EvtAutoRelayAddrsUpdated{},
+	event.EvtPeerIdentificationCompleted{},
+	event.EvtPeerIdentificationFailed{},
+	event.EvtNATDeviceTypeChanged{},
+	event.EvtNATMappingsChanged{},
+	event.EvtPeerConnectednessChanged{},
+	event.EvtPortMappingChanged{},
+	event.EvtPeerProtocolsUpdated{},
+	event.EvtLocalProtocolsUpdated{},
+	event.EvtLocalReachabilityChanged{},
+	event.EvtHostReachableAddrsChanged{},
+	event.EvtRelayGoingAway{},
+}
+
+func TestTypeNamesComplete(t *testing.T) {
+	for typ := range typeNames {
+		evt, ok := toPB(reflect.New(typ).Elem().Interface())
+		require.True(t, ok, typ)
+		require.NotNil(t, evt.Payload, typ)
+	}
+
+	require.Len(t, typeNames, len(allEventTypes))
+	for _, e := range allEventTypes {
+		typ := reflect.TypeOf(e)
+		require.Contains(t, typeNames, typ, "%s can't be exported", typ)
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	evt, ok := toPB(event.EvtNATDeviceTypeChanged{TransportProtocol: network.NATTransportUDP, NatDeviceType: network.NATDeviceTypeSymmetric})
+	require.True(t, ok)
+	evt.Seq = 7
+
+	b, err := JSON.Encode(evt)
+	require.NoError(t, err)
+	require.True(t, bytes.HasSuffix(b, []byte("\n")))
+	require.Equal(t, 1, bytes.Count(b, []byte("\n")))
+	require.Contains(t, string(b), `"nat_device_type_changed"`)
+	var decoded pb.Event
+	require.NoError(t, protojson.Unmarshal(b, &decoded))
+	require.True(t, proto.Equal(evt, &decoded))
+
+	b, err = Protobuf.Encode(evt)
+	require.NoError(t, err)
+	decoded.Reset()
+	require.NoError(t, protodelim.UnmarshalFrom(bytes.NewReader(b), &decoded))
+	require.True(t, proto.Equal(evt, &decoded))
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events")
+	for i := 0; i < 2; i++ {
+		sink, err := NewFileSink(path, Protobuf)
+		require.NoError(t, err)
+		require.NoError(t, sink.WriteEvent(&pb.Event{Seq: uint64(i)}))
+		require.NoError(t, sink.Close())
+	}
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for i := 0; i < 2; i++ {
+		var evt pb.Event
+		require.NoError(t, protodelim.UnmarshalFrom(r, &evt))
+		require.Equal(t, uint64(i), evt.Seq)
+	}
+}
+
+func TestUnixSocketSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sock")
+	sink := NewUnixSocketSink(path, JSON)
+	defer sink.Close()
+
+	// nobody is listening yet
+	require.Error(t, sink.WriteEvent(&pb.Event{Seq: 1}))
+
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer l.Close()
+
+	require.NoError(t, sink.WriteEvent(&pb.Event{Seq: 2}))
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	require.NoError(t, err)
+	var evt pb.Event
+	require.NoError(t, protojson.Unmarshal(line, &evt))
+	require.Equal(t, uint64(2), evt.Seq)
+
+	require.NoError(t, sink.Close())
+	require.ErrorIs(t, sink.WriteEvent(&pb.Event{}), net.ErrClosed)
+}
+
+func TestSSEHandler(t *testing.T) {
+	h := NewSSEHandler()
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// the client is registered before the headers are sent
+	require.NoError(t, h.WriteEvent(&pb.Event{Seq: 3, Type: TypeLocalReachabilityChanged}))
+
+	r := bufio.NewReader(resp.Body)
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		if line == "\n" {
+			break
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	require.Len(t, lines, 3)
+	require.Equal(t, "id: 3", lines[0])
+	require.Equal(t, "event: "+TypeLocalReachabilityChanged, lines[1])
+	require.True(t, strings.HasPrefix(lines[2], "data: "))
+	var evt pb.Event
+	require.NoError(t, protojson.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &evt))
+	require.Equal(t, uint64(3), evt.Seq)
+
+	// closing the handler ends the stream
+	require.NoError(t, h.Close())
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.ReadString('\n')
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not closed")
+	}
+}
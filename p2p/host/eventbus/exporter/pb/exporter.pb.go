@@ -0,0 +1,1431 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.2
+// source: p2p/host/eventbus/exporter/pb/exporter.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Event is an event of the event bus, as exported to external sinks.
+//
+// Fields may be added in future versions of the schema, but existing fields
+// are never renumbered or repurposed. Incompatible changes bump the version.
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The version of the schema.
+	Version uint32 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	// The stable name of the event type, e.g. "peer_connectedness_changed".
+	Type string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	// The time the event was exported, in Unix nanoseconds.
+	Timestamp int64 `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// The sequence number of the event. It increases by one for every
+	// exported event, and restarts from 1 when the exporter is restarted.
+	Seq uint64 `protobuf:"varint,4,opt,name=seq,proto3" json:"seq,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*Event_PeerConnectednessChanged
+	//	*Event_PeerIdentificationCompleted
+	//	*Event_PeerIdentificationFailed
+	//	*Event_PeerProtocolsUpdated
+	//	*Event_LocalProtocolsUpdated
+	//	*Event_LocalAddressesUpdated
+	//	*Event_LocalReachabilityChanged
+	//	*Event_HostReachableAddrsChanged
+	//	*Event_NatDeviceTypeChanged
+	//	*Event_AutoRelayAddrsUpdated
+	//	*Event_NatMappingsChanged
+	//	*Event_RelayGoingAway
+	//	*Event_PortMappingChanged
+	Payload       isEvent_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Event) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Event) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Event) GetPayload() isEvent_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Event) GetPeerConnectednessChanged() *PeerConnectednessChanged {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_PeerConnectednessChanged); ok {
+			return x.PeerConnectednessChanged
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetPeerIdentificationCompleted() *PeerIdentificationCompleted {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_PeerIdentificationCompleted); ok {
+			return x.PeerIdentificationCompleted
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetPeerIdentificationFailed() *PeerIdentificationFailed {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_PeerIdentificationFailed); ok {
+			return x.PeerIdentificationFailed
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetPeerProtocolsUpdated() *PeerProtocolsUpdated {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_PeerProtocolsUpdated); ok {
+			return x.PeerProtocolsUpdated
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetLocalProtocolsUpdated() *LocalProtocolsUpdated {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_LocalProtocolsUpdated); ok {
+			return x.LocalProtocolsUpdated
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetLocalAddressesUpdated() *LocalAddressesUpdated {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_LocalAddressesUpdated); ok {
+			return x.LocalAddressesUpdated
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetLocalReachabilityChanged() *LocalReachabilityChanged {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_LocalReachabilityChanged); ok {
+			return x.LocalReachabilityChanged
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetHostReachableAddrsChanged() *HostReachableAddrsChanged {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_HostReachableAddrsChanged); ok {
+			return x.HostReachableAddrsChanged
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetNatDeviceTypeChanged() *NATDeviceTypeChanged {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_NatDeviceTypeChanged); ok {
+			return x.NatDeviceTypeChanged
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetAutoRelayAddrsUpdated() *AutoRelayAddrsUpdated {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_AutoRelayAddrsUpdated); ok {
+			return x.AutoRelayAddrsUpdated
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetNatMappingsChanged() *NATMappingsChanged {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_NatMappingsChanged); ok {
+			return x.NatMappingsChanged
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetRelayGoingAway() *RelayGoingAway {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_RelayGoingAway); ok {
+			return x.RelayGoingAway
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetPortMappingChanged() *PortMappingChanged {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_PortMappingChanged); ok {
+			return x.PortMappingChanged
+		}
+	}
+	return nil
+}
+
+type isEvent_Payload interface {
+	isEvent_Payload()
+}
+
+type Event_PeerConnectednessChanged struct {
+	PeerConnectednessChanged *PeerConnectednessChanged `protobuf:"bytes,10,opt,name=peer_connectedness_changed,json=peerConnectednessChanged,proto3,oneof"`
+}
+
+type Event_PeerIdentificationCompleted struct {
+	PeerIdentificationCompleted *PeerIdentificationCompleted `protobuf:"bytes,11,opt,name=peer_identification_completed,json=peerIdentificationCompleted,proto3,oneof"`
+}
+
+type Event_PeerIdentificationFailed struct {
+	PeerIdentificationFailed *PeerIdentificationFailed `protobuf:"bytes,12,opt,name=peer_identification_failed,json=peerIdentificationFailed,proto3,oneof"`
+}
+
+type Event_PeerProtocolsUpdated struct {
+	PeerProtocolsUpdated *PeerProtocolsUpdated `protobuf:"bytes,13,opt,name=peer_protocols_updated,json=peerProtocolsUpdated,proto3,oneof"`
+}
+
+type Event_LocalProtocolsUpdated struct {
+	LocalProtocolsUpdated *LocalProtocolsUpdated `protobuf:"bytes,14,opt,name=local_protocols_updated,json=localProtocolsUpdated,proto3,oneof"`
+}
+
+type Event_LocalAddressesUpdated struct {
+	LocalAddressesUpdated *LocalAddressesUpdated `protobuf:"bytes,15,opt,name=local_addresses_updated,json=localAddressesUpdated,proto3,oneof"`
+}
+
+type Event_LocalReachabilityChanged struct {
+	LocalReachabilityChanged *LocalReachabilityChanged `protobuf:"bytes,16,opt,name=local_reachability_changed,json=localReachabilityChanged,proto3,oneof"`
+}
+
+type Event_HostReachableAddrsChanged struct {
+	HostReachableAddrsChanged *HostReachableAddrsChanged `protobuf:"bytes,17,opt,name=host_reachable_addrs_changed,json=hostReachableAddrsChanged,proto3,oneof"`
+}
+
+type Event_NatDeviceTypeChanged struct {
+	NatDeviceTypeChanged *NATDeviceTypeChanged `protobuf:"bytes,18,opt,name=nat_device_type_changed,json=natDeviceTypeChanged,proto3,oneof"`
+}
+
+type Event_AutoRelayAddrsUpdated struct {
+	AutoRelayAddrsUpdated *AutoRelayAddrsUpdated `protobuf:"bytes,19,opt,name=auto_relay_addrs_updated,json=autoRelayAddrsUpdated,proto3,oneof"`
+}
+
+type Event_NatMappingsChanged struct {
+	NatMappingsChanged *NATMappingsChanged `protobuf:"bytes,20,opt,name=nat_mappings_changed,json=natMappingsChanged,proto3,oneof"`
+}
+
+type Event_RelayGoingAway struct {
+	RelayGoingAway *RelayGoingAway `protobuf:"bytes,21,opt,name=relay_going_away,json=relayGoingAway,proto3,oneof"`
+}
+
+type Event_PortMappingChanged struct {
+	PortMappingChanged *PortMappingChanged `protobuf:"bytes,22,opt,name=port_mapping_changed,json=portMappingChanged,proto3,oneof"`
+}
+
+func (*Event_PeerConnectednessChanged) isEvent_Payload() {}
+
+func (*Event_PeerIdentificationCompleted) isEvent_Payload() {}
+
+func (*Event_PeerIdentificationFailed) isEvent_Payload() {}
+
+func (*Event_PeerProtocolsUpdated) isEvent_Payload() {}
+
+func (*Event_LocalProtocolsUpdated) isEvent_Payload() {}
+
+func (*Event_LocalAddressesUpdated) isEvent_Payload() {}
+
+func (*Event_LocalReachabilityChanged) isEvent_Payload() {}
+
+func (*Event_HostReachableAddrsChanged) isEvent_Payload() {}
+
+func (*Event_NatDeviceTypeChanged) isEvent_Payload() {}
+
+func (*Event_AutoRelayAddrsUpdated) isEvent_Payload() {}
+
+func (*Event_NatMappingsChanged) isEvent_Payload() {}
+
+func (*Event_RelayGoingAway) isEvent_Payload() {}
+
+func (*Event_PortMappingChanged) isEvent_Payload() {}
+
+type PeerConnectednessChanged struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Peer          string                 `protobuf:"bytes,1,opt,name=peer,proto3" json:"peer,omitempty"`
+	Connectedness string                 `protobuf:"bytes,2,opt,name=connectedness,proto3" json:"connectedness,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeerConnectednessChanged) Reset() {
+	*x = PeerConnectednessChanged{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerConnectednessChanged) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerConnectednessChanged) ProtoMessage() {}
+
+func (x *PeerConnectednessChanged) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerConnectednessChanged.ProtoReflect.Descriptor instead.
+func (*PeerConnectednessChanged) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PeerConnectednessChanged) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *PeerConnectednessChanged) GetConnectedness() string {
+	if x != nil {
+		return x.Connectedness
+	}
+	return ""
+}
+
+type PeerIdentificationCompleted struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Peer            string                 `protobuf:"bytes,1,opt,name=peer,proto3" json:"peer,omitempty"`
+	ListenAddrs     []string               `protobuf:"bytes,2,rep,name=listen_addrs,json=listenAddrs,proto3" json:"listen_addrs,omitempty"`
+	Protocols       []string               `protobuf:"bytes,3,rep,name=protocols,proto3" json:"protocols,omitempty"`
+	AgentVersion    string                 `protobuf:"bytes,4,opt,name=agent_version,json=agentVersion,proto3" json:"agent_version,omitempty"`
+	ProtocolVersion string                 `protobuf:"bytes,5,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	ObservedAddr    string                 `protobuf:"bytes,6,opt,name=observed_addr,json=observedAddr,proto3" json:"observed_addr,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *PeerIdentificationCompleted) Reset() {
+	*x = PeerIdentificationCompleted{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerIdentificationCompleted) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerIdentificationCompleted) ProtoMessage() {}
+
+func (x *PeerIdentificationCompleted) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerIdentificationCompleted.ProtoReflect.Descriptor instead.
+func (*PeerIdentificationCompleted) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PeerIdentificationCompleted) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *PeerIdentificationCompleted) GetListenAddrs() []string {
+	if x != nil {
+		return x.ListenAddrs
+	}
+	return nil
+}
+
+func (x *PeerIdentificationCompleted) GetProtocols() []string {
+	if x != nil {
+		return x.Protocols
+	}
+	return nil
+}
+
+func (x *PeerIdentificationCompleted) GetAgentVersion() string {
+	if x != nil {
+		return x.AgentVersion
+	}
+	return ""
+}
+
+func (x *PeerIdentificationCompleted) GetProtocolVersion() string {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return ""
+}
+
+func (x *PeerIdentificationCompleted) GetObservedAddr() string {
+	if x != nil {
+		return x.ObservedAddr
+	}
+	return ""
+}
+
+type PeerIdentificationFailed struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Peer          string                 `protobuf:"bytes,1,opt,name=peer,proto3" json:"peer,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeerIdentificationFailed) Reset() {
+	*x = PeerIdentificationFailed{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerIdentificationFailed) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerIdentificationFailed) ProtoMessage() {}
+
+func (x *PeerIdentificationFailed) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerIdentificationFailed.ProtoReflect.Descriptor instead.
+func (*PeerIdentificationFailed) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PeerIdentificationFailed) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *PeerIdentificationFailed) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type PeerProtocolsUpdated struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Peer          string                 `protobuf:"bytes,1,opt,name=peer,proto3" json:"peer,omitempty"`
+	Added         []string               `protobuf:"bytes,2,rep,name=added,proto3" json:"added,omitempty"`
+	Removed       []string               `protobuf:"bytes,3,rep,name=removed,proto3" json:"removed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeerProtocolsUpdated) Reset() {
+	*x = PeerProtocolsUpdated{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerProtocolsUpdated) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerProtocolsUpdated) ProtoMessage() {}
+
+func (x *PeerProtocolsUpdated) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerProtocolsUpdated.ProtoReflect.Descriptor instead.
+func (*PeerProtocolsUpdated) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PeerProtocolsUpdated) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *PeerProtocolsUpdated) GetAdded() []string {
+	if x != nil {
+		return x.Added
+	}
+	return nil
+}
+
+func (x *PeerProtocolsUpdated) GetRemoved() []string {
+	if x != nil {
+		return x.Removed
+	}
+	return nil
+}
+
+type LocalProtocolsUpdated struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Added         []string               `protobuf:"bytes,1,rep,name=added,proto3" json:"added,omitempty"`
+	Removed       []string               `protobuf:"bytes,2,rep,name=removed,proto3" json:"removed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LocalProtocolsUpdated) Reset() {
+	*x = LocalProtocolsUpdated{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LocalProtocolsUpdated) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LocalProtocolsUpdated) ProtoMessage() {}
+
+func (x *LocalProtocolsUpdated) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LocalProtocolsUpdated.ProtoReflect.Descriptor instead.
+func (*LocalProtocolsUpdated) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *LocalProtocolsUpdated) GetAdded() []string {
+	if x != nil {
+		return x.Added
+	}
+	return nil
+}
+
+func (x *LocalProtocolsUpdated) GetRemoved() []string {
+	if x != nil {
+		return x.Removed
+	}
+	return nil
+}
+
+type UpdatedAddress struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Address string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	// One of "unknown", "added", "maintained" or "removed".
+	Action        string `protobuf:"bytes,2,opt,name=action,proto3" json:"action,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdatedAddress) Reset() {
+	*x = UpdatedAddress{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdatedAddress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdatedAddress) ProtoMessage() {}
+
+func (x *UpdatedAddress) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdatedAddress.ProtoReflect.Descriptor instead.
+func (*UpdatedAddress) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *UpdatedAddress) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *UpdatedAddress) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+type LocalAddressesUpdated struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Diffs         bool                   `protobuf:"varint,1,opt,name=diffs,proto3" json:"diffs,omitempty"`
+	Current       []*UpdatedAddress      `protobuf:"bytes,2,rep,name=current,proto3" json:"current,omitempty"`
+	Removed       []*UpdatedAddress      `protobuf:"bytes,3,rep,name=removed,proto3" json:"removed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LocalAddressesUpdated) Reset() {
+	*x = LocalAddressesUpdated{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LocalAddressesUpdated) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LocalAddressesUpdated) ProtoMessage() {}
+
+func (x *LocalAddressesUpdated) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LocalAddressesUpdated.ProtoReflect.Descriptor instead.
+func (*LocalAddressesUpdated) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *LocalAddressesUpdated) GetDiffs() bool {
+	if x != nil {
+		return x.Diffs
+	}
+	return false
+}
+
+func (x *LocalAddressesUpdated) GetCurrent() []*UpdatedAddress {
+	if x != nil {
+		return x.Current
+	}
+	return nil
+}
+
+func (x *LocalAddressesUpdated) GetRemoved() []*UpdatedAddress {
+	if x != nil {
+		return x.Removed
+	}
+	return nil
+}
+
+type LocalReachabilityChanged struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reachability  string                 `protobuf:"bytes,1,opt,name=reachability,proto3" json:"reachability,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LocalReachabilityChanged) Reset() {
+	*x = LocalReachabilityChanged{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LocalReachabilityChanged) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LocalReachabilityChanged) ProtoMessage() {}
+
+func (x *LocalReachabilityChanged) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LocalReachabilityChanged.ProtoReflect.Descriptor instead.
+func (*LocalReachabilityChanged) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *LocalReachabilityChanged) GetReachability() string {
+	if x != nil {
+		return x.Reachability
+	}
+	return ""
+}
+
+type HostReachableAddrsChanged struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reachable     []string               `protobuf:"bytes,1,rep,name=reachable,proto3" json:"reachable,omitempty"`
+	Unreachable   []string               `protobuf:"bytes,2,rep,name=unreachable,proto3" json:"unreachable,omitempty"`
+	Unknown       []string               `protobuf:"bytes,3,rep,name=unknown,proto3" json:"unknown,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HostReachableAddrsChanged) Reset() {
+	*x = HostReachableAddrsChanged{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HostReachableAddrsChanged) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HostReachableAddrsChanged) ProtoMessage() {}
+
+func (x *HostReachableAddrsChanged) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HostReachableAddrsChanged.ProtoReflect.Descriptor instead.
+func (*HostReachableAddrsChanged) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *HostReachableAddrsChanged) GetReachable() []string {
+	if x != nil {
+		return x.Reachable
+	}
+	return nil
+}
+
+func (x *HostReachableAddrsChanged) GetUnreachable() []string {
+	if x != nil {
+		return x.Unreachable
+	}
+	return nil
+}
+
+func (x *HostReachableAddrsChanged) GetUnknown() []string {
+	if x != nil {
+		return x.Unknown
+	}
+	return nil
+}
+
+type NATDeviceTypeChanged struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	TransportProtocol string                 `protobuf:"bytes,1,opt,name=transport_protocol,json=transportProtocol,proto3" json:"transport_protocol,omitempty"`
+	NatDeviceType     string                 `protobuf:"bytes,2,opt,name=nat_device_type,json=natDeviceType,proto3" json:"nat_device_type,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *NATDeviceTypeChanged) Reset() {
+	*x = NATDeviceTypeChanged{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NATDeviceTypeChanged) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NATDeviceTypeChanged) ProtoMessage() {}
+
+func (x *NATDeviceTypeChanged) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NATDeviceTypeChanged.ProtoReflect.Descriptor instead.
+func (*NATDeviceTypeChanged) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *NATDeviceTypeChanged) GetTransportProtocol() string {
+	if x != nil {
+		return x.TransportProtocol
+	}
+	return ""
+}
+
+func (x *NATDeviceTypeChanged) GetNatDeviceType() string {
+	if x != nil {
+		return x.NatDeviceType
+	}
+	return ""
+}
+
+type AutoRelayAddrsUpdated struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RelayAddrs    []string               `protobuf:"bytes,1,rep,name=relay_addrs,json=relayAddrs,proto3" json:"relay_addrs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AutoRelayAddrsUpdated) Reset() {
+	*x = AutoRelayAddrsUpdated{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AutoRelayAddrsUpdated) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AutoRelayAddrsUpdated) ProtoMessage() {}
+
+func (x *AutoRelayAddrsUpdated) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AutoRelayAddrsUpdated.ProtoReflect.Descriptor instead.
+func (*AutoRelayAddrsUpdated) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *AutoRelayAddrsUpdated) GetRelayAddrs() []string {
+	if x != nil {
+		return x.RelayAddrs
+	}
+	return nil
+}
+
+type NATMapping struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	LocalAddr         string                 `protobuf:"bytes,1,opt,name=local_addr,json=localAddr,proto3" json:"local_addr,omitempty"`
+	TransportProtocol string                 `protobuf:"bytes,2,opt,name=transport_protocol,json=transportProtocol,proto3" json:"transport_protocol,omitempty"`
+	Ipv6              bool                   `protobuf:"varint,3,opt,name=ipv6,proto3" json:"ipv6,omitempty"`
+	DeviceType        string                 `protobuf:"bytes,4,opt,name=device_type,json=deviceType,proto3" json:"device_type,omitempty"`
+	Behavior          string                 `protobuf:"bytes,5,opt,name=behavior,proto3" json:"behavior,omitempty"`
+	ExternalAddr      string                 `protobuf:"bytes,6,opt,name=external_addr,json=externalAddr,proto3" json:"external_addr,omitempty"`
+	Observations      uint32                 `protobuf:"varint,7,opt,name=observations,proto3" json:"observations,omitempty"`
+	Confidence        float64                `protobuf:"fixed64,8,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	Hairpinning       bool                   `protobuf:"varint,9,opt,name=hairpinning,proto3" json:"hairpinning,omitempty"`
+	Cgnat             bool                   `protobuf:"varint,10,opt,name=cgnat,proto3" json:"cgnat,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *NATMapping) Reset() {
+	*x = NATMapping{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NATMapping) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NATMapping) ProtoMessage() {}
+
+func (x *NATMapping) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NATMapping.ProtoReflect.Descriptor instead.
+func (*NATMapping) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *NATMapping) GetLocalAddr() string {
+	if x != nil {
+		return x.LocalAddr
+	}
+	return ""
+}
+
+func (x *NATMapping) GetTransportProtocol() string {
+	if x != nil {
+		return x.TransportProtocol
+	}
+	return ""
+}
+
+func (x *NATMapping) GetIpv6() bool {
+	if x != nil {
+		return x.Ipv6
+	}
+	return false
+}
+
+func (x *NATMapping) GetDeviceType() string {
+	if x != nil {
+		return x.DeviceType
+	}
+	return ""
+}
+
+func (x *NATMapping) GetBehavior() string {
+	if x != nil {
+		return x.Behavior
+	}
+	return ""
+}
+
+func (x *NATMapping) GetExternalAddr() string {
+	if x != nil {
+		return x.ExternalAddr
+	}
+	return ""
+}
+
+func (x *NATMapping) GetObservations() uint32 {
+	if x != nil {
+		return x.Observations
+	}
+	return 0
+}
+
+func (x *NATMapping) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *NATMapping) GetHairpinning() bool {
+	if x != nil {
+		return x.Hairpinning
+	}
+	return false
+}
+
+func (x *NATMapping) GetCgnat() bool {
+	if x != nil {
+		return x.Cgnat
+	}
+	return false
+}
+
+type NATMappingsChanged struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Mappings      []*NATMapping          `protobuf:"bytes,1,rep,name=mappings,proto3" json:"mappings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NATMappingsChanged) Reset() {
+	*x = NATMappingsChanged{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NATMappingsChanged) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NATMappingsChanged) ProtoMessage() {}
+
+func (x *NATMappingsChanged) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NATMappingsChanged.ProtoReflect.Descriptor instead.
+func (*NATMappingsChanged) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *NATMappingsChanged) GetMappings() []*NATMapping {
+	if x != nil {
+		return x.Mappings
+	}
+	return nil
+}
+
+type RelayGoingAway struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Relay string                 `protobuf:"bytes,1,opt,name=relay,proto3" json:"relay,omitempty"`
+	// The expected downtime of the relay in nanoseconds, 0 if unknown.
+	Downtime      int64 `protobuf:"varint,2,opt,name=downtime,proto3" json:"downtime,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RelayGoingAway) Reset() {
+	*x = RelayGoingAway{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RelayGoingAway) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RelayGoingAway) ProtoMessage() {}
+
+func (x *RelayGoingAway) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RelayGoingAway.ProtoReflect.Descriptor instead.
+func (*RelayGoingAway) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *RelayGoingAway) GetRelay() string {
+	if x != nil {
+		return x.Relay
+	}
+	return ""
+}
+
+func (x *RelayGoingAway) GetDowntime() int64 {
+	if x != nil {
+		return x.Downtime
+	}
+	return 0
+}
+
+type PortMappingChanged struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One of "created", "renewed", "failed" or "lost".
+	Change       string `protobuf:"bytes,1,opt,name=change,proto3" json:"change,omitempty"`
+	GatewayType  string `protobuf:"bytes,2,opt,name=gateway_type,json=gatewayType,proto3" json:"gateway_type,omitempty"`
+	Protocol     string `protobuf:"bytes,3,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	InternalPort uint32 `protobuf:"varint,4,opt,name=internal_port,json=internalPort,proto3" json:"internal_port,omitempty"`
+	ExternalAddr string `protobuf:"bytes,5,opt,name=external_addr,json=externalAddr,proto3" json:"external_addr,omitempty"`
+	// The lifetime of the mapping in nanoseconds.
+	Lease         int64  `protobuf:"varint,6,opt,name=lease,proto3" json:"lease,omitempty"`
+	Error         string `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PortMappingChanged) Reset() {
+	*x = PortMappingChanged{}
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PortMappingChanged) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PortMappingChanged) ProtoMessage() {}
+
+func (x *PortMappingChanged) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PortMappingChanged.ProtoReflect.Descriptor instead.
+func (*PortMappingChanged) Descriptor() ([]byte, []int) {
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *PortMappingChanged) GetChange() string {
+	if x != nil {
+		return x.Change
+	}
+	return ""
+}
+
+func (x *PortMappingChanged) GetGatewayType() string {
+	if x != nil {
+		return x.GatewayType
+	}
+	return ""
+}
+
+func (x *PortMappingChanged) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+func (x *PortMappingChanged) GetInternalPort() uint32 {
+	if x != nil {
+		return x.InternalPort
+	}
+	return 0
+}
+
+func (x *PortMappingChanged) GetExternalAddr() string {
+	if x != nil {
+		return x.ExternalAddr
+	}
+	return ""
+}
+
+func (x *PortMappingChanged) GetLease() int64 {
+	if x != nil {
+		return x.Lease
+	}
+	return 0
+}
+
+func (x *PortMappingChanged) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+var File_p2p_host_eventbus_exporter_pb_exporter_proto protoreflect.FileDescriptor
+
+const file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDesc = "" +
+	"\n" +
+	",p2p/host/eventbus/exporter/pb/exporter.proto\x12\vexporter.pb\"\xc5\n" +
+	"\n" +
+	"\x05Event\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\rR\aversion\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x1c\n" +
+	"\ttimestamp\x18\x03 \x01(\x03R\ttimestamp\x12\x10\n" +
+	"\x03seq\x18\x04 \x01(\x04R\x03seq\x12e\n" +
+	"\x1apeer_connectedness_changed\x18\n" +
+	" \x01(\v2%.exporter.pb.PeerConnectednessChangedH\x00R\x18peerConnectednessChanged\x12n\n" +
+	"\x1dpeer_identification_completed\x18\v \x01(\v2(.exporter.pb.PeerIdentificationCompletedH\x00R\x1bpeerIdentificationCompleted\x12e\n" +
+	"\x1apeer_identification_failed\x18\f \x01(\v2%.exporter.pb.PeerIdentificationFailedH\x00R\x18peerIdentificationFailed\x12Y\n" +
+	"\x16peer_protocols_updated\x18\r \x01(\v2!.exporter.pb.PeerProtocolsUpdatedH\x00R\x14peerProtocolsUpdated\x12\\\n" +
+	"\x17local_protocols_updated\x18\x0e \x01(\v2\".exporter.pb.LocalProtocolsUpdatedH\x00R\x15localProtocolsUpdated\x12\\\n" +
+	"\x17local_addresses_updated\x18\x0f \x01(\v2\".exporter.pb.LocalAddressesUpdatedH\x00R\x15localAddressesUpdated\x12e\n" +
+	"\x1alocal_reachability_changed\x18\x10 \x01(\v2%.exporter.pb.LocalReachabilityChangedH\x00R\x18localReachabilityChanged\x12i\n" +
+	"\x1chost_reachable_addrs_changed\x18\x11 \x01(\v2&.exporter.pb.HostReachableAddrsChangedH\x00R\x19hostReachableAddrsChanged\x12Z\n" +
+	"\x17nat_device_type_changed\x18\x12 \x01(\v2!.exporter.pb.NATDeviceTypeChangedH\x00R\x14natDeviceTypeChanged\x12]\n" +
+	"\x18auto_relay_addrs_updated\x18\x13 \x01(\v2\".exporter.pb.AutoRelayAddrsUpdatedH\x00R\x15autoRelayAddrsUpdated\x12S\n" +
+	"\x14nat_mappings_changed\x18\x14 \x01(\v2\x1f.exporter.pb.NATMappingsChangedH\x00R\x12natMappingsChanged\x12G\n" +
+	"\x10relay_going_away\x18\x15 \x01(\v2\x1b.exporter.pb.RelayGoingAwayH\x00R\x0erelayGoingAway\x12S\n" +
+	"\x14port_mapping_changed\x18\x16 \x01(\v2\x1f.exporter.pb.PortMappingChangedH\x00R\x12portMappingChangedB\t\n" +
+	"\apayload\"T\n" +
+	"\x18PeerConnectednessChanged\x12\x12\n" +
+	"\x04peer\x18\x01 \x01(\tR\x04peer\x12$\n" +
+	"\rconnectedness\x18\x02 \x01(\tR\rconnectedness\"\xe7\x01\n" +
+	"\x1bPeerIdentificationCompleted\x12\x12\n" +
+	"\x04peer\x18\x01 \x01(\tR\x04peer\x12!\n" +
+	"\flisten_addrs\x18\x02 \x03(\tR\vlistenAddrs\x12\x1c\n" +
+	"\tprotocols\x18\x03 \x03(\tR\tprotocols\x12#\n" +
+	"\ragent_version\x18\x04 \x01(\tR\fagentVersion\x12)\n" +
+	"\x10protocol_version\x18\x05 \x01(\tR\x0fprotocolVersion\x12#\n" +
+	"\robserved_addr\x18\x06 \x01(\tR\fobservedAddr\"F\n" +
+	"\x18PeerIdentificationFailed\x12\x12\n" +
+	"\x04peer\x18\x01 \x01(\tR\x04peer\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"Z\n" +
+	"\x14PeerProtocolsUpdated\x12\x12\n" +
+	"\x04peer\x18\x01 \x01(\tR\x04peer\x12\x14\n" +
+	"\x05added\x18\x02 \x03(\tR\x05added\x12\x18\n" +
+	"\aremoved\x18\x03 \x03(\tR\aremoved\"G\n" +
+	"\x15LocalProtocolsUpdated\x12\x14\n" +
+	"\x05added\x18\x01 \x03(\tR\x05added\x12\x18\n" +
+	"\aremoved\x18\x02 \x03(\tR\aremoved\"B\n" +
+	"\x0eUpdatedAddress\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x16\n" +
+	"\x06action\x18\x02 \x01(\tR\x06action\"\x9b\x01\n" +
+	"\x15LocalAddressesUpdated\x12\x14\n" +
+	"\x05diffs\x18\x01 \x01(\bR\x05diffs\x125\n" +
+	"\acurrent\x18\x02 \x03(\v2\x1b.exporter.pb.UpdatedAddressR\acurrent\x125\n" +
+	"\aremoved\x18\x03 \x03(\v2\x1b.exporter.pb.UpdatedAddressR\aremoved\">\n" +
+	"\x18LocalReachabilityChanged\x12\"\n" +
+	"\freachability\x18\x01 \x01(\tR\freachability\"u\n" +
+	"\x19HostReachableAddrsChanged\x12\x1c\n" +
+	"\treachable\x18\x01 \x03(\tR\treachable\x12 \n" +
+	"\vunreachable\x18\x02 \x03(\tR\vunreachable\x12\x18\n" +
+	"\aunknown\x18\x03 \x03(\tR\aunknown\"m\n" +
+	"\x14NATDeviceTypeChanged\x12-\n" +
+	"\x12transport_protocol\x18\x01 \x01(\tR\x11transportProtocol\x12&\n" +
+	"\x0fnat_device_type\x18\x02 \x01(\tR\rnatDeviceType\"8\n" +
+	"\x15AutoRelayAddrsUpdated\x12\x1f\n" +
+	"\vrelay_addrs\x18\x01 \x03(\tR\n" +
+	"relayAddrs\"\xcc\x02\n" +
+	"\n" +
+	"NATMapping\x12\x1d\n" +
+	"\n" +
+	"local_addr\x18\x01 \x01(\tR\tlocalAddr\x12-\n" +
+	"\x12transport_protocol\x18\x02 \x01(\tR\x11transportProtocol\x12\x12\n" +
+	"\x04ipv6\x18\x03 \x01(\bR\x04ipv6\x12\x1f\n" +
+	"\vdevice_type\x18\x04 \x01(\tR\n" +
+	"deviceType\x12\x1a\n" +
+	"\bbehavior\x18\x05 \x01(\tR\bbehavior\x12#\n" +
+	"\rexternal_addr\x18\x06 \x01(\tR\fexternalAddr\x12\"\n" +
+	"\fobservations\x18\a \x01(\rR\fobservations\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\b \x01(\x01R\n" +
+	"confidence\x12 \n" +
+	"\vhairpinning\x18\t \x01(\bR\vhairpinning\x12\x14\n" +
+	"\x05cgnat\x18\n" +
+	" \x01(\bR\x05cgnat\"I\n" +
+	"\x12NATMappingsChanged\x123\n" +
+	"\bmappings\x18\x01 \x03(\v2\x17.exporter.pb.NATMappingR\bmappings\"B\n" +
+	"\x0eRelayGoingAway\x12\x14\n" +
+	"\x05relay\x18\x01 \x01(\tR\x05relay\x12\x1a\n" +
+	"\bdowntime\x18\x02 \x01(\x03R\bdowntime\"\xe1\x01\n" +
+	"\x12PortMappingChanged\x12\x16\n" +
+	"\x06change\x18\x01 \x01(\tR\x06change\x12!\n" +
+	"\fgateway_type\x18\x02 \x01(\tR\vgatewayType\x12\x1a\n" +
+	"\bprotocol\x18\x03 \x01(\tR\bprotocol\x12#\n" +
+	"\rinternal_port\x18\x04 \x01(\rR\finternalPort\x12#\n" +
+	"\rexternal_addr\x18\x05 \x01(\tR\fexternalAddr\x12\x14\n" +
+	"\x05lease\x18\x06 \x01(\x03R\x05lease\x12\x14\n" +
+	"\x05error\x18\a \x01(\tR\x05errorB;Z9github.com/libp2p/go-libp2p/p2p/host/eventbus/exporter/pbb\x06proto3"
+
+var (
+	file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescOnce sync.Once
+	file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescData []byte
+)
+
+func file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescGZIP() []byte {
+	file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescOnce.Do(func() {
+		file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDesc), len(file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDesc)))
+	})
+	return file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDescData
+}
+
+var file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_p2p_host_eventbus_exporter_pb_exporter_proto_goTypes = []any{
+	(*Event)(nil),                       // 0: exporter.pb.Event
+	(*PeerConnectednessChanged)(nil),    // 1: exporter.pb.PeerConnectednessChanged
+	(*PeerIdentificationCompleted)(nil), // 2: exporter.pb.PeerIdentificationCompleted
+	(*PeerIdentificationFailed)(nil),    // 3: exporter.pb.PeerIdentificationFailed
+	(*PeerProtocolsUpdated)(nil),        // 4: exporter.pb.PeerProtocolsUpdated
+	(*LocalProtocolsUpdated)(nil),       // 5: exporter.pb.LocalProtocolsUpdated
+	(*UpdatedAddress)(nil),              // 6: exporter.pb.UpdatedAddress
+	(*LocalAddressesUpdated)(nil),       // 7: exporter.pb.LocalAddressesUpdated
+	(*LocalReachabilityChanged)(nil),    // 8: exporter.pb.LocalReachabilityChanged
+	(*HostReachableAddrsChanged)(nil),   // 9: exporter.pb.HostReachableAddrsChanged
+	(*NATDeviceTypeChanged)(nil),        // 10: exporter.pb.NATDeviceTypeChanged
+	(*AutoRelayAddrsUpdated)(nil),       // 11: exporter.pb.AutoRelayAddrsUpdated
+	(*NATMapping)(nil),                  // 12: exporter.pb.NATMapping
+	(*NATMappingsChanged)(nil),          // 13: exporter.pb.NATMappingsChanged
+	(*RelayGoingAway)(nil),              // 14: exporter.pb.RelayGoingAway
+	(*PortMappingChanged)(nil),          // 15: exporter.pb.PortMappingChanged
+}
+var file_p2p_host_eventbus_exporter_pb_exporter_proto_depIdxs = []int32{
+	1,  // 0: exporter.pb.Event.peer_connectedness_changed:type_name -> exporter.pb.PeerConnectednessChanged
+	2,  // 1: exporter.pb.Event.peer_identification_completed:type_name -> exporter.pb.PeerIdentificationCompleted
+	3,  // 2: exporter.pb.Event.peer_identification_failed:type_name -> exporter.pb.PeerIdentificationFailed
+	4,  // 3: exporter.pb.Event.peer_protocols_updated:type_name -> exporter.pb.PeerProtocolsUpdated
+	5,  // 4: exporter.pb.Event.local_protocols_updated:type_name -> exporter.pb.LocalProtocolsUpdated
+	7,  // 5: exporter.pb.Event.local_addresses_updated:type_name -> exporter.pb.LocalAddressesUpdated
+	8,  // 6: exporter.pb.Event.local_reachability_changed:type_name -> exporter.pb.LocalReachabilityChanged
+	9,  // 7: exporter.pb.Event.host_reachable_addrs_changed:type_name -> exporter.pb.HostReachableAddrsChanged
+	10, // 8: exporter.pb.Event.nat_device_type_changed:type_name -> exporter.pb.NATDeviceTypeChanged
+	11, // 9: exporter.pb.Event.auto_relay_addrs_updated:type_name -> exporter.pb.AutoRelayAddrsUpdated
+	13, // 10: exporter.pb.Event.nat_mappings_changed:type_name -> exporter.pb.NATMappingsChanged
+	14, // 11: exporter.pb.Event.relay_going_away:type_name -> exporter.pb.RelayGoingAway
+	15, // 12: exporter.pb.Event.port_mapping_changed:type_name -> exporter.pb.PortMappingChanged
+	6,  // 13: exporter.pb.LocalAddressesUpdated.current:type_name -> exporter.pb.UpdatedAddress
+	6,  // 14: exporter.pb.LocalAddressesUpdated.removed:type_name -> exporter.pb.UpdatedAddress
+	12, // 15: exporter.pb.NATMappingsChanged.mappings:type_name -> exporter.pb.NATMapping
+	16, // [16:16] is the sub-list for method output_type
+	16, // [16:16] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
+}
+
+func init() { file_p2p_host_eventbus_exporter_pb_exporter_proto_init() }
+func file_p2p_host_eventbus_exporter_pb_exporter_proto_init() {
+	if File_p2p_host_eventbus_exporter_pb_exporter_proto != nil {
+		return
+	}
+	file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes[0].OneofWrappers = []any{
+		(*Event_PeerConnectednessChanged)(nil),
+		(*Event_PeerIdentificationCompleted)(nil),
+		(*Event_PeerIdentificationFailed)(nil),
+		(*Event_PeerProtocolsUpdated)(nil),
+		(*Event_LocalProtocolsUpdated)(nil),
+		(*Event_LocalAddressesUpdated)(nil),
+		(*Event_LocalReachabilityChanged)(nil),
+		(*Event_HostReachableAddrsChanged)(nil),
+		(*Event_NatDeviceTypeChanged)(nil),
+		(*Event_AutoRelayAddrsUpdated)(nil),
+		(*Event_NatMappingsChanged)(nil),
+		(*Event_RelayGoingAway)(nil),
+		(*Event_PortMappingChanged)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDesc), len(file_p2p_host_eventbus_exporter_pb_exporter_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   16,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_p2p_host_eventbus_exporter_pb_exporter_proto_goTypes,
+		DependencyIndexes: file_p2p_host_eventbus_exporter_pb_exporter_proto_depIdxs,
+		MessageInfos:      file_p2p_host_eventbus_exporter_pb_exporter_proto_msgTypes,
+	}.Build()
+	File_p2p_host_eventbus_exporter_pb_exporter_proto = out.File
+	file_p2p_host_eventbus_exporter_pb_exporter_proto_goTypes = nil
+	file_p2p_host_eventbus_exporter_pb_exporter_proto_depIdxs = nil
+}
@@ -0,0 +1,157 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus/exporter/pb"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Encoding is the serialisation format of exported events.
+type Encoding int
+
+const (
+	// JSON encodes events with the canonical protobuf JSON mapping, using the
+	// field names of the schema, one event per line.
+	JSON Encoding = iota
+	// Protobuf encodes events as protobuf messages, each prefixed with its
+	// uvarint encoded length.
+	Protobuf
+)
+
+var jsonOpts = protojson.MarshalOptions{UseProtoNames: true}
+
+// Encode serialises the event.
+func (e Encoding) Encode(evt *pb.Event) ([]byte, error) {
+	switch e {
+	case JSON:
+		b, err := jsonOpts.Marshal(evt)
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	case Protobuf:
+		var buf bytes.Buffer
+		if _, err := protodelim.MarshalTo(&buf, evt); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown encoding: %d", e)
+	}
+}
+
+// Sink receives the exported events.
+type Sink interface {
+	io.Closer
+
+	// WriteEvent writes the event. It is called from a single goroutine.
+	WriteEvent(evt *pb.Event) error
+}
+
+type writerSink struct {
+	mx  sync.Mutex
+	w   io.Writer
+	enc Encoding
+}
+
+// NewWriterSink creates a sink writing the events to w. Closing the sink
+// closes w if it is an io.Closer.
+func NewWriterSink(w io.Writer, enc Encoding) Sink {
+	return &writerSink{w: w, enc: enc}
+}
+
+// NewFileSink creates a sink appending the events to the file at path,
+// creating it if needed.
+func NewFileSink(path string, enc Encoding) (Sink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterSink(f, enc), nil
+}
+
+func (s *writerSink) WriteEvent(evt *pb.Event) error {
+	b, err := s.enc.Encode(evt)
+	if err != nil {
+		return err
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	_, err = s.w.Write(b)
+	return err
+}
+
+func (s *writerSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+const unixSocketTimeout = time.Second
+
+type unixSocketSink struct {
+	path string
+	enc  Encoding
+
+	mx     sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// NewUnixSocketSink creates a sink writing the events to the Unix socket at
+// path. The socket is connected lazily, and reconnected after errors. Events
+// that can't be written in time are dropped, so that a stalled reader can't
+// stall the exporter.
+func NewUnixSocketSink(path string, enc Encoding) Sink {
+	return &unixSocketSink{path: path, enc: enc}
+}
+
+func (s *unixSocketSink) WriteEvent(evt *pb.Event) error {
+	b, err := s.enc.Encode(evt)
+	if err != nil {
+		return err
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.closed {
+		return net.ErrClosed
+	}
+	if s.conn == nil {
+		conn, err := net.DialTimeout("unix", s.path, unixSocketTimeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(unixSocketTimeout))
+	if _, err := s.conn.Write(b); err != nil {
+		// The reader may have lost part of an event, start afresh.
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *unixSocketSink) Close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.closed = true
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
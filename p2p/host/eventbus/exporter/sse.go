@@ -0,0 +1,108 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus/exporter/pb"
+)
+
+const sseClientBufSize = 64
+
+// SSEHandler is a Sink streaming the events to HTTP clients as server-sent
+// events, encoded as JSON. The event type is used as the event name, and the
+// sequence number as the event ID.
+//
+// Events are dropped for clients that don't keep up.
+type SSEHandler struct {
+	mx      sync.Mutex
+	clients map[chan []byte]struct{}
+	closed  bool
+}
+
+var (
+	_ Sink         = (*SSEHandler)(nil)
+	_ http.Handler = (*SSEHandler)(nil)
+)
+
+// NewSSEHandler creates a new SSEHandler.
+func NewSSEHandler() *SSEHandler {
+	return &SSEHandler{clients: make(map[chan []byte]struct{})}
+}
+
+func (h *SSEHandler) WriteEvent(evt *pb.Event) error {
+	b, err := jsonOpts.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "id: %d\nevent: %s\ndata: %s\n\n", evt.GetSeq(), evt.GetType(), b)
+
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	for ch := range h.clients {
+		select {
+		case ch <- msg.Bytes():
+		default:
+		}
+	}
+	return nil
+}
+
+func (h *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := make(chan []byte, sseClientBufSize)
+	h.mx.Lock()
+	if h.closed {
+		h.mx.Unlock()
+		http.Error(w, "exporter closed", http.StatusServiceUnavailable)
+		return
+	}
+	h.clients[ch] = struct{}{}
+	h.mx.Unlock()
+	defer func() {
+		h.mx.Lock()
+		delete(h.clients, ch)
+		h.mx.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Close disconnects all clients.
+func (h *SSEHandler) Close() error {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	h.closed = true
+	for ch := range h.clients {
+		close(ch)
+		delete(h.clients, ch)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package eventbus
 
 import (
+	"errors"
 	"fmt"
 	"runtime"
 	"strings"
@@ -10,6 +11,9 @@ import (
 type subSettings struct {
 	buffer int
 	name   string
+	policy OverflowPolicy
+	filter func(interface{}) bool
+	replay int
 }
 
 var subCnt atomic.Int64
@@ -53,8 +57,72 @@ func Name(name string) func(interface{}) error {
 	}
 }
 
+// OverflowPolicy determines what happens when an event is emitted while the
+// channel of a subscription is full.
+type OverflowPolicy int
+
+const (
+	// Block blocks the emitter until the subscriber consumes an event. This is
+	// the default.
+	Block OverflowPolicy = iota
+	// DropOldest drops the oldest queued event to make room for the new one.
+	DropOldest
+	// DropNewest drops the new event.
+	DropNewest
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropOldest:
+		return "drop-oldest"
+	case DropNewest:
+		return "drop-newest"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+	}
+}
+
+// Policy is a Subscription option setting the overflow policy of the
+// subscription. The drop policies require a buffered subscription.
+func Policy(p OverflowPolicy) func(interface{}) error {
+	return func(s interface{}) error {
+		if p < Block || p > DropNewest {
+			return fmt.Errorf("unknown overflow policy: %d", p)
+		}
+		s.(*subSettings).policy = p
+		return nil
+	}
+}
+
+// Filter is a Subscription option that only delivers the events for which f
+// returns true. f is called by the emitter before the event is queued, so it
+// must be fast and must not use the bus.
+func Filter(f func(evt interface{}) bool) func(interface{}) error {
+	return func(s interface{}) error {
+		s.(*subSettings).filter = f
+		return nil
+	}
+}
+
+// Replay is a Subscription option that delivers up to the n last events
+// retained by emitters created with the ReplayBuffer option when the
+// subscription is created. It takes precedence over the remembered event of
+// Stateful emitters.
+func Replay(n int) func(interface{}) error {
+	return func(s interface{}) error {
+		if n < 0 {
+			return errors.New("replay must not be negative")
+		}
+		s.(*subSettings).replay = n
+		return nil
+	}
+}
+
 type emitterSettings struct {
 	makeStateful bool
+	replayBuffer int
 }
 
 // Stateful is an Emitter option which makes the eventbus channel
@@ -69,6 +137,20 @@ func Stateful(s interface{}) error {
 	return nil
 }
 
+// ReplayBuffer is an Emitter option which makes the eventbus channel retain
+// the last n events sent, for subscribers created with the Replay option.
+// When several emitters of the same type set a replay buffer, the largest one
+// is used.
+func ReplayBuffer(n int) func(interface{}) error {
+	return func(s interface{}) error {
+		if n < 0 {
+			return errors.New("replay buffer must not be negative")
+		}
+		s.(*emitterSettings).replayBuffer = n
+		return nil
+	}
+}
+
 type Option func(*basicBus)
 
 func WithMetricsTracer(metricsTracer MetricsTracer) Option {
@@ -0,0 +1,94 @@
+package peerstore
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// AddrSource is where an address was learned from. Sources are ordered by
+// trust: when an address is learned from several sources, the most trusted
+// one is kept.
+type AddrSource uint8
+
+const (
+	AddrSourceUnknown AddrSource = iota
+	// AddrSourceObserved is for addresses observed on connections.
+	AddrSourceObserved
+	// AddrSourceDiscovery is for addresses learned through peer discovery.
+	AddrSourceDiscovery
+	// AddrSourceIdentify is for addresses advertised by the peer itself.
+	AddrSourceIdentify
+	// AddrSourceSignedRecord is for addresses from a signed peer record.
+	AddrSourceSignedRecord
+)
+
+func (s AddrSource) String() string {
+	switch s {
+	case AddrSourceObserved:
+		return "observed"
+	case AddrSourceDiscovery:
+		return "discovery"
+	case AddrSourceIdentify:
+		return "identify"
+	case AddrSourceSignedRecord:
+		return "signed-record"
+	default:
+		return "unknown"
+	}
+}
+
+// AddrStats are the statistics of an address in the address book.
+type AddrStats struct {
+	Source AddrSource
+	// LastSuccess is the last time the address was dialed successfully.
+	LastSuccess time.Time
+	// LastFailure is the last time a dial to the address failed.
+	LastFailure time.Time
+	// Failures is the number of consecutive failed dials since the last
+	// successful dial.
+	Failures int
+}
+
+// AddrTracker is implemented by address books that track the sources of
+// addresses and the outcome of dials to them.
+type AddrTracker interface {
+	// AddAddrsWithSource is like AddrBook.AddAddrs, and records where the
+	// addresses were learned from.
+	AddAddrsWithSource(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration, src AddrSource)
+	// RecordDialSuccess records a successful dial to the address.
+	RecordDialSuccess(p peer.ID, addr ma.Multiaddr)
+	// RecordDialFailure records a failed dial to the address.
+	RecordDialFailure(p peer.ID, addr ma.Multiaddr)
+	// AddrStats returns the statistics of an address of the peer.
+	AddrStats(p peer.ID, addr ma.Multiaddr) (AddrStats, bool)
+}
+
+// AddAddrsWithSource adds the addresses to the address book, recording their
+// source if the address book is an AddrTracker.
+func AddAddrsWithSource(ab pstore.AddrBook, p peer.ID, addrs []ma.Multiaddr, ttl time.Duration, src AddrSource) {
+	if t, ok := ab.(AddrTracker); ok {
+		t.AddAddrsWithSource(p, addrs, ttl, src)
+		return
+	}
+	ab.AddAddrs(p, addrs, ttl)
+}
+
+// RecordDialSuccess records a successful dial if the address book is an
+// AddrTracker.
+func RecordDialSuccess(ab pstore.AddrBook, p peer.ID, addr ma.Multiaddr) {
+	if t, ok := ab.(AddrTracker); ok {
+		t.RecordDialSuccess(p, addr)
+	}
+}
+
+// RecordDialFailure records a failed dial if the address book is an
+// AddrTracker.
+func RecordDialFailure(ab pstore.AddrBook, p peer.ID, addr ma.Multiaddr) {
+	if t, ok := ab.(AddrTracker); ok {
+		t.RecordDialFailure(p, addr)
+	}
+}
@@ -0,0 +1,333 @@
+package peerstore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+)
+
+var (
+	// ErrMetadataTooLarge is returned when storing a typed metadata value
+	// would exceed the size limit of the value or of the peer.
+	ErrMetadataTooLarge = errors.New("metadata too large")
+	// ErrMetadataTypeMismatch is returned when a stored metadata value
+	// doesn't match the schema it is read with.
+	ErrMetadataTypeMismatch = errors.New("metadata type mismatch")
+)
+
+// DefaultMaxPeerMetadataSize is the default limit of the encoded size of the
+// typed metadata values of a single peer.
+const DefaultMaxPeerMetadataSize = 64 << 10
+
+func init() {
+	gob.Register(typedValue{})
+}
+
+// typedValue is how typed metadata values are stored in the metadata store.
+type typedValue struct {
+	// Schema is the key of the schema the value was stored with, and Version
+	// its version. Values are only decoded by the same schema.
+	Schema  string
+	Version uint
+	// Data is the JSON encoding of the value.
+	Data []byte
+}
+
+type registeredSchema struct {
+	typ     reflect.Type
+	version uint
+}
+
+var schemas = struct {
+	sync.Mutex
+	registered map[string]registeredSchema
+}{registered: make(map[string]registeredSchema)}
+
+// Schema describes the type of the metadata values stored under a key. Typed
+// values are stored JSON encoded, so they can be inspected, and are tagged with
+// the key and version of their schema, which are checked when they are read.
+type Schema[T any] struct {
+	key          string
+	version      uint
+	maxValueSize int
+	migrate      func(old any) (T, error)
+}
+
+// SchemaOption configures a Schema.
+type SchemaOption[T any] func(*Schema[T])
+
+// WithMaxValueSize limits the encoded size of the values of the schema.
+func WithMaxValueSize[T any](n int) SchemaOption[T] {
+	return func(s *Schema[T]) {
+		s.maxValueSize = n
+	}
+}
+
+// WithMigration sets the function used to convert the values stored under the
+// key that the schema can't read directly: legacy (untyped) values that are
+// not of type T, and typed values stored by an older version of the schema,
+// which are passed as a json.RawMessage. Legacy values of type T are always
+// migrated.
+func WithMigration[T any](f func(old any) (T, error)) SchemaOption[T] {
+	return func(s *Schema[T]) {
+		s.migrate = f
+	}
+}
+
+// RegisterSchema registers version version of the schema of the metadata
+// values stored under key. Registering the same key, version and type again
+// returns an equivalent schema, registering the key with another version or
+// type is an error.
+//
+// T is also registered with gob, so that legacy values of type T can be
+// decoded from persistent peerstores and migrated. It is an error if T was
+// registered with gob under a different name, or if its name was registered
+// for a different type.
+func RegisterSchema[T any](key string, version uint, opts ...SchemaOption[T]) (*Schema[T], error) {
+	typ := reflect.TypeFor[T]()
+	schemas.Lock()
+	defer schemas.Unlock()
+
+	existing, ok := schemas.registered[key]
+	if ok && (existing.typ != typ || existing.version != version) {
+		return nil, fmt.Errorf("metadata key %q already registered with version %d of type %s", key, existing.version, existing.typ)
+	}
+	if !ok && typ.Kind() != reflect.Interface {
+		if err := registerGob(*new(T)); err != nil {
+			return nil, fmt.Errorf("failed to register metadata key %q: %w", key, err)
+		}
+	}
+	schemas.registered[key] = registeredSchema{typ: typ, version: version}
+
+	s := &Schema[T]{key: key, version: version}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// MustRegisterSchema is like RegisterSchema, but panics on error. It is meant
+// to be used in package level variable declarations.
+func MustRegisterSchema[T any](key string, version uint, opts ...SchemaOption[T]) *Schema[T] {
+	s, err := RegisterSchema(key, version, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// registerGob registers v with gob, turning its panics into errors.
+func registerGob(v any) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("gob: %v", r)
+		}
+	}()
+	gob.Register(v)
+	return nil
+}
+
+// Key returns the metadata key of the schema.
+func (s *Schema[T]) Key() string {
+	return s.key
+}
+
+// Version returns the version of the schema.
+func (s *Schema[T]) Version() uint {
+	return s.version
+}
+
+// TypedMetadata stores typed metadata values in a peerstore.PeerMetadata, and
+// limits the size of the typed values of each peer.
+//
+// The size limit is enforced per TypedMetadata: each instance only accounts
+// for the values it wrote or read, so all typed values of a peerstore should
+// go through a single instance. In particular, the sizes of values written by
+// an earlier TypedMetadata, e.g. in a persistent peerstore, are only accounted
+// once they are read.
+type TypedMetadata struct {
+	pm          pstore.PeerMetadata
+	maxPeerSize int
+
+	mx    sync.Mutex
+	sizes map[peer.ID]map[string]int
+}
+
+// TypedMetadataOption configures a TypedMetadata.
+type TypedMetadataOption func(*TypedMetadata) error
+
+// WithMaxPeerMetadataSize sets the limit of the encoded size of the typed
+// metadata values of a single peer. 0 disables the limit.
+func WithMaxPeerMetadataSize(n int) TypedMetadataOption {
+	return func(m *TypedMetadata) error {
+		if n < 0 {
+			return errors.New("metadata size limit must not be negative")
+		}
+		m.maxPeerSize = n
+		return nil
+	}
+}
+
+// NewTypedMetadata creates a TypedMetadata storing values in pm.
+func NewTypedMetadata(pm pstore.PeerMetadata, opts ...TypedMetadataOption) (*TypedMetadata, error) {
+	m := &TypedMetadata{
+		pm:          pm,
+		maxPeerSize: DefaultMaxPeerMetadataSize,
+		sizes:       make(map[peer.ID]map[string]int),
+	}
+	for _, opt := range opts {
+		if err := opt(m); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *TypedMetadata) setSize(p peer.ID, key string, size int) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.setSizeLocked(p, key, size)
+}
+
+func (m *TypedMetadata) setSizeLocked(p peer.ID, key string, size int) {
+	if m.sizes[p] == nil {
+		m.sizes[p] = make(map[string]int)
+	}
+	m.sizes[p][key] = size
+}
+
+// reserve records the new size of the value under key, if it fits in the
+// peer's limit. It returns a function restoring the previous size.
+func (m *TypedMetadata) reserve(p peer.ID, key string, size int) (undo func(), err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	total := size
+	for k, s := range m.sizes[p] {
+		if k != key {
+			total += s
+		}
+	}
+	if m.maxPeerSize > 0 && total > m.maxPeerSize {
+		return nil, fmt.Errorf("%w: %d bytes of metadata for peer %s, limit is %d", ErrMetadataTooLarge, total, p, m.maxPeerSize)
+	}
+	if m.sizes[p] == nil {
+		m.sizes[p] = make(map[string]int)
+	}
+	prev, hadPrev := m.sizes[p][key]
+	m.sizes[p][key] = size
+	return func() {
+		m.mx.Lock()
+		defer m.mx.Unlock()
+
+		switch {
+		case hadPrev:
+			m.setSizeLocked(p, key, prev)
+		case m.sizes[p] != nil:
+			delete(m.sizes[p], key)
+			if len(m.sizes[p]) == 0 {
+				delete(m.sizes, p)
+			}
+		}
+	}, nil
+}
+
+// RemovePeer forgets the sizes of the typed values of the peer. It should be
+// called along with peerstore.Peerstore.RemovePeer.
+func (m *TypedMetadata) RemovePeer(p peer.ID) {
+	m.mx.Lock()
+	delete(m.sizes, p)
+	m.mx.Unlock()
+}
+
+// Inspect returns the JSON encoding of the typed value stored under key.
+func (m *TypedMetadata) Inspect(p peer.ID, key string) (json.RawMessage, error) {
+	v, err := m.pm.Get(p, key)
+	if err != nil {
+		return nil, err
+	}
+	tv, ok := v.(typedValue)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q holds an untyped value of type %T", ErrMetadataTypeMismatch, key, v)
+	}
+	return json.RawMessage(tv.Data), nil
+}
+
+// Put stores a typed value for the peer.
+func Put[T any](m *TypedMetadata, p peer.ID, s *Schema[T], v T) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata %q: %w", s.key, err)
+	}
+	if s.maxValueSize > 0 && len(data) > s.maxValueSize {
+		return fmt.Errorf("%w: %d bytes for %q, limit is %d", ErrMetadataTooLarge, len(data), s.key, s.maxValueSize)
+	}
+	undo, err := m.reserve(p, s.key, len(data))
+	if err != nil {
+		return err
+	}
+	if err := m.pm.Put(p, s.key, typedValue{Schema: s.key, Version: s.version, Data: data}); err != nil {
+		undo()
+		return err
+	}
+	return nil
+}
+
+// Get returns the typed value stored for the peer. It returns
+// peerstore.ErrNotFound if there is no value, and an error wrapping
+// ErrMetadataTypeMismatch if the stored value doesn't match the schema.
+//
+// Legacy values stored with PeerMetadata.Put are migrated to typed values if
+// they are of type T, or if the schema has a migration function. So are the
+// values stored by an older version of the schema, if it has one.
+func Get[T any](m *TypedMetadata, p peer.ID, s *Schema[T]) (T, error) {
+	var zero T
+	v, err := m.pm.Get(p, s.key)
+	if err != nil {
+		return zero, err
+	}
+
+	tv, ok := v.(typedValue)
+	if !ok {
+		return migrate(m, p, s, v)
+	}
+	if tv.Schema == s.key && tv.Version < s.version && s.migrate != nil {
+		return migrate(m, p, s, json.RawMessage(tv.Data))
+	}
+	if tv.Schema != s.key || tv.Version != s.version {
+		return zero, fmt.Errorf("%w: %q is stored as version %d of %q, expected version %d", ErrMetadataTypeMismatch, s.key, tv.Version, tv.Schema, s.version)
+	}
+	var res T
+	dec := json.NewDecoder(bytes.NewReader(tv.Data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&res); err != nil {
+		return zero, fmt.Errorf("%w: failed to decode version %d of %q: %w", ErrMetadataTypeMismatch, s.version, s.key, err)
+	}
+	m.setSize(p, s.key, len(tv.Data))
+	return res, nil
+}
+
+func migrate[T any](m *TypedMetadata, p peer.ID, s *Schema[T], old any) (T, error) {
+	var zero T
+	res, ok := old.(T)
+	if !ok {
+		if s.migrate == nil {
+			return zero, fmt.Errorf("%w: %q holds a value of type %T, expected %s", ErrMetadataTypeMismatch, s.key, old, reflect.TypeFor[T]())
+		}
+		var err error
+		if res, err = s.migrate(old); err != nil {
+			return zero, fmt.Errorf("%w: failed to migrate %q: %w", ErrMetadataTypeMismatch, s.key, err)
+		}
+	}
+	if err := Put(m, p, s, res); err != nil {
+		log.Warnw("failed to store migrated metadata", "peer", p, "key", s.key, "error", err)
+	}
+	return res, nil
+}
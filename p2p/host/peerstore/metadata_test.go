@@ -0,0 +1,219 @@
+package peerstore_test
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoreds"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+)
+
+type testPeerInfo struct {
+	Name  string
+	Score int
+}
+
+type legacyPeerInfo struct {
+	Name string
+}
+
+var (
+	infoSchema   = pstore.MustRegisterSchema[testPeerInfo]("test-info", 1)
+	limitSchema  = pstore.MustRegisterSchema("test-limited", 1, pstore.WithMaxValueSize[string](16))
+	legacySchema = pstore.MustRegisterSchema("test-legacy", 1, pstore.WithMigration(func(old any) (testPeerInfo, error) {
+		l, ok := old.(legacyPeerInfo)
+		if !ok {
+			return testPeerInfo{}, errors.New("unexpected type")
+		}
+		return testPeerInfo{Name: l.Name}, nil
+	}))
+)
+
+func init() {
+	// registers legacyPeerInfo with gob, as a schema of an older version would have
+	pstore.MustRegisterSchema[legacyPeerInfo]("test-legacy-type", 1)
+}
+
+func metadataStores(t *testing.T) map[string]peerstore.PeerMetadata {
+	dsmd, err := pstoreds.NewPeerMetadata(context.Background(), ds.NewMapDatastore(), pstoreds.DefaultOpts())
+	require.NoError(t, err)
+	return map[string]peerstore.PeerMetadata{
+		"mem": pstoremem.NewPeerMetadata(),
+		"ds":  dsmd,
+	}
+}
+
+func TestTypedMetadata(t *testing.T) {
+	for name, pm := range metadataStores(t) {
+		t.Run(name, func(t *testing.T) {
+			m, err := pstore.NewTypedMetadata(pm)
+			require.NoError(t, err)
+			p := peer.ID("peer")
+
+			_, err = pstore.Get(m, p, infoSchema)
+			require.ErrorIs(t, err, peerstore.ErrNotFound)
+
+			require.NoError(t, pstore.Put(m, p, infoSchema, testPeerInfo{Name: "foo", Score: 3}))
+			v, err := pstore.Get(m, p, infoSchema)
+			require.NoError(t, err)
+			require.Equal(t, testPeerInfo{Name: "foo", Score: 3}, v)
+
+			raw, err := m.Inspect(p, infoSchema.Key())
+			require.NoError(t, err)
+			require.JSONEq(t, `{"Name":"foo","Score":3}`, string(raw))
+
+			m.RemovePeer(p)
+			v, err = pstore.Get(m, p, infoSchema)
+			require.NoError(t, err)
+			require.Equal(t, "foo", v.Name)
+		})
+	}
+}
+
+func TestTypedMetadataTypeMismatch(t *testing.T) {
+	for name, pm := range metadataStores(t) {
+		t.Run(name, func(t *testing.T) {
+			m, err := pstore.NewTypedMetadata(pm)
+			require.NoError(t, err)
+			p := peer.ID("peer")
+
+			// an untyped value of another type, without a migration function
+			require.NoError(t, pm.Put(p, infoSchema.Key(), 42))
+			_, err = pstore.Get(m, p, infoSchema)
+			require.ErrorIs(t, err, pstore.ErrMetadataTypeMismatch)
+
+			// a typed value that doesn't decode into the schema's type
+			strSchema := pstore.MustRegisterSchema[string]("test-mismatch-"+name, 1)
+			require.NoError(t, pstore.Put(m, p, strSchema, "not a struct"))
+			raw, err := pm.Get(p, strSchema.Key())
+			require.NoError(t, err)
+			require.NoError(t, pm.Put(p, infoSchema.Key(), raw))
+			_, err = pstore.Get(m, p, infoSchema)
+			require.ErrorIs(t, err, pstore.ErrMetadataTypeMismatch)
+
+			// a typed value of another type that would decode partially
+			subsetSchema := pstore.MustRegisterSchema[legacyPeerInfo]("test-subset-"+name, 1)
+			require.NoError(t, pstore.Put(m, p, infoSchema, testPeerInfo{Name: "foo", Score: 3}))
+			raw, err = pm.Get(p, infoSchema.Key())
+			require.NoError(t, err)
+			require.NoError(t, pm.Put(p, subsetSchema.Key(), raw))
+			_, err = pstore.Get(m, p, subsetSchema)
+			require.ErrorIs(t, err, pstore.ErrMetadataTypeMismatch)
+		})
+	}
+}
+
+type failingMetadata struct {
+	peerstore.PeerMetadata
+	fail bool
+}
+
+func (m *failingMetadata) Put(p peer.ID, key string, val any) error {
+	if m.fail {
+		return errors.New("put failed")
+	}
+	return m.PeerMetadata.Put(p, key, val)
+}
+
+func TestTypedMetadataFailedPut(t *testing.T) {
+	pm := &failingMetadata{PeerMetadata: pstoremem.NewPeerMetadata()}
+	m, err := pstore.NewTypedMetadata(pm, pstore.WithMaxPeerMetadataSize(64))
+	require.NoError(t, err)
+	p := peer.ID("peer")
+
+	require.NoError(t, pstore.Put(m, p, infoSchema, testPeerInfo{Name: "short"}))
+	pm.fail = true
+	require.Error(t, pstore.Put(m, p, infoSchema, testPeerInfo{Name: strings.Repeat("a", 40)}))
+	require.Error(t, pstore.Put(m, p, limitSchema, "short"))
+	pm.fail = false
+
+	// the failed writes don't count against the peer's limit
+	require.NoError(t, pstore.Put(m, p, limitSchema, strings.Repeat("a", 14)))
+	require.NoError(t, pstore.Put(m, p, infoSchema, testPeerInfo{Name: strings.Repeat("a", 20)}))
+}
+
+type gobConflict struct{ A int }
+
+func TestTypedMetadataRegisterConflict(t *testing.T) {
+	_, err := pstore.RegisterSchema[testPeerInfo]("test-info", 1) // same type and version is fine
+	require.NoError(t, err)
+	_, err = pstore.RegisterSchema[string]("test-info", 1)
+	require.Error(t, err)
+	_, err = pstore.RegisterSchema[testPeerInfo]("test-info", 2)
+	require.Error(t, err)
+	require.Panics(t, func() { pstore.MustRegisterSchema[string]("test-info", 1) })
+
+	// the gob name of gobConflict is taken by another type
+	gob.RegisterName("github.com/libp2p/go-libp2p/p2p/host/peerstore_test.gobConflict", struct{ B string }{})
+	_, err = pstore.RegisterSchema[gobConflict]("test-gob-conflict", 1)
+	require.Error(t, err)
+}
+
+func TestTypedMetadataSizeLimits(t *testing.T) {
+	m, err := pstore.NewTypedMetadata(pstoremem.NewPeerMetadata(), pstore.WithMaxPeerMetadataSize(64))
+	require.NoError(t, err)
+	p := peer.ID("peer")
+
+	err = pstore.Put(m, p, limitSchema, strings.Repeat("a", 20))
+	require.ErrorIs(t, err, pstore.ErrMetadataTooLarge)
+	require.NoError(t, pstore.Put(m, p, limitSchema, "short"))
+
+	require.NoError(t, pstore.Put(m, p, infoSchema, testPeerInfo{Name: strings.Repeat("a", 20)}))
+	err = pstore.Put(m, p, infoSchema, testPeerInfo{Name: strings.Repeat("a", 50)})
+	require.ErrorIs(t, err, pstore.ErrMetadataTooLarge)
+	// the failed write didn't replace the value
+	v, err := pstore.Get(m, p, infoSchema)
+	require.NoError(t, err)
+	require.Equal(t, strings.Repeat("a", 20), v.Name)
+
+	// other peers have their own budget
+	require.NoError(t, pstore.Put(m, peer.ID("other"), infoSchema, testPeerInfo{Name: strings.Repeat("a", 40)}))
+
+	// replacing a value only accounts for the new size
+	require.NoError(t, pstore.Put(m, p, infoSchema, testPeerInfo{Name: strings.Repeat("b", 30)}))
+
+	m.RemovePeer(p)
+	require.NoError(t, pstore.Put(m, p, infoSchema, testPeerInfo{Name: strings.Repeat("a", 40)}))
+}
+
+func TestTypedMetadataMigration(t *testing.T) {
+	for name, pm := range metadataStores(t) {
+		t.Run(name, func(t *testing.T) {
+			m, err := pstore.NewTypedMetadata(pm)
+			require.NoError(t, err)
+			p := peer.ID("peer")
+
+			// values of the schema's type are migrated as is
+			require.NoError(t, pm.Put(p, infoSchema.Key(), testPeerInfo{Name: "old", Score: 1}))
+			_, err = m.Inspect(p, infoSchema.Key())
+			require.ErrorIs(t, err, pstore.ErrMetadataTypeMismatch)
+			v, err := pstore.Get(m, p, infoSchema)
+			require.NoError(t, err)
+			require.Equal(t, testPeerInfo{Name: "old", Score: 1}, v)
+			raw, err := m.Inspect(p, infoSchema.Key())
+			require.NoError(t, err)
+			require.JSONEq(t, `{"Name":"old","Score":1}`, string(raw))
+
+			// values of other types go through the migration function
+			require.NoError(t, pm.Put(p, legacySchema.Key(), legacyPeerInfo{Name: "legacy"}))
+			v, err = pstore.Get(m, p, legacySchema)
+			require.NoError(t, err)
+			require.Equal(t, testPeerInfo{Name: "legacy"}, v)
+			_, err = m.Inspect(p, legacySchema.Key())
+			require.NoError(t, err)
+
+			require.NoError(t, pm.Put(p, legacySchema.Key(), "garbage"))
+			_, err = pstore.Get(m, p, legacySchema)
+			require.ErrorIs(t, err, pstore.ErrMetadataTypeMismatch)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package peerstore
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mapMetadata map[string]any
+
+func (m mapMetadata) Get(_ peer.ID, key string) (any, error) {
+	v, ok := m[key]
+	if !ok {
+		return nil, pstore.ErrNotFound
+	}
+	return v, nil
+}
+
+func (m mapMetadata) Put(_ peer.ID, key string, val any) error {
+	m[key] = val
+	return nil
+}
+
+func (m mapMetadata) RemovePeer(peer.ID) {}
+
+func TestTypedMetadataVersions(t *testing.T) {
+	type infoV1 struct{ Name string }
+	type infoV2 struct {
+		Name  string
+		Score int
+	}
+	pm := mapMetadata{}
+	m, err := NewTypedMetadata(pm)
+	require.NoError(t, err)
+	p := peer.ID("peer")
+
+	// a value stored by version 1 of the schemas, in an earlier process
+	data, err := json.Marshal(infoV1{Name: "foo"})
+	require.NoError(t, err)
+	for _, key := range []string{"test-version", "test-version-migrating"} {
+		pm[key] = typedValue{Schema: key, Version: 1, Data: data}
+	}
+
+	v2 := MustRegisterSchema[infoV2]("test-version", 2)
+	_, err = Get(m, p, v2)
+	require.ErrorIs(t, err, ErrMetadataTypeMismatch)
+
+	migrating := MustRegisterSchema("test-version-migrating", 2, WithMigration(func(old any) (infoV2, error) {
+		var v1 infoV1
+		if err := json.Unmarshal(old.(json.RawMessage), &v1); err != nil {
+			return infoV2{}, err
+		}
+		return infoV2{Name: v1.Name, Score: 1}, nil
+	}))
+	v, err := Get(m, p, migrating)
+	require.NoError(t, err)
+	require.Equal(t, infoV2{Name: "foo", Score: 1}, v)
+	// the migrated value is stored with the new version
+	require.Equal(t, uint(2), pm["test-version-migrating"].(typedValue).Version)
+
+	// a newer version is a mismatch too
+	pm["test-version"] = typedValue{Schema: "test-version", Version: 3, Data: data}
+	_, err = Get(m, p, v2)
+	require.ErrorIs(t, err, ErrMetadataTypeMismatch)
+}
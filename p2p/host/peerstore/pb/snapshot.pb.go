@@ -0,0 +1,326 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.2
+// source: p2p/host/peerstore/pb/snapshot.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Snapshot is a dump of the state of a peerstore.
+type Snapshot struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The version of the snapshot format.
+	Version uint32 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	// The point in time when the snapshot was taken, in Unix nanoseconds.
+	Timestamp     int64        `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Peers         []*PeerEntry `protobuf:"bytes,3,rep,name=peers,proto3" json:"peers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Snapshot) Reset() {
+	*x = Snapshot{}
+	mi := &file_p2p_host_peerstore_pb_snapshot_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Snapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Snapshot) ProtoMessage() {}
+
+func (x *Snapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_peerstore_pb_snapshot_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Snapshot.ProtoReflect.Descriptor instead.
+func (*Snapshot) Descriptor() ([]byte, []int) {
+	return file_p2p_host_peerstore_pb_snapshot_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Snapshot) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Snapshot) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Snapshot) GetPeers() []*PeerEntry {
+	if x != nil {
+		return x.Peers
+	}
+	return nil
+}
+
+// PeerEntry is the state of a single peer.
+type PeerEntry struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The peer ID.
+	Id    []byte                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Addrs []*PeerEntry_AddrEntry `protobuf:"bytes,2,rep,name=addrs,proto3" json:"addrs,omitempty"`
+	// The serialized bytes of the SignedEnvelope containing the most recent PeerRecord.
+	SignedPeerRecord []byte `protobuf:"bytes,3,opt,name=signed_peer_record,json=signedPeerRecord,proto3" json:"signed_peer_record,omitempty"`
+	// The serialized public key.
+	PublicKey []byte   `protobuf:"bytes,4,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Protocols []string `protobuf:"bytes,5,rep,name=protocols,proto3" json:"protocols,omitempty"`
+	// The gob encoded metadata values, by key.
+	Metadata map[string][]byte `protobuf:"bytes,6,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The latency EWMA, in nanoseconds.
+	Latency       int64 `protobuf:"varint,7,opt,name=latency,proto3" json:"latency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeerEntry) Reset() {
+	*x = PeerEntry{}
+	mi := &file_p2p_host_peerstore_pb_snapshot_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerEntry) ProtoMessage() {}
+
+func (x *PeerEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_peerstore_pb_snapshot_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerEntry.ProtoReflect.Descriptor instead.
+func (*PeerEntry) Descriptor() ([]byte, []int) {
+	return file_p2p_host_peerstore_pb_snapshot_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PeerEntry) GetId() []byte {
+	if x != nil {
+		return x.Id
+	}
+	return nil
+}
+
+func (x *PeerEntry) GetAddrs() []*PeerEntry_AddrEntry {
+	if x != nil {
+		return x.Addrs
+	}
+	return nil
+}
+
+func (x *PeerEntry) GetSignedPeerRecord() []byte {
+	if x != nil {
+		return x.SignedPeerRecord
+	}
+	return nil
+}
+
+func (x *PeerEntry) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *PeerEntry) GetProtocols() []string {
+	if x != nil {
+		return x.Protocols
+	}
+	return nil
+}
+
+func (x *PeerEntry) GetMetadata() map[string][]byte {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *PeerEntry) GetLatency() int64 {
+	if x != nil {
+		return x.Latency
+	}
+	return 0
+}
+
+// AddrEntry represents a single multiaddress.
+type PeerEntry_AddrEntry struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Addr  []byte                 `protobuf:"bytes,1,opt,name=addr,proto3" json:"addr,omitempty"`
+	// The original TTL of this address, in nanoseconds.
+	Ttl int64 `protobuf:"varint,2,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	// The point in time when this address expires, in Unix nanoseconds.
+	Expiry        int64 `protobuf:"varint,3,opt,name=expiry,proto3" json:"expiry,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeerEntry_AddrEntry) Reset() {
+	*x = PeerEntry_AddrEntry{}
+	mi := &file_p2p_host_peerstore_pb_snapshot_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerEntry_AddrEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerEntry_AddrEntry) ProtoMessage() {}
+
+func (x *PeerEntry_AddrEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_host_peerstore_pb_snapshot_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerEntry_AddrEntry.ProtoReflect.Descriptor instead.
+func (*PeerEntry_AddrEntry) Descriptor() ([]byte, []int) {
+	return file_p2p_host_peerstore_pb_snapshot_proto_rawDescGZIP(), []int{1, 1}
+}
+
+func (x *PeerEntry_AddrEntry) GetAddr() []byte {
+	if x != nil {
+		return x.Addr
+	}
+	return nil
+}
+
+func (x *PeerEntry_AddrEntry) GetTtl() int64 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+func (x *PeerEntry_AddrEntry) GetExpiry() int64 {
+	if x != nil {
+		return x.Expiry
+	}
+	return 0
+}
+
+var File_p2p_host_peerstore_pb_snapshot_proto protoreflect.FileDescriptor
+
+const file_p2p_host_peerstore_pb_snapshot_proto_rawDesc = "" +
+	"\n" +
+	"$p2p/host/peerstore/pb/snapshot.proto\x12\fpeerstore.pb\"q\n" +
+	"\bSnapshot\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\rR\aversion\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x12-\n" +
+	"\x05peers\x18\x03 \x03(\v2\x17.peerstore.pb.PeerEntryR\x05peers\"\xa4\x03\n" +
+	"\tPeerEntry\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\fR\x02id\x127\n" +
+	"\x05addrs\x18\x02 \x03(\v2!.peerstore.pb.PeerEntry.AddrEntryR\x05addrs\x12,\n" +
+	"\x12signed_peer_record\x18\x03 \x01(\fR\x10signedPeerRecord\x12\x1d\n" +
+	"\n" +
+	"public_key\x18\x04 \x01(\fR\tpublicKey\x12\x1c\n" +
+	"\tprotocols\x18\x05 \x03(\tR\tprotocols\x12A\n" +
+	"\bmetadata\x18\x06 \x03(\v2%.peerstore.pb.PeerEntry.MetadataEntryR\bmetadata\x12\x18\n" +
+	"\alatency\x18\a \x01(\x03R\alatency\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\fR\x05value:\x028\x01\x1aI\n" +
+	"\tAddrEntry\x12\x12\n" +
+	"\x04addr\x18\x01 \x01(\fR\x04addr\x12\x10\n" +
+	"\x03ttl\x18\x02 \x01(\x03R\x03ttl\x12\x16\n" +
+	"\x06expiry\x18\x03 \x01(\x03R\x06expiryB3Z1github.com/libp2p/go-libp2p/p2p/host/peerstore/pbb\x06proto3"
+
+var (
+	file_p2p_host_peerstore_pb_snapshot_proto_rawDescOnce sync.Once
+	file_p2p_host_peerstore_pb_snapshot_proto_rawDescData []byte
+)
+
+func file_p2p_host_peerstore_pb_snapshot_proto_rawDescGZIP() []byte {
+	file_p2p_host_peerstore_pb_snapshot_proto_rawDescOnce.Do(func() {
+		file_p2p_host_peerstore_pb_snapshot_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_p2p_host_peerstore_pb_snapshot_proto_rawDesc), len(file_p2p_host_peerstore_pb_snapshot_proto_rawDesc)))
+	})
+	return file_p2p_host_peerstore_pb_snapshot_proto_rawDescData
+}
+
+var file_p2p_host_peerstore_pb_snapshot_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_p2p_host_peerstore_pb_snapshot_proto_goTypes = []any{
+	(*Snapshot)(nil),            // 0: peerstore.pb.Snapshot
+	(*PeerEntry)(nil),           // 1: peerstore.pb.PeerEntry
+	nil,                         // 2: peerstore.pb.PeerEntry.MetadataEntry
+	(*PeerEntry_AddrEntry)(nil), // 3: peerstore.pb.PeerEntry.AddrEntry
+}
+var file_p2p_host_peerstore_pb_snapshot_proto_depIdxs = []int32{
+	1, // 0: peerstore.pb.Snapshot.peers:type_name -> peerstore.pb.PeerEntry
+	3, // 1: peerstore.pb.PeerEntry.addrs:type_name -> peerstore.pb.PeerEntry.AddrEntry
+	2, // 2: peerstore.pb.PeerEntry.metadata:type_name -> peerstore.pb.PeerEntry.MetadataEntry
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_p2p_host_peerstore_pb_snapshot_proto_init() }
+func file_p2p_host_peerstore_pb_snapshot_proto_init() {
+	if File_p2p_host_peerstore_pb_snapshot_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_p2p_host_peerstore_pb_snapshot_proto_rawDesc), len(file_p2p_host_peerstore_pb_snapshot_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_p2p_host_peerstore_pb_snapshot_proto_goTypes,
+		DependencyIndexes: file_p2p_host_peerstore_pb_snapshot_proto_depIdxs,
+		MessageInfos:      file_p2p_host_peerstore_pb_snapshot_proto_msgTypes,
+	}.Build()
+	File_p2p_host_peerstore_pb_snapshot_proto = out.File
+	file_p2p_host_peerstore_pb_snapshot_proto_goTypes = nil
+	file_p2p_host_peerstore_pb_snapshot_proto_depIdxs = nil
+}
@@ -48,6 +48,14 @@ func TestDsPeerstore(t *testing.T) {
 	}
 }
 
+func TestDsSnapshot(t *testing.T) {
+	for name, dsFactory := range dstores {
+		t.Run(name, func(t *testing.T) {
+			pt.TestSnapshot(t, peerstoreFactory(t, dsFactory, DefaultOpts()))
+		})
+	}
+}
+
 func TestDsAddrBook(t *testing.T) {
 	for name, dsFactory := range dstores {
 		t.Run(name+" Cacheful", func(t *testing.T) {
@@ -22,6 +22,9 @@ func (s *protoSegments) get(p peer.ID) *protoSegment {
 
 var errTooManyProtocols = errors.New("too many protocols")
 
+// protocolsKey is the metadata key the protocols of a peer are stored under.
+const protocolsKey = "protocols"
+
 type ProtoBookOption func(*dsProtoBook) error
 
 func WithMaxProtocols(num int) ProtoBookOption {
@@ -73,7 +76,7 @@ func (pb *dsProtoBook) SetProtocols(p peer.ID, protos ...protocol.ID) error {
 	s.Lock()
 	defer s.Unlock()
 
-	return pb.meta.Put(p, "protocols", protomap)
+	return pb.meta.Put(p, protocolsKey, protomap)
 }
 
 func (pb *dsProtoBook) AddProtocols(p peer.ID, protos ...protocol.ID) error {
@@ -93,7 +96,7 @@ func (pb *dsProtoBook) AddProtocols(p peer.ID, protos ...protocol.ID) error {
 		pmap[proto] = struct{}{}
 	}
 
-	return pb.meta.Put(p, "protocols", pmap)
+	return pb.meta.Put(p, protocolsKey, pmap)
 }
 
 func (pb *dsProtoBook) GetProtocols(p peer.ID) ([]protocol.ID, error) {
@@ -171,11 +174,11 @@ func (pb *dsProtoBook) RemoveProtocols(p peer.ID, protos ...protocol.ID) error {
 		delete(pmap, proto)
 	}
 
-	return pb.meta.Put(p, "protocols", pmap)
+	return pb.meta.Put(p, protocolsKey, pmap)
 }
 
 func (pb *dsProtoBook) getProtocolMap(p peer.ID) (map[protocol.ID]struct{}, error) {
-	iprotomap, err := pb.meta.Get(p, "protocols")
+	iprotomap, err := pb.meta.Get(p, protocolsKey)
 	switch err {
 	default:
 		return nil, err
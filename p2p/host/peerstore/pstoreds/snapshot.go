@@ -0,0 +1,91 @@
+package pstoreds
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/multiformats/go-base32"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var _ pstore.Snapshotter = &pstoreds{}
+
+// Export writes a snapshot of the peerstore to w.
+func (ps *pstoreds) Export(w io.Writer, opts ...pstore.SnapshotOption) error {
+	src := pstore.SnapshotSource{
+		Addrs:    ps.dsAddrBook.snapshotAddrs,
+		Metadata: ps.dsPeerMetadata.all,
+	}
+	return pstore.WriteSnapshot(w, ps, src, ps.dsAddrBook.clock.Now(), opts...)
+}
+
+// Import adds the state of a snapshot read from r to the peerstore.
+func (ps *pstoreds) Import(r io.Reader, opts ...pstore.SnapshotOption) error {
+	return pstore.ReadSnapshot(r, ps, ps.dsAddrBook.clock.Now(), opts...)
+}
+
+// snapshotAddrs returns the unexpired addresses of the peer with their TTLs.
+func (ab *dsAddrBook) snapshotAddrs(p peer.ID) []pstore.SnapshotAddr {
+	pr, err := ab.loadRecord(p, true, true)
+	if err != nil {
+		log.Warnw("failed to load peerstore entry while exporting addrs", "peer", p, "error", err)
+		return nil
+	}
+
+	pr.RLock()
+	defer pr.RUnlock()
+
+	addrs := make([]pstore.SnapshotAddr, 0, len(pr.Addrs))
+	for _, a := range pr.Addrs {
+		addr, err := ma.NewMultiaddrBytes(a.Addr)
+		if err != nil {
+			log.Warnw("failed to parse peerstore entry while exporting addrs", "peer", p, "error", err)
+			continue
+		}
+		addrs = append(addrs, pstore.SnapshotAddr{
+			Addr:   addr,
+			TTL:    time.Duration(a.Ttl),
+			Expiry: time.Unix(a.Expiry, 0),
+		})
+	}
+	return addrs
+}
+
+// all returns all metadata of the peer, except for the protocols which are
+// managed by the protobook.
+func (pm *dsPeerMetadata) all(p peer.ID) map[string]interface{} {
+	prefix := pmBase.ChildString(base32.RawStdEncoding.EncodeToString([]byte(p)))
+	results, err := pm.ds.Query(context.TODO(), query.Query{Prefix: prefix.String()})
+	if err != nil {
+		log.Warnw("querying datastore when exporting metadata failed", "peer", p, "error", err)
+		return nil
+	}
+	defer results.Close()
+
+	m := make(map[string]interface{})
+	for r := range results.Next() {
+		if r.Error != nil {
+			log.Warnw("querying datastore when exporting metadata failed", "peer", p, "error", r.Error)
+			return m
+		}
+		key := ds.RawKey(r.Key).Name()
+		if key == protocolsKey {
+			continue
+		}
+		var v interface{}
+		if err := gob.NewDecoder(bytes.NewReader(r.Value)).Decode(&v); err != nil {
+			log.Warnw("failed to decode metadata value while exporting", "peer", p, "key", key, "error", err)
+			continue
+		}
+		m[key] = v
+	}
+	return m
+}
@@ -0,0 +1,323 @@
+package pstoreidx
+
+import (
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// peerEntry is the indexed state of a peer.
+type peerEntry struct {
+	addrs    map[string]ma.Multiaddr // addr.Bytes() -> addr
+	protos   map[protocol.ID]struct{}
+	meta     map[string]struct{}
+	lastSeen time.Time
+}
+
+func (e *peerEntry) empty() bool {
+	return len(e.addrs) == 0 && len(e.protos) == 0 && len(e.meta) == 0 && e.lastSeen.IsZero()
+}
+
+// index holds the secondary indexes of the peerstore.
+type index struct {
+	mx    sync.RWMutex
+	peers map[peer.ID]*peerEntry
+
+	byProto     map[protocol.ID]map[peer.ID]struct{}
+	byMeta      map[string]map[peer.ID]struct{}
+	byTransport map[int]map[peer.ID]int        // multiaddr protocol code -> peer -> number of addrs
+	byIP        map[netip.Addr]map[peer.ID]int // ip -> peer -> number of addrs
+	ips         []netip.Addr                   // sorted keys of byIP, for prefix lookups
+}
+
+func newIndex() *index {
+	return &index{
+		peers:       make(map[peer.ID]*peerEntry),
+		byProto:     make(map[protocol.ID]map[peer.ID]struct{}),
+		byMeta:      make(map[string]map[peer.ID]struct{}),
+		byTransport: make(map[int]map[peer.ID]int),
+		byIP:        make(map[netip.Addr]map[peer.ID]int),
+	}
+}
+
+// entry returns the entry of the peer, creating it if needed. The lock must be held.
+func (idx *index) entry(p peer.ID) *peerEntry {
+	e, ok := idx.peers[p]
+	if !ok {
+		e = &peerEntry{
+			addrs:  make(map[string]ma.Multiaddr),
+			protos: make(map[protocol.ID]struct{}),
+			meta:   make(map[string]struct{}),
+		}
+		idx.peers[p] = e
+	}
+	return e
+}
+
+// maybeRemove removes the entry of the peer if it holds no state. The lock must be held.
+func (idx *index) maybeRemove(p peer.ID, e *peerEntry) {
+	if e.empty() {
+		delete(idx.peers, p)
+	}
+}
+
+func addToSet[K comparable](m map[K]map[peer.ID]struct{}, k K, p peer.ID) {
+	s, ok := m[k]
+	if !ok {
+		s = make(map[peer.ID]struct{})
+		m[k] = s
+	}
+	s[p] = struct{}{}
+}
+
+func removeFromSet[K comparable](m map[K]map[peer.ID]struct{}, k K, p peer.ID) {
+	s := m[k]
+	delete(s, p)
+	if len(s) == 0 {
+		delete(m, k)
+	}
+}
+
+func addrCodes(a ma.Multiaddr) []int {
+	codes := make([]int, 0, len(a))
+	for _, c := range a {
+		if !slices.Contains(codes, c.Code()) {
+			codes = append(codes, c.Code())
+		}
+	}
+	return codes
+}
+
+func (idx *index) indexAddr(p peer.ID, a ma.Multiaddr) {
+	for _, code := range addrCodes(a) {
+		m, ok := idx.byTransport[code]
+		if !ok {
+			m = make(map[peer.ID]int)
+			idx.byTransport[code] = m
+		}
+		m[p]++
+	}
+	if ip, ok := pstore.AddrIP(a); ok {
+		m, ok := idx.byIP[ip]
+		if !ok {
+			m = make(map[peer.ID]int)
+			idx.byIP[ip] = m
+			i, _ := slices.BinarySearchFunc(idx.ips, ip, netip.Addr.Compare)
+			idx.ips = slices.Insert(idx.ips, i, ip)
+		}
+		m[p]++
+	}
+}
+
+func (idx *index) unindexAddr(p peer.ID, a ma.Multiaddr) {
+	for _, code := range addrCodes(a) {
+		m := idx.byTransport[code]
+		if m[p]--; m[p] <= 0 {
+			delete(m, p)
+		}
+		if len(m) == 0 {
+			delete(idx.byTransport, code)
+		}
+	}
+	if ip, ok := pstore.AddrIP(a); ok {
+		m := idx.byIP[ip]
+		if m[p]--; m[p] <= 0 {
+			delete(m, p)
+		}
+		if len(m) == 0 {
+			delete(idx.byIP, ip)
+			if i, found := slices.BinarySearchFunc(idx.ips, ip, netip.Addr.Compare); found {
+				idx.ips = slices.Delete(idx.ips, i, i+1)
+			}
+		}
+	}
+}
+
+// setAddrs replaces the indexed addresses of the peer.
+func (idx *index) setAddrs(p peer.ID, addrs []ma.Multiaddr) {
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+
+	e := idx.entry(p)
+	current := make(map[string]ma.Multiaddr, len(addrs))
+	for _, a := range addrs {
+		current[string(a.Bytes())] = a
+	}
+	for k, a := range e.addrs {
+		if _, ok := current[k]; !ok {
+			idx.unindexAddr(p, a)
+			delete(e.addrs, k)
+		}
+	}
+	for k, a := range current {
+		if _, ok := e.addrs[k]; !ok {
+			idx.indexAddr(p, a)
+			e.addrs[k] = a
+		}
+	}
+	idx.maybeRemove(p, e)
+}
+
+// setProtocols replaces the indexed protocols of the peer.
+func (idx *index) setProtocols(p peer.ID, protos []protocol.ID) {
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+
+	e := idx.entry(p)
+	for proto := range e.protos {
+		if !slices.Contains(protos, proto) {
+			removeFromSet(idx.byProto, proto, p)
+			delete(e.protos, proto)
+		}
+	}
+	for _, proto := range protos {
+		e.protos[proto] = struct{}{}
+		addToSet(idx.byProto, proto, p)
+	}
+	idx.maybeRemove(p, e)
+}
+
+func (idx *index) addMetadataKey(p peer.ID, key string) {
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+
+	idx.entry(p).meta[key] = struct{}{}
+	addToSet(idx.byMeta, key, p)
+}
+
+func (idx *index) touch(p peer.ID, now time.Time) {
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+
+	if e := idx.entry(p); now.After(e.lastSeen) {
+		e.lastSeen = now
+	}
+}
+
+// removePeer removes all indexed state of the peer except its addresses.
+func (idx *index) removePeer(p peer.ID) {
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+
+	e, ok := idx.peers[p]
+	if !ok {
+		return
+	}
+	for proto := range e.protos {
+		removeFromSet(idx.byProto, proto, p)
+	}
+	for key := range e.meta {
+		removeFromSet(idx.byMeta, key, p)
+	}
+	clear(e.protos)
+	clear(e.meta)
+	e.lastSeen = time.Time{}
+	idx.maybeRemove(p, e)
+}
+
+// peersWithAddrs returns the peers with indexed addresses.
+func (idx *index) peersWithAddrs() []peer.ID {
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+
+	peers := make([]peer.ID, 0, len(idx.peers))
+	for p, e := range idx.peers {
+		if len(e.addrs) > 0 {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// candidates returns the peers matching the query according to the index,
+// sorted by peer ID. Addresses may have expired since they were indexed, so
+// peers selected by their addresses need to be checked against the address
+// book.
+func (idx *index) candidates(q *pstore.Query) []peer.ID {
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+
+	// Start from the smallest set of peers given by an index.
+	var smallest map[peer.ID]struct{}
+	consider := func(s map[peer.ID]struct{}) {
+		if smallest == nil || len(s) < len(smallest) {
+			smallest = s
+		}
+	}
+	for _, proto := range q.Protocols {
+		consider(idx.byProto[proto])
+	}
+	if q.MetadataKey != "" {
+		consider(idx.byMeta[q.MetadataKey])
+	}
+	if q.Transport != "" {
+		code := ma.ProtocolWithName(q.Transport).Code
+		s := make(map[peer.ID]struct{}, len(idx.byTransport[code]))
+		for p := range idx.byTransport[code] {
+			s[p] = struct{}{}
+		}
+		consider(s)
+	}
+	if q.AddrPrefix.IsValid() {
+		s := make(map[peer.ID]struct{})
+		start, _ := slices.BinarySearchFunc(idx.ips, q.AddrPrefix.Addr(), netip.Addr.Compare)
+		for _, ip := range idx.ips[start:] {
+			if !q.AddrPrefix.Contains(ip) {
+				break
+			}
+			for p := range idx.byIP[ip] {
+				s[p] = struct{}{}
+			}
+		}
+		consider(s)
+	}
+
+	var result []peer.ID
+	match := func(p peer.ID, e *peerEntry) {
+		for _, proto := range q.Protocols {
+			if _, ok := e.protos[proto]; !ok {
+				return
+			}
+		}
+		if q.MetadataKey != "" {
+			if _, ok := e.meta[q.MetadataKey]; !ok {
+				return
+			}
+		}
+		if !q.SeenAfter.IsZero() && !e.lastSeen.After(q.SeenAfter) {
+			return
+		}
+		if q.HasAddrCriteria() && !slices.ContainsFunc(mapValues(e.addrs), q.MatchesAddr) {
+			return
+		}
+		result = append(result, p)
+	}
+	if smallest != nil {
+		for p := range smallest {
+			if e, ok := idx.peers[p]; ok {
+				match(p, e)
+			}
+		}
+	} else {
+		for p, e := range idx.peers {
+			match(p, e)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+func mapValues[K comparable, V any](m map[K]V) []V {
+	values := make([]V, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+	return values
+}
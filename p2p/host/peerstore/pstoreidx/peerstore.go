@@ -0,0 +1,405 @@
+// Package pstoreidx provides a peerstore that maintains secondary indexes over
+// another peerstore, such as a pstoremem or pstoreds peerstore, so that it can
+// be queried for peers by protocol, address, transport, metadata key and last
+// seen time without scanning all peers. Queries go through the core
+// peerstore.Querier interface, see peerstore.GetQuerier.
+package pstoreidx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/record"
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
+
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("peerstore/idx")
+
+type clock interface {
+	Now() time.Time
+}
+
+type realclock struct{}
+
+func (rc realclock) Now() time.Time {
+	return time.Now()
+}
+
+// defaultMetadataKeys are the metadata keys that are indexed for the peers
+// already present in the wrapped peerstore.
+var defaultMetadataKeys = []string{"AgentVersion", "ProtocolVersion"}
+
+type Option func(*idxPeerstore) error
+
+// WithClock sets the clock used for the last seen times.
+func WithClock(c clock) Option {
+	return func(ps *idxPeerstore) error {
+		ps.clock = c
+		return nil
+	}
+}
+
+// WithRefreshInterval sets the interval at which the indexed addresses are
+// refreshed, to drop the addresses that expired in the wrapped peerstore.
+// Defaults to one minute.
+func WithRefreshInterval(d time.Duration) Option {
+	return func(ps *idxPeerstore) error {
+		if d <= 0 {
+			return errors.New("refresh interval must be positive")
+		}
+		ps.refreshInterval = d
+		return nil
+	}
+}
+
+// WithMetadataKeys adds metadata keys to index for the peers already present in
+// the wrapped peerstore. Metadata stored through the indexed peerstore is
+// always indexed.
+func WithMetadataKeys(keys ...string) Option {
+	return func(ps *idxPeerstore) error {
+		ps.metadataKeys = append(ps.metadataKeys, keys...)
+		return nil
+	}
+}
+
+type segment struct {
+	sync.Mutex
+}
+
+// segments serialize the updates of a peer's state and its index entries.
+type segments [256]*segment
+
+func (s *segments) get(p peer.ID) *segment {
+	if len(p) == 0 {
+		return s[0]
+	}
+	return s[p[len(p)-1]]
+}
+
+type idxPeerstore struct {
+	peerstore.Peerstore
+	cab peerstore.CertifiedAddrBook
+
+	idx      *index
+	segments segments
+
+	clock           clock
+	refreshInterval time.Duration
+	metadataKeys    []string
+
+	refCount sync.WaitGroup
+	cancel   context.CancelFunc
+}
+
+var (
+	_ peerstore.Peerstore         = &idxPeerstore{}
+	_ peerstore.CertifiedAddrBook = &idxPeerstore{}
+	_ peerstore.Querier           = &idxPeerstore{}
+	_ pstore.Snapshotter          = &idxPeerstore{}
+	_ pstore.AddrTracker          = &idxPeerstore{}
+)
+
+// NewPeerstore creates a peerstore that indexes the state of ps. The wrapped
+// peerstore must implement peerstore.CertifiedAddrBook, and must only be
+// modified through the returned peerstore afterwards. Closing the returned
+// peerstore closes ps.
+//
+// The addresses and protocols of the peers already in ps are indexed when the
+// peerstore is created, as are their metadata values under the keys given by
+// WithMetadataKeys. Their last seen times are unknown.
+//
+// A peer is seen whenever addresses, a signed peer record, protocols or a
+// latency measurement are added for it.
+func NewPeerstore(ps peerstore.Peerstore, opts ...Option) (*idxPeerstore, error) {
+	cab, ok := peerstore.GetCertifiedAddrBook(ps)
+	if !ok {
+		return nil, errors.New("peerstore must implement CertifiedAddrBook")
+	}
+	ips := &idxPeerstore{
+		Peerstore:       ps,
+		cab:             cab,
+		idx:             newIndex(),
+		clock:           realclock{},
+		refreshInterval: time.Minute,
+		metadataKeys:    append([]string(nil), defaultMetadataKeys...),
+	}
+	for i := range ips.segments {
+		ips.segments[i] = &segment{}
+	}
+	for _, opt := range opts {
+		if err := opt(ips); err != nil {
+			return nil, err
+		}
+	}
+	ips.rebuild()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ips.cancel = cancel
+	ips.refCount.Add(1)
+	go ips.background(ctx)
+	return ips, nil
+}
+
+// rebuild indexes the state of all peers of the wrapped peerstore.
+func (ps *idxPeerstore) rebuild() {
+	for _, p := range ps.Peerstore.Peers() {
+		s := ps.segments.get(p)
+		s.Lock()
+		ps.reindexAddrs(p)
+		ps.reindexProtocols(p)
+		for _, key := range ps.metadataKeys {
+			if _, err := ps.Peerstore.Get(p, key); err == nil {
+				ps.idx.addMetadataKey(p, key)
+			}
+		}
+		s.Unlock()
+	}
+}
+
+func (ps *idxPeerstore) background(ctx context.Context) {
+	defer ps.refCount.Done()
+
+	ticker := time.NewTicker(ps.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, p := range ps.idx.peersWithAddrs() {
+				s := ps.segments.get(p)
+				s.Lock()
+				ps.reindexAddrs(p)
+				s.Unlock()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (ps *idxPeerstore) Close() error {
+	ps.cancel()
+	ps.refCount.Wait()
+	return ps.Peerstore.Close()
+}
+
+// reindexAddrs updates the indexed addresses of the peer. The peer's segment must be locked.
+func (ps *idxPeerstore) reindexAddrs(p peer.ID) {
+	ps.idx.setAddrs(p, ps.Peerstore.Addrs(p))
+}
+
+// reindexProtocols updates the indexed protocols of the peer. The peer's segment must be locked.
+func (ps *idxPeerstore) reindexProtocols(p peer.ID) {
+	protos, err := ps.Peerstore.GetProtocols(p)
+	if err != nil {
+		log.Warnw("failed to get protocols for indexing", "peer", p, "error", err)
+		return
+	}
+	ps.idx.setProtocols(p, protos)
+}
+
+// updateAddrs runs the update of the addresses of the peer and reindexes them.
+func (ps *idxPeerstore) updateAddrs(p peer.ID, seen bool, update func()) {
+	s := ps.segments.get(p)
+	s.Lock()
+	defer s.Unlock()
+
+	update()
+	ps.reindexAddrs(p)
+	if seen {
+		ps.idx.touch(p, ps.clock.Now())
+	}
+}
+
+func (ps *idxPeerstore) AddAddr(p peer.ID, addr ma.Multiaddr, ttl time.Duration) {
+	ps.AddAddrs(p, []ma.Multiaddr{addr}, ttl)
+}
+
+func (ps *idxPeerstore) AddAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) {
+	ps.updateAddrs(p, ttl > 0 && len(addrs) > 0, func() { ps.Peerstore.AddAddrs(p, addrs, ttl) })
+}
+
+func (ps *idxPeerstore) SetAddr(p peer.ID, addr ma.Multiaddr, ttl time.Duration) {
+	ps.SetAddrs(p, []ma.Multiaddr{addr}, ttl)
+}
+
+func (ps *idxPeerstore) SetAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) {
+	ps.updateAddrs(p, ttl > 0 && len(addrs) > 0, func() { ps.Peerstore.SetAddrs(p, addrs, ttl) })
+}
+
+func (ps *idxPeerstore) UpdateAddrs(p peer.ID, oldTTL time.Duration, newTTL time.Duration) {
+	ps.updateAddrs(p, false, func() { ps.Peerstore.UpdateAddrs(p, oldTTL, newTTL) })
+}
+
+func (ps *idxPeerstore) ClearAddrs(p peer.ID) {
+	ps.updateAddrs(p, false, func() { ps.Peerstore.ClearAddrs(p) })
+}
+
+// AddAddrsWithSource adds the addresses to the wrapped peerstore, recording
+// their source if it supports it.
+func (ps *idxPeerstore) AddAddrsWithSource(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration, src pstore.AddrSource) {
+	ps.updateAddrs(p, ttl > 0 && len(addrs) > 0, func() { pstore.AddAddrsWithSource(ps.Peerstore, p, addrs, ttl, src) })
+}
+
+// RecordDialSuccess records a successful dial if the wrapped peerstore
+// supports it.
+func (ps *idxPeerstore) RecordDialSuccess(p peer.ID, addr ma.Multiaddr) {
+	pstore.RecordDialSuccess(ps.Peerstore, p, addr)
+}
+
+// RecordDialFailure records a failed dial if the wrapped peerstore supports
+// it. This may remove the address.
+func (ps *idxPeerstore) RecordDialFailure(p peer.ID, addr ma.Multiaddr) {
+	ps.updateAddrs(p, false, func() { pstore.RecordDialFailure(ps.Peerstore, p, addr) })
+}
+
+// AddrStats returns the statistics of an address of the peer, if the wrapped
+// peerstore tracks them.
+func (ps *idxPeerstore) AddrStats(p peer.ID, addr ma.Multiaddr) (pstore.AddrStats, bool) {
+	if t, ok := ps.Peerstore.(pstore.AddrTracker); ok {
+		return t.AddrStats(p, addr)
+	}
+	return pstore.AddrStats{}, false
+}
+
+func (ps *idxPeerstore) ConsumePeerRecord(s *record.Envelope, ttl time.Duration) (accepted bool, err error) {
+	r, err := s.Record()
+	if err != nil {
+		return false, err
+	}
+	rec, ok := r.(*peer.PeerRecord)
+	if !ok {
+		return false, errors.New("unable to process envelope: not a PeerRecord")
+	}
+	ps.updateAddrs(rec.PeerID, false, func() { accepted, err = ps.cab.ConsumePeerRecord(s, ttl) })
+	if accepted {
+		ps.idx.touch(rec.PeerID, ps.clock.Now())
+	}
+	return accepted, err
+}
+
+func (ps *idxPeerstore) GetPeerRecord(p peer.ID) *record.Envelope {
+	return ps.cab.GetPeerRecord(p)
+}
+
+// updateProtocols runs the update of the protocols of the peer and reindexes them.
+func (ps *idxPeerstore) updateProtocols(p peer.ID, seen bool, update func() error) error {
+	s := ps.segments.get(p)
+	s.Lock()
+	defer s.Unlock()
+
+	err := update()
+	ps.reindexProtocols(p)
+	if err == nil && seen {
+		ps.idx.touch(p, ps.clock.Now())
+	}
+	return err
+}
+
+func (ps *idxPeerstore) SetProtocols(p peer.ID, protos ...protocol.ID) error {
+	return ps.updateProtocols(p, true, func() error { return ps.Peerstore.SetProtocols(p, protos...) })
+}
+
+func (ps *idxPeerstore) AddProtocols(p peer.ID, protos ...protocol.ID) error {
+	return ps.updateProtocols(p, true, func() error { return ps.Peerstore.AddProtocols(p, protos...) })
+}
+
+func (ps *idxPeerstore) RemoveProtocols(p peer.ID, protos ...protocol.ID) error {
+	return ps.updateProtocols(p, false, func() error { return ps.Peerstore.RemoveProtocols(p, protos...) })
+}
+
+func (ps *idxPeerstore) Put(p peer.ID, key string, val interface{}) error {
+	s := ps.segments.get(p)
+	s.Lock()
+	defer s.Unlock()
+
+	if err := ps.Peerstore.Put(p, key, val); err != nil {
+		return err
+	}
+	ps.idx.addMetadataKey(p, key)
+	return nil
+}
+
+func (ps *idxPeerstore) RecordLatency(p peer.ID, d time.Duration) {
+	ps.Peerstore.RecordLatency(p, d)
+	ps.idx.touch(p, ps.clock.Now())
+}
+
+// RemovePeer removes the peer's information from the wrapped peerstore and
+// from the indexes, except its addresses.
+func (ps *idxPeerstore) RemovePeer(p peer.ID) {
+	s := ps.segments.get(p)
+	s.Lock()
+	defer s.Unlock()
+
+	ps.Peerstore.RemovePeer(p)
+	ps.idx.removePeer(p)
+}
+
+// QueryPeers returns the peers that match the query, ordered by peer ID.
+func (ps *idxPeerstore) QueryPeers(q peerstore.Query) (peer.IDSlice, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	candidates := ps.idx.candidates(&q)
+	result := make(peer.IDSlice, 0, len(candidates))
+	for _, p := range candidates {
+		if q.Limit > 0 && len(result) >= q.Limit {
+			break
+		}
+		if q.HasAddrCriteria() && !ps.hasMatchingAddr(p, &q) {
+			continue
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// hasMatchingAddr checks that the peer still has an address matching the query
+// in the wrapped peerstore, as indexed addresses may have expired.
+func (ps *idxPeerstore) hasMatchingAddr(p peer.ID, q *peerstore.Query) bool {
+	addrs := ps.Peerstore.Addrs(p)
+	for _, a := range addrs {
+		if q.MatchesAddr(a) {
+			return true
+		}
+	}
+	s := ps.segments.get(p)
+	s.Lock()
+	ps.reindexAddrs(p)
+	s.Unlock()
+	return false
+}
+
+// Export writes a snapshot of the wrapped peerstore to w.
+func (ps *idxPeerstore) Export(w io.Writer, opts ...pstore.SnapshotOption) error {
+	s, ok := ps.Peerstore.(pstore.Snapshotter)
+	if !ok {
+		return fmt.Errorf("wrapped peerstore %T doesn't support snapshots", ps.Peerstore)
+	}
+	return s.Export(w, opts...)
+}
+
+// Import adds the state of a snapshot read from r to the wrapped peerstore,
+// and indexes it. As for the peers present when the peerstore is created, only
+// the metadata keys given by WithMetadataKeys are indexed.
+func (ps *idxPeerstore) Import(r io.Reader, opts ...pstore.SnapshotOption) error {
+	s, ok := ps.Peerstore.(pstore.Snapshotter)
+	if !ok {
+		return fmt.Errorf("wrapped peerstore %T doesn't support snapshots", ps.Peerstore)
+	}
+	err := s.Import(r, opts...)
+	ps.rebuild()
+	return err
+}
@@ -0,0 +1,120 @@
+package pstoreidx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoreds"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+	pt "github.com/libp2p/go-libp2p/p2p/host/peerstore/test"
+
+	mockClock "github.com/benbjohnson/clock"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+func memFactory(t *testing.T, clk *mockClock.Mock) pt.PeerstoreFactory {
+	return func() (pstore.Peerstore, func()) {
+		mem, err := pstoremem.NewPeerstore(pstoremem.WithClock(clk))
+		require.NoError(t, err)
+		ps, err := NewPeerstore(mem, WithClock(clk))
+		require.NoError(t, err)
+		return ps, func() { ps.Close() }
+	}
+}
+
+func dsFactory(t *testing.T, clk *mockClock.Mock) pt.PeerstoreFactory {
+	return func() (pstore.Peerstore, func()) {
+		opts := pstoreds.DefaultOpts()
+		opts.Clock = clk
+		dsps, err := pstoreds.NewPeerstore(context.Background(), sync.MutexWrap(ds.NewMapDatastore()), opts)
+		require.NoError(t, err)
+		ps, err := NewPeerstore(dsps, WithClock(clk))
+		require.NoError(t, err)
+		return ps, func() { ps.Close() }
+	}
+}
+
+func TestIndexedPeerstore(t *testing.T) {
+	t.Run("pstoremem", func(t *testing.T) {
+		clk := mockClock.NewMock()
+		pt.TestPeerstore(t, memFactory(t, clk))
+		pt.TestSnapshot(t, memFactory(t, clk))
+	})
+	t.Run("pstoreds", func(t *testing.T) {
+		clk := mockClock.NewMock()
+		pt.TestPeerstore(t, dsFactory(t, clk))
+		pt.TestSnapshot(t, dsFactory(t, clk))
+	})
+}
+
+func TestIndexedAddrBook(t *testing.T) {
+	t.Run("pstoremem", func(t *testing.T) {
+		clk := mockClock.NewMock()
+		factory := memFactory(t, clk)
+		pt.TestAddrBook(t, func() (pstore.AddrBook, func()) { return factory() }, clk)
+	})
+	t.Run("pstoreds", func(t *testing.T) {
+		clk := mockClock.NewMock()
+		factory := dsFactory(t, clk)
+		pt.TestAddrBook(t, func() (pstore.AddrBook, func()) { return factory() }, clk)
+	})
+}
+
+func TestIndexedKeyBook(t *testing.T) {
+	t.Run("pstoremem", func(t *testing.T) {
+		factory := memFactory(t, mockClock.NewMock())
+		pt.TestKeyBook(t, func() (pstore.KeyBook, func()) { return factory() })
+	})
+	t.Run("pstoreds", func(t *testing.T) {
+		factory := dsFactory(t, mockClock.NewMock())
+		pt.TestKeyBook(t, func() (pstore.KeyBook, func()) { return factory() })
+	})
+}
+
+func TestQuery(t *testing.T) {
+	t.Run("pstoremem", func(t *testing.T) {
+		clk := mockClock.NewMock()
+		pt.TestQuery(t, memFactory(t, clk), clk)
+	})
+	t.Run("pstoreds", func(t *testing.T) {
+		clk := mockClock.NewMock()
+		pt.TestQuery(t, dsFactory(t, clk), clk)
+	})
+}
+
+func TestRebuildIndex(t *testing.T) {
+	mem, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	p := pt.GeneratePeerIDs(1)[0]
+	mem.AddAddr(p, pt.Multiaddr("/ip4/1.2.3.4/tcp/1"), time.Hour)
+	require.NoError(t, mem.AddProtocols(p, "/a"))
+	require.NoError(t, mem.Put(p, "AgentVersion", "a"))
+	require.NoError(t, mem.Put(p, "custom", "a"))
+
+	ps, err := NewPeerstore(mem, WithMetadataKeys("custom"))
+	require.NoError(t, err)
+	defer ps.Close()
+
+	for _, q := range []peerstore.Query{
+		{Transport: "tcp"},
+		{Protocols: []protocol.ID{"/a"}},
+		{MetadataKey: "AgentVersion"},
+		{MetadataKey: "custom"},
+	} {
+		peers, err := ps.QueryPeers(q)
+		require.NoError(t, err)
+		require.Equal(t, []peer.ID{p}, []peer.ID(peers))
+	}
+}
+
+func TestRequiresCertifiedAddrBook(t *testing.T) {
+	_, err := NewPeerstore(struct{ pstore.Peerstore }{})
+	require.Error(t, err)
+}
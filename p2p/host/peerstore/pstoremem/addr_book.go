@@ -12,6 +12,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	"github.com/libp2p/go-libp2p/core/record"
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
 
 	logging "github.com/ipfs/go-log/v2"
 	ma "github.com/multiformats/go-multiaddr"
@@ -26,6 +27,12 @@ type expiringAddr struct {
 	Peer   peer.ID
 	// to sort by expiry time, -1 means it's not in the heap
 	heapIndex int
+
+	Source      pstore.AddrSource
+	LastSuccess time.Time
+	LastFailure time.Time
+	// consecutive dial failures since the last successful dial
+	Failures int
 }
 
 func (e *expiringAddr) ExpiredBy(t time.Time) bool {
@@ -165,6 +172,7 @@ func (rc realclock) Now() time.Time {
 const (
 	defaultMaxSignedPeerRecords = 100_000
 	defaultMaxUnconnectedAddrs  = 1_000_000
+	defaultMaxAddrFailures      = 5
 )
 
 // memoryAddrBook manages addresses.
@@ -174,6 +182,7 @@ type memoryAddrBook struct {
 	signedPeerRecords    map[peer.ID]*peerRecordState
 	maxUnconnectedAddrs  int
 	maxSignedPeerRecords int
+	maxAddrFailures      int
 
 	refCount sync.WaitGroup
 	cancel   func()
@@ -184,6 +193,7 @@ type memoryAddrBook struct {
 
 var _ peerstore.AddrBook = (*memoryAddrBook)(nil)
 var _ peerstore.CertifiedAddrBook = (*memoryAddrBook)(nil)
+var _ pstore.AddrTracker = (*memoryAddrBook)(nil)
 
 func NewAddrBook(opts ...AddrBookOption) *memoryAddrBook {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -196,6 +206,7 @@ func NewAddrBook(opts ...AddrBookOption) *memoryAddrBook {
 		clock:                realclock{},
 		maxUnconnectedAddrs:  defaultMaxUnconnectedAddrs,
 		maxSignedPeerRecords: defaultMaxSignedPeerRecords,
+		maxAddrFailures:      defaultMaxAddrFailures,
 	}
 	for _, opt := range opts {
 		opt(ab)
@@ -232,6 +243,19 @@ func WithMaxSignedPeerRecords(n int) AddrBookOption {
 	}
 }
 
+// WithMaxAddrFailures sets the number of consecutive failed dials after which
+// an address is removed, unless it is a connected or permanent address. 0
+// disables the removal. Defaults to 5.
+func WithMaxAddrFailures(n int) AddrBookOption {
+	return func(b *memoryAddrBook) error {
+		if n < 0 {
+			return errors.New("max address failures must not be negative")
+		}
+		b.maxAddrFailures = n
+		return nil
+	}
+}
+
 // background periodically schedules a gc
 func (mab *memoryAddrBook) background(ctx context.Context) {
 	defer mab.refCount.Done()
@@ -286,7 +310,13 @@ func (mab *memoryAddrBook) AddAddr(p peer.ID, addr ma.Multiaddr, ttl time.Durati
 // AddAddrs adds `addrs` for peer `p`, which will expire after the given `ttl`.
 // This function never reduces the TTL or expiration of an address.
 func (mab *memoryAddrBook) AddAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) {
-	mab.addAddrs(p, addrs, ttl)
+	mab.addAddrs(p, addrs, ttl, pstore.AddrSourceUnknown)
+}
+
+// AddAddrsWithSource is like AddAddrs, and records where the addresses were
+// learned from.
+func (mab *memoryAddrBook) AddAddrsWithSource(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration, src pstore.AddrSource) {
+	mab.addAddrs(p, addrs, ttl, src)
 }
 
 // ConsumePeerRecord adds addresses from a signed peer.PeerRecord, which will expire after the given TTL.
@@ -320,7 +350,7 @@ func (mab *memoryAddrBook) ConsumePeerRecord(recordEnvelope *record.Envelope, tt
 		Envelope: recordEnvelope,
 		Seq:      rec.Seq,
 	}
-	mab.addAddrsUnlocked(rec.PeerID, rec.Addrs, ttl)
+	mab.addAddrsUnlocked(rec.PeerID, rec.Addrs, ttl, pstore.AddrSourceSignedRecord)
 	return true, nil
 }
 
@@ -330,14 +360,14 @@ func (mab *memoryAddrBook) maybeDeleteSignedPeerRecordUnlocked(p peer.ID) {
 	}
 }
 
-func (mab *memoryAddrBook) addAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) {
+func (mab *memoryAddrBook) addAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration, src pstore.AddrSource) {
 	mab.mu.Lock()
 	defer mab.mu.Unlock()
 
-	mab.addAddrsUnlocked(p, addrs, ttl)
+	mab.addAddrsUnlocked(p, addrs, ttl, src)
 }
 
-func (mab *memoryAddrBook) addAddrsUnlocked(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) {
+func (mab *memoryAddrBook) addAddrsUnlocked(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration, src pstore.AddrSource) {
 	defer mab.maybeDeleteSignedPeerRecordUnlocked(p)
 
 	// if ttl is zero, exit. nothing to do.
@@ -365,10 +395,14 @@ func (mab *memoryAddrBook) addAddrsUnlocked(p peer.ID, addrs []ma.Multiaddr, ttl
 		a, found := mab.addrs.FindAddr(p, addr)
 		if !found {
 			// not found, announce it.
-			entry := &expiringAddr{Addr: addr, Expiry: exp, TTL: ttl, Peer: p}
+			entry := &expiringAddr{Addr: addr, Expiry: exp, TTL: ttl, Peer: p, Source: src}
 			mab.addrs.Insert(entry)
 			mab.subManager.BroadcastAddr(p, addr)
 		} else {
+			// keep the most trusted source
+			if src > a.Source {
+				a.Source = src
+			}
 			// update ttl & exp to whichever is greater between new and existing entry
 			var changed bool
 			if ttl > a.TTL {
@@ -465,14 +499,28 @@ func (mab *memoryAddrBook) UpdateAddrs(p peer.ID, oldTTL time.Duration, newTTL t
 	}
 }
 
-// Addrs returns all known (and valid) addresses for a given peer
+// Addrs returns all known (and valid) addresses for a given peer, ordered by
+// their dial history. See sortByDialHistory.
 func (mab *memoryAddrBook) Addrs(p peer.ID) []ma.Multiaddr {
 	mab.mu.RLock()
 	defer mab.mu.RUnlock()
-	if _, ok := mab.addrs.Addrs[p]; !ok {
+	amap, ok := mab.addrs.Addrs[p]
+	if !ok {
 		return nil
 	}
-	return validAddrs(mab.clock.Now(), mab.addrs.Addrs[p])
+	now := mab.clock.Now()
+	valid := make([]*expiringAddr, 0, len(amap))
+	for _, a := range amap {
+		if !a.ExpiredBy(now) {
+			valid = append(valid, a)
+		}
+	}
+	sortByDialHistory(valid)
+	addrs := make([]ma.Multiaddr, len(valid))
+	for i, a := range valid {
+		addrs[i] = a.Addr
+	}
+	return addrs
 }
 
 func validAddrs(now time.Time, amap map[string]*expiringAddr) []ma.Multiaddr {
@@ -660,3 +708,63 @@ func (mgr *AddrSubManager) AddrStream(ctx context.Context, p peer.ID, initial []
 
 	return out
 }
+
+// RecordDialSuccess records a successful dial to the address.
+func (mab *memoryAddrBook) RecordDialSuccess(p peer.ID, addr ma.Multiaddr) {
+	mab.mu.Lock()
+	defer mab.mu.Unlock()
+
+	addr, _ = peer.SplitAddr(addr)
+	if addr == nil {
+		return
+	}
+	if a, ok := mab.addrs.FindAddr(p, addr); ok {
+		a.LastSuccess = mab.clock.Now()
+		a.Failures = 0
+	}
+}
+
+// RecordDialFailure records a failed dial to the address. After too many
+// consecutive failures, the address is removed unless its TTL is at least
+// ConnectedAddrTTL, which covers both connected and permanent addresses.
+func (mab *memoryAddrBook) RecordDialFailure(p peer.ID, addr ma.Multiaddr) {
+	mab.mu.Lock()
+	defer mab.mu.Unlock()
+
+	addr, _ = peer.SplitAddr(addr)
+	if addr == nil {
+		return
+	}
+	a, ok := mab.addrs.FindAddr(p, addr)
+	if !ok {
+		return
+	}
+	a.LastFailure = mab.clock.Now()
+	a.Failures++
+	if mab.maxAddrFailures > 0 && a.Failures >= mab.maxAddrFailures && !a.IsConnected() {
+		log.Debugw("removing address after consecutive dial failures", "peer", p, "addr", addr, "failures", a.Failures)
+		mab.addrs.Delete(a)
+		mab.maybeDeleteSignedPeerRecordUnlocked(p)
+	}
+}
+
+// AddrStats returns the statistics of an address of the peer.
+func (mab *memoryAddrBook) AddrStats(p peer.ID, addr ma.Multiaddr) (pstore.AddrStats, bool) {
+	mab.mu.RLock()
+	defer mab.mu.RUnlock()
+
+	addr, _ = peer.SplitAddr(addr)
+	if addr == nil {
+		return pstore.AddrStats{}, false
+	}
+	a, ok := mab.addrs.FindAddr(p, addr)
+	if !ok || a.ExpiredBy(mab.clock.Now()) {
+		return pstore.AddrStats{}, false
+	}
+	return pstore.AddrStats{
+		Source:      a.Source,
+		LastSuccess: a.LastSuccess,
+		LastFailure: a.LastFailure,
+		Failures:    a.Failures,
+	}, true
+}
@@ -9,6 +9,10 @@ import (
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
+
+	mockClock "github.com/benbjohnson/clock"
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/require"
 )
@@ -186,6 +190,58 @@ func TestPeerLimits(t *testing.T) {
 	require.Equal(t, 1024, ab.addrs.NumUnconnectedAddrs())
 }
 
+func TestAddrDialHistory(t *testing.T) {
+	clk := mockClock.NewMock()
+	ab := NewAddrBook(WithClock(clk), WithMaxAddrFailures(3))
+	defer ab.Close()
+
+	p := peer.ID("peer")
+	succeeded := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	signed := ma.StringCast("/ip4/1.2.3.4/tcp/2")
+	identified := ma.StringCast("/ip4/1.2.3.4/tcp/3")
+	failed := ma.StringCast("/ip4/1.2.3.4/tcp/4")
+	permanent := ma.StringCast("/ip4/1.2.3.4/tcp/5")
+
+	ab.AddAddrsWithSource(p, []ma.Multiaddr{succeeded, identified, failed}, time.Hour, pstore.AddrSourceIdentify)
+	ab.AddAddrsWithSource(p, []ma.Multiaddr{signed}, time.Hour, pstore.AddrSourceDiscovery)
+	// The most trusted source is kept.
+	ab.AddAddrsWithSource(p, []ma.Multiaddr{signed}, time.Hour, pstore.AddrSourceSignedRecord)
+	ab.AddAddrsWithSource(p, []ma.Multiaddr{signed}, time.Hour, pstore.AddrSourceObserved)
+	ab.AddAddr(p, permanent, peerstore.PermanentAddrTTL)
+
+	ab.RecordDialFailure(p, failed)
+	clk.Add(time.Second)
+	ab.RecordDialFailure(p, succeeded)
+	clk.Add(time.Second)
+	ab.RecordDialSuccess(p, succeeded)
+
+	stats, ok := ab.AddrStats(p, succeeded)
+	require.True(t, ok)
+	require.Equal(t, pstore.AddrStats{
+		Source:      pstore.AddrSourceIdentify,
+		LastSuccess: clk.Now(),
+		LastFailure: clk.Now().Add(-time.Second),
+		Failures:    0,
+	}, stats)
+	stats, ok = ab.AddrStats(p, signed)
+	require.True(t, ok)
+	require.Equal(t, pstore.AddrSourceSignedRecord, stats.Source)
+
+	require.Equal(t, []ma.Multiaddr{succeeded, signed, identified, permanent, failed}, ab.Addrs(p))
+
+	// Chronically failing addresses are removed, except permanent ones.
+	for i := 0; i < 3; i++ {
+		ab.RecordDialFailure(p, failed)
+		ab.RecordDialFailure(p, permanent)
+	}
+	_, ok = ab.AddrStats(p, failed)
+	require.False(t, ok)
+	stats, ok = ab.AddrStats(p, permanent)
+	require.True(t, ok)
+	require.Equal(t, 3, stats.Failures)
+	require.Equal(t, []ma.Multiaddr{succeeded, signed, identified, permanent}, ab.Addrs(p))
+}
+
 func BenchmarkPeerAddrs(b *testing.B) {
 	sizes := [...]int{1, 10, 100, 1000, 10_000, 100_000, 1000_000}
 	for _, sz := range sizes {
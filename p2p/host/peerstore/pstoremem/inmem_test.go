@@ -38,6 +38,14 @@ func TestInMemoryPeerstore(t *testing.T) {
 	})
 }
 
+func TestInMemorySnapshot(t *testing.T) {
+	pt.TestSnapshot(t, func() (pstore.Peerstore, func()) {
+		ps, err := NewPeerstore()
+		require.NoError(t, err)
+		return ps, func() { ps.Close() }
+	})
+}
+
 func TestPeerstoreProtoStoreLimits(t *testing.T) {
 	const limit = 10
 	ps, err := NewPeerstore(WithMaxProtocols(limit))
@@ -0,0 +1,52 @@
+package pstoremem
+
+import (
+	"io"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
+)
+
+var _ pstore.Snapshotter = &pstoremem{}
+
+// Export writes a snapshot of the peerstore to w.
+func (ps *pstoremem) Export(w io.Writer, opts ...pstore.SnapshotOption) error {
+	src := pstore.SnapshotSource{
+		Addrs:    ps.memoryAddrBook.snapshotAddrs,
+		Metadata: ps.memoryPeerMetadata.all,
+	}
+	return pstore.WriteSnapshot(w, ps, src, ps.memoryAddrBook.clock.Now(), opts...)
+}
+
+// Import adds the state of a snapshot read from r to the peerstore.
+func (ps *pstoremem) Import(r io.Reader, opts ...pstore.SnapshotOption) error {
+	return pstore.ReadSnapshot(r, ps, ps.memoryAddrBook.clock.Now(), opts...)
+}
+
+// snapshotAddrs returns the unexpired addresses of the peer with their TTLs.
+func (mab *memoryAddrBook) snapshotAddrs(p peer.ID) []pstore.SnapshotAddr {
+	mab.mu.RLock()
+	defer mab.mu.RUnlock()
+
+	now := mab.clock.Now()
+	addrs := make([]pstore.SnapshotAddr, 0, len(mab.addrs.Addrs[p]))
+	for _, a := range mab.addrs.Addrs[p] {
+		if a.ExpiredBy(now) {
+			continue
+		}
+		addrs = append(addrs, pstore.SnapshotAddr{Addr: a.Addr, TTL: a.TTL, Expiry: a.Expiry})
+	}
+	return addrs
+}
+
+// all returns a copy of all metadata of the peer.
+func (ps *memoryPeerMetadata) all(p peer.ID) map[string]interface{} {
+	ps.dslock.RLock()
+	defer ps.dslock.RUnlock()
+
+	m := make(map[string]interface{}, len(ps.ds[p]))
+	for k, v := range ps.ds[p] {
+		m[k] = v
+	}
+	return m
+}
@@ -2,6 +2,7 @@ package pstoremem
 
 import (
 	"bytes"
+	"sort"
 
 	ma "github.com/multiformats/go-multiaddr"
 	mafmt "github.com/multiformats/go-multiaddr-fmt"
@@ -48,3 +49,49 @@ func (al addrList) Less(i, j int) bool {
 	// for the rest, just sort by bytes
 	return bytes.Compare(a.Bytes(), b.Bytes()) > 0
 }
+
+// dialHistoryClass ranks addresses by the outcome of the last dial.
+func dialHistoryClass(a *expiringAddr) int {
+	switch {
+	case !a.LastSuccess.IsZero() && !a.LastFailure.After(a.LastSuccess):
+		// the last dial succeeded
+		return 0
+	case a.LastFailure.IsZero():
+		// never dialed
+		return 1
+	default:
+		return 2
+	}
+}
+
+// sortByDialHistory orders addresses such that the addresses whose last dial
+// succeeded come first, most recent success first, followed by the addresses
+// that were never dialed, most trusted source first, and finally the addresses
+// whose last dial failed, fewest consecutive failures first.
+func sortByDialHistory(addrs []*expiringAddr) {
+	sort.Slice(addrs, func(i, j int) bool {
+		a, b := addrs[i], addrs[j]
+		ca, cb := dialHistoryClass(a), dialHistoryClass(b)
+		if ca != cb {
+			return ca < cb
+		}
+		switch ca {
+		case 0:
+			if !a.LastSuccess.Equal(b.LastSuccess) {
+				return a.LastSuccess.After(b.LastSuccess)
+			}
+		case 1:
+			if a.Source != b.Source {
+				return a.Source > b.Source
+			}
+		case 2:
+			if a.Failures != b.Failures {
+				return a.Failures < b.Failures
+			}
+			if !a.LastFailure.Equal(b.LastFailure) {
+				return a.LastFailure.Before(b.LastFailure)
+			}
+		}
+		return bytes.Compare(a.Addr.Bytes(), b.Addr.Bytes()) < 0
+	})
+}
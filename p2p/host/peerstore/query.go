@@ -0,0 +1,29 @@
+package peerstore
+
+import (
+	"net/netip"
+
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// Query is an alias of the core peerstore.Query.
+type Query = pstore.Query
+
+// Querier is an alias of the core peerstore.Querier.
+type Querier = pstore.Querier
+
+// AddrIP returns the IP address of a multiaddr, if it has one.
+func AddrIP(a ma.Multiaddr) (netip.Addr, bool) {
+	ip, err := manet.ToIP(a)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
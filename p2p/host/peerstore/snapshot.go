@@ -0,0 +1,336 @@
+package peerstore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	ic "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pb"
+
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+	"google.golang.org/protobuf/proto"
+)
+
+var log = logging.Logger("peerstore")
+
+// SnapshotVersion is the version of the snapshot format written by Export.
+const SnapshotVersion = 1
+
+// Snapshotter is implemented by peerstores that can export their state to, and
+// import it from, a snapshot.
+//
+// A snapshot contains, for every peer, its addresses with their TTLs, its
+// signed peer record, public key, protocols, metadata and latency. Private keys
+// are never exported.
+type Snapshotter interface {
+	// Export writes a snapshot of the peerstore to w.
+	Export(w io.Writer, opts ...SnapshotOption) error
+	// Import adds the state of a snapshot read from r to the peerstore.
+	Import(r io.Reader, opts ...SnapshotOption) error
+}
+
+// TTLClass is a class of address TTLs. TTLClasses can be combined to filter
+// addresses by several classes.
+type TTLClass uint8
+
+const (
+	// TTLClassPermanent is the class of addresses with pstore.PermanentAddrTTL.
+	TTLClassPermanent TTLClass = 1 << iota
+	// TTLClassConnected is the class of addresses with pstore.ConnectedAddrTTL.
+	TTLClassConnected
+	// TTLClassTemporary is the class of addresses with a TTL of at most
+	// pstore.TempAddrTTL.
+	TTLClassTemporary
+	// TTLClassOther is the class of all other addresses, e.g. with
+	// pstore.RecentlyConnectedAddrTTL or pstore.AddressTTL.
+	TTLClassOther
+
+	// TTLClassAll matches addresses of all classes.
+	TTLClassAll = TTLClassPermanent | TTLClassConnected | TTLClassTemporary | TTLClassOther
+)
+
+// ClassifyTTL returns the class of an address TTL.
+func ClassifyTTL(ttl time.Duration) TTLClass {
+	switch {
+	case ttl == pstore.PermanentAddrTTL:
+		return TTLClassPermanent
+	case ttl == pstore.ConnectedAddrTTL:
+		return TTLClassConnected
+	case ttl <= pstore.TempAddrTTL:
+		return TTLClassTemporary
+	default:
+		return TTLClassOther
+	}
+}
+
+type snapshotConfig struct {
+	peers      map[peer.ID]struct{}
+	ttlClasses TTLClass
+}
+
+func (cfg *snapshotConfig) includesPeer(p peer.ID) bool {
+	if cfg.peers == nil {
+		return true
+	}
+	_, ok := cfg.peers[p]
+	return ok
+}
+
+// SnapshotOption configures the export or import of a snapshot.
+type SnapshotOption func(*snapshotConfig) error
+
+// WithSnapshotPeers restricts the export or import to the given peers.
+func WithSnapshotPeers(peers ...peer.ID) SnapshotOption {
+	return func(cfg *snapshotConfig) error {
+		if cfg.peers == nil {
+			cfg.peers = make(map[peer.ID]struct{}, len(peers))
+		}
+		for _, p := range peers {
+			cfg.peers[p] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithSnapshotTTLClasses restricts the exported or imported addresses to the
+// given TTL classes. The other state of the peers is not affected.
+func WithSnapshotTTLClasses(c TTLClass) SnapshotOption {
+	return func(cfg *snapshotConfig) error {
+		if c == 0 || c&^TTLClassAll != 0 {
+			return fmt.Errorf("invalid ttl classes: %d", c)
+		}
+		cfg.ttlClasses = c
+		return nil
+	}
+}
+
+func newSnapshotConfig(opts []SnapshotOption) (*snapshotConfig, error) {
+	cfg := &snapshotConfig{ttlClasses: TTLClassAll}
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// SnapshotAddr is an address with its TTL, as stored by the address book.
+type SnapshotAddr struct {
+	Addr   ma.Multiaddr
+	TTL    time.Duration
+	Expiry time.Time
+}
+
+// SnapshotSource provides the state of a peerstore that is not accessible
+// through the pstore.Peerstore interface.
+type SnapshotSource struct {
+	// Addrs returns the unexpired addresses of a peer, with their TTLs.
+	Addrs func(peer.ID) []SnapshotAddr
+	// Metadata returns all metadata of a peer, by key.
+	Metadata func(peer.ID) map[string]interface{}
+}
+
+// WriteSnapshot writes a snapshot of the peerstore to w. It is used by
+// peerstore implementations to implement Snapshotter.
+func WriteSnapshot(w io.Writer, ps pstore.Peerstore, src SnapshotSource, now time.Time, opts ...SnapshotOption) error {
+	cfg, err := newSnapshotConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	peers := ps.Peers()
+	sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+
+	snap := &pb.Snapshot{
+		Version:   SnapshotVersion,
+		Timestamp: now.UnixNano(),
+	}
+	cab, hasCab := pstore.GetCertifiedAddrBook(ps)
+	for _, p := range peers {
+		if !cfg.includesPeer(p) {
+			continue
+		}
+		entry := &pb.PeerEntry{
+			Id:      []byte(p),
+			Latency: int64(ps.LatencyEWMA(p)),
+		}
+		for _, a := range src.Addrs(p) {
+			if ClassifyTTL(a.TTL)&cfg.ttlClasses == 0 {
+				continue
+			}
+			entry.Addrs = append(entry.Addrs, &pb.PeerEntry_AddrEntry{
+				Addr:   a.Addr.Bytes(),
+				Ttl:    int64(a.TTL),
+				Expiry: a.Expiry.UnixNano(),
+			})
+		}
+		if hasCab {
+			if env := cab.GetPeerRecord(p); env != nil {
+				if entry.SignedPeerRecord, err = env.Marshal(); err != nil {
+					return fmt.Errorf("failed to marshal peer record of %s: %w", p, err)
+				}
+			}
+		}
+		if pk := ps.PubKey(p); pk != nil {
+			if entry.PublicKey, err = ic.MarshalPublicKey(pk); err != nil {
+				return fmt.Errorf("failed to marshal public key of %s: %w", p, err)
+			}
+		}
+		protos, err := ps.GetProtocols(p)
+		if err != nil {
+			return fmt.Errorf("failed to get protocols of %s: %w", p, err)
+		}
+		for _, proto := range protos {
+			entry.Protocols = append(entry.Protocols, string(proto))
+		}
+		sort.Strings(entry.Protocols)
+		for k, v := range src.Metadata(p) {
+			var buf bytes.Buffer
+			if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+				log.Debugw("skipping metadata value that can't be gob encoded", "peer", p, "key", k, "error", err)
+				continue
+			}
+			if entry.Metadata == nil {
+				entry.Metadata = make(map[string][]byte)
+			}
+			entry.Metadata[k] = buf.Bytes()
+		}
+		snap.Peers = append(snap.Peers, entry)
+	}
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// ReadSnapshot reads a snapshot from r and adds its state to the peerstore. It
+// is used by peerstore implementations to implement Snapshotter.
+//
+// Addresses are added with the TTL remaining until their expiry, except for
+// permanent addresses, which stay permanent, and connected addresses, which are
+// added with pstore.RecentlyConnectedAddrTTL as there is no connection to the
+// peer. Expired addresses are skipped.
+func ReadSnapshot(r io.Reader, ps pstore.Peerstore, now time.Time, opts ...SnapshotOption) error {
+	cfg, err := newSnapshotConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	snap := &pb.Snapshot{}
+	if err := proto.Unmarshal(b, snap); err != nil {
+		return fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+	if snap.Version == 0 || snap.Version > SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version: %d", snap.Version)
+	}
+
+	for _, entry := range snap.Peers {
+		p, err := peer.IDFromBytes(entry.Id)
+		if err != nil {
+			return fmt.Errorf("invalid peer ID in snapshot: %w", err)
+		}
+		if !cfg.includesPeer(p) {
+			continue
+		}
+		if err := importPeer(ps, p, entry, cfg, now); err != nil {
+			return fmt.Errorf("failed to import peer %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func importPeer(ps pstore.Peerstore, p peer.ID, entry *pb.PeerEntry, cfg *snapshotConfig, now time.Time) error {
+	if len(entry.PublicKey) > 0 {
+		pk, err := ic.UnmarshalPublicKey(entry.PublicKey)
+		if err != nil {
+			return err
+		}
+		if err := ps.AddPubKey(p, pk); err != nil {
+			return err
+		}
+	}
+
+	addrsByTTL := make(map[time.Duration][]ma.Multiaddr)
+	minTTL := time.Duration(0)
+	for _, a := range entry.Addrs {
+		ttl := time.Duration(a.Ttl)
+		if ClassifyTTL(ttl)&cfg.ttlClasses == 0 {
+			continue
+		}
+		addr, err := ma.NewMultiaddrBytes(a.Addr)
+		if err != nil {
+			return err
+		}
+		switch ttl {
+		case pstore.PermanentAddrTTL:
+		case pstore.ConnectedAddrTTL:
+			ttl = pstore.RecentlyConnectedAddrTTL
+		default:
+			ttl = time.Unix(0, a.Expiry).Sub(now)
+			if ttl <= 0 {
+				continue
+			}
+		}
+		addrsByTTL[ttl] = append(addrsByTTL[ttl], addr)
+		if minTTL == 0 || ttl < minTTL {
+			minTTL = ttl
+		}
+	}
+
+	// Consume the peer record first, with the shortest TTL, so that adding the
+	// addresses extends each of them to its own TTL.
+	if cab, ok := pstore.GetCertifiedAddrBook(ps); ok && len(entry.SignedPeerRecord) > 0 && minTTL > 0 {
+		env, _, err := record.ConsumeEnvelope(entry.SignedPeerRecord, peer.PeerRecordEnvelopeDomain)
+		if err != nil {
+			return err
+		}
+		if _, err := cab.ConsumePeerRecord(env, minTTL); err != nil {
+			return err
+		}
+	}
+	for ttl, addrs := range addrsByTTL {
+		ps.AddAddrs(p, addrs, ttl)
+	}
+
+	if len(entry.Protocols) > 0 {
+		protos := make([]protocol.ID, 0, len(entry.Protocols))
+		for _, proto := range entry.Protocols {
+			protos = append(protos, protocol.ID(proto))
+		}
+		if err := ps.AddProtocols(p, protos...); err != nil {
+			return err
+		}
+	}
+
+	for k, b := range entry.Metadata {
+		var v interface{}
+		if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v); err != nil {
+			log.Warnw("skipping metadata value that can't be gob decoded", "peer", p, "key", k, "error", err)
+			continue
+		}
+		if err := ps.Put(p, k, v); err != nil {
+			return err
+		}
+	}
+
+	if entry.Latency > 0 {
+		ps.RecordLatency(p, time.Duration(entry.Latency))
+	}
+	return nil
+}
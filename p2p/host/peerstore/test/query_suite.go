@@ -0,0 +1,168 @@
+package test
+
+import (
+	"net/netip"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	mockClock "github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+var querySuite = map[string]func(pstore.Peerstore, *mockClock.Mock) func(*testing.T){
+	"ByProtocol":    testQueryByProtocol,
+	"ByAddress":     testQueryByAddress,
+	"ByMetadataKey": testQueryByMetadataKey,
+	"BySeen":        testQueryBySeen,
+	"ExpiredAddrs":  testQueryExpiredAddrs,
+	"RemovePeer":    testQueryRemovePeer,
+	"Invalid":       testQueryInvalid,
+}
+
+// TestQuery tests peerstore queries. The peerstores created by factory must
+// implement peerstore.Querier, and use clk.
+func TestQuery(t *testing.T, factory PeerstoreFactory, clk *mockClock.Mock) {
+	for name, test := range querySuite {
+		ps, closeFunc := factory()
+
+		t.Run(name, test(ps, clk))
+
+		if closeFunc != nil {
+			closeFunc()
+		}
+	}
+}
+
+func queryPeers(t *testing.T, ps pstore.Peerstore, q pstore.Query) peer.IDSlice {
+	t.Helper()
+	qs, ok := pstore.GetQuerier(ps)
+	require.True(t, ok, "expected peerstore to implement Querier")
+	peers, err := qs.QueryPeers(q)
+	require.NoError(t, err)
+	return peers
+}
+
+func sortedPeers(peers ...peer.ID) peer.IDSlice {
+	s := append(peer.IDSlice(nil), peers...)
+	sort.Sort(s)
+	return s
+}
+
+func testQueryByProtocol(ps pstore.Peerstore, _ *mockClock.Mock) func(*testing.T) {
+	return func(t *testing.T) {
+		peers := GeneratePeerIDs(3)
+		require.NoError(t, ps.SetProtocols(peers[0], "/a", "/b"))
+		require.NoError(t, ps.SetProtocols(peers[1], "/a"))
+		require.NoError(t, ps.SetProtocols(peers[2], "/b"))
+
+		require.Equal(t, sortedPeers(peers[0], peers[1]), queryPeers(t, ps, pstore.Query{Protocols: []protocol.ID{"/a"}}))
+		require.Equal(t, sortedPeers(peers[0]), queryPeers(t, ps, pstore.Query{Protocols: []protocol.ID{"/a", "/b"}}))
+		require.Empty(t, queryPeers(t, ps, pstore.Query{Protocols: []protocol.ID{"/c"}}))
+
+		require.NoError(t, ps.RemoveProtocols(peers[0], "/a"))
+		require.Equal(t, sortedPeers(peers[1]), queryPeers(t, ps, pstore.Query{Protocols: []protocol.ID{"/a"}}))
+		require.NoError(t, ps.SetProtocols(peers[2], "/a"))
+		require.Equal(t, sortedPeers(peers[1], peers[2]), queryPeers(t, ps, pstore.Query{Protocols: []protocol.ID{"/a"}}))
+		require.Equal(t, sortedPeers(peers[0]), queryPeers(t, ps, pstore.Query{Protocols: []protocol.ID{"/b"}}))
+
+		require.Len(t, queryPeers(t, ps, pstore.Query{Protocols: []protocol.ID{"/a"}, Limit: 1}), 1)
+	}
+}
+
+func testQueryByAddress(ps pstore.Peerstore, _ *mockClock.Mock) func(*testing.T) {
+	return func(t *testing.T) {
+		peers := GeneratePeerIDs(3)
+		ps.AddAddr(peers[0], Multiaddr("/ip4/10.0.1.1/tcp/1"), time.Hour)
+		ps.AddAddr(peers[1], Multiaddr("/ip4/10.0.2.1/udp/1/quic-v1"), time.Hour)
+		ps.AddAddr(peers[1], Multiaddr("/ip4/192.168.0.1/tcp/1"), time.Hour)
+		ps.AddAddr(peers[2], Multiaddr("/ip6/2001:db8::1/tcp/1"), time.Hour)
+
+		require.Equal(t, sortedPeers(peers[0], peers[1]), queryPeers(t, ps, pstore.Query{AddrPrefix: netip.MustParsePrefix("10.0.0.0/16")}))
+		require.Equal(t, sortedPeers(peers[1]), queryPeers(t, ps, pstore.Query{AddrPrefix: netip.MustParsePrefix("10.0.2.0/24")}))
+		require.Equal(t, sortedPeers(peers[2]), queryPeers(t, ps, pstore.Query{AddrPrefix: netip.MustParsePrefix("2001:db8::/32")}))
+		require.Equal(t, sortedPeers(peers[0], peers[1], peers[2]), queryPeers(t, ps, pstore.Query{Transport: "tcp"}))
+		require.Equal(t, sortedPeers(peers[1]), queryPeers(t, ps, pstore.Query{Transport: "quic-v1"}))
+		// A single address must match both the prefix and the transport.
+		require.Equal(t, sortedPeers(peers[0]), queryPeers(t, ps, pstore.Query{AddrPrefix: netip.MustParsePrefix("10.0.0.0/16"), Transport: "tcp"}))
+
+		ps.SetAddr(peers[0], Multiaddr("/ip4/10.0.1.1/tcp/1"), 0)
+		require.Equal(t, sortedPeers(peers[1]), queryPeers(t, ps, pstore.Query{AddrPrefix: netip.MustParsePrefix("10.0.0.0/16")}))
+		ps.ClearAddrs(peers[1])
+		require.Empty(t, queryPeers(t, ps, pstore.Query{AddrPrefix: netip.MustParsePrefix("10.0.0.0/8")}))
+	}
+}
+
+func testQueryByMetadataKey(ps pstore.Peerstore, _ *mockClock.Mock) func(*testing.T) {
+	return func(t *testing.T) {
+		peers := GeneratePeerIDs(2)
+		require.NoError(t, ps.Put(peers[0], "AgentVersion", "a"))
+		require.NoError(t, ps.Put(peers[1], "other", 1))
+
+		require.Equal(t, sortedPeers(peers[0]), queryPeers(t, ps, pstore.Query{MetadataKey: "AgentVersion"}))
+		require.Equal(t, sortedPeers(peers[1]), queryPeers(t, ps, pstore.Query{MetadataKey: "other"}))
+		require.Empty(t, queryPeers(t, ps, pstore.Query{MetadataKey: "missing"}))
+	}
+}
+
+func testQueryBySeen(ps pstore.Peerstore, clk *mockClock.Mock) func(*testing.T) {
+	return func(t *testing.T) {
+		peers := GeneratePeerIDs(3)
+		ps.AddAddr(peers[0], Multiaddr("/ip4/1.2.3.4/tcp/1"), time.Hour)
+		clk.Add(time.Minute)
+		start := clk.Now()
+		clk.Add(time.Minute)
+		require.NoError(t, ps.AddProtocols(peers[1], "/a"))
+		ps.RecordLatency(peers[2], time.Millisecond)
+
+		require.Equal(t, sortedPeers(peers[1], peers[2]), queryPeers(t, ps, pstore.Query{SeenAfter: start}))
+		require.Equal(t, sortedPeers(peers[1]), queryPeers(t, ps, pstore.Query{SeenAfter: start, Protocols: []protocol.ID{"/a"}}))
+
+		clk.Add(time.Minute)
+		ps.AddAddr(peers[0], Multiaddr("/ip4/1.2.3.4/tcp/1"), time.Hour)
+		require.Equal(t, sortedPeers(peers[0], peers[1], peers[2]), queryPeers(t, ps, pstore.Query{SeenAfter: start}))
+	}
+}
+
+func testQueryExpiredAddrs(ps pstore.Peerstore, clk *mockClock.Mock) func(*testing.T) {
+	return func(t *testing.T) {
+		peers := GeneratePeerIDs(2)
+		ps.AddAddr(peers[0], Multiaddr("/ip4/1.2.3.4/tcp/1"), time.Hour)
+		ps.AddAddr(peers[1], Multiaddr("/ip4/1.2.3.5/tcp/1"), 2*time.Hour)
+
+		clk.Add(90 * time.Minute)
+		require.Equal(t, sortedPeers(peers[1]), queryPeers(t, ps, pstore.Query{Transport: "tcp"}))
+	}
+}
+
+func testQueryRemovePeer(ps pstore.Peerstore, _ *mockClock.Mock) func(*testing.T) {
+	return func(t *testing.T) {
+		p := GeneratePeerIDs(1)[0]
+		ps.AddAddr(p, Multiaddr("/ip4/1.2.3.4/tcp/1"), time.Hour)
+		require.NoError(t, ps.AddProtocols(p, "/a"))
+		require.NoError(t, ps.Put(p, "key", "value"))
+
+		ps.RemovePeer(p)
+		require.Empty(t, queryPeers(t, ps, pstore.Query{Protocols: []protocol.ID{"/a"}}))
+		require.Empty(t, queryPeers(t, ps, pstore.Query{MetadataKey: "key"}))
+		// RemovePeer doesn't remove the addresses.
+		require.Equal(t, sortedPeers(p), queryPeers(t, ps, pstore.Query{Transport: "tcp"}))
+	}
+}
+
+func testQueryInvalid(ps pstore.Peerstore, _ *mockClock.Mock) func(*testing.T) {
+	return func(t *testing.T) {
+		qs, ok := pstore.GetQuerier(ps)
+		require.True(t, ok, "expected peerstore to implement Querier")
+		_, err := qs.QueryPeers(pstore.Query{Transport: "foobar"})
+		require.Error(t, err)
+		_, err = qs.QueryPeers(pstore.Query{AddrPrefix: netip.MustParsePrefix("10.0.0.1/8")})
+		require.Error(t, err)
+		_, err = qs.QueryPeers(pstore.Query{Limit: -1})
+		require.Error(t, err)
+	}
+}
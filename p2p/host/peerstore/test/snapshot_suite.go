@@ -0,0 +1,196 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+var snapshotSuite = map[string]func(PeerstoreFactory) func(*testing.T){
+	"RoundTrip":          testSnapshotRoundTrip,
+	"FilterPeers":        testSnapshotFilterPeers,
+	"FilterTTLClasses":   testSnapshotFilterTTLClasses,
+	"UnsupportedVersion": testSnapshotUnsupportedVersion,
+	"ConnectedAddrs":     testSnapshotConnectedAddrs,
+}
+
+// TestSnapshot tests the export and import of peerstore snapshots. The
+// peerstores created by factory must implement peerstore.Snapshotter.
+func TestSnapshot(t *testing.T, factory PeerstoreFactory) {
+	for name, test := range snapshotSuite {
+		t.Run(name, test(factory))
+	}
+}
+
+type snapshotPeer struct {
+	id   peer.ID
+	priv crypto.PrivKey
+	env  *record.Envelope
+}
+
+func newSnapshotPeer(t *testing.T, addrs []ma.Multiaddr) snapshotPeer {
+	t.Helper()
+	priv, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	rec := peer.NewPeerRecord()
+	rec.PeerID = id
+	rec.Addrs = addrs
+	env, err := record.Seal(rec, priv)
+	require.NoError(t, err)
+	return snapshotPeer{id: id, priv: priv, env: env}
+}
+
+func exportSnapshot(t *testing.T, ps pstore.Peerstore, opts ...peerstore.SnapshotOption) []byte {
+	t.Helper()
+	s, ok := ps.(peerstore.Snapshotter)
+	require.True(t, ok, "expected peerstore to implement Snapshotter")
+	var buf bytes.Buffer
+	require.NoError(t, s.Export(&buf, opts...))
+	return buf.Bytes()
+}
+
+func importSnapshot(t *testing.T, ps pstore.Peerstore, b []byte, opts ...peerstore.SnapshotOption) error {
+	t.Helper()
+	s, ok := ps.(peerstore.Snapshotter)
+	require.True(t, ok, "expected peerstore to implement Snapshotter")
+	return s.Import(bytes.NewReader(b), opts...)
+}
+
+func testSnapshotRoundTrip(factory PeerstoreFactory) func(*testing.T) {
+	return func(t *testing.T) {
+		src, closeSrc := factory()
+		defer closeSrc()
+
+		addrs := GenerateAddrs(3)
+		p := newSnapshotPeer(t, addrs[:2])
+		cab, ok := pstore.GetCertifiedAddrBook(src)
+		require.True(t, ok)
+		accepted, err := cab.ConsumePeerRecord(p.env, time.Hour)
+		require.NoError(t, err)
+		require.True(t, accepted)
+		src.AddAddr(p.id, addrs[2], pstore.PermanentAddrTTL)
+		require.NoError(t, src.AddPubKey(p.id, p.priv.GetPublic()))
+		require.NoError(t, src.AddPrivKey(p.id, p.priv))
+		require.NoError(t, src.SetProtocols(p.id, "/foo/1.0.0", "/bar/1.0.0"))
+		require.NoError(t, src.Put(p.id, "AgentVersion", "test/1.0"))
+		src.RecordLatency(p.id, 42*time.Millisecond)
+
+		b := exportSnapshot(t, src)
+
+		dst, closeDst := factory()
+		defer closeDst()
+		require.NoError(t, importSnapshot(t, dst, b))
+
+		AssertAddressesEqual(t, addrs, dst.Addrs(p.id))
+		require.True(t, p.priv.GetPublic().Equals(dst.PubKey(p.id)))
+		require.Nil(t, dst.PrivKey(p.id), "private keys must not be exported")
+		protos, err := dst.GetProtocols(p.id)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []protocol.ID{"/foo/1.0.0", "/bar/1.0.0"}, protos)
+		v, err := dst.Get(p.id, "AgentVersion")
+		require.NoError(t, err)
+		require.Equal(t, "test/1.0", v)
+		require.Equal(t, 42*time.Millisecond, dst.LatencyEWMA(p.id))
+
+		dstCab, ok := pstore.GetCertifiedAddrBook(dst)
+		require.True(t, ok)
+		env := dstCab.GetPeerRecord(p.id)
+		require.NotNil(t, env)
+		require.True(t, env.Equal(p.env))
+
+		// Exporting the imported state results in the same peer state.
+		require.NoError(t, importSnapshot(t, src, exportSnapshot(t, dst)))
+		AssertAddressesEqual(t, addrs, src.Addrs(p.id))
+	}
+}
+
+func testSnapshotFilterPeers(factory PeerstoreFactory) func(*testing.T) {
+	return func(t *testing.T) {
+		src, closeSrc := factory()
+		defer closeSrc()
+
+		peers := GeneratePeerIDs(3)
+		addrs := GenerateAddrs(3)
+		for i, p := range peers {
+			src.AddAddr(p, addrs[i], time.Hour)
+		}
+
+		dst, closeDst := factory()
+		defer closeDst()
+		b := exportSnapshot(t, src, peerstore.WithSnapshotPeers(peers[0], peers[1]))
+		require.NoError(t, importSnapshot(t, dst, b, peerstore.WithSnapshotPeers(peers[1])))
+
+		require.Empty(t, dst.Addrs(peers[0]))
+		AssertAddressesEqual(t, addrs[1:2], dst.Addrs(peers[1]))
+		require.Empty(t, dst.Addrs(peers[2]))
+	}
+}
+
+func testSnapshotFilterTTLClasses(factory PeerstoreFactory) func(*testing.T) {
+	return func(t *testing.T) {
+		src, closeSrc := factory()
+		defer closeSrc()
+
+		p := GeneratePeerIDs(1)[0]
+		addrs := GenerateAddrs(4)
+		src.AddAddr(p, addrs[0], pstore.PermanentAddrTTL)
+		src.AddAddr(p, addrs[1], pstore.ConnectedAddrTTL)
+		src.AddAddr(p, addrs[2], pstore.TempAddrTTL)
+		src.AddAddr(p, addrs[3], pstore.AddressTTL)
+
+		b := exportSnapshot(t, src, peerstore.WithSnapshotTTLClasses(peerstore.TTLClassPermanent|peerstore.TTLClassConnected|peerstore.TTLClassOther))
+
+		dst, closeDst := factory()
+		defer closeDst()
+		require.NoError(t, importSnapshot(t, dst, b, peerstore.WithSnapshotTTLClasses(peerstore.TTLClassPermanent|peerstore.TTLClassOther)))
+		AssertAddressesEqual(t, []ma.Multiaddr{addrs[0], addrs[3]}, dst.Addrs(p))
+
+		var buf bytes.Buffer
+		require.Error(t, src.(peerstore.Snapshotter).Export(&buf, peerstore.WithSnapshotTTLClasses(0)))
+	}
+}
+
+func testSnapshotConnectedAddrs(factory PeerstoreFactory) func(*testing.T) {
+	return func(t *testing.T) {
+		src, closeSrc := factory()
+		defer closeSrc()
+
+		p := GeneratePeerIDs(1)[0]
+		addrs := GenerateAddrs(1)
+		src.AddAddrs(p, addrs, pstore.ConnectedAddrTTL)
+
+		dst, closeDst := factory()
+		defer closeDst()
+		require.NoError(t, importSnapshot(t, dst, exportSnapshot(t, src)))
+		AssertAddressesEqual(t, addrs, dst.Addrs(p))
+
+		// There is no connection to the peer on the importing side, so the
+		// address must not be imported as connected.
+		dst.UpdateAddrs(p, pstore.RecentlyConnectedAddrTTL, 0)
+		require.Empty(t, dst.Addrs(p))
+	}
+}
+
+func testSnapshotUnsupportedVersion(factory PeerstoreFactory) func(*testing.T) {
+	return func(t *testing.T) {
+		ps, closePs := factory()
+		defer closePs()
+
+		// A snapshot with version 2 (field 1, varint).
+		require.ErrorContains(t, importSnapshot(t, ps, []byte{0x08, 0x02}), "unsupported snapshot version")
+		require.Error(t, importSnapshot(t, ps, []byte("not a snapshot")))
+	}
+}
@@ -33,6 +33,12 @@ type ResourceManagerStat struct {
 	Services  map[string]network.ScopeStat
 	Protocols map[protocol.ID]network.ScopeStat
 	Peers     map[peer.ID]network.ScopeStat
+
+	// ProtocolMemoryShares and ServiceMemoryShares hold the state of the
+	// active protocol and service scopes in the memory fair share mode.
+	// They are nil unless the mode is enabled with WithMemoryFairShare.
+	ProtocolMemoryShares map[protocol.ID]MemoryShareStat
+	ServiceMemoryShares  map[string]MemoryShareStat
 }
 
 var _ ResourceManagerState = (*resourceManager)(nil)
@@ -140,6 +146,16 @@ func (r *resourceManager) Stat() (result ResourceManagerStat) {
 	for _, svc := range svcs {
 		result.Services[svc.service] = svc.Stat()
 	}
+	if r.protoMemShare != nil {
+		shares := r.protoMemShare.stat()
+		result.ProtocolMemoryShares = make(map[protocol.ID]MemoryShareStat, len(shares))
+		for proto, st := range shares {
+			result.ProtocolMemoryShares[protocol.ID(proto)] = st
+		}
+	}
+	if r.svcMemShare != nil {
+		result.ServiceMemoryShares = r.svcMemShare.stat()
+	}
 	result.Transient = r.transient.Stat()
 	result.System = r.system.Stat()
 
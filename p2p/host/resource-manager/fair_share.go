@@ -0,0 +1,201 @@
+package rcmgr
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// MemoryFairShareConfig configures the fair share mode for memory reservations.
+//
+// In fair share mode the system memory limit is divided among the protocol
+// scopes that currently hold memory, proportionally to their weights, and
+// independently among the service scopes. A scope can always reserve memory up
+// to its share. It may borrow beyond its share only as long as enough memory
+// remains for every other active scope to grow to its own share, so that a
+// bursty protocol can't starve the others.
+//
+// Reservations with network.ReservationPriorityAlways are accounted, but are
+// never blocked by the fair share check.
+type MemoryFairShareConfig struct {
+	// ProtocolWeights are the weights of protocol scopes.
+	ProtocolWeights map[protocol.ID]uint
+	// ServiceWeights are the weights of service scopes.
+	ServiceWeights map[string]uint
+	// DefaultWeight is the weight of scopes that are not listed. Defaults to 1.
+	DefaultWeight uint
+}
+
+// MemoryShareStat is the state of a scope in the memory fair share mode.
+type MemoryShareStat struct {
+	// Weight is the configured weight of the scope.
+	Weight uint
+	// Share is the amount of memory the scope is currently guaranteed.
+	Share int64
+	// Memory is the amount of memory the scope has reserved.
+	Memory int64
+}
+
+// WithMemoryFairShare enables the fair share mode for memory reservations of
+// protocol and service scopes.
+func WithMemoryFairShare(cfg MemoryFairShareConfig) Option {
+	return func(r *resourceManager) error {
+		defaultWeight := cfg.DefaultWeight
+		if defaultWeight == 0 {
+			defaultWeight = 1
+		}
+		protoWeights := make(map[string]uint, len(cfg.ProtocolWeights))
+		for p, w := range cfg.ProtocolWeights {
+			if w == 0 {
+				return fmt.Errorf("invalid weight 0 for protocol %s", p)
+			}
+			protoWeights[string(p)] = w
+		}
+		svcWeights := make(map[string]uint, len(cfg.ServiceWeights))
+		for s, w := range cfg.ServiceWeights {
+			if w == 0 {
+				return fmt.Errorf("invalid weight 0 for service %s", s)
+			}
+			svcWeights[s] = w
+		}
+		systemLimit := func() int64 { return r.limits.GetSystemLimits().GetMemoryLimit() }
+		r.protoMemShare = newMemoryFairShare(systemLimit, protoWeights, defaultWeight)
+		r.svcMemShare = newMemoryFairShare(systemLimit, svcWeights, defaultWeight)
+		return nil
+	}
+}
+
+// memoryFairShare divides memory among a group of scopes by weight.
+type memoryFairShare struct {
+	limit         func() int64
+	weights       map[string]uint
+	defaultWeight uint
+
+	mx    sync.Mutex
+	usage map[string]int64 // only active scopes, i.e. with memory reserved
+	total int64
+}
+
+func newMemoryFairShare(limit func() int64, weights map[string]uint, defaultWeight uint) *memoryFairShare {
+	return &memoryFairShare{
+		limit:         limit,
+		weights:       weights,
+		defaultWeight: defaultWeight,
+		usage:         make(map[string]int64),
+	}
+}
+
+func (f *memoryFairShare) weight(key string) uint {
+	if w, ok := f.weights[key]; ok {
+		return w
+	}
+	return f.defaultWeight
+}
+
+// totalWeightLocked returns the sum of the weights of the active scopes and key.
+func (f *memoryFairShare) totalWeightLocked(key string) uint64 {
+	var total uint64
+	for k := range f.usage {
+		total += uint64(f.weight(k))
+	}
+	if _, ok := f.usage[key]; !ok {
+		total += uint64(f.weight(key))
+	}
+	return total
+}
+
+func (f *memoryFairShare) share(limit int64, key string, totalWeight uint64) int64 {
+	// Use big.Int as limit * weight may overflow.
+	share := new(big.Int).Mul(big.NewInt(limit), new(big.Int).SetUint64(uint64(f.weight(key))))
+	return share.Div(share, new(big.Int).SetUint64(totalWeight)).Int64()
+}
+
+func (f *memoryFairShare) reserve(key string, size int64, prio uint8) error {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	limit := f.limit()
+	if limit == math.MaxInt64 || prio == network.ReservationPriorityAlways {
+		f.add(key, size)
+		return nil
+	}
+
+	current := f.usage[key]
+	totalWeight := f.totalWeightLocked(key)
+	share := f.share(limit, key, totalWeight)
+	if current+size > share {
+		// Borrowing beyond our share is fine as long as all other active
+		// scopes can still grow to their share.
+		needed := f.total + size
+		for k, mem := range f.usage {
+			if k == key {
+				continue
+			}
+			if s := f.share(limit, k, totalWeight); s > mem {
+				needed += s - mem
+			}
+		}
+		if needed > limit {
+			return &ErrMemoryLimitExceeded{
+				current:   current,
+				attempted: size,
+				limit:     share,
+				priority:  prio,
+				err:       fmt.Errorf("cannot reserve memory beyond fair share: %w", network.ErrResourceLimitExceeded),
+			}
+		}
+	}
+	f.add(key, size)
+	return nil
+}
+
+func (f *memoryFairShare) add(key string, size int64) {
+	if size == 0 {
+		return
+	}
+	f.usage[key] += size
+	f.total += size
+}
+
+func (f *memoryFairShare) release(key string, size int64) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	current, ok := f.usage[key]
+	if !ok {
+		return
+	}
+	if size > current {
+		size = current
+	}
+	f.total -= size
+	if current == size {
+		delete(f.usage, key)
+	} else {
+		f.usage[key] = current - size
+	}
+}
+
+func (f *memoryFairShare) stat() map[string]MemoryShareStat {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	limit := f.limit()
+	var totalWeight uint64
+	for k := range f.usage {
+		totalWeight += uint64(f.weight(k))
+	}
+	result := make(map[string]MemoryShareStat, len(f.usage))
+	for k, mem := range f.usage {
+		result[k] = MemoryShareStat{
+			Weight: f.weight(k),
+			Share:  f.share(limit, k, totalWeight),
+			Memory: mem,
+		}
+	}
+	return result
+}
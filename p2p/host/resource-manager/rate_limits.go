@@ -0,0 +1,198 @@
+package rcmgr
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/x/rate"
+
+	xrate "golang.org/x/time/rate"
+)
+
+// ErrRateLimitExceeded is returned when opening a connection or a stream is
+// rejected because it exceeds a configured rate limit.
+var ErrRateLimitExceeded = fmt.Errorf("rate limit exceeded: %w", network.ErrResourceLimitExceeded)
+
+// RateLimits configures token bucket limits on how often remote peers may open
+// connections and streams. The limits only apply to inbound connections and
+// streams, so that the local application isn't throttled by its own activity.
+// Per subnet limits for connections are configured with WithConnRateLimiters.
+// A zero Limit (RPS of 0) disables the corresponding limit.
+type RateLimits struct {
+	// PeerConn limits how often new connections can be attached to a single peer.
+	PeerConn rate.Limit
+	// PeerStream limits how often streams can be opened with a single peer.
+	PeerStream rate.Limit
+	// Protocol limits how often streams can be opened for a protocol, across all peers.
+	Protocol map[protocol.ID]rate.Limit
+	// ProtocolPeer limits how often streams can be opened for a protocol with a single peer.
+	ProtocolPeer map[protocol.ID]rate.Limit
+	// GracePeriod is the time to wait before removing a full capacity bucket.
+	// Defaults to a minute.
+	GracePeriod time.Duration
+}
+
+// WithRateLimits sets rate limits for opening connections and streams per
+// peer and per protocol.
+func WithRateLimits(limits RateLimits) Option {
+	return func(rm *resourceManager) error {
+		if limits.PeerConn.RPS < 0 || limits.PeerConn.Burst < 0 {
+			return fmt.Errorf("invalid per peer connection rate limit: %+v", limits.PeerConn)
+		}
+		if limits.PeerStream.RPS < 0 || limits.PeerStream.Burst < 0 {
+			return fmt.Errorf("invalid per peer stream rate limit: %+v", limits.PeerStream)
+		}
+		if limits.GracePeriod < 0 {
+			return fmt.Errorf("invalid rate limit grace period: %s", limits.GracePeriod)
+		}
+		for p, l := range limits.Protocol {
+			if l.RPS < 0 || l.Burst < 0 {
+				return fmt.Errorf("invalid rate limit for protocol %s: %+v", p, l)
+			}
+		}
+		for p, l := range limits.ProtocolPeer {
+			if l.RPS < 0 || l.Burst < 0 {
+				return fmt.Errorf("invalid per peer rate limit for protocol %s: %+v", p, l)
+			}
+		}
+		rm.rateLimiter = newRateLimiter(limits)
+		return nil
+	}
+}
+
+type protocolPeer struct {
+	proto protocol.ID
+	peer  peer.ID
+}
+
+// rateLimiter applies the RateLimits. A nil *rateLimiter allows everything.
+type rateLimiter struct {
+	peerConn     *keyedLimiter[peer.ID]
+	peerStream   *keyedLimiter[peer.ID]
+	protocol     map[protocol.ID]*xrate.Limiter
+	protocolPeer map[protocol.ID]*keyedLimiter[protocolPeer]
+}
+
+func newRateLimiter(limits RateLimits) *rateLimiter {
+	gracePeriod := limits.GracePeriod
+	if gracePeriod <= 0 {
+		gracePeriod = time.Minute
+	}
+	r := &rateLimiter{
+		peerConn:     newKeyedLimiter[peer.ID](limits.PeerConn, gracePeriod),
+		peerStream:   newKeyedLimiter[peer.ID](limits.PeerStream, gracePeriod),
+		protocol:     make(map[protocol.ID]*xrate.Limiter, len(limits.Protocol)),
+		protocolPeer: make(map[protocol.ID]*keyedLimiter[protocolPeer], len(limits.ProtocolPeer)),
+	}
+	for p, l := range limits.Protocol {
+		if l.RPS != 0 {
+			r.protocol[p] = xrate.NewLimiter(xrate.Limit(l.RPS), l.Burst)
+		}
+	}
+	for p, l := range limits.ProtocolPeer {
+		r.protocolPeer[p] = newKeyedLimiter[protocolPeer](l, gracePeriod)
+	}
+	return r
+}
+
+func (r *rateLimiter) AllowPeerConn(p peer.ID, dir network.Direction) bool {
+	if r == nil || dir != network.DirInbound {
+		return true
+	}
+	return r.peerConn.Allow(p, time.Now())
+}
+
+func (r *rateLimiter) AllowPeerStream(p peer.ID, dir network.Direction) bool {
+	if r == nil || dir != network.DirInbound {
+		return true
+	}
+	return r.peerStream.Allow(p, time.Now())
+}
+
+// AllowProtocol reports whether the peer p may open a stream for proto. It
+// returns whether the stream was blocked by the per peer limit or by the
+// protocol wide limit.
+func (r *rateLimiter) AllowProtocol(proto protocol.ID, p peer.ID, dir network.Direction) (allowed bool, peerBlocked bool) {
+	if r == nil || dir != network.DirInbound {
+		return true, false
+	}
+	// Check the more specific limit first so that streams rejected by a
+	// single peer's bucket don't consume tokens from the protocol wide bucket.
+	if l, ok := r.protocolPeer[proto]; ok {
+		if !l.Allow(protocolPeer{proto: proto, peer: p}, time.Now()) {
+			return false, true
+		}
+	}
+	if l, ok := r.protocol[proto]; ok {
+		if !l.Allow() {
+			return false, false
+		}
+	}
+	return true, false
+}
+
+func (r *rateLimiter) gc() {
+	if r == nil {
+		return
+	}
+	now := time.Now()
+	r.peerConn.gc(now)
+	r.peerStream.gc(now)
+	for _, l := range r.protocolPeer {
+		l.gc(now)
+	}
+}
+
+// keyedLimiter keeps a token bucket for every key.
+type keyedLimiter[K comparable] struct {
+	limit       rate.Limit
+	gracePeriod time.Duration
+
+	mx      sync.Mutex
+	buckets map[K]*bucketWithLastUse
+}
+
+type bucketWithLastUse struct {
+	*xrate.Limiter
+	lastUse time.Time
+}
+
+func newKeyedLimiter[K comparable](limit rate.Limit, gracePeriod time.Duration) *keyedLimiter[K] {
+	return &keyedLimiter[K]{
+		limit:       limit,
+		gracePeriod: gracePeriod,
+		buckets:     make(map[K]*bucketWithLastUse),
+	}
+}
+
+func (l *keyedLimiter[K]) Allow(k K, now time.Time) bool {
+	if l.limit.RPS == 0 {
+		return true
+	}
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &bucketWithLastUse{Limiter: xrate.NewLimiter(xrate.Limit(l.limit.RPS), l.limit.Burst)}
+		l.buckets[k] = b
+	}
+	b.lastUse = now
+	return b.AllowN(now, 1)
+}
+
+// gc removes full buckets that haven't been used for the grace period.
+func (l *keyedLimiter[K]) gc(now time.Time) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	for k, b := range l.buckets {
+		if now.Sub(b.lastUse) >= l.gracePeriod && b.TokensAt(now) >= float64(l.limit.Burst) {
+			delete(l.buckets, k)
+		}
+	}
+}
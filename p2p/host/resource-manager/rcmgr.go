@@ -2,7 +2,6 @@ package rcmgr
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net"
 	"net/netip"
@@ -25,8 +24,12 @@ var log = logging.Logger("rcmgr")
 type resourceManager struct {
 	limits Limiter
 
-	connLimiter                    *connLimiter
-	connRateLimiter                *rate.Limiter
+	connLimiter     *connLimiter
+	connRateLimiter *rate.Limiter
+	rateLimiter     *rateLimiter
+
+	protoMemShare                  *memoryFairShare
+	svcMemShare                    *memoryFairShare
 	verifySourceAddressRateLimiter *rate.Limiter
 
 	trace          *trace
@@ -381,7 +384,8 @@ func (r *resourceManager) OpenConnection(dir network.Direction, usefd bool, endp
 
 func (r *resourceManager) openConnection(dir network.Direction, usefd bool, endpoint multiaddr.Multiaddr, ip netip.Addr) (network.ConnManagementScope, error) {
 	if !r.connRateLimiter.Allow(ip) {
-		return nil, errors.New("rate limit exceeded")
+		r.trace.RateLimitConn("transient", dir)
+		return nil, ErrRateLimitExceeded
 	}
 
 	if ip.IsValid() {
@@ -416,6 +420,12 @@ func (r *resourceManager) openConnection(dir network.Direction, usefd bool, endp
 }
 
 func (r *resourceManager) OpenStream(p peer.ID, dir network.Direction) (network.StreamManagementScope, error) {
+	if !r.rateLimiter.AllowPeerStream(p, dir) {
+		r.trace.RateLimitStream(peerScopeName(p), dir)
+		r.metrics.BlockStream(p, dir)
+		return nil, ErrRateLimitExceeded
+	}
+
 	peer := r.getPeerScope(p)
 	stream := newStreamScope(dir, r.limits.GetStreamLimits(p), peer, r)
 	peer.DecRef() // we have the reference in edges
@@ -457,6 +467,8 @@ func (r *resourceManager) background() {
 }
 
 func (r *resourceManager) gc() {
+	r.rateLimiter.gc()
+
 	r.mx.Lock()
 	defer r.mx.Unlock()
 
@@ -526,23 +538,33 @@ func newTransientScope(limit Limit, rcmgr *resourceManager, name string, systemS
 }
 
 func newServiceScope(service string, limit Limit, rcmgr *resourceManager) *serviceScope {
-	return &serviceScope{
+	s := &serviceScope{
 		resourceScope: newResourceScope(limit,
 			[]*resourceScope{rcmgr.system.resourceScope},
 			fmt.Sprintf("service:%s", service), rcmgr.trace, rcmgr.metrics),
 		service: service,
 		rcmgr:   rcmgr,
 	}
+	if rcmgr.svcMemShare != nil {
+		s.rc.fairShare = rcmgr.svcMemShare
+		s.rc.fairShareKey = service
+	}
+	return s
 }
 
 func newProtocolScope(proto protocol.ID, limit Limit, rcmgr *resourceManager) *protocolScope {
-	return &protocolScope{
+	s := &protocolScope{
 		resourceScope: newResourceScope(limit,
 			[]*resourceScope{rcmgr.system.resourceScope},
 			fmt.Sprintf("protocol:%s", proto), rcmgr.trace, rcmgr.metrics),
 		proto: proto,
 		rcmgr: rcmgr,
 	}
+	if rcmgr.protoMemShare != nil {
+		s.rc.fairShare = rcmgr.protoMemShare
+		s.rc.fairShareKey = string(proto)
+	}
+	return s
 }
 
 func newPeerScope(p peer.ID, limit Limit, rcmgr *resourceManager) *peerScope {
@@ -813,6 +835,12 @@ func (s *connectionScope) SetPeer(p peer.ID) error {
 		}
 	}
 
+	if !s.isAllowlisted && !s.rcmgr.rateLimiter.AllowPeerConn(p, s.dir) {
+		s.rcmgr.trace.RateLimitConn(peerScopeName(p), s.dir)
+		s.rcmgr.metrics.BlockPeer(p)
+		return ErrRateLimitExceeded
+	}
+
 	s.peer = s.rcmgr.getPeerScope(p)
 
 	// juggle resources from transient scope to peer scope
@@ -858,6 +886,17 @@ func (s *streamScope) SetProtocol(proto protocol.ID) error {
 		return fmt.Errorf("stream scope already attached to a protocol")
 	}
 
+	if ok, peerBlocked := s.rcmgr.rateLimiter.AllowProtocol(proto, s.peer.peer, s.dir); !ok {
+		if peerBlocked {
+			s.rcmgr.trace.RateLimitStream(fmt.Sprintf("protocol:%s.%s", proto, peerScopeName(s.peer.peer)), s.dir)
+			s.rcmgr.metrics.BlockProtocolPeer(proto, s.peer.peer)
+		} else {
+			s.rcmgr.trace.RateLimitStream(fmt.Sprintf("protocol:%s", proto), s.dir)
+			s.rcmgr.metrics.BlockProtocol(proto)
+		}
+		return ErrRateLimitExceeded
+	}
+
 	s.proto = s.rcmgr.getProtocolScope(proto)
 
 	// juggle resources from transient scope to protocol scope
@@ -4,6 +4,7 @@ import (
 	"net"
 	"net/netip"
 	"testing"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -1173,3 +1174,116 @@ func TestVerifySourceAddressRateLimiter(t *testing.T) {
 	require.False(t, rcmgr.VerifySourceAddress(na2))
 	require.True(t, rcmgr.VerifySourceAddress(na2))
 }
+
+func TestResourceManagerPeerAndProtocolRateLimiting(t *testing.T) {
+	rcmgr, err := NewResourceManager(NewFixedLimiter(InfiniteLimits), WithRateLimits(RateLimits{
+		PeerConn:   rate.Limit{RPS: 0.00001, Burst: 1},
+		PeerStream: rate.Limit{RPS: 0.00001, Burst: 3},
+		ProtocolPeer: map[protocol.ID]rate.Limit{
+			"/test": {RPS: 0.00001, Burst: 1},
+		},
+	}))
+	require.NoError(t, err)
+	defer rcmgr.Close()
+
+	p1 := test.RandPeerIDFatal(t)
+	p2 := test.RandPeerIDFatal(t)
+
+	conn, err := rcmgr.OpenConnection(network.DirInbound, true, multiaddr.StringCast("/ip4/1.2.3.4"))
+	require.NoError(t, err)
+	require.NoError(t, conn.SetPeer(p1))
+	conn.Done()
+
+	conn, err = rcmgr.OpenConnection(network.DirInbound, true, multiaddr.StringCast("/ip4/1.2.3.5"))
+	require.NoError(t, err)
+	require.ErrorIs(t, conn.SetPeer(p1), ErrRateLimitExceeded)
+	require.NoError(t, conn.SetPeer(p2))
+	conn.Done()
+
+	stream, err := rcmgr.OpenStream(p1, network.DirInbound)
+	require.NoError(t, err)
+	require.NoError(t, stream.SetProtocol("/test"))
+	stream.Done()
+
+	stream, err = rcmgr.OpenStream(p1, network.DirInbound)
+	require.NoError(t, err)
+	require.ErrorIs(t, stream.SetProtocol("/test"), network.ErrResourceLimitExceeded)
+	require.NoError(t, stream.SetProtocol("/other"))
+	stream.Done()
+
+	stream, err = rcmgr.OpenStream(p1, network.DirInbound)
+	require.NoError(t, err)
+	stream.Done()
+
+	_, err = rcmgr.OpenStream(p1, network.DirInbound)
+	require.ErrorIs(t, err, ErrRateLimitExceeded)
+
+	// other peers have their own buckets
+	stream, err = rcmgr.OpenStream(p2, network.DirInbound)
+	require.NoError(t, err)
+	require.NoError(t, stream.SetProtocol("/test"))
+	stream.Done()
+
+	// outbound connections and streams aren't limited
+	conn, err = rcmgr.OpenConnection(network.DirOutbound, true, multiaddr.StringCast("/ip4/1.2.3.6"))
+	require.NoError(t, err)
+	require.NoError(t, conn.SetPeer(p1))
+	conn.Done()
+	stream, err = rcmgr.OpenStream(p1, network.DirOutbound)
+	require.NoError(t, err)
+	require.NoError(t, stream.SetProtocol("/test"))
+	stream.Done()
+}
+
+func TestResourceManagerInvalidRateLimits(t *testing.T) {
+	for _, limits := range []RateLimits{
+		{PeerConn: rate.Limit{RPS: -1, Burst: 1}},
+		{PeerStream: rate.Limit{RPS: 1, Burst: -1}},
+		{Protocol: map[protocol.ID]rate.Limit{"/test": {RPS: -1}}},
+		{ProtocolPeer: map[protocol.ID]rate.Limit{"/test": {Burst: -1}}},
+		{GracePeriod: -time.Second},
+	} {
+		_, err := NewResourceManager(NewFixedLimiter(InfiniteLimits), WithRateLimits(limits))
+		require.Error(t, err, "%+v", limits)
+	}
+}
+
+func TestResourceManagerMemoryFairShare(t *testing.T) {
+	limits := InfiniteLimits
+	limits.system.Memory = 1000
+	protoA := protocol.ID("/A")
+	protoB := protocol.ID("/B")
+	rcmgr, err := NewResourceManager(NewFixedLimiter(limits), WithMemoryFairShare(MemoryFairShareConfig{
+		ProtocolWeights: map[protocol.ID]uint{protoB: 3},
+	}))
+	require.NoError(t, err)
+	defer rcmgr.Close()
+
+	reserve := func(proto protocol.ID, size int) error {
+		return rcmgr.ViewProtocol(proto, func(s network.ProtocolScope) error {
+			return s.ReserveMemory(size, network.ReservationPriorityAlways-1)
+		})
+	}
+
+	// alone, A can use all the memory
+	require.NoError(t, reserve(protoA, 200))
+	require.NoError(t, reserve(protoB, 100))
+	// A's share is now 250, and growing beyond that would starve B
+	require.ErrorIs(t, reserve(protoA, 100), network.ErrResourceLimitExceeded)
+	require.NoError(t, reserve(protoA, 50))
+	require.NoError(t, reserve(protoB, 600))
+
+	stat := rcmgr.(ResourceManagerState).Stat()
+	require.Equal(t, MemoryShareStat{Weight: 1, Share: 250, Memory: 250}, stat.ProtocolMemoryShares[protoA])
+	require.Equal(t, MemoryShareStat{Weight: 3, Share: 750, Memory: 700}, stat.ProtocolMemoryShares[protoB])
+	require.Empty(t, stat.ServiceMemoryShares)
+
+	// once B releases its memory, A can borrow again
+	require.NoError(t, rcmgr.ViewProtocol(protoB, func(s network.ProtocolScope) error {
+		s.ReleaseMemory(700)
+		return nil
+	}))
+	require.NoError(t, reserve(protoA, 500))
+	stat = rcmgr.(ResourceManagerState).Stat()
+	require.NotContains(t, stat.ProtocolMemoryShares, protoB)
+}
@@ -19,6 +19,11 @@ type resources struct {
 	nfd                     int
 
 	memory int64
+
+	// fairShare is set for protocol and service scopes in the memory fair
+	// share mode.
+	fairShare    *memoryFairShare
+	fairShareKey string
 }
 
 // A resourceScope can be a DAG, where a downstream node is not allowed to outlive an upstream node
@@ -143,12 +148,20 @@ func (rc *resources) reserveMemory(size int64, prio uint8) error {
 	if err := rc.checkMemory(size, prio); err != nil {
 		return err
 	}
+	if rc.fairShare != nil {
+		if err := rc.fairShare.reserve(rc.fairShareKey, size, prio); err != nil {
+			return err
+		}
+	}
 
 	rc.memory += size
 	return nil
 }
 
 func (rc *resources) releaseMemory(size int64) {
+	if rc.fairShare != nil {
+		rc.fairShare.release(rc.fairShareKey, size)
+	}
 	rc.memory -= size
 
 	// sanity check for bugs upstream
@@ -766,6 +779,9 @@ func (s *resourceScope) doneUnlocked() {
 	s.rc.nconnsIn = 0
 	s.rc.nconnsOut = 0
 	s.rc.nfd = 0
+	if s.rc.fairShare != nil {
+		s.rc.fairShare.release(s.rc.fairShareKey, s.rc.memory)
+	}
 	s.rc.memory = 0
 
 	s.done = true
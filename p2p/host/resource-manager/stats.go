@@ -123,6 +123,13 @@ var (
 		Name:      "blocked_resources",
 		Help:      "Number of blocked resources",
 	}, []string{"dir", "scope", "resource"})
+
+	// Rate limited resources
+	rateLimitedResources = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricNamespace,
+		Name:      "rate_limited_resources_total",
+		Help:      "Number of connections and streams rejected by rate limits",
+	}, []string{"dir", "scope", "resource"})
 )
 
 var (
@@ -157,6 +164,7 @@ func MustRegisterWith(reg prometheus.Registerer) {
 		previousConnMemory,
 		fds,
 		blockedResources,
+		rateLimitedResources,
 	)
 }
 
@@ -351,18 +359,7 @@ func (r StatsTraceReporter) consumeEventWithLabelSlice(evt TraceEvt, tags *[]str
 			resource = "memory"
 		}
 
-		scopeName := evt.Name
-		// Only the top scopeName. We don't want to get the peerid here.
-		// Using indexes and slices to avoid allocating.
-		scopeSplitIdx := strings.IndexByte(scopeName, ':')
-		if scopeSplitIdx != -1 {
-			scopeName = evt.Name[0:scopeSplitIdx]
-		}
-		// Drop the connection or stream id
-		idSplitIdx := strings.IndexByte(scopeName, '-')
-		if idSplitIdx != -1 {
-			scopeName = scopeName[0:idSplitIdx]
-		}
+		scopeName := topScopeName(evt.Name)
 
 		if evt.DeltaIn != 0 {
 			*tags = (*tags)[:0]
@@ -386,5 +383,40 @@ func (r StatsTraceReporter) consumeEventWithLabelSlice(evt TraceEvt, tags *[]str
 			*tags = append(*tags, "", scopeName, resource)
 			blockedResources.WithLabelValues(*tags...).Add(float64(evt.Delta))
 		}
+
+	case TraceRateLimitConnEvt, TraceRateLimitStreamEvt:
+		resource := "connection"
+		if evt.Type == TraceRateLimitStreamEvt {
+			resource = "stream"
+		}
+		scopeName := topScopeName(evt.Name)
+
+		if evt.DeltaIn != 0 {
+			*tags = (*tags)[:0]
+			*tags = append(*tags, "inbound", scopeName, resource)
+			rateLimitedResources.WithLabelValues(*tags...).Add(float64(evt.DeltaIn))
+		}
+
+		if evt.DeltaOut != 0 {
+			*tags = (*tags)[:0]
+			*tags = append(*tags, "outbound", scopeName, resource)
+			rateLimitedResources.WithLabelValues(*tags...).Add(float64(evt.DeltaOut))
+		}
+	}
+}
+
+// topScopeName returns only the top level scope name, so that we don't get the
+// peer ID, connection ID or stream ID in metric labels.
+// Using indexes and slices to avoid allocating.
+func topScopeName(scopeName string) string {
+	scopeSplitIdx := strings.IndexByte(scopeName, ':')
+	if scopeSplitIdx != -1 {
+		scopeName = scopeName[0:scopeSplitIdx]
+	}
+	// Drop the connection or stream id
+	idSplitIdx := strings.IndexByte(scopeName, '-')
+	if idSplitIdx != -1 {
+		scopeName = scopeName[0:idSplitIdx]
 	}
+	return scopeName
 }
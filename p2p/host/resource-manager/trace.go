@@ -69,6 +69,8 @@ const (
 	TraceAddConnEvt            TraceEvtTyp = "add_conn"
 	TraceBlockAddConnEvt       TraceEvtTyp = "block_add_conn"
 	TraceRemoveConnEvt         TraceEvtTyp = "remove_conn"
+	TraceRateLimitConnEvt      TraceEvtTyp = "rate_limit_conn"
+	TraceRateLimitStreamEvt    TraceEvtTyp = "rate_limit_stream"
 )
 
 type scopeClass struct {
@@ -607,6 +609,46 @@ func (t *trace) BlockAddConn(scope string, dir network.Direction, usefd bool, nc
 	})
 }
 
+func (t *trace) RateLimitConn(scope string, dir network.Direction) {
+	if t == nil {
+		return
+	}
+
+	var deltaIn, deltaOut int
+	if dir == network.DirInbound {
+		deltaIn = 1
+	} else {
+		deltaOut = 1
+	}
+
+	t.push(TraceEvt{
+		Type:     TraceRateLimitConnEvt,
+		Name:     scope,
+		DeltaIn:  deltaIn,
+		DeltaOut: deltaOut,
+	})
+}
+
+func (t *trace) RateLimitStream(scope string, dir network.Direction) {
+	if t == nil {
+		return
+	}
+
+	var deltaIn, deltaOut int
+	if dir == network.DirInbound {
+		deltaIn = 1
+	} else {
+		deltaOut = 1
+	}
+
+	t.push(TraceEvt{
+		Type:     TraceRateLimitStreamEvt,
+		Name:     scope,
+		DeltaIn:  deltaIn,
+		DeltaOut: deltaOut,
+	})
+}
+
 func (t *trace) RemoveConn(scope string, dir network.Direction, usefd bool, nconnsIn, nconnsOut, nfd int) {
 	if t == nil {
 		return
@@ -37,6 +37,8 @@ type BasicConnMgr struct {
 	plk       sync.RWMutex
 	protected map[peer.ID]map[string]struct{}
 
+	persist *persister
+
 	// channel-based semaphore that enforces only a single trim is in progress
 	trimMutex sync.Mutex
 	connCount atomic.Int32
@@ -139,6 +141,13 @@ func NewConnManager(low, hi int, opts ...Option) (*BasicConnMgr, error) {
 		}
 	}
 
+	if cfg.persistDS != nil {
+		cm.persist = newPersister(cfg.persistDS, cfg.persistInterval)
+		if err := cm.persist.restore(context.Background(), cm); err != nil {
+			return nil, fmt.Errorf("failed to restore persisted state: %w", err)
+		}
+	}
+
 	cm.ctx, cm.cancel = context.WithCancel(context.Background())
 
 	decay, _ := NewDecayer(cfg.decayer, cm)
@@ -146,6 +155,10 @@ func NewConnManager(low, hi int, opts ...Option) (*BasicConnMgr, error) {
 
 	cm.refCount.Add(1)
 	go cm.background()
+	if cm.persist != nil {
+		cm.refCount.Add(1)
+		go cm.persist.background(cm)
+	}
 	return cm, nil
 }
 
@@ -406,7 +419,7 @@ func (cm *BasicConnMgr) getConnsToCloseEmergency(target int) []network.Conn {
 	cm.plk.RUnlock()
 
 	// Sort peers according to their value.
-	candidates.SortByValueAndStreams(&cm.segments, true)
+	cm.sortCandidates(candidates, true)
 
 	selected := make([]network.Conn, 0, target+10)
 	for _, inf := range candidates {
@@ -439,7 +452,7 @@ func (cm *BasicConnMgr) getConnsToCloseEmergency(target int) []network.Conn {
 	}
 	cm.plk.RUnlock()
 
-	candidates.SortByValueAndStreams(&cm.segments, true)
+	cm.sortCandidates(candidates, true)
 	for _, inf := range candidates {
 		if target <= 0 {
 			break
@@ -473,15 +486,26 @@ func (cm *BasicConnMgr) getConnsToClose() []network.Conn {
 	var ncandidates int
 	gracePeriodStart := cm.clock.Now().Add(-cm.cfg.gracePeriod)
 
+	var diversity *diversityState
+	if cm.cfg.diversity != nil {
+		diversity = newDiversityState()
+	}
+
 	cm.plk.RLock()
 	for _, s := range cm.segments.buckets {
 		s.Lock()
 		for id, inf := range s.peers {
-			if _, ok := cm.protected[id]; ok {
+			_, protected := cm.protected[id]
+			inGracePeriod := inf.firstSeen.After(gracePeriodStart)
+			if diversity != nil {
+				// protected peers and peers in the grace period are retained, but still count towards the diversity constraints.
+				diversity.add(cm.cfg.diversity, inf, !protected && !inGracePeriod)
+			}
+			if protected {
 				// skip over protected peer.
 				continue
 			}
-			if inf.firstSeen.After(gracePeriodStart) {
+			if inGracePeriod {
 				// skip peers in the grace period.
 				continue
 			}
@@ -504,9 +528,12 @@ func (cm *BasicConnMgr) getConnsToClose() []network.Conn {
 	}
 
 	// Sort peers according to their value.
-	candidates.SortByValueAndStreams(&cm.segments, false)
+	cm.sortCandidates(candidates, false)
 
 	target := ncandidates - cm.cfg.lowWater
+	if diversity != nil {
+		candidates = cm.cfg.diversity.reorder(candidates, diversity, target, cm.cfg.lowWater)
+	}
 
 	// slightly overallocate because we may have more than one conns per peer
 	selected := make([]network.Conn, 0, target+10)
@@ -99,6 +99,17 @@ func NewDecayer(cfg *DecayerCfg, mgr *BasicConnMgr) (*decayer, error) {
 }
 
 func (d *decayer) RegisterDecayingTag(name string, interval time.Duration, decayFn connmgr.DecayFn, bumpFn connmgr.BumpFn) (connmgr.DecayingTag, error) {
+	tag, err := d.registerDecayingTag(name, interval, decayFn, bumpFn)
+	if err != nil {
+		return nil, err
+	}
+	if d.mgr != nil && d.mgr.persist != nil {
+		d.mgr.persist.restoreDecaying(d.mgr, tag, d.clock.Now())
+	}
+	return tag, nil
+}
+
+func (d *decayer) registerDecayingTag(name string, interval time.Duration, decayFn connmgr.DecayFn, bumpFn connmgr.BumpFn) (*decayingTag, error) {
 	d.tagsMu.Lock()
 	defer d.tagsMu.Unlock()
 
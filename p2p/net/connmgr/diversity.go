@@ -0,0 +1,187 @@
+package connmgr
+
+import (
+	"errors"
+	"math"
+	"net/netip"
+
+	asnutil "github.com/libp2p/go-libp2p-asn-util"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// DiversityConstraints cap the fraction of the connections retained after a
+// trim that may belong to a single network. When trimming, peers in networks
+// over their cap are removed first, in the order given by the ranking of
+// peers, before falling back to the normal order.
+//
+// This makes it harder for an attacker controlling a single network, e.g. a
+// hosting provider, to eclipse the node.
+type DiversityConstraints struct {
+	// IPv4SubnetFraction caps the fraction of connections from a single IPv4
+	// /24 subnet. 0 disables the constraint.
+	IPv4SubnetFraction float64
+	// IPv6SubnetFraction caps the fraction of connections from a single IPv6
+	// /48 subnet. 0 disables the constraint.
+	IPv6SubnetFraction float64
+	// ASNFraction caps the fraction of connections from a single autonomous
+	// system. ASNs are only known for IPv6 addresses. 0 disables the constraint.
+	ASNFraction float64
+}
+
+const (
+	diversityIPv4PrefixLength = 24
+	diversityIPv6PrefixLength = 48
+)
+
+// WithDiversityConstraints sets constraints on the networks of the retained
+// connections.
+func WithDiversityConstraints(c DiversityConstraints) Option {
+	return func(cfg *config) error {
+		for _, f := range []float64{c.IPv4SubnetFraction, c.IPv6SubnetFraction, c.ASNFraction} {
+			if f < 0 || f > 1 {
+				return errors.New("diversity constraint fractions must be between 0 and 1")
+			}
+		}
+		cfg.diversity = &c
+		return nil
+	}
+}
+
+type networkBucket struct {
+	prefix netip.Prefix
+	asn    uint32
+}
+
+// buckets returns the network buckets the address belongs to, for the enabled
+// constraints.
+func (c *DiversityConstraints) buckets(addr ma.Multiaddr) []networkBucket {
+	if addr == nil {
+		return nil
+	}
+	ip, err := manet.ToIP(addr)
+	if err != nil {
+		return nil
+	}
+	ipAddr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return nil
+	}
+	ipAddr = ipAddr.Unmap()
+
+	var buckets []networkBucket
+	if ipAddr.Is4() {
+		if c.IPv4SubnetFraction > 0 {
+			prefix, _ := ipAddr.Prefix(diversityIPv4PrefixLength)
+			buckets = append(buckets, networkBucket{prefix: prefix})
+		}
+		return buckets
+	}
+	if c.IPv6SubnetFraction > 0 {
+		prefix, _ := ipAddr.Prefix(diversityIPv6PrefixLength)
+		buckets = append(buckets, networkBucket{prefix: prefix})
+	}
+	if c.ASNFraction > 0 {
+		if asn := asnutil.AsnForIPv6(ip); asn != 0 {
+			buckets = append(buckets, networkBucket{asn: asn})
+		}
+	}
+	return buckets
+}
+
+// limit returns the maximum number of retained connections in the bucket.
+func (c *DiversityConstraints) limit(b networkBucket, retained int) int {
+	var fraction float64
+	switch {
+	case b.asn != 0:
+		fraction = c.ASNFraction
+	case b.prefix.Addr().Is4():
+		fraction = c.IPv4SubnetFraction
+	default:
+		fraction = c.IPv6SubnetFraction
+	}
+	return max(1, int(math.Floor(fraction*float64(retained))))
+}
+
+// diversityState holds the connection counts per network bucket of all
+// connections, and the buckets of the connections of the trim candidates.
+type diversityState struct {
+	counts     map[networkBucket]int
+	candidates map[peer.ID]*candidateBuckets
+}
+
+type candidateBuckets struct {
+	nconns  int
+	buckets []networkBucket // one entry per connection and bucket kind
+}
+
+func newDiversityState() *diversityState {
+	return &diversityState{
+		counts:     make(map[networkBucket]int),
+		candidates: make(map[peer.ID]*candidateBuckets),
+	}
+}
+
+// add counts the connections of a peer. The peer's segment must be locked.
+func (s *diversityState) add(c *DiversityConstraints, inf *peerInfo, candidate bool) {
+	var cb *candidateBuckets
+	if candidate {
+		cb = &candidateBuckets{nconns: len(inf.conns)}
+		s.candidates[inf.id] = cb
+	}
+	for conn := range inf.conns {
+		for _, b := range c.buckets(conn.RemoteMultiaddr()) {
+			s.counts[b]++
+			if cb != nil {
+				cb.buckets = append(cb.buckets, b)
+			}
+		}
+	}
+}
+
+// reorder moves the candidates that are in over represented networks to the
+// front, so that they are trimmed first. candidates must already be sorted.
+// target is the number of connections to trim and retained the number of
+// connections to retain.
+func (c *DiversityConstraints) reorder(candidates peerInfos, state *diversityState, target, retained int) peerInfos {
+	remaining := make(peerInfos, len(candidates))
+	copy(remaining, candidates)
+	result := make(peerInfos, 0, len(candidates))
+
+	overLimit := func(p peer.ID) bool {
+		cb, ok := state.candidates[p]
+		if !ok {
+			return false
+		}
+		for _, b := range cb.buckets {
+			if state.counts[b] > c.limit(b, retained) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for target > 0 && len(remaining) > 0 {
+		idx := -1
+		for i, inf := range remaining {
+			if overLimit(inf.id) {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			break
+		}
+		inf := remaining[idx]
+		remaining = append(remaining[:idx], remaining[idx+1:]...)
+		result = append(result, inf)
+		cb := state.candidates[inf.id]
+		for _, b := range cb.buckets {
+			state.counts[b]--
+		}
+		target -= cb.nconns
+	}
+	return append(result, remaining...)
+}
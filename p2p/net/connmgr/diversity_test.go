@@ -0,0 +1,52 @@
+package connmgr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	tu "github.com/libp2p/go-libp2p/core/test"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+type addrConn struct {
+	tconn
+	addr ma.Multiaddr
+}
+
+func (c *addrConn) RemoteMultiaddr() ma.Multiaddr { return c.addr }
+
+func TestDiversityConstraints(t *testing.T) {
+	cm, err := NewConnManager(4, 6, WithGracePeriod(0), WithDiversityConstraints(DiversityConstraints{IPv4SubnetFraction: 0.5}))
+	require.NoError(t, err)
+	defer cm.Close()
+	not := cm.Notifee()
+
+	var sameSubnet, diverse []*addrConn
+	for i := 0; i < 5; i++ {
+		c := &addrConn{tconn: tconn{peer: tu.RandPeerIDFatal(t)}, addr: ma.StringCast(fmt.Sprintf("/ip4/1.2.3.%d/tcp/1", i+1))}
+		cm.TagPeer(c.peer, "value", 100+i)
+		sameSubnet = append(sameSubnet, c)
+		not.Connected(nil, c)
+	}
+	for i := 0; i < 3; i++ {
+		c := &addrConn{tconn: tconn{peer: tu.RandPeerIDFatal(t)}, addr: ma.StringCast(fmt.Sprintf("/ip4/%d.0.0.1/tcp/1", i+10))}
+		cm.TagPeer(c.peer, "value", 10+i)
+		diverse = append(diverse, c)
+		not.Connected(nil, c)
+	}
+
+	cm.TrimOpenConns(context.Background())
+
+	// at most half of the 4 retained connections are from the same subnet,
+	// the most valuable ones are kept.
+	for i, c := range sameSubnet {
+		require.Equal(t, i < 3, c.isClosed(), "conn %d", i)
+	}
+	// the remaining connection is trimmed by value
+	require.True(t, diverse[0].isClosed())
+	require.False(t, diverse[1].isClosed())
+	require.False(t, diverse[2].isClosed())
+}
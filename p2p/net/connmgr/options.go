@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/ipfs/go-datastore"
 )
 
 // config is the configuration struct for the basic connection manager.
@@ -15,6 +16,11 @@ type config struct {
 	silencePeriod time.Duration
 	decayer       *DecayerCfg
 	clock         clock.Clock
+	policy        Policy
+	diversity     *DiversityConstraints
+
+	persistDS       datastore.Datastore
+	persistInterval time.Duration
 }
 
 // Option represents an option for the basic connection manager.
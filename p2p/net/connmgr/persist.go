@@ -0,0 +1,299 @@
+package connmgr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+)
+
+const (
+	persistNamespace = "/libp2p/net/connmgr"
+	keyPersistPeer   = "/peer/"
+
+	// maxRestoredDecaySteps bounds the number of times the decay function is
+	// applied to a restored decaying tag.
+	maxRestoredDecaySteps = 10000
+)
+
+// WithPersistence persists tags, decaying tags and protections to the
+// datastore. The state is snapshotted every interval and when the connection
+// manager is closed, and restored when the connection manager is created.
+//
+// Restored peers are tracked like peers that were tagged before connecting:
+// they are pruned if they don't connect within the grace period. Restored
+// decaying tag values are only applied once a decaying tag with the same name
+// is registered, and are decayed for the time elapsed since they were saved.
+func WithPersistence(ds datastore.Datastore, interval time.Duration) Option {
+	return func(cfg *config) error {
+		if ds == nil {
+			return errors.New("datastore must not be nil")
+		}
+		if interval <= 0 {
+			return errors.New("persistence interval must be positive")
+		}
+		cfg.persistDS = ds
+		cfg.persistInterval = interval
+		return nil
+	}
+}
+
+type persistedPeer struct {
+	Tags      map[string]int                    `json:",omitempty"`
+	Decaying  map[string]persistedDecayingValue `json:",omitempty"`
+	Protected []string                          `json:",omitempty"`
+}
+
+type persistedDecayingValue struct {
+	Value     int
+	Added     time.Time
+	LastVisit time.Time
+}
+
+// persister snapshots and restores the connection manager state.
+type persister struct {
+	ds       datastore.Datastore
+	interval time.Duration
+
+	mx sync.Mutex
+	// restored decaying tag values, by tag name, waiting for the tag to be registered.
+	pendingDecaying map[string]map[peer.ID]persistedDecayingValue
+}
+
+func newPersister(ds datastore.Datastore, interval time.Duration) *persister {
+	return &persister{
+		ds:              namespace.Wrap(ds, datastore.NewKey(persistNamespace)),
+		interval:        interval,
+		pendingDecaying: make(map[string]map[peer.ID]persistedDecayingValue),
+	}
+}
+
+// restore loads the persisted state into cm. It must be called before cm is used.
+func (ps *persister) restore(ctx context.Context, cm *BasicConnMgr) error {
+	res, err := ps.ds.Query(ctx, query.Query{Prefix: keyPersistPeer})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	now := cm.clock.Now()
+	for r := range res.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		p, err := peer.Decode(datastore.RawKey(r.Key).BaseNamespace())
+		if err != nil {
+			log.Warnw("skipping persisted peer with invalid key", "key", r.Key, "error", err)
+			continue
+		}
+		var pp persistedPeer
+		if err := json.Unmarshal(r.Value, &pp); err != nil {
+			log.Warnw("skipping invalid persisted peer", "peer", p, "error", err)
+			continue
+		}
+
+		if len(pp.Tags) > 0 {
+			s := cm.segments.get(p)
+			s.Lock()
+			pi := s.tagInfoFor(p, now)
+			for t, v := range pp.Tags {
+				pi.value += v - pi.tags[t]
+				pi.tags[t] = v
+			}
+			s.Unlock()
+		}
+		for _, t := range pp.Protected {
+			cm.Protect(p, t)
+		}
+		ps.mx.Lock()
+		for name, v := range pp.Decaying {
+			if ps.pendingDecaying[name] == nil {
+				ps.pendingDecaying[name] = make(map[peer.ID]persistedDecayingValue)
+			}
+			ps.pendingDecaying[name][p] = v
+		}
+		ps.mx.Unlock()
+	}
+	return nil
+}
+
+// restoreDecaying applies the restored values of a newly registered decaying tag.
+func (ps *persister) restoreDecaying(cm *BasicConnMgr, tag *decayingTag, now time.Time) {
+	ps.mx.Lock()
+	pending := ps.pendingDecaying[tag.name]
+	delete(ps.pendingDecaying, tag.name)
+	ps.mx.Unlock()
+
+	for p, pv := range pending {
+		v := &connmgr.DecayingValue{
+			Tag:       tag,
+			Peer:      p,
+			Added:     pv.Added,
+			LastVisit: pv.LastVisit,
+			Value:     pv.Value,
+		}
+		// Apply the decay for the time elapsed since the value was last visited.
+		steps := int64(now.Sub(pv.LastVisit) / tag.interval)
+		removed := false
+		for i := int64(0); i < min(steps, maxRestoredDecaySteps); i++ {
+			after, rm := tag.decayFn(*v)
+			if rm {
+				removed = true
+				break
+			}
+			v.Value = after
+		}
+		if removed {
+			continue
+		}
+		v.LastVisit = now
+
+		s := cm.segments.get(p)
+		s.Lock()
+		pi := s.tagInfoFor(p, now)
+		if _, ok := pi.decaying[tag]; !ok {
+			pi.decaying[tag] = v
+			pi.value += v.Value
+		}
+		s.Unlock()
+	}
+}
+
+// snapshot collects the state to persist.
+func (ps *persister) snapshot(cm *BasicConnMgr) map[peer.ID]*persistedPeer {
+	state := make(map[peer.ID]*persistedPeer)
+	get := func(p peer.ID) *persistedPeer {
+		pp, ok := state[p]
+		if !ok {
+			pp = &persistedPeer{}
+			state[p] = pp
+		}
+		return pp
+	}
+
+	for _, s := range cm.segments.buckets {
+		s.Lock()
+		for p, pi := range s.peers {
+			if len(pi.tags) == 0 && len(pi.decaying) == 0 {
+				continue
+			}
+			pp := get(p)
+			if len(pi.tags) > 0 {
+				pp.Tags = make(map[string]int, len(pi.tags))
+				for t, v := range pi.tags {
+					pp.Tags[t] = v
+				}
+			}
+			if len(pi.decaying) > 0 {
+				pp.Decaying = make(map[string]persistedDecayingValue, len(pi.decaying))
+				for t, v := range pi.decaying {
+					pp.Decaying[t.name] = persistedDecayingValue{Value: v.Value, Added: v.Added, LastVisit: v.LastVisit}
+				}
+			}
+		}
+		s.Unlock()
+	}
+
+	cm.plk.RLock()
+	for p, tags := range cm.protected {
+		pp := get(p)
+		for t := range tags {
+			pp.Protected = append(pp.Protected, t)
+		}
+	}
+	cm.plk.RUnlock()
+
+	// Keep the restored decaying values of tags that weren't registered again (yet).
+	ps.mx.Lock()
+	for name, values := range ps.pendingDecaying {
+		for p, v := range values {
+			pp := get(p)
+			if pp.Decaying == nil {
+				pp.Decaying = make(map[string]persistedDecayingValue)
+			}
+			pp.Decaying[name] = v
+		}
+	}
+	ps.mx.Unlock()
+	return state
+}
+
+// save writes a snapshot of the state to the datastore, and removes peers that
+// are no longer tracked.
+func (ps *persister) save(ctx context.Context, cm *BasicConnMgr) error {
+	state := ps.snapshot(cm)
+
+	res, err := ps.ds.Query(ctx, query.Query{Prefix: keyPersistPeer, KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	var stale []datastore.Key
+	for r := range res.Next() {
+		if r.Error != nil {
+			res.Close()
+			return r.Error
+		}
+		p, err := peer.Decode(datastore.RawKey(r.Key).BaseNamespace())
+		if _, ok := state[p]; err != nil || !ok {
+			stale = append(stale, datastore.RawKey(r.Key))
+		}
+	}
+	res.Close()
+
+	var w datastore.Write = ps.ds
+	var batch datastore.Batch
+	if bds, ok := ps.ds.(datastore.Batching); ok {
+		batch, err = bds.Batch(ctx)
+		if err != nil {
+			return err
+		}
+		w = batch
+	}
+	for _, k := range stale {
+		if err := w.Delete(ctx, k); err != nil {
+			return err
+		}
+	}
+	for p, pp := range state {
+		b, err := json.Marshal(pp)
+		if err != nil {
+			return err
+		}
+		if err := w.Put(ctx, datastore.NewKey(keyPersistPeer+p.String()), b); err != nil {
+			return err
+		}
+	}
+	if batch != nil {
+		return batch.Commit(ctx)
+	}
+	return nil
+}
+
+func (ps *persister) background(cm *BasicConnMgr) {
+	defer cm.refCount.Done()
+
+	ticker := cm.clock.Ticker(ps.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := ps.save(cm.ctx, cm); err != nil {
+				log.Errorw("failed to persist connection manager state", "error", err)
+			}
+		case <-cm.ctx.Done():
+			if err := ps.save(context.Background(), cm); err != nil {
+				log.Errorw("failed to persist connection manager state", "error", err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package connmgr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	tu "github.com/libp2p/go-libp2p/core/test"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistence(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	mockClock := clock.NewMock()
+	newConnMgr := func() *BasicConnMgr {
+		cm, err := NewConnManager(10, 20,
+			WithClock(mockClock),
+			DecayerConfig(&DecayerCfg{Resolution: time.Minute, Clock: mockClock}),
+			WithPersistence(ds, time.Hour),
+		)
+		require.NoError(t, err)
+		return cm
+	}
+	decayFn := connmgr.DecayLinear(0.5)
+
+	p1 := tu.RandPeerIDFatal(t)
+	p2 := tu.RandPeerIDFatal(t)
+
+	cm := newConnMgr()
+	cm.TagPeer(p1, "static", 10)
+	cm.Protect(p2, "keep")
+	tag, err := cm.RegisterDecayingTag("decaying", time.Minute, decayFn, connmgr.BumpSumUnbounded())
+	require.NoError(t, err)
+	require.NoError(t, tag.Bump(p1, 40))
+	require.Eventually(t, func() bool {
+		return cm.GetTagInfo(p1).Value == 50
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, cm.Close())
+
+	// restart after two decay intervals
+	mockClock.Add(2 * time.Minute)
+	cm = newConnMgr()
+	defer cm.Close()
+
+	require.True(t, cm.IsProtected(p2, "keep"))
+	require.Equal(t, 10, cm.GetTagInfo(p1).Tags["static"])
+	require.NotContains(t, cm.GetTagInfo(p1).Tags, "decaying")
+
+	_, err = cm.RegisterDecayingTag("decaying", time.Minute, decayFn, connmgr.BumpSumUnbounded())
+	require.NoError(t, err)
+	info := cm.GetTagInfo(p1)
+	require.Equal(t, 10, info.Tags["decaying"])
+	require.Equal(t, 20, info.Value)
+}
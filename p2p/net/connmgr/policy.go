@@ -0,0 +1,273 @@
+package connmgr
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// Policy ranks peers when the connection manager trims connections. Peers
+// with a lower score are trimmed first.
+//
+// Score is called once per candidate peer for every trim, without holding
+// any connection manager locks, so it may consult other components such as
+// the peerstore.
+type Policy interface {
+	Score(p PeerSnapshot) float64
+}
+
+// PolicyFunc is a function that implements Policy.
+type PolicyFunc func(p PeerSnapshot) float64
+
+func (f PolicyFunc) Score(p PeerSnapshot) float64 { return f(p) }
+
+// PeerSnapshot is the state of a peer at the time of a trim.
+type PeerSnapshot struct {
+	ID peer.ID
+	// Value is the sum of all tag values, including decaying tags.
+	Value int
+	// Tags are the values of the (non decaying) tags of the peer.
+	Tags map[string]int
+	// FirstSeen is when the connection manager began tracking the peer.
+	FirstSeen time.Time
+	// Conns are the connections to the peer.
+	Conns []ConnSnapshot
+	// Now is the time of the trim.
+	Now time.Time
+}
+
+// ConnSnapshot is the state of a connection at the time of a trim.
+type ConnSnapshot struct {
+	Direction network.Direction
+	// Opened is when the connection manager was notified of the connection.
+	Opened time.Time
+	// Transport is the transport of the connection, e.g. tcp or quic-v1.
+	Transport string
+	// Protocols are the protocols of the streams open on the connection.
+	Protocols []protocol.ID
+}
+
+// WithPolicy sets the policy used to rank peers when trimming connections.
+// By default, peers are ranked by their tag values and stream counts.
+//
+// Temporary peers, which hold early tags but no connections yet, are always
+// trimmed first.
+func WithPolicy(p Policy) Option {
+	return func(cfg *config) error {
+		cfg.policy = p
+		return nil
+	}
+}
+
+// TagValuePolicy scores peers by the sum of their tag values.
+func TagValuePolicy() Policy {
+	return PolicyFunc(func(p PeerSnapshot) float64 {
+		return float64(p.Value)
+	})
+}
+
+// LatencyPolicy scores peers by their latency EWMA as recorded in the
+// peerstore, scaled to (0, 1]: a peer with the reference latency scores 0.5,
+// lower latencies score higher. Peers with no recorded latency score 0. A
+// non-positive reference scores all peers with a recorded latency equally.
+func LatencyPolicy(m peerstore.Metrics, reference time.Duration) Policy {
+	return PolicyFunc(func(p PeerSnapshot) float64 {
+		lat := m.LatencyEWMA(p.ID)
+		if lat <= 0 {
+			return 0
+		}
+		if reference <= 0 {
+			// every recorded latency is infinitely worse than the reference.
+			return math.SmallestNonzeroFloat64
+		}
+		return 1 / (1 + float64(lat)/float64(reference))
+	})
+}
+
+// BandwidthPolicy scores peers by their current bandwidth usage (inbound and
+// outbound) in bytes per second, as reported by the bandwidth counter.
+func BandwidthPolicy(r metrics.Reporter) Policy {
+	return PolicyFunc(func(p PeerSnapshot) float64 {
+		s := r.GetBandwidthForPeer(p.ID)
+		return s.RateIn + s.RateOut
+	})
+}
+
+// ConnAgePolicy scores peers by the age of their oldest connection, in
+// seconds, such that long lived connections are kept.
+func ConnAgePolicy() Policy {
+	return PolicyFunc(func(p PeerSnapshot) float64 {
+		var oldest time.Time
+		for _, c := range p.Conns {
+			if oldest.IsZero() || c.Opened.Before(oldest) {
+				oldest = c.Opened
+			}
+		}
+		if oldest.IsZero() {
+			return 0
+		}
+		return p.Now.Sub(oldest).Seconds()
+	})
+}
+
+// OutboundPolicy scores peers with at least one outbound connection 1, and 0
+// otherwise. Outbound connections are harder for an attacker to obtain.
+func OutboundPolicy() Policy {
+	return PolicyFunc(func(p PeerSnapshot) float64 {
+		for _, c := range p.Conns {
+			if c.Direction == network.DirOutbound {
+				return 1
+			}
+		}
+		return 0
+	})
+}
+
+// TransportPolicy scores peers by the best score of their connections'
+// transports. Transports that are not listed score 0.
+func TransportPolicy(scores map[string]float64) Policy {
+	return PolicyFunc(func(p PeerSnapshot) float64 {
+		best := 0.0
+		for i, c := range p.Conns {
+			if s := scores[c.Transport]; i == 0 || s > best {
+				best = s
+			}
+		}
+		return best
+	})
+}
+
+// ProtocolDiversityPolicy scores peers by the number of distinct protocols
+// they have streams open for.
+func ProtocolDiversityPolicy() Policy {
+	return PolicyFunc(func(p PeerSnapshot) float64 {
+		protos := make(map[protocol.ID]struct{})
+		for _, c := range p.Conns {
+			for _, proto := range c.Protocols {
+				if proto != "" {
+					protos[proto] = struct{}{}
+				}
+			}
+		}
+		return float64(len(protos))
+	})
+}
+
+// WeightedPolicy is a Policy with a weight, for use with CombinedPolicy.
+type WeightedPolicy struct {
+	Policy Policy
+	Weight float64
+}
+
+// CombinedPolicy scores peers by the weighted sum of the scores of policies.
+func CombinedPolicy(policies ...WeightedPolicy) Policy {
+	return PolicyFunc(func(p PeerSnapshot) float64 {
+		var score float64
+		for _, wp := range policies {
+			score += wp.Weight * wp.Policy.Score(p)
+		}
+		return score
+	})
+}
+
+// snapshot returns the snapshot of the peer. The peer's segment must be locked.
+func (pi *peerInfo) snapshot(now time.Time) PeerSnapshot {
+	snap := PeerSnapshot{
+		ID:        pi.id,
+		Value:     pi.value,
+		Tags:      make(map[string]int, len(pi.tags)),
+		FirstSeen: pi.firstSeen,
+		Conns:     make([]ConnSnapshot, 0, len(pi.conns)),
+		Now:       now,
+	}
+	for t, v := range pi.tags {
+		snap.Tags[t] = v
+	}
+	for c, opened := range pi.conns {
+		streams := c.GetStreams()
+		protos := make([]protocol.ID, 0, len(streams))
+		for _, s := range streams {
+			protos = append(protos, s.Protocol())
+		}
+		snap.Conns = append(snap.Conns, ConnSnapshot{
+			Direction: c.Stat().Direction,
+			Opened:    opened,
+			Transport: c.ConnState().Transport,
+			Protocols: protos,
+		})
+	}
+	return snap
+}
+
+// SortByPolicy sorts peerInfos by their policy score, lowest first. Temporary
+// peers are sorted before all others. Peers with equal scores are ordered by
+// their streams and connection direction, as in SortByValueAndStreams.
+func (p peerInfos) SortByPolicy(segments *segments, policy Policy, now time.Time, sortByMoreStreams bool) {
+	type scored struct {
+		temp     bool
+		score    float64
+		incoming bool
+		streams  int
+	}
+	scores := make(map[peer.ID]scored, len(p))
+	for _, inf := range p {
+		s := segments.get(inf.id)
+		s.Lock()
+		sc := scored{temp: inf.temp}
+		for c := range inf.conns {
+			stat := c.Stat()
+			if stat.Direction == network.DirInbound {
+				sc.incoming = true
+			}
+			sc.streams += stat.NumStreams
+		}
+		snap := inf.snapshot(now)
+		s.Unlock()
+
+		sc.score = policy.Score(snap)
+		if math.IsNaN(sc.score) {
+			sc.score = math.Inf(-1)
+		}
+		scores[inf.id] = sc
+	}
+
+	sort.SliceStable(p, func(i, j int) bool {
+		left, right := scores[p[i].id], scores[p[j].id]
+		// temporary peers are preferred for pruning.
+		if left.temp != right.temp {
+			return left.temp
+		}
+		if left.score != right.score {
+			return left.score < right.score
+		}
+		// prefer closing inactive connections (no streams open)
+		if left.streams != right.streams && (left.streams == 0 || right.streams == 0) {
+			return left.streams < right.streams
+		}
+		// incoming connections are preferred for pruning
+		if left.incoming != right.incoming {
+			return left.incoming
+		}
+		if sortByMoreStreams {
+			// prune connections with a higher number of streams first
+			return right.streams < left.streams
+		}
+		return left.streams < right.streams
+	})
+}
+
+// sortCandidates sorts the candidates for trimming, using the configured
+// policy if there is one.
+func (cm *BasicConnMgr) sortCandidates(candidates peerInfos, sortByMoreStreams bool) {
+	if cm.cfg.policy != nil {
+		candidates.SortByPolicy(&cm.segments, cm.cfg.policy, cm.clock.Now(), sortByMoreStreams)
+		return
+	}
+	candidates.SortByValueAndStreams(&cm.segments, sortByMoreStreams)
+}
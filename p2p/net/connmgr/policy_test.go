@@ -0,0 +1,128 @@
+package connmgr
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	tu "github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore"
+
+	"github.com/stretchr/testify/require"
+)
+
+type policyConn struct {
+	tconn
+	transport string
+}
+
+func (c *policyConn) ConnState() network.ConnectionState {
+	return network.ConnectionState{Transport: c.transport}
+}
+
+func (c *policyConn) GetStreams() []network.Stream { return nil }
+
+type statsConn struct {
+	policyConn
+	stats network.ConnStats
+}
+
+func (c *statsConn) Stat() network.ConnStats { return c.stats }
+
+func newPolicyConn(t *testing.T, transport string) *policyConn {
+	return &policyConn{tconn: tconn{peer: tu.RandPeerIDFatal(t)}, transport: transport}
+}
+
+func TestLatencyPolicyKeepsLowLatencyPeers(t *testing.T) {
+	m := peerstore.NewMetrics()
+	cm, err := NewConnManager(1, 2, WithGracePeriod(0), WithPolicy(LatencyPolicy(m, 100*time.Millisecond)))
+	require.NoError(t, err)
+	defer cm.Close()
+	not := cm.Notifee()
+
+	fast := newPolicyConn(t, "tcp")
+	slow := newPolicyConn(t, "tcp")
+	unknown := newPolicyConn(t, "tcp")
+	m.RecordLatency(fast.peer, 10*time.Millisecond)
+	m.RecordLatency(slow.peer, 500*time.Millisecond)
+	// the tag value is ignored by this policy
+	cm.TagPeer(slow.peer, "important", 100)
+
+	for _, c := range []*policyConn{fast, slow, unknown} {
+		not.Connected(nil, c)
+	}
+	cm.TrimOpenConns(context.Background())
+
+	require.False(t, fast.isClosed())
+	require.True(t, slow.isClosed())
+	require.True(t, unknown.isClosed())
+}
+
+func TestLatencyPolicyZeroReference(t *testing.T) {
+	m := peerstore.NewMetrics()
+	p := tu.RandPeerIDFatal(t)
+	policy := LatencyPolicy(m, 0)
+	require.Zero(t, policy.Score(PeerSnapshot{ID: p}))
+
+	m.RecordLatency(p, 10*time.Millisecond)
+	score := policy.Score(PeerSnapshot{ID: p})
+	require.False(t, math.IsNaN(score))
+	require.False(t, math.IsInf(score, 0))
+	require.Positive(t, score)
+}
+
+func TestSortByPolicyTiebreak(t *testing.T) {
+	newPeer := func(name string, stats ...network.ConnStats) *peerInfo {
+		pi := &peerInfo{id: peer.ID(name), conns: make(map[network.Conn]time.Time)}
+		for _, st := range stats {
+			pi.conns[&statsConn{stats: st}] = time.Now()
+		}
+		return pi
+	}
+	outgoing := func(streams int) network.ConnStats {
+		return network.ConnStats{Stats: network.Stats{Direction: network.DirOutbound}, NumStreams: streams}
+	}
+	incoming := network.ConnStats{Stats: network.Stats{Direction: network.DirInbound}}
+
+	p1 := newPeer("peer1", outgoing(1))
+	p2 := newPeer("peer2", outgoing(1), incoming)
+	p3 := newPeer("peer3", outgoing(0), incoming)
+	p4 := newPeer("peer4", outgoing(2), incoming)
+
+	// all peers score the same, so the stream and direction tiebreaks apply.
+	pis := peerInfos{p1, p2, p3, p4}
+	pis.SortByPolicy(makeSegmentsWithPeerInfos(pis), TagValuePolicy(), time.Now(), true)
+	require.Equal(t, peerInfos{p3, p4, p2, p1}, pis)
+
+	pis = peerInfos{p1, p2, p3, p4}
+	pis.SortByPolicy(makeSegmentsWithPeerInfos(pis), TagValuePolicy(), time.Now(), false)
+	require.Equal(t, peerInfos{p3, p2, p4, p1}, pis)
+
+	// the score still takes precedence.
+	p4.value = 1
+	pis = peerInfos{p1, p2, p3, p4}
+	pis.SortByPolicy(makeSegmentsWithPeerInfos(pis), TagValuePolicy(), time.Now(), true)
+	require.Equal(t, peerInfos{p3, p2, p1, p4}, pis)
+}
+
+func TestCombinedPolicy(t *testing.T) {
+	now := time.Now()
+	policy := CombinedPolicy(
+		WeightedPolicy{Policy: TagValuePolicy(), Weight: 1},
+		WeightedPolicy{Policy: TransportPolicy(map[string]float64{"quic-v1": 1}), Weight: 10},
+		WeightedPolicy{Policy: OutboundPolicy(), Weight: 100},
+		WeightedPolicy{Policy: ConnAgePolicy(), Weight: 1000},
+	)
+	score := policy.Score(PeerSnapshot{
+		Value: 5,
+		Conns: []ConnSnapshot{
+			{Direction: network.DirInbound, Transport: "tcp", Opened: now.Add(-time.Second)},
+			{Direction: network.DirOutbound, Transport: "quic-v1", Opened: now},
+		},
+		Now: now,
+	})
+	require.Equal(t, 5+10+100+1000.0, score)
+}
@@ -8,6 +8,7 @@ import (
 	"math"
 	"math/rand"
 	"net"
+	"slices"
 	"strings"
 	"time"
 
@@ -65,6 +66,7 @@ func discoverNATs(ctx context.Context) ([]NAT, []error) {
 	}
 	upnpCh := make(chan natsAndErrs)
 	pmpCh := make(chan natsAndErrs)
+	pcpCh := make(chan natsAndErrs)
 
 	go func() {
 		defer close(upnpCh)
@@ -109,25 +111,71 @@ func discoverNATs(ctx context.Context) ([]NAT, []error) {
 		}
 	}()
 
+	go func() {
+		defer close(pcpCh)
+		nat, err := discoverPCP(ctx)
+		var nats []NAT
+		var errs []error
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			nats = append(nats, nat)
+		}
+		select {
+		case pcpCh <- natsAndErrs{nats, errs}:
+		case <-ctx.Done():
+		}
+	}()
+
 	var nats []NAT
 	var errs []error
 
-	for upnpCh != nil || pmpCh != nil {
+	for upnpCh != nil || pmpCh != nil || pcpCh != nil {
 		select {
 		case res := <-pmpCh:
 			pmpCh = nil
 			nats = append(nats, res.nats...)
 			errs = append(errs, res.errs...)
+		case res := <-pcpCh:
+			pcpCh = nil
+			nats = append(nats, res.nats...)
+			errs = append(errs, res.errs...)
 		case res := <-upnpCh:
 			upnpCh = nil
 			nats = append(nats, res.nats...)
 			errs = append(errs, res.errs...)
 		case <-ctx.Done():
 			errs = append(errs, ctx.Err())
-			return nats, errs
+			return preferPCP(nats), errs
+		}
+	}
+	return preferPCP(nats), errs
+}
+
+// preferPCP removes the NAT-PMP NATs of gateways that also speak PCP, its
+// successor. PCP servers usually also answer NAT-PMP requests.
+func preferPCP(nats []NAT) []NAT {
+	var pcpGateways []net.IP
+	for _, n := range nats {
+		if n.Type() == "PCP" {
+			gw, _ := n.GetDeviceAddress()
+			pcpGateways = append(pcpGateways, gw)
+		}
+	}
+	if len(pcpGateways) == 0 {
+		return nats
+	}
+	res := nats[:0]
+	for _, n := range nats {
+		if n.Type() == "NAT-PMP" {
+			gw, _ := n.GetDeviceAddress()
+			if slices.ContainsFunc(pcpGateways, gw.Equal) {
+				continue
+			}
 		}
+		res = append(res, n)
 	}
-	return nats, errs
+	return res
 }
 
 // DiscoverGateway attempts to find a gateway device.
@@ -185,3 +233,30 @@ func getDefaultGateway() (net.IP, error) {
 	_, ip, _, err := router.Route(net.IPv4zero)
 	return ip, err
 }
+
+// getInternalAddress returns the address of the local host on the network of
+// the gateway.
+func getInternalAddress(gateway net.IP) (net.IP, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, addr := range addrs {
+			switch x := addr.(type) {
+			case *net.IPNet:
+				if x.Contains(gateway) {
+					return x.IP, nil
+				}
+			}
+		}
+	}
+
+	return nil, ErrNoInternalAddress
+}
@@ -67,28 +67,7 @@ func (n *natpmpNAT) GetDeviceAddress() (addr net.IP, err error) {
 }
 
 func (n *natpmpNAT) GetInternalAddress() (addr net.IP, err error) {
-	ifaces, err := net.Interfaces()
-	if err != nil {
-		return nil, err
-	}
-
-	for _, iface := range ifaces {
-		addrs, err := iface.Addrs()
-		if err != nil {
-			return nil, err
-		}
-
-		for _, addr := range addrs {
-			switch x := addr.(type) {
-			case *net.IPNet:
-				if x.Contains(n.gateway) {
-					return x.IP, nil
-				}
-			}
-		}
-	}
-
-	return nil, ErrNoInternalAddress
+	return getInternalAddress(n.gateway)
 }
 
 func (n *natpmpNAT) GetExternalAddress() (addr net.IP, err error) {
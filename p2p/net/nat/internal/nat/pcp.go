@@ -0,0 +1,674 @@
+package nat
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-netroute"
+)
+
+// PCP (Port Control Protocol) as specified in RFC 6887.
+
+var (
+	_ NAT = (*pcpNAT)(nil)
+	_ PCP = (*pcpNAT)(nil)
+)
+
+const (
+	pcpVersion = 2
+	pcpPort    = 5351
+
+	pcpOpAnnounce = 0
+	pcpOpMap      = 1
+	pcpOpPeer     = 2
+
+	pcpOptThirdParty = 1
+
+	pcpResponseBit = 0x80
+
+	pcpHeaderSize    = 24
+	pcpMapSize       = 36
+	pcpPeerSize      = 56
+	pcpMaxSize       = 1100
+	pcpNonceSize     = 12
+	pcpOptHeaderSize = 4
+
+	pcpProtoTCP = 6
+	pcpProtoUDP = 17
+
+	pcpMaxAttempts = 6
+
+	// pcpInitialRetransmit is the time we wait for a response before
+	// retransmitting a request for the first time. RFC 6887 recommends 3s, but
+	// like NAT-PMP we start lower, as the server is on the local network.
+	pcpInitialRetransmit = 250 * time.Millisecond
+
+	// pcpDefaultLifetime is the lifetime we request for mappings without a
+	// timeout. A lifetime of 0 deletes a mapping.
+	pcpDefaultLifetime = 2 * time.Hour
+)
+
+// PCP result codes used by the client, see pcpResultNames for all codes
+const (
+	pcpSuccess         = 0
+	pcpUnsuppVersion   = 1
+	pcpNotAuthorized   = 2
+	pcpAddressMismatch = 12
+)
+
+var pcpResultNames = [...]string{
+	"SUCCESS",
+	"UNSUPP_VERSION",
+	"NOT_AUTHORIZED",
+	"MALFORMED_REQUEST",
+	"UNSUPP_OPCODE",
+	"UNSUPP_OPTION",
+	"MALFORMED_OPTION",
+	"NETWORK_FAILURE",
+	"NO_RESOURCES",
+	"UNSUPP_PROTOCOL",
+	"USER_EX_QUOTA",
+	"CANNOT_PROVIDE_EXTERNAL",
+	"ADDRESS_MISMATCH",
+	"EXCESSIVE_REMOTE_PEERS",
+}
+
+// PCPError is the error returned when a PCP server refuses a request.
+type PCPError struct {
+	ResultCode uint8
+}
+
+func (e PCPError) Error() string {
+	if int(e.ResultCode) < len(pcpResultNames) {
+		return fmt.Sprintf("PCP error: %s", pcpResultNames[e.ResultCode])
+	}
+	return fmt.Sprintf("PCP error: result code %d", e.ResultCode)
+}
+
+var errPCPNoIPv6 = errors.New("no IPv6 PCP server")
+
+// PCP is implemented by NATs that speak the Port Control Protocol. On top of
+// port mappings, PCP supports opening IPv6 firewall pinholes, mappings on
+// behalf of other hosts and mappings towards specific peers.
+type PCP interface {
+	NAT
+
+	// AddPinhole opens an IPv6 firewall pinhole to the given local address
+	// and port. The address must be an IPv6 address of the local host.
+	AddPinhole(ctx context.Context, protocol string, internalAddr netip.Addr, internalPort int, timeout time.Duration) error
+
+	// DeletePinhole closes an IPv6 firewall pinhole.
+	DeletePinhole(ctx context.Context, protocol string, internalAddr netip.Addr, internalPort int) error
+
+	// AddThirdPartyMapping maps a port of another host on the local network to
+	// an external port, using the THIRD_PARTY option.
+	AddThirdPartyMapping(ctx context.Context, protocol string, internalAddr netip.Addr, internalPort int, timeout time.Duration) (mappedExternalPort int, err error)
+
+	// DeleteThirdPartyMapping removes a mapping of a port of another host.
+	DeleteThirdPartyMapping(ctx context.Context, protocol string, internalAddr netip.Addr, internalPort int) error
+
+	// AddPeerMapping creates a mapping of a port on the local host for
+	// communication with a single remote peer, using the PEER opcode. It
+	// returns the external address and port of the mapping.
+	AddPeerMapping(ctx context.Context, protocol string, internalPort int, remote netip.AddrPort, timeout time.Duration) (netip.AddrPort, error)
+
+	// DeletePeerMapping removes a mapping towards a remote peer.
+	DeletePeerMapping(ctx context.Context, protocol string, internalPort int, remote netip.AddrPort) error
+}
+
+// pcpMappingKey identifies a mapping, so that we can reuse its nonce and
+// external port when renewing it, as required by RFC 6887.
+type pcpMappingKey struct {
+	protocol     string
+	internalAddr netip.Addr // zero for mappings of the local host
+	internalPort int
+	remote       netip.AddrPort // zero for MAP mappings
+}
+
+// source returns the local address to send the requests for the mapping to
+// server from, and the options of the requests. Pinholes are requested from
+// the pinholed address. Mappings for other hosts are requested from our
+// default address, using the THIRD_PARTY option.
+func (k pcpMappingKey) source(server *net.UDPAddr) (local net.IP, opts []byte) {
+	switch {
+	case !k.internalAddr.IsValid():
+		return nil, nil
+	case k.internalAddr.Is6() && !k.internalAddr.Is4In6() && server.IP.To4() == nil:
+		return k.internalAddr.AsSlice(), nil
+	default:
+		return nil, pcpOption(pcpOptThirdParty, pcpAddr(k.internalAddr.AsSlice()))
+	}
+}
+
+// unspecified returns the all-zeros address of the family of the mapping,
+// used as the suggested external address. Servers use its family to pick the
+// family of the external address (RFC 6887, section 11.1), and reject it if
+// it doesn't match the family of the internal address: the address of the
+// third party, or else the source address of the request, which has the
+// family of server.
+func (k pcpMappingKey) unspecified(server *net.UDPAddr) net.IP {
+	if k.internalAddr.IsValid() {
+		if k.internalAddr.Unmap().Is4() {
+			return net.IPv4zero
+		}
+		return net.IPv6unspecified
+	}
+	if server.IP.To4() != nil {
+		return net.IPv4zero
+	}
+	return net.IPv6unspecified
+}
+
+type pcpMapping struct {
+	nonce        [pcpNonceSize]byte
+	externalPort int
+	// server and lifetime are the server and the requested lifetime of the
+	// mapping, to re-create it when the server lost its state
+	server   *net.UDPAddr
+	lifetime uint32
+}
+
+// pcpEpoch is the last epoch time a server sent us, and when we received it.
+type pcpEpoch struct {
+	server   uint32
+	received time.Time
+}
+
+// valid reports whether the epoch time curr received at now is consistent
+// with e, following RFC 6887, section 8.5. An inconsistent epoch time means
+// that the server lost its state, e.g. because it rebooted, and that the
+// mappings need to be re-created.
+func (e pcpEpoch) valid(curr uint32, now time.Time) bool {
+	if curr+1 < e.server {
+		return false
+	}
+	clientDelta := int64(now.Sub(e.received) / time.Second)
+	serverDelta := int64(curr) - int64(e.server)
+	return clientDelta+2 >= serverDelta-serverDelta/16 && serverDelta+2 >= clientDelta-clientDelta/16
+}
+
+type pcpNAT struct {
+	gateway net.IP
+	// server is the address of the PCP server, the gateway
+	server *net.UDPAddr
+	// server6 is the IPv6 address of the PCP server, used for pinholes. nil
+	// if the gateway doesn't have an IPv6 address.
+	server6 *net.UDPAddr
+
+	mx       sync.Mutex
+	mappings map[pcpMappingKey]*pcpMapping
+	extIP    net.IP
+	// epochs are the epochs of the servers, keyed by server address
+	epochs map[string]pcpEpoch
+}
+
+func discoverPCP(ctx context.Context) (NAT, error) {
+	ip, err := getDefaultGateway()
+	if err != nil {
+		return nil, err
+	}
+	n, err := discoverPCPWithAddr(ctx, ip, &net.UDPAddr{IP: ip, Port: pcpPort})
+	if err != nil {
+		return nil, err
+	}
+	n.server6 = getDefaultGateway6()
+	return n, nil
+}
+
+// discoverPCPWithAddr checks that server speaks PCP, by sending an ANNOUNCE
+// request.
+func discoverPCPWithAddr(ctx context.Context, gateway net.IP, server *net.UDPAddr) (*pcpNAT, error) {
+	n := &pcpNAT{
+		gateway:  gateway,
+		server:   server,
+		mappings: make(map[pcpMappingKey]*pcpMapping),
+		epochs:   make(map[string]pcpEpoch),
+	}
+	res, err := pcpRequest(ctx, n.server, nil, pcpOpAnnounce, 0, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	n.updateEpoch(n.server, res.epoch)
+	return n, nil
+}
+
+// getDefaultGateway6 returns the address of the IPv6 default gateway's PCP
+// server, or nil if there is none.
+func getDefaultGateway6() *net.UDPAddr {
+	router, err := netroute.New()
+	if err != nil {
+		return nil
+	}
+	iface, ip, _, err := router.Route(net.IPv6zero)
+	if err != nil || ip == nil || ip.To4() != nil {
+		return nil
+	}
+	addr := &net.UDPAddr{IP: ip, Port: pcpPort}
+	if ip.IsLinkLocalUnicast() && iface != nil {
+		addr.Zone = iface.Name
+	}
+	return addr
+}
+
+func (n *pcpNAT) Type() string {
+	return "PCP"
+}
+
+func (n *pcpNAT) GetDeviceAddress() (addr net.IP, err error) {
+	return n.gateway, nil
+}
+
+func (n *pcpNAT) GetInternalAddress() (addr net.IP, err error) {
+	return getInternalAddress(n.gateway)
+}
+
+// GetExternalAddress returns the external address assigned to the last
+// mapping. PCP has no request for the external address, so if we don't have
+// a mapping yet, we create and delete a mapping of the discard port.
+func (n *pcpNAT) GetExternalAddress() (addr net.IP, err error) {
+	n.mx.Lock()
+	extIP := n.extIP
+	n.mx.Unlock()
+	if extIP != nil {
+		return extIP, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	const discardPort = 9
+	if _, err := n.AddPortMapping(ctx, "udp", discardPort, "", time.Minute); err != nil {
+		return nil, err
+	}
+	if err := n.DeletePortMapping(ctx, "udp", discardPort); err != nil {
+		log.Debugf("failed to delete PCP probe mapping: %s", err)
+	}
+
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	if n.extIP == nil {
+		return nil, ErrNoExternalAddress
+	}
+	return n.extIP, nil
+}
+
+func (n *pcpNAT) AddPortMapping(ctx context.Context, protocol string, internalPort int, _ string, timeout time.Duration) (int, error) {
+	key := pcpMappingKey{protocol: protocol, internalPort: internalPort}
+	ext, err := n.addMapping(ctx, n.server, key, timeout)
+	if err != nil {
+		return 0, err
+	}
+	return int(ext.Port()), nil
+}
+
+func (n *pcpNAT) DeletePortMapping(ctx context.Context, protocol string, internalPort int) error {
+	return n.deleteMapping(ctx, n.server, pcpMappingKey{protocol: protocol, internalPort: internalPort})
+}
+
+func (n *pcpNAT) AddPinhole(ctx context.Context, protocol string, internalAddr netip.Addr, internalPort int, timeout time.Duration) error {
+	if n.server6 == nil {
+		return errPCPNoIPv6
+	}
+	if !internalAddr.Is6() || internalAddr.Is4In6() {
+		return fmt.Errorf("pinholes require an IPv6 address: %s", internalAddr)
+	}
+	key := pcpMappingKey{protocol: protocol, internalAddr: internalAddr, internalPort: internalPort}
+	_, err := n.addMapping(ctx, n.server6, key, timeout)
+	return err
+}
+
+func (n *pcpNAT) DeletePinhole(ctx context.Context, protocol string, internalAddr netip.Addr, internalPort int) error {
+	if n.server6 == nil {
+		return errPCPNoIPv6
+	}
+	key := pcpMappingKey{protocol: protocol, internalAddr: internalAddr, internalPort: internalPort}
+	return n.deleteMapping(ctx, n.server6, key)
+}
+
+func (n *pcpNAT) AddThirdPartyMapping(ctx context.Context, protocol string, internalAddr netip.Addr, internalPort int, timeout time.Duration) (int, error) {
+	if !internalAddr.IsValid() {
+		return 0, errors.New("invalid third party address")
+	}
+	key := pcpMappingKey{protocol: protocol, internalAddr: internalAddr, internalPort: internalPort}
+	ext, err := n.addMapping(ctx, n.server, key, timeout)
+	if err != nil {
+		return 0, err
+	}
+	return int(ext.Port()), nil
+}
+
+func (n *pcpNAT) DeleteThirdPartyMapping(ctx context.Context, protocol string, internalAddr netip.Addr, internalPort int) error {
+	key := pcpMappingKey{protocol: protocol, internalAddr: internalAddr, internalPort: internalPort}
+	return n.deleteMapping(ctx, n.server, key)
+}
+
+func (n *pcpNAT) AddPeerMapping(ctx context.Context, protocol string, internalPort int, remote netip.AddrPort, timeout time.Duration) (netip.AddrPort, error) {
+	if !remote.IsValid() {
+		return netip.AddrPort{}, errors.New("invalid remote peer address")
+	}
+	key := pcpMappingKey{protocol: protocol, internalPort: internalPort, remote: remote}
+	return n.addMapping(ctx, n.server, key, timeout)
+}
+
+func (n *pcpNAT) DeletePeerMapping(ctx context.Context, protocol string, internalPort int, remote netip.AddrPort) error {
+	key := pcpMappingKey{protocol: protocol, internalPort: internalPort, remote: remote}
+	return n.deleteMapping(ctx, n.server, key)
+}
+
+// mapping returns the state of the mapping, creating it if necessary.
+func (n *pcpNAT) mapping(key pcpMappingKey) (*pcpMapping, error) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	m, ok := n.mappings[key]
+	if !ok {
+		m = &pcpMapping{}
+		if _, err := rand.Read(m.nonce[:]); err != nil {
+			return nil, err
+		}
+		n.mappings[key] = m
+	}
+	return m, nil
+}
+
+// updateEpoch records the epoch time of a response of server, and reports
+// whether it is consistent with the previous one.
+func (n *pcpNAT) updateEpoch(server *net.UDPAddr, epoch uint32) bool {
+	now := time.Now()
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	prev, ok := n.epochs[server.String()]
+	n.epochs[server.String()] = pcpEpoch{server: epoch, received: now}
+	return !ok || prev.valid(epoch, now)
+}
+
+func (n *pcpNAT) addMapping(ctx context.Context, server *net.UDPAddr, key pcpMappingKey, timeout time.Duration) (netip.AddrPort, error) {
+	if timeout <= 0 {
+		timeout = pcpDefaultLifetime
+	}
+	lifetime := uint32(max(timeout/time.Second, 1))
+	ext, epochValid, err := n.requestMapping(ctx, server, key, lifetime)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	if !epochValid {
+		log.Debugf("PCP server %s lost its state, re-creating the mappings", server)
+		n.remap(ctx, server, key)
+	}
+	return ext, nil
+}
+
+// remap re-creates the mappings requested from server, except for the
+// mapping with key skip.
+func (n *pcpNAT) remap(ctx context.Context, server *net.UDPAddr, skip pcpMappingKey) {
+	type mapping struct {
+		key      pcpMappingKey
+		lifetime uint32
+	}
+	var mappings []mapping
+	n.mx.Lock()
+	for k, m := range n.mappings {
+		if k != skip && m.server.String() == server.String() {
+			mappings = append(mappings, mapping{key: k, lifetime: m.lifetime})
+		}
+	}
+	n.mx.Unlock()
+	for _, m := range mappings {
+		if _, _, err := n.requestMapping(ctx, server, m.key, m.lifetime); err != nil {
+			log.Debugf("failed to re-create PCP mapping %s/%d: %s", m.key.protocol, m.key.internalPort, err)
+		}
+	}
+}
+
+// requestMapping sends a MAP or PEER request creating or renewing a mapping.
+// It returns the external address of the mapping, and whether the epoch time
+// of the response was consistent with the previous responses of the server.
+func (n *pcpNAT) requestMapping(ctx context.Context, server *net.UDPAddr, key pcpMappingKey, lifetime uint32) (ext netip.AddrPort, epochValid bool, err error) {
+	proto, err := pcpProtocol(key.protocol)
+	if err != nil {
+		return netip.AddrPort{}, false, err
+	}
+	m, err := n.mapping(key)
+	if err != nil {
+		return netip.AddrPort{}, false, err
+	}
+
+	n.mx.Lock()
+	m.server = server
+	m.lifetime = lifetime
+	suggested := m.externalPort
+	n.mx.Unlock()
+	if suggested == 0 {
+		suggested = key.internalPort
+	}
+
+	local, opts := key.source(server)
+	op := byte(pcpOpMap)
+	payload := pcpMapPayload(m.nonce, proto, key.internalPort, suggested, key.unspecified(server))
+	if key.remote.IsValid() {
+		op = pcpOpPeer
+		payload = pcpPeerPayload(payload, key.remote)
+	}
+	res, err := pcpRequest(ctx, server, local, op, lifetime, payload, opts)
+	if err != nil {
+		return netip.AddrPort{}, false, err
+	}
+	if res.nonce != m.nonce {
+		return netip.AddrPort{}, false, errors.New("PCP response nonce mismatch")
+	}
+	if res.lifetime == 0 {
+		return netip.AddrPort{}, false, errors.New("PCP server returned a mapping with zero lifetime")
+	}
+	epochValid = n.updateEpoch(server, res.epoch)
+
+	n.mx.Lock()
+	m.externalPort = int(res.externalPort)
+	// pinholes don't tell us anything about the external IPv4 address
+	if ip := res.externalIP.Unmap(); ip.Is4() && !ip.IsUnspecified() {
+		n.extIP = ip.AsSlice()
+	}
+	n.mx.Unlock()
+	return netip.AddrPortFrom(res.externalIP.Unmap(), res.externalPort), epochValid, nil
+}
+
+func (n *pcpNAT) deleteMapping(ctx context.Context, server *net.UDPAddr, key pcpMappingKey) error {
+	n.mx.Lock()
+	m, ok := n.mappings[key]
+	delete(n.mappings, key)
+	n.mx.Unlock()
+	if !ok {
+		return nil
+	}
+	proto, err := pcpProtocol(key.protocol)
+	if err != nil {
+		return err
+	}
+	local, opts := key.source(server)
+	op := byte(pcpOpMap)
+	payload := pcpMapPayload(m.nonce, proto, key.internalPort, 0, key.unspecified(server))
+	if key.remote.IsValid() {
+		op = pcpOpPeer
+		payload = pcpPeerPayload(payload, key.remote)
+	}
+	// a lifetime of 0 deletes the mapping
+	_, err = pcpRequest(ctx, server, local, op, 0, payload, opts)
+	return err
+}
+
+func pcpProtocol(protocol string) (byte, error) {
+	switch protocol {
+	case "tcp":
+		return pcpProtoTCP, nil
+	case "udp":
+		return pcpProtoUDP, nil
+	default:
+		return 0, fmt.Errorf("invalid protocol: %s", protocol)
+	}
+}
+
+// pcpAddr returns the 16 byte representation of an IP address used by PCP,
+// with IPv4 addresses mapped to IPv6.
+func pcpAddr(ip net.IP) []byte {
+	if ip == nil {
+		return make([]byte, net.IPv6len)
+	}
+	return ip.To16()
+}
+
+func pcpMapPayload(nonce [pcpNonceSize]byte, proto byte, internalPort, suggestedPort int, suggestedIP net.IP) []byte {
+	b := make([]byte, pcpMapSize)
+	copy(b, nonce[:])
+	b[12] = proto
+	binary.BigEndian.PutUint16(b[16:], uint16(internalPort))
+	binary.BigEndian.PutUint16(b[18:], uint16(suggestedPort))
+	copy(b[20:], pcpAddr(suggestedIP))
+	return b
+}
+
+func pcpPeerPayload(mapPayload []byte, remote netip.AddrPort) []byte {
+	b := make([]byte, pcpPeerSize)
+	copy(b, mapPayload)
+	binary.BigEndian.PutUint16(b[36:], remote.Port())
+	ip := remote.Addr().As16()
+	copy(b[40:], ip[:])
+	return b
+}
+
+func pcpOption(code byte, data []byte) []byte {
+	padded := (len(data) + 3) &^ 3
+	b := make([]byte, pcpOptHeaderSize+padded)
+	b[0] = code
+	binary.BigEndian.PutUint16(b[2:], uint16(len(data)))
+	copy(b[pcpOptHeaderSize:], data)
+	return b
+}
+
+type pcpResponse struct {
+	opcode     byte
+	resultCode uint8
+	lifetime   uint32
+	epoch      uint32
+	// MAP and PEER responses only
+	nonce        [pcpNonceSize]byte
+	externalPort uint16
+	externalIP   netip.Addr
+}
+
+func parsePCPResponse(b []byte) (*pcpResponse, error) {
+	if len(b) >= 2 && b[0] != pcpVersion && b[1]&pcpResponseBit != 0 {
+		// NAT-PMP servers answer with an unsupported version error
+		return nil, PCPError{ResultCode: pcpUnsuppVersion}
+	}
+	if len(b) < pcpHeaderSize || len(b) > pcpMaxSize || len(b)%4 != 0 {
+		return nil, errors.New("invalid PCP response size")
+	}
+	if b[1]&pcpResponseBit == 0 {
+		return nil, errors.New("not a PCP response")
+	}
+	res := &pcpResponse{
+		opcode:     b[1] &^ pcpResponseBit,
+		resultCode: b[3],
+		lifetime:   binary.BigEndian.Uint32(b[4:]),
+		epoch:      binary.BigEndian.Uint32(b[8:]),
+	}
+	if res.resultCode != pcpSuccess {
+		return res, PCPError{ResultCode: res.resultCode}
+	}
+	switch res.opcode {
+	case pcpOpMap, pcpOpPeer:
+		size := pcpMapSize
+		if res.opcode == pcpOpPeer {
+			size = pcpPeerSize
+		}
+		p := b[pcpHeaderSize:]
+		if len(p) < size {
+			return nil, errors.New("PCP response too short")
+		}
+		copy(res.nonce[:], p)
+		res.externalPort = binary.BigEndian.Uint16(p[18:])
+		res.externalIP, _ = netip.AddrFromSlice(p[20:36])
+	}
+	return res, nil
+}
+
+// pcpRequest sends a request to server, from the local address if not nil,
+// retransmitting it until we receive a response or ctx is done.
+func pcpRequest(ctx context.Context, server *net.UDPAddr, local net.IP, op byte, lifetime uint32, payload, opts []byte) (*pcpResponse, error) {
+	var laddr *net.UDPAddr
+	if local != nil {
+		laddr = &net.UDPAddr{IP: local, Zone: server.Zone}
+	}
+	conn, err := net.DialUDP("udp", laddr, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// the client address must be the source address of the request
+	clientIP := conn.LocalAddr().(*net.UDPAddr).IP
+	req := make([]byte, pcpHeaderSize, pcpHeaderSize+len(payload)+len(opts))
+	req[0] = pcpVersion
+	req[1] = op
+	binary.BigEndian.PutUint32(req[4:], lifetime)
+	copy(req[8:], pcpAddr(clientIP))
+	req = append(req, payload...)
+	req = append(req, opts...)
+
+	var nonce []byte
+	if op == pcpOpMap || op == pcpOpPeer {
+		nonce = payload[:pcpNonceSize]
+	}
+
+	buf := make([]byte, pcpMaxSize)
+	rto := pcpInitialRetransmit
+	for i := 0; i < pcpMaxAttempts; i++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(rto)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		conn.SetReadDeadline(deadline)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				var nerr net.Error
+				if errors.As(err, &nerr) && nerr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			res, err := parsePCPResponse(buf[:n])
+			if res == nil {
+				var perr PCPError
+				if errors.As(err, &perr) {
+					return nil, err
+				}
+				log.Debugf("ignoring invalid PCP response: %s", err)
+				continue
+			}
+			if res.opcode != op {
+				continue
+			}
+			if nonce != nil && res.resultCode == pcpSuccess && string(res.nonce[:]) != string(nonce) {
+				// response to an earlier request
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return res, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		rto *= 2
+	}
+	return nil, errors.New("PCP request timed out")
+}
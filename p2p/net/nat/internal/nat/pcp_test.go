@@ -0,0 +1,389 @@
+package nat
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// pcpMalformedRequest is the result code of the fake server for requests
+// with a suggested external address of the wrong family.
+const pcpMalformedRequest = 3
+
+type fakePCPRequest struct {
+	op           byte
+	lifetime     uint32
+	clientIP     netip.Addr
+	nonce        [pcpNonceSize]byte
+	proto        byte
+	internalPort uint16
+	suggested    uint16
+	suggestedIP  netip.Addr
+	remote       netip.AddrPort
+	thirdParty   netip.Addr
+}
+
+// fakePCPServer is a minimal PCP server, mapping ports to themselves on a
+// fixed external address.
+type fakePCPServer struct {
+	conn  *net.UDPConn
+	extIP netip.Addr
+
+	mx       sync.Mutex
+	requests []fakePCPRequest
+	// result is the result code of the responses
+	result uint8
+	// drop is the number of requests to ignore
+	drop int
+	// natpmp makes the server answer like a NAT-PMP server
+	natpmp bool
+	// epoch is the epoch time of the responses
+	epoch uint32
+}
+
+func newFakePCPServer(t *testing.T, network, addr string) *fakePCPServer {
+	t.Helper()
+	conn, err := net.ListenUDP(network, &net.UDPAddr{IP: net.ParseIP(addr)})
+	if err != nil {
+		t.Skipf("can't listen on %s: %s", addr, err)
+	}
+	s := &fakePCPServer{conn: conn, extIP: netip.MustParseAddr("1.2.3.4"), epoch: 42}
+	t.Cleanup(func() { conn.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakePCPServer) addr() *net.UDPAddr {
+	return s.conn.LocalAddr().(*net.UDPAddr)
+}
+
+func (s *fakePCPServer) getRequests() []fakePCPRequest {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return append([]fakePCPRequest(nil), s.requests...)
+}
+
+func (s *fakePCPServer) serve() {
+	buf := make([]byte, pcpMaxSize)
+	for {
+		n, from, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if res := s.handle(buf[:n], from); res != nil {
+			s.conn.WriteToUDP(res, from)
+		}
+	}
+}
+
+func (s *fakePCPServer) handle(b []byte, from *net.UDPAddr) []byte {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.drop > 0 {
+		s.drop--
+		return nil
+	}
+	if s.natpmp {
+		res := make([]byte, 8)
+		res[1] = pcpResponseBit | b[1]
+		binary.BigEndian.PutUint16(res[2:], pcpUnsuppVersion)
+		return res
+	}
+
+	req := fakePCPRequest{
+		op:       b[1],
+		lifetime: binary.BigEndian.Uint32(b[4:]),
+	}
+	req.clientIP, _ = netip.AddrFromSlice(b[8:24])
+	req.clientIP = req.clientIP.Unmap()
+	p := b[pcpHeaderSize:]
+	var payload []byte
+	switch req.op {
+	case pcpOpMap, pcpOpPeer:
+		size := pcpMapSize
+		if req.op == pcpOpPeer {
+			size = pcpPeerSize
+			ip, _ := netip.AddrFromSlice(p[40:56])
+			req.remote = netip.AddrPortFrom(ip.Unmap(), binary.BigEndian.Uint16(p[36:]))
+		}
+		payload = append([]byte(nil), p[:size]...)
+		copy(req.nonce[:], p)
+		req.proto = p[12]
+		req.internalPort = binary.BigEndian.Uint16(p[16:])
+		req.suggested = binary.BigEndian.Uint16(p[18:])
+		req.suggestedIP, _ = netip.AddrFromSlice(p[20:36])
+		for opts := p[size:]; len(opts) >= pcpOptHeaderSize; {
+			l := int(binary.BigEndian.Uint16(opts[2:]))
+			if opts[0] == pcpOptThirdParty {
+				req.thirdParty, _ = netip.AddrFromSlice(opts[4 : 4+l])
+				req.thirdParty = req.thirdParty.Unmap()
+			}
+			opts = opts[pcpOptHeaderSize+(l+3)&^3:]
+		}
+	}
+	s.requests = append(s.requests, req)
+
+	result := s.result
+	if src, _ := netip.AddrFromSlice(from.IP); src.Unmap() != req.clientIP {
+		result = pcpAddressMismatch
+	}
+	// like miniupnpd, pick the family of the mapping from the suggested
+	// external address, and reject mappings of the wrong family
+	internal := req.clientIP
+	if req.thirdParty.IsValid() {
+		internal = req.thirdParty
+	}
+	if payload != nil && req.suggestedIP.Is4In6() != internal.Is4() {
+		result = pcpMalformedRequest
+	}
+	res := make([]byte, pcpHeaderSize, pcpHeaderSize+len(payload))
+	res[0] = pcpVersion
+	res[1] = pcpResponseBit | req.op
+	res[3] = result
+	binary.BigEndian.PutUint32(res[4:], req.lifetime)
+	binary.BigEndian.PutUint32(res[8:], s.epoch)
+	if payload != nil {
+		ext := s.extIP
+		if req.clientIP.Is6() {
+			ext = req.clientIP
+		}
+		binary.BigEndian.PutUint16(payload[18:], req.internalPort)
+		ip := ext.As16()
+		copy(payload[20:], ip[:])
+		res = append(res, payload...)
+	}
+	return res
+}
+
+func TestPCPMapping(t *testing.T) {
+	s := newFakePCPServer(t, "udp4", "127.0.0.1")
+	ctx := context.Background()
+	n, err := discoverPCPWithAddr(ctx, net.IPv4(127, 0, 0, 1), s.addr())
+	require.NoError(t, err)
+	require.Equal(t, "PCP", n.Type())
+
+	port, err := n.AddPortMapping(ctx, "tcp", 4001, "libp2p", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 4001, port)
+	ext, err := n.GetExternalAddress()
+	require.NoError(t, err)
+	require.Equal(t, net.IP(s.extIP.AsSlice()), ext)
+
+	// renewals reuse the nonce
+	_, err = n.AddPortMapping(ctx, "tcp", 4001, "libp2p", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, n.DeletePortMapping(ctx, "tcp", 4001))
+
+	reqs := s.getRequests()
+	require.Len(t, reqs, 4)
+	require.Equal(t, byte(pcpOpAnnounce), reqs[0].op)
+	for _, req := range reqs[1:] {
+		require.Equal(t, byte(pcpOpMap), req.op)
+		require.Equal(t, byte(pcpProtoTCP), req.proto)
+		require.Equal(t, uint16(4001), req.internalPort)
+		require.Equal(t, reqs[1].nonce, req.nonce)
+		require.Equal(t, netip.MustParseAddr("::ffff:0.0.0.0"), req.suggestedIP)
+	}
+	require.Equal(t, uint32(60), reqs[1].lifetime)
+	require.Equal(t, uint32(0), reqs[3].lifetime)
+}
+
+func TestPCPExternalAddressProbe(t *testing.T) {
+	s := newFakePCPServer(t, "udp4", "127.0.0.1")
+	n, err := discoverPCPWithAddr(context.Background(), net.IPv4(127, 0, 0, 1), s.addr())
+	require.NoError(t, err)
+
+	ext, err := n.GetExternalAddress()
+	require.NoError(t, err)
+	require.Equal(t, net.IP(s.extIP.AsSlice()), ext)
+	reqs := s.getRequests()
+	require.Len(t, reqs, 3)
+	require.Equal(t, uint32(0), reqs[2].lifetime, "the probe mapping should be deleted")
+}
+
+func TestPCPThirdPartyAndPeer(t *testing.T) {
+	s := newFakePCPServer(t, "udp4", "127.0.0.1")
+	ctx := context.Background()
+	n, err := discoverPCPWithAddr(ctx, net.IPv4(127, 0, 0, 1), s.addr())
+	require.NoError(t, err)
+
+	other := netip.MustParseAddr("192.168.1.42")
+	port, err := n.AddThirdPartyMapping(ctx, "udp", other, 5000, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 5000, port)
+
+	remote := netip.MustParseAddrPort("5.6.7.8:9000")
+	ext, err := n.AddPeerMapping(ctx, "udp", 6000, remote, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, netip.AddrPortFrom(s.extIP, 6000), ext)
+
+	// an IPv6 third party of an IPv4 server
+	other6 := netip.MustParseAddr("2001:db8::42")
+	_, err = n.AddThirdPartyMapping(ctx, "udp", other6, 5001, time.Minute)
+	require.NoError(t, err)
+
+	require.NoError(t, n.DeleteThirdPartyMapping(ctx, "udp", other, 5000))
+	require.NoError(t, n.DeletePeerMapping(ctx, "udp", 6000, remote))
+
+	reqs := s.getRequests()
+	require.Len(t, reqs, 6)
+	require.Equal(t, byte(pcpOpMap), reqs[1].op)
+	require.Equal(t, other, reqs[1].thirdParty)
+	require.Equal(t, byte(pcpProtoUDP), reqs[1].proto)
+	require.Equal(t, byte(pcpOpPeer), reqs[2].op)
+	require.Equal(t, remote, reqs[2].remote)
+	require.False(t, reqs[2].thirdParty.IsValid())
+	for _, req := range reqs[1:3] {
+		require.True(t, req.suggestedIP.Is4In6())
+	}
+	require.Equal(t, other6, reqs[3].thirdParty)
+	require.Equal(t, netip.IPv6Unspecified(), reqs[3].suggestedIP)
+
+	require.Equal(t, other, reqs[4].thirdParty)
+	require.Equal(t, uint32(0), reqs[4].lifetime)
+	require.Equal(t, reqs[1].nonce, reqs[4].nonce)
+	require.Equal(t, byte(pcpOpPeer), reqs[5].op)
+	require.Equal(t, remote, reqs[5].remote)
+	require.Equal(t, uint32(0), reqs[5].lifetime)
+	require.Equal(t, reqs[2].nonce, reqs[5].nonce)
+}
+
+func TestPCPEpoch(t *testing.T) {
+	start := time.Now()
+	e := pcpEpoch{server: 1000, received: start}
+	require.True(t, e.valid(1000, start))
+	require.True(t, e.valid(999, start), "the epoch may go back by one second")
+	require.False(t, e.valid(998, start))
+	require.True(t, e.valid(1060, start.Add(time.Minute)))
+	require.False(t, e.valid(1000, start.Add(time.Hour)), "the server epoch must advance with our clock")
+	require.False(t, e.valid(5000, start.Add(time.Minute)))
+}
+
+func TestPCPRemapOnEpochReset(t *testing.T) {
+	s := newFakePCPServer(t, "udp4", "127.0.0.1")
+	ctx := context.Background()
+	n, err := discoverPCPWithAddr(ctx, net.IPv4(127, 0, 0, 1), s.addr())
+	require.NoError(t, err)
+
+	_, err = n.AddPortMapping(ctx, "tcp", 4001, "libp2p", time.Minute)
+	require.NoError(t, err)
+	_, err = n.AddPortMapping(ctx, "udp", 4001, "libp2p", time.Minute)
+	require.NoError(t, err)
+	require.Len(t, s.getRequests(), 3)
+
+	// the server rebooted and lost the udp mapping
+	s.mx.Lock()
+	s.epoch = 0
+	s.mx.Unlock()
+	_, err = n.AddPortMapping(ctx, "tcp", 4001, "libp2p", time.Minute)
+	require.NoError(t, err)
+
+	reqs := s.getRequests()
+	require.Len(t, reqs, 5)
+	require.Equal(t, byte(pcpProtoTCP), reqs[3].proto)
+	require.Equal(t, byte(pcpProtoUDP), reqs[4].proto)
+	require.Equal(t, reqs[2].nonce, reqs[4].nonce)
+	require.Equal(t, uint32(60), reqs[4].lifetime)
+
+	// the epoch is consistent again
+	_, err = n.AddPortMapping(ctx, "tcp", 4001, "libp2p", time.Minute)
+	require.NoError(t, err)
+	require.Len(t, s.getRequests(), 6)
+}
+
+func TestPCPPinhole(t *testing.T) {
+	s := newFakePCPServer(t, "udp6", "::1")
+	ctx := context.Background()
+	n, err := discoverPCPWithAddr(ctx, net.IPv6loopback, s.addr())
+	require.NoError(t, err)
+
+	require.ErrorIs(t, (&pcpNAT{}).AddPinhole(ctx, "tcp", netip.IPv6Loopback(), 4001, time.Minute), errPCPNoIPv6)
+
+	n.server6 = s.addr()
+	require.Error(t, n.AddPinhole(ctx, "tcp", netip.MustParseAddr("192.168.1.42"), 4001, time.Minute))
+	require.NoError(t, n.AddPinhole(ctx, "tcp", netip.IPv6Loopback(), 4001, time.Minute))
+	require.NoError(t, n.DeletePinhole(ctx, "tcp", netip.IPv6Loopback(), 4001))
+
+	reqs := s.getRequests()
+	require.Len(t, reqs, 3)
+	require.Equal(t, netip.IPv6Loopback(), reqs[1].clientIP)
+	require.False(t, reqs[1].thirdParty.IsValid())
+	require.Equal(t, uint32(0), reqs[2].lifetime)
+	for _, req := range reqs[1:] {
+		require.Equal(t, netip.IPv6Unspecified(), req.suggestedIP)
+	}
+
+	// pinholes don't change the external IPv4 address
+	n.mx.Lock()
+	require.Nil(t, n.extIP)
+	n.mx.Unlock()
+}
+
+func TestPCPErrors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("error result", func(t *testing.T) {
+		s := newFakePCPServer(t, "udp4", "127.0.0.1")
+		n, err := discoverPCPWithAddr(ctx, net.IPv4(127, 0, 0, 1), s.addr())
+		require.NoError(t, err)
+		s.mx.Lock()
+		s.result = pcpNotAuthorized
+		s.mx.Unlock()
+		_, err = n.AddPortMapping(ctx, "tcp", 4001, "libp2p", time.Minute)
+		require.Equal(t, PCPError{ResultCode: pcpNotAuthorized}, err)
+		require.EqualError(t, err, "PCP error: NOT_AUTHORIZED")
+	})
+
+	t.Run("retransmission", func(t *testing.T) {
+		s := newFakePCPServer(t, "udp4", "127.0.0.1")
+		s.mx.Lock()
+		s.drop = 2
+		s.mx.Unlock()
+		_, err := discoverPCPWithAddr(ctx, net.IPv4(127, 0, 0, 1), s.addr())
+		require.NoError(t, err)
+	})
+
+	t.Run("NAT-PMP server", func(t *testing.T) {
+		s := newFakePCPServer(t, "udp4", "127.0.0.1")
+		s.mx.Lock()
+		s.natpmp = true
+		s.mx.Unlock()
+		_, err := discoverPCPWithAddr(ctx, net.IPv4(127, 0, 0, 1), s.addr())
+		require.Equal(t, PCPError{ResultCode: pcpUnsuppVersion}, err)
+	})
+
+	t.Run("invalid protocol", func(t *testing.T) {
+		s := newFakePCPServer(t, "udp4", "127.0.0.1")
+		n, err := discoverPCPWithAddr(ctx, net.IPv4(127, 0, 0, 1), s.addr())
+		require.NoError(t, err)
+		_, err = n.AddPortMapping(ctx, "sctp", 4001, "libp2p", time.Minute)
+		require.Error(t, err)
+	})
+}
+
+type fakeNAT struct {
+	NAT
+	typ     string
+	gateway net.IP
+}
+
+func (n fakeNAT) Type() string                      { return n.typ }
+func (n fakeNAT) GetDeviceAddress() (net.IP, error) { return n.gateway, nil }
+
+func TestPreferPCP(t *testing.T) {
+	gw := net.IPv4(192, 168, 1, 1)
+	upnp := fakeNAT{typ: "UPNP (IG1-IP1)", gateway: gw}
+	pmp := fakeNAT{typ: "NAT-PMP", gateway: gw}
+	otherPMP := fakeNAT{typ: "NAT-PMP", gateway: net.IPv4(10, 0, 0, 1)}
+	pcp := fakeNAT{typ: "PCP", gateway: gw}
+
+	require.Equal(t, []NAT{upnp, pmp}, preferPCP([]NAT{upnp, pmp}))
+	require.Equal(t, []NAT{upnp, otherPMP, pcp}, preferPCP([]NAT{upnp, pmp, otherPMP, pcp}))
+}
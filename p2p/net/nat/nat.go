@@ -1,22 +1,35 @@
 package nat
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net/netip"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	logging "github.com/ipfs/go-log/v2"
 
+	"github.com/libp2p/go-libp2p/core/event"
+
 	"github.com/libp2p/go-libp2p/p2p/net/nat/internal/nat"
 )
 
 // ErrNoMapping signals no mapping exists for an address
 var ErrNoMapping = errors.New("mapping not established")
 
+// ErrPinholesUnsupported is returned when adding a pinhole to a NAT that
+// doesn't speak PCP.
+var ErrPinholesUnsupported = errors.New("NAT doesn't support IPv6 pinholes")
+
+// ErrPCPUnsupported is returned when adding a third party or a peer mapping
+// to a NAT that doesn't speak PCP.
+var ErrPCPUnsupported = errors.New("NAT doesn't speak PCP")
+
 var log = logging.Logger("nat")
 
 // MappingDuration is a default port mapping duration.
@@ -31,11 +44,64 @@ type entry struct {
 	port     int
 }
 
+// Mapping is the status of a port mapping.
+type Mapping struct {
+	// Protocol is the transport protocol, "tcp" or "udp".
+	Protocol string
+	// InternalPort is the mapped local port.
+	InternalPort int
+	// ExternalAddr is the external address of the mapping. It is not valid if
+	// the mapping is not established.
+	ExternalAddr netip.AddrPort
+	// Lease is the lifetime of the mapping, 0 if the gateway only granted a
+	// permanent mapping.
+	Lease time.Duration
+	// LastUpdate is the time of the last attempt to create or renew the
+	// mapping.
+	LastUpdate time.Time
+	// LastError is the error of the last attempt, nil if it succeeded.
+	LastError error
+}
+
+type mappingStatus struct {
+	lease   time.Duration
+	updated time.Time
+	err     error
+}
+
+// Option is an option for DiscoverNAT.
+type Option func(*NAT)
+
+// WithMappingEventHandler sets a function that is called when a port mapping
+// is created, renewed, fails or is lost.
+func WithMappingEventHandler(f func(event.EvtPortMappingChanged)) Option {
+	return func(n *NAT) {
+		n.onEvent = f
+	}
+}
+
+var errZeroExternalPort = errors.New("gateway returned external port 0")
+
+type pinhole struct {
+	protocol string
+	addr     netip.AddrPort
+}
+
+// thirdPartyMapping is a mapping of a port of another host on the local
+// network.
+type thirdPartyMapping = pinhole
+
+type peerMapping struct {
+	protocol string
+	port     int
+	remote   netip.AddrPort
+}
+
 // so we can mock it in tests
 var discoverGateway = nat.DiscoverGateway
 
 // DiscoverNAT looks for a NAT device in the network and returns an object that can manage port mappings.
-func DiscoverNAT(ctx context.Context) (*NAT, error) {
+func DiscoverNAT(ctx context.Context, opts ...Option) (*NAT, error) {
 	natInstance, err := discoverGateway(ctx)
 	if err != nil {
 		return nil, err
@@ -56,10 +122,17 @@ func DiscoverNAT(ctx context.Context) (*NAT, error) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	nat := &NAT{
-		nat:       natInstance,
-		mappings:  make(map[entry]int),
-		ctx:       ctx,
-		ctxCancel: cancel,
+		nat:        natInstance,
+		mappings:   make(map[entry]int),
+		status:     make(map[entry]mappingStatus),
+		pinholes:   make(map[pinhole]struct{}),
+		thirdParty: make(map[thirdPartyMapping]struct{}),
+		peers:      make(map[peerMapping]struct{}),
+		ctx:        ctx,
+		ctxCancel:  cancel,
+	}
+	for _, opt := range opts {
+		opt(nat)
 	}
 	nat.extAddr.Store(&extAddr)
 	nat.refCount.Add(1)
@@ -84,9 +157,16 @@ type NAT struct {
 	ctx       context.Context
 	ctxCancel context.CancelFunc
 
-	mappingmu sync.RWMutex // guards mappings
-	closed    bool
-	mappings  map[entry]int
+	mappingmu  sync.RWMutex // guards mappings, status, pinholes, thirdParty and peers
+	closed     bool
+	mappings   map[entry]int
+	status     map[entry]mappingStatus
+	pinholes   map[pinhole]struct{}
+	thirdParty map[thirdPartyMapping]struct{}
+	peers      map[peerMapping]struct{}
+
+	// onEvent is called when a mapping changes, nil if not set
+	onEvent func(event.EvtPortMappingChanged)
 }
 
 // Close shuts down all port mappings. NAT can no longer be used.
@@ -100,6 +180,39 @@ func (nat *NAT) Close() error {
 	return nil
 }
 
+// Type returns the port mapping protocol of the gateway, e.g. "NAT-PMP".
+func (nat *NAT) Type() string {
+	return nat.nat.Type()
+}
+
+// Mappings returns the status of all port mappings, sorted by protocol and
+// internal port.
+func (nat *NAT) Mappings() []Mapping {
+	nat.mappingmu.Lock()
+	defer nat.mappingmu.Unlock()
+
+	extAddr := *nat.extAddr.Load()
+	res := make([]Mapping, 0, len(nat.mappings))
+	for e, extPort := range nat.mappings {
+		st := nat.status[e]
+		m := Mapping{
+			Protocol:     e.protocol,
+			InternalPort: e.port,
+			Lease:        st.lease,
+			LastUpdate:   st.updated,
+			LastError:    st.err,
+		}
+		if extPort != 0 && extAddr.IsValid() {
+			m.ExternalAddr = netip.AddrPortFrom(extAddr, uint16(extPort))
+		}
+		res = append(res, m)
+	}
+	slices.SortFunc(res, func(a, b Mapping) int {
+		return cmp.Or(cmp.Compare(a.Protocol, b.Protocol), cmp.Compare(a.InternalPort, b.InternalPort))
+	})
+	return res
+}
+
 func (nat *NAT) GetMapping(protocol string, port int) (addr netip.AddrPort, found bool) {
 	nat.mappingmu.Lock()
 	defer nat.mappingmu.Unlock()
@@ -128,19 +241,22 @@ func (nat *NAT) AddMapping(ctx context.Context, protocol string, port int) error
 	}
 
 	nat.mappingmu.Lock()
-	defer nat.mappingmu.Unlock()
-
 	if nat.closed {
+		nat.mappingmu.Unlock()
 		return errors.New("closed")
 	}
 
 	// do it once synchronously, so first mapping is done right away, and before exiting,
 	// allowing users -- in the optimistic case -- to use results right after.
-	extPort := nat.establishMapping(ctx, protocol, port)
+	e := entry{protocol: protocol, port: port}
+	extPort, lease, err := nat.establishMapping(ctx, protocol, port)
 	// Don't validate the mapping here, we refresh the mappings based on this map.
 	// We can try getting a port again in case it succeeds. In the worst case,
 	// this is one extra LAN request every few minutes.
-	nat.mappings[entry{protocol: protocol, port: port}] = extPort
+	evt := nat.updateMapping(e, extPort, lease, err)
+	nat.mappingmu.Unlock()
+
+	nat.emit(evt)
 	return nil
 }
 
@@ -155,6 +271,7 @@ func (nat *NAT) RemoveMapping(ctx context.Context, protocol string, port int) er
 		e := entry{protocol: protocol, port: port}
 		if _, ok := nat.mappings[e]; ok {
 			delete(nat.mappings, e)
+			delete(nat.status, e)
 			return nat.nat.DeletePortMapping(ctx, protocol, port)
 		}
 		return errors.New("unknown mapping")
@@ -163,6 +280,150 @@ func (nat *NAT) RemoveMapping(ctx context.Context, protocol string, port int) er
 	}
 }
 
+// AddPinhole opens an IPv6 firewall pinhole for protocol to the local address
+// addr, if the NAT speaks PCP. It blocks until the pinhole was opened. Once
+// added, it periodically renews the pinhole.
+func (nat *NAT) AddPinhole(ctx context.Context, protocol string, addr netip.AddrPort) error {
+	switch protocol {
+	case "tcp", "udp":
+	default:
+		return fmt.Errorf("invalid protocol: %s", protocol)
+	}
+	pcp, ok := asPCP(nat.nat)
+	if !ok {
+		return ErrPinholesUnsupported
+	}
+
+	nat.mappingmu.Lock()
+	defer nat.mappingmu.Unlock()
+
+	if nat.closed {
+		return errors.New("closed")
+	}
+
+	nat.natmu.Lock()
+	err := pcp.AddPinhole(ctx, protocol, addr.Addr(), int(addr.Port()), MappingDuration)
+	nat.natmu.Unlock()
+	if err != nil {
+		return err
+	}
+	nat.pinholes[pinhole{protocol: protocol, addr: addr}] = struct{}{}
+	return nil
+}
+
+// RemovePinhole closes an IPv6 firewall pinhole.
+func (nat *NAT) RemovePinhole(ctx context.Context, protocol string, addr netip.AddrPort) error {
+	nat.mappingmu.Lock()
+	defer nat.mappingmu.Unlock()
+
+	p := pinhole{protocol: protocol, addr: addr}
+	if _, ok := nat.pinholes[p]; !ok {
+		return errors.New("unknown pinhole")
+	}
+	delete(nat.pinholes, p)
+	pcp, _ := asPCP(nat.nat)
+	nat.natmu.Lock()
+	defer nat.natmu.Unlock()
+	return pcp.DeletePinhole(ctx, protocol, addr.Addr(), int(addr.Port()))
+}
+
+// AddThirdPartyMapping maps the port of another host on the local network,
+// internal, to an external port, if the NAT speaks PCP. It blocks until the
+// mapping was established, and returns the external address of the mapping,
+// which is invalid if the external IP of the NAT is unknown. Once added, it
+// periodically renews the mapping.
+func (nat *NAT) AddThirdPartyMapping(ctx context.Context, protocol string, internal netip.AddrPort) (netip.AddrPort, error) {
+	switch protocol {
+	case "tcp", "udp":
+	default:
+		return netip.AddrPort{}, fmt.Errorf("invalid protocol: %s", protocol)
+	}
+	pcp, ok := asPCP(nat.nat)
+	if !ok {
+		return netip.AddrPort{}, ErrPCPUnsupported
+	}
+
+	nat.mappingmu.Lock()
+	defer nat.mappingmu.Unlock()
+
+	if nat.closed {
+		return netip.AddrPort{}, errors.New("closed")
+	}
+
+	nat.natmu.Lock()
+	extPort, err := pcp.AddThirdPartyMapping(ctx, protocol, internal.Addr(), int(internal.Port()), MappingDuration)
+	nat.natmu.Unlock()
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	nat.thirdParty[thirdPartyMapping{protocol: protocol, addr: internal}] = struct{}{}
+	return netip.AddrPortFrom(*nat.extAddr.Load(), uint16(extPort)), nil
+}
+
+// RemoveThirdPartyMapping removes a mapping of a port of another host.
+func (nat *NAT) RemoveThirdPartyMapping(ctx context.Context, protocol string, internal netip.AddrPort) error {
+	nat.mappingmu.Lock()
+	defer nat.mappingmu.Unlock()
+
+	m := thirdPartyMapping{protocol: protocol, addr: internal}
+	if _, ok := nat.thirdParty[m]; !ok {
+		return errors.New("unknown mapping")
+	}
+	delete(nat.thirdParty, m)
+	pcp, _ := asPCP(nat.nat)
+	nat.natmu.Lock()
+	defer nat.natmu.Unlock()
+	return pcp.DeleteThirdPartyMapping(ctx, protocol, internal.Addr(), int(internal.Port()))
+}
+
+// AddPeerMapping maps the local port to an external address for the
+// communication with the single remote peer, if the NAT speaks PCP. It blocks
+// until the mapping was established, and returns the external address of the
+// mapping. Once added, it periodically renews the mapping.
+func (nat *NAT) AddPeerMapping(ctx context.Context, protocol string, port int, remote netip.AddrPort) (netip.AddrPort, error) {
+	switch protocol {
+	case "tcp", "udp":
+	default:
+		return netip.AddrPort{}, fmt.Errorf("invalid protocol: %s", protocol)
+	}
+	pcp, ok := asPCP(nat.nat)
+	if !ok {
+		return netip.AddrPort{}, ErrPCPUnsupported
+	}
+
+	nat.mappingmu.Lock()
+	defer nat.mappingmu.Unlock()
+
+	if nat.closed {
+		return netip.AddrPort{}, errors.New("closed")
+	}
+
+	nat.natmu.Lock()
+	ext, err := pcp.AddPeerMapping(ctx, protocol, port, remote, MappingDuration)
+	nat.natmu.Unlock()
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	nat.peers[peerMapping{protocol: protocol, port: port, remote: remote}] = struct{}{}
+	return ext, nil
+}
+
+// RemovePeerMapping removes a mapping towards a remote peer.
+func (nat *NAT) RemovePeerMapping(ctx context.Context, protocol string, port int, remote netip.AddrPort) error {
+	nat.mappingmu.Lock()
+	defer nat.mappingmu.Unlock()
+
+	m := peerMapping{protocol: protocol, port: port, remote: remote}
+	if _, ok := nat.peers[m]; !ok {
+		return errors.New("unknown mapping")
+	}
+	delete(nat.peers, m)
+	pcp, _ := asPCP(nat.nat)
+	nat.natmu.Lock()
+	defer nat.natmu.Unlock()
+	return pcp.DeletePeerMapping(ctx, protocol, port, remote)
+}
+
 func (nat *NAT) background() {
 	const mappingUpdate = MappingDuration / 3
 
@@ -173,8 +434,14 @@ func (nat *NAT) background() {
 	t := time.NewTimer(minTime(nextMappingUpdate, nextAddrUpdate).Sub(now)) // don't use a ticker here. We don't know how long establishing the mappings takes.
 	defer t.Stop()
 
+	type result struct {
+		port  int
+		lease time.Duration
+		err   error
+	}
 	var in []entry
-	var out []int // port numbers
+	var out []result
+	var events []event.EvtPortMappingChanged
 	for {
 		select {
 		case now := <-t.C:
@@ -189,16 +456,22 @@ func (nat *NAT) background() {
 				// Establishing the mapping involves network requests.
 				// Don't hold the mutex, just save the ports.
 				for _, e := range in {
-					out = append(out, nat.establishMapping(nat.ctx, e.protocol, e.port))
+					port, lease, err := nat.establishMapping(nat.ctx, e.protocol, e.port)
+					out = append(out, result{port: port, lease: lease, err: err})
 				}
+				nat.renewPCP(nat.ctx)
+				events = events[:0]
 				nat.mappingmu.Lock()
 				for i, p := range in {
 					if _, ok := nat.mappings[p]; !ok {
 						continue // entry might have been deleted
 					}
-					nat.mappings[p] = out[i]
+					events = append(events, nat.updateMapping(p, out[i].port, out[i].lease, out[i].err))
 				}
 				nat.mappingmu.Unlock()
+				for _, evt := range events {
+					nat.emit(evt)
+				}
 				nextMappingUpdate = time.Now().Add(mappingUpdate)
 			}
 			if now.After(nextAddrUpdate) {
@@ -217,23 +490,41 @@ func (nat *NAT) background() {
 			defer cancel()
 			for e := range nat.mappings {
 				delete(nat.mappings, e)
+				delete(nat.status, e)
 				nat.nat.DeletePortMapping(ctx, e.protocol, e.port)
 			}
+			if pcp, ok := asPCP(nat.nat); ok {
+				for p := range nat.pinholes {
+					delete(nat.pinholes, p)
+					pcp.DeletePinhole(ctx, p.protocol, p.addr.Addr(), int(p.addr.Port()))
+				}
+				for m := range nat.thirdParty {
+					delete(nat.thirdParty, m)
+					pcp.DeleteThirdPartyMapping(ctx, m.protocol, m.addr.Addr(), int(m.addr.Port()))
+				}
+				for m := range nat.peers {
+					delete(nat.peers, m)
+					pcp.DeletePeerMapping(ctx, m.protocol, m.port, m.remote)
+				}
+			}
 			nat.mappingmu.Unlock()
 			return
 		}
 	}
 }
 
-func (nat *NAT) establishMapping(ctx context.Context, protocol string, internalPort int) (externalPort int) {
+// establishMapping creates or renews a mapping, returning the external port
+// and the lease of the mapping.
+func (nat *NAT) establishMapping(ctx context.Context, protocol string, internalPort int) (externalPort int, lease time.Duration, err error) {
 	log.Debugf("Attempting port map: %s/%d", protocol, internalPort)
 	const comment = "libp2p"
 
 	nat.natmu.Lock()
-	var err error
+	lease = MappingDuration
 	externalPort, err = nat.nat.AddPortMapping(ctx, protocol, internalPort, comment, MappingDuration)
 	if err != nil {
 		// Some hardware does not support mappings with timeout, so try that
+		lease = 0
 		externalPort, err = nat.nat.AddPortMapping(ctx, protocol, internalPort, comment, 0)
 	}
 	nat.natmu.Unlock()
@@ -243,14 +534,101 @@ func (nat *NAT) establishMapping(ctx context.Context, protocol string, internalP
 			log.Warnf("NAT port mapping failed: protocol=%s internal_port=%d error=%q", protocol, internalPort, err)
 		} else {
 			log.Warnf("NAT port mapping failed: protocol=%s internal_port=%d external_port=0", protocol, internalPort)
+			err = errZeroExternalPort
 		}
 		// we do not close if the mapping failed,
 		// because it may work again next time.
-		return 0
+		return 0, 0, err
 	}
 
 	log.Debugf("NAT Mapping: %d --> %d (%s)", externalPort, internalPort, protocol)
-	return externalPort
+	return externalPort, lease, nil
+}
+
+// updateMapping records the result of an attempt to create or renew a
+// mapping, and returns the event describing the change. It must be called
+// with the mappingmu held.
+func (nat *NAT) updateMapping(e entry, extPort int, lease time.Duration, err error) event.EvtPortMappingChanged {
+	prevPort, existed := nat.mappings[e]
+	nat.mappings[e] = extPort
+	nat.status[e] = mappingStatus{lease: lease, updated: time.Now(), err: err}
+
+	evt := event.EvtPortMappingChanged{
+		GatewayType:  nat.nat.Type(),
+		Protocol:     e.protocol,
+		InternalPort: e.port,
+		Lease:        lease,
+		Error:        err,
+	}
+	established := existed && prevPort != 0
+	switch {
+	case err != nil && established:
+		evt.Change = event.PortMappingLost
+	case err != nil:
+		evt.Change = event.PortMappingFailed
+	case established && prevPort != extPort:
+		evt.Change = event.PortMappingRemapped
+	case established:
+		evt.Change = event.PortMappingRenewed
+	default:
+		evt.Change = event.PortMappingCreated
+	}
+	if err == nil {
+		if extAddr := *nat.extAddr.Load(); extAddr.IsValid() {
+			evt.ExternalAddr = netip.AddrPortFrom(extAddr, uint16(extPort))
+		}
+	}
+	return evt
+}
+
+func (nat *NAT) emit(evt event.EvtPortMappingChanged) {
+	if nat.onEvent != nil {
+		nat.onEvent(evt)
+	}
+}
+
+// renewPCP renews the pinholes, third party and peer mappings. We keep the
+// ones that fail, they may work again next time.
+func (nat *NAT) renewPCP(ctx context.Context) {
+	pcp, ok := asPCP(nat.nat)
+	if !ok {
+		return
+	}
+	nat.mappingmu.Lock()
+	pinholes := slices.Collect(maps.Keys(nat.pinholes))
+	thirdParty := slices.Collect(maps.Keys(nat.thirdParty))
+	peers := slices.Collect(maps.Keys(nat.peers))
+	nat.mappingmu.Unlock()
+
+	for _, p := range pinholes {
+		nat.natmu.Lock()
+		err := pcp.AddPinhole(ctx, p.protocol, p.addr.Addr(), int(p.addr.Port()), MappingDuration)
+		nat.natmu.Unlock()
+		if err != nil {
+			log.Warnf("NAT pinhole renewal failed: protocol=%s addr=%s error=%q", p.protocol, p.addr, err)
+		}
+	}
+	for _, m := range thirdParty {
+		nat.natmu.Lock()
+		_, err := pcp.AddThirdPartyMapping(ctx, m.protocol, m.addr.Addr(), int(m.addr.Port()), MappingDuration)
+		nat.natmu.Unlock()
+		if err != nil {
+			log.Warnf("NAT third party mapping renewal failed: protocol=%s addr=%s error=%q", m.protocol, m.addr, err)
+		}
+	}
+	for _, m := range peers {
+		nat.natmu.Lock()
+		_, err := pcp.AddPeerMapping(ctx, m.protocol, m.port, m.remote, MappingDuration)
+		nat.natmu.Unlock()
+		if err != nil {
+			log.Warnf("NAT peer mapping renewal failed: protocol=%s port=%d remote=%s error=%q", m.protocol, m.port, m.remote, err)
+		}
+	}
+}
+
+func asPCP(n nat.NAT) (nat.PCP, bool) {
+	pcp, ok := n.(nat.PCP)
+	return pcp, ok
 }
 
 func minTime(a, b time.Time) time.Time {
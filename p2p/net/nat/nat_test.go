@@ -6,7 +6,9 @@ import (
 	"net"
 	"net/netip"
 	"testing"
+	"time"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/p2p/net/nat/internal/nat"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
@@ -19,6 +21,7 @@ func setupMockNAT(t *testing.T) (mockNAT *MockNAT, reset func()) {
 	ctrl := gomock.NewController(t)
 	mockNAT = NewMockNAT(ctrl)
 	mockNAT.EXPECT().GetDeviceAddress().Return(nil, errors.New("nope")) // is only used for logging
+	mockNAT.EXPECT().Type().Return("mock").AnyTimes()
 	origDiscoverGateway := discoverGateway
 	discoverGateway = func(_ context.Context) (nat.NAT, error) { return mockNAT, nil }
 	return mockNAT, func() {
@@ -82,3 +85,211 @@ func TestAddMappingInvalidPort(t *testing.T) {
 	_, found := nat.GetMapping("tcp", 10000)
 	require.False(t, found, "didn't expect a port mapping for invalid nat-ed port")
 }
+
+func TestMappingEvents(t *testing.T) {
+	mockNAT, reset := setupMockNAT(t)
+	defer reset()
+
+	var evts []event.EvtPortMappingChanged
+	mockNAT.EXPECT().GetExternalAddress().Return(net.IPv4(1, 2, 3, 4), nil)
+	nat, err := DiscoverNAT(context.Background(), WithMappingEventHandler(func(evt event.EvtPortMappingChanged) {
+		evts = append(evts, evt)
+	}))
+	require.NoError(t, err)
+	defer func() {
+		mockNAT.EXPECT().DeletePortMapping(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+		nat.Close()
+	}()
+	extIP, _ := netip.AddrFromSlice(net.IPv4(1, 2, 3, 4))
+	mappingErr := errors.New("mapping failed")
+
+	// the first attempt fails, and so does the permanent fallback
+	mockNAT.EXPECT().AddPortMapping(gomock.Any(), "tcp", 10000, gomock.Any(), MappingDuration).Return(0, mappingErr)
+	mockNAT.EXPECT().AddPortMapping(gomock.Any(), "tcp", 10000, gomock.Any(), time.Duration(0)).Return(0, mappingErr)
+	require.NoError(t, nat.AddMapping(context.Background(), "tcp", 10000))
+	require.Len(t, evts, 1)
+	require.Equal(t, event.PortMappingFailed, evts[0].Change)
+	require.Equal(t, "mock", evts[0].GatewayType)
+	require.ErrorIs(t, evts[0].Error, mappingErr)
+	require.False(t, evts[0].ExternalAddr.IsValid())
+
+	mappings := nat.Mappings()
+	require.Len(t, mappings, 1)
+	require.Equal(t, "tcp", mappings[0].Protocol)
+	require.Equal(t, 10000, mappings[0].InternalPort)
+	require.False(t, mappings[0].ExternalAddr.IsValid())
+	require.ErrorIs(t, mappings[0].LastError, mappingErr)
+
+	// adding the mapping again retries it
+	mockNAT.EXPECT().AddPortMapping(gomock.Any(), "tcp", 10000, gomock.Any(), MappingDuration).Return(1234, nil)
+	require.NoError(t, nat.AddMapping(context.Background(), "tcp", 10000))
+	require.Len(t, evts, 2)
+	require.Equal(t, event.PortMappingCreated, evts[1].Change)
+	require.Equal(t, netip.AddrPortFrom(extIP, 1234), evts[1].ExternalAddr)
+	require.Equal(t, MappingDuration, evts[1].Lease)
+	require.NoError(t, evts[1].Error)
+
+	mockNAT.EXPECT().AddPortMapping(gomock.Any(), "tcp", 10000, gomock.Any(), MappingDuration).Return(1234, nil)
+	require.NoError(t, nat.AddMapping(context.Background(), "tcp", 10000))
+	require.Len(t, evts, 3)
+	require.Equal(t, event.PortMappingRenewed, evts[2].Change)
+
+	// the gateway renews the mapping with another external port
+	mockNAT.EXPECT().AddPortMapping(gomock.Any(), "tcp", 10000, gomock.Any(), MappingDuration).Return(1235, nil)
+	require.NoError(t, nat.AddMapping(context.Background(), "tcp", 10000))
+	require.Len(t, evts, 4)
+	require.Equal(t, event.PortMappingRemapped, evts[3].Change)
+	require.Equal(t, netip.AddrPortFrom(extIP, 1235), evts[3].ExternalAddr)
+
+	mockNAT.EXPECT().AddPortMapping(gomock.Any(), "udp", 10000, gomock.Any(), MappingDuration).Return(0, mappingErr)
+	mockNAT.EXPECT().AddPortMapping(gomock.Any(), "udp", 10000, gomock.Any(), time.Duration(0)).Return(4321, nil)
+	require.NoError(t, nat.AddMapping(context.Background(), "udp", 10000))
+	require.Len(t, evts, 5)
+	require.Equal(t, event.PortMappingCreated, evts[4].Change)
+	require.Zero(t, evts[4].Lease, "expected a permanent mapping")
+
+	mockNAT.EXPECT().AddPortMapping(gomock.Any(), "tcp", 10000, gomock.Any(), gomock.Any()).Return(0, mappingErr).Times(2)
+	require.NoError(t, nat.AddMapping(context.Background(), "tcp", 10000))
+	require.Len(t, evts, 6)
+	require.Equal(t, event.PortMappingLost, evts[5].Change)
+
+	mappings = nat.Mappings()
+	require.Len(t, mappings, 2)
+	require.Equal(t, "tcp", mappings[0].Protocol)
+	require.False(t, mappings[0].ExternalAddr.IsValid())
+	require.Equal(t, "udp", mappings[1].Protocol)
+	require.Equal(t, netip.AddrPortFrom(extIP, 4321), mappings[1].ExternalAddr)
+	require.NoError(t, mappings[1].LastError)
+	require.Equal(t, "mock", nat.Type())
+}
+
+type mockPCP struct {
+	*MockNAT
+	pinholes map[netip.AddrPort]int // number of AddPinhole calls, -1 once deleted
+	// number of AddThirdPartyMapping and AddPeerMapping calls, -1 once deleted
+	thirdParty map[netip.AddrPort]int
+	peers      map[netip.AddrPort]int
+}
+
+func newMockPCP(mockNAT *MockNAT) *mockPCP {
+	return &mockPCP{
+		MockNAT:    mockNAT,
+		pinholes:   make(map[netip.AddrPort]int),
+		thirdParty: make(map[netip.AddrPort]int),
+		peers:      make(map[netip.AddrPort]int),
+	}
+}
+
+func (m *mockPCP) AddPinhole(_ context.Context, _ string, addr netip.Addr, port int, _ time.Duration) error {
+	m.pinholes[netip.AddrPortFrom(addr, uint16(port))]++
+	return nil
+}
+
+func (m *mockPCP) DeletePinhole(_ context.Context, _ string, addr netip.Addr, port int) error {
+	m.pinholes[netip.AddrPortFrom(addr, uint16(port))] = -1
+	return nil
+}
+
+func (m *mockPCP) AddThirdPartyMapping(_ context.Context, _ string, addr netip.Addr, port int, _ time.Duration) (int, error) {
+	m.thirdParty[netip.AddrPortFrom(addr, uint16(port))]++
+	return port + 1, nil
+}
+
+func (m *mockPCP) DeleteThirdPartyMapping(_ context.Context, _ string, addr netip.Addr, port int) error {
+	m.thirdParty[netip.AddrPortFrom(addr, uint16(port))] = -1
+	return nil
+}
+
+func (m *mockPCP) AddPeerMapping(_ context.Context, _ string, port int, remote netip.AddrPort, _ time.Duration) (netip.AddrPort, error) {
+	m.peers[remote]++
+	return netip.AddrPortFrom(netip.MustParseAddr("1.2.3.4"), uint16(port)), nil
+}
+
+func (m *mockPCP) DeletePeerMapping(_ context.Context, _ string, _ int, remote netip.AddrPort) error {
+	m.peers[remote] = -1
+	return nil
+}
+
+func TestPinholes(t *testing.T) {
+	mockNAT, reset := setupMockNAT(t)
+	defer reset()
+	mockNAT.EXPECT().GetExternalAddress().Return(net.IPv4(1, 2, 3, 4), nil)
+	n, err := DiscoverNAT(context.Background())
+	require.NoError(t, err)
+	addr := netip.MustParseAddrPort("[2001:db8::1]:4001")
+	require.ErrorIs(t, n.AddPinhole(context.Background(), "tcp", addr), ErrPinholesUnsupported)
+	require.NoError(t, n.Close())
+
+	mockNAT, reset = setupMockNAT(t)
+	defer reset()
+	pcp := newMockPCP(mockNAT)
+	discoverGateway = func(_ context.Context) (nat.NAT, error) { return pcp, nil }
+	mockNAT.EXPECT().GetExternalAddress().Return(net.IPv4(1, 2, 3, 4), nil)
+	n, err = DiscoverNAT(context.Background())
+	require.NoError(t, err)
+
+	require.Error(t, n.AddPinhole(context.Background(), "sctp", addr))
+	require.NoError(t, n.AddPinhole(context.Background(), "tcp", addr))
+	require.Equal(t, 1, pcp.pinholes[addr])
+	require.Error(t, n.RemovePinhole(context.Background(), "udp", addr), "expected error for unknown pinhole")
+
+	other := netip.MustParseAddrPort("[2001:db8::2]:4001")
+	require.NoError(t, n.AddPinhole(context.Background(), "udp", other))
+	require.NoError(t, n.RemovePinhole(context.Background(), "udp", other))
+	require.Equal(t, -1, pcp.pinholes[other])
+
+	// pinholes are deleted when closing the NAT
+	require.NoError(t, n.Close())
+	require.Equal(t, -1, pcp.pinholes[addr])
+}
+
+func TestThirdPartyAndPeerMappings(t *testing.T) {
+	mockNAT, reset := setupMockNAT(t)
+	defer reset()
+	mockNAT.EXPECT().GetExternalAddress().Return(net.IPv4(1, 2, 3, 4), nil)
+	n, err := DiscoverNAT(context.Background())
+	require.NoError(t, err)
+	internal := netip.MustParseAddrPort("192.168.1.42:4001")
+	remote := netip.MustParseAddrPort("5.6.7.8:9000")
+	_, err = n.AddThirdPartyMapping(context.Background(), "tcp", internal)
+	require.ErrorIs(t, err, ErrPCPUnsupported)
+	_, err = n.AddPeerMapping(context.Background(), "udp", 4001, remote)
+	require.ErrorIs(t, err, ErrPCPUnsupported)
+	require.NoError(t, n.Close())
+
+	mockNAT, reset = setupMockNAT(t)
+	defer reset()
+	pcp := newMockPCP(mockNAT)
+	discoverGateway = func(_ context.Context) (nat.NAT, error) { return pcp, nil }
+	mockNAT.EXPECT().GetExternalAddress().Return(net.IPv4(1, 2, 3, 4), nil)
+	n, err = DiscoverNAT(context.Background())
+	require.NoError(t, err)
+	extIP, _ := netip.AddrFromSlice(net.IPv4(1, 2, 3, 4))
+
+	_, err = n.AddThirdPartyMapping(context.Background(), "sctp", internal)
+	require.Error(t, err)
+	ext, err := n.AddThirdPartyMapping(context.Background(), "tcp", internal)
+	require.NoError(t, err)
+	require.Equal(t, netip.AddrPortFrom(extIP, 4002), ext)
+	require.Equal(t, 1, pcp.thirdParty[internal])
+	require.Error(t, n.RemoveThirdPartyMapping(context.Background(), "udp", internal), "expected error for unknown mapping")
+
+	ext, err = n.AddPeerMapping(context.Background(), "udp", 4001, remote)
+	require.NoError(t, err)
+	require.Equal(t, netip.MustParseAddrPort("1.2.3.4:4001"), ext)
+	require.Equal(t, 1, pcp.peers[remote])
+
+	// the mappings are renewed with the port mappings
+	n.renewPCP(context.Background())
+	require.Equal(t, 2, pcp.thirdParty[internal])
+	require.Equal(t, 2, pcp.peers[remote])
+
+	require.NoError(t, n.RemovePeerMapping(context.Background(), "udp", 4001, remote))
+	require.Equal(t, -1, pcp.peers[remote])
+	n.renewPCP(context.Background())
+	require.Equal(t, -1, pcp.peers[remote], "removed mappings aren't renewed")
+
+	// the mappings are deleted when closing the NAT
+	require.NoError(t, n.Close())
+	require.Equal(t, -1, pcp.thirdParty[internal])
+}
@@ -49,6 +49,9 @@ func (ad *activeDial) dial(ctx context.Context) (*Conn, error) {
 	if simConnect, isClient, reason := network.GetSimultaneousConnect(ctx); simConnect {
 		dialCtx = network.WithSimultaneousConnect(dialCtx, isClient, reason)
 	}
+	if addrs, stagger := network.GetExtraDialAddrs(ctx); len(addrs) > 0 {
+		dialCtx = network.WithExtraDialAddrs(dialCtx, addrs, stagger)
+	}
 
 	resch := make(chan dialResponse, 1)
 	select {
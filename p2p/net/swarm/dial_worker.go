@@ -14,6 +14,13 @@ import (
 	manet "github.com/multiformats/go-multiaddr/net"
 )
 
+// dialOutcomeRecorder is implemented by address books that keep track of the
+// outcome of dials to addresses.
+type dialOutcomeRecorder interface {
+	RecordDialSuccess(p peer.ID, addr ma.Multiaddr)
+	RecordDialFailure(p peer.ID, addr ma.Multiaddr)
+}
+
 // dialRequest is structure used to request dials to the peer associated with a
 // worker loop
 type dialRequest struct {
@@ -178,7 +185,8 @@ loop:
 			}
 
 			addrs, addrErrs, err := w.s.addrsForDial(req.ctx, w.peer)
-			if err != nil {
+			extraAddrs, stagger := w.s.extraAddrsForDial(req.ctx, w.peer, addrs)
+			if err != nil && len(extraAddrs) == 0 {
 				req.resch <- dialResponse{
 					err: &DialError{
 						Peer:       w.peer,
@@ -191,6 +199,11 @@ loop:
 			// get the delays to dial these addrs from the swarms dialRanker
 			simConnect, _, _ := network.GetSimultaneousConnect(req.ctx)
 			addrRanking := w.rankAddrs(addrs, simConnect)
+			// dial the extra addresses one at a time, starting now
+			elapsed := w.cl.Now().Sub(startTime)
+			for i, a := range extraAddrs {
+				addrRanking = append(addrRanking, network.AddrDelay{Addr: a, Delay: elapsed + time.Duration(i)*stagger})
+			}
 			addrDelay := make(map[string]time.Duration, len(addrRanking))
 
 			// create the pending request object
@@ -356,6 +369,9 @@ loop:
 				}
 
 				ad.conn = conn
+				if r, ok := w.s.peers.(dialOutcomeRecorder); ok {
+					r.RecordDialSuccess(w.peer, res.Addr)
+				}
 				if !w.connected {
 					w.connected = true
 					if w.s.metricsTracer != nil {
@@ -372,6 +388,9 @@ loop:
 				// we only add backoff if there has not been a successful connection
 				// for consistency with the old dialer behavior.
 				w.s.backf.AddBackoff(w.peer, res.Addr)
+				if r, ok := w.s.peers.(dialOutcomeRecorder); ok {
+					r.RecordDialFailure(w.peer, res.Addr)
+				}
 			} else if res.Err == ErrDialRefusedBlackHole {
 				log.Errorf("SWARM BUG: unexpected ErrDialRefusedBlackHole while dialing peer %s to addr %s",
 					w.peer, res.Addr)
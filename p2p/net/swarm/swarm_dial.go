@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/netip"
+	"slices"
 	"strconv"
 	"sync"
 	"time"
@@ -320,6 +321,23 @@ func (s *Swarm) addrsForDial(ctx context.Context, p peer.ID) (goodAddrs []ma.Mul
 	return goodAddrs, addrErrs, nil
 }
 
+// extraAddrsForDial returns the addresses set with network.WithExtraDialAddrs
+// that aren't in addrs, filtered like the addresses of the peer, and the
+// interval between their dials. Unlike those, they aren't added to the
+// peerstore.
+func (s *Swarm) extraAddrsForDial(ctx context.Context, p peer.ID, addrs []ma.Multiaddr) ([]ma.Multiaddr, time.Duration) {
+	extra, stagger := network.GetExtraDialAddrs(ctx)
+	if len(extra) == 0 {
+		return nil, 0
+	}
+	extra = ma.Unique(slices.DeleteFunc(slices.Clone(extra), func(a ma.Multiaddr) bool { return ma.Contains(addrs, a) }))
+	extra, _ = s.filterKnownUndialables(p, extra)
+	if forceDirect, _ := network.GetForceDirectDial(ctx); forceDirect {
+		extra = ma.FilterAddrs(extra, s.nonProxyAddr)
+	}
+	return extra, stagger
+}
+
 func startsWithDNSComponent(m ma.Multiaddr) bool {
 	if m == nil {
 		return false
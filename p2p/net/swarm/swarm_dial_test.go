@@ -429,3 +429,33 @@ func TestSkipDialingManyDNS(t *testing.T) {
 	require.NoError(t, err)
 	require.Less(t, len(resolved), 3, "got: %v", resolved)
 }
+
+func TestDialPeerExtraAddrs(t *testing.T) {
+	s1 := makeSwarmWithNoListenAddrs(t)
+	defer s1.Close()
+	s2 := makeSwarmWithNoListenAddrs(t)
+	defer s2.Close()
+
+	// t1 will accept and keep the other end waiting
+	t1 := ma.StringCast("/ip4/127.0.0.1/tcp/10002")
+	recvCh := make(chan struct{}, 1)
+	list, ch := makeTCPListener(t, t1, recvCh)
+	defer list.Close()
+	defer func() { ch <- struct{}{} }()
+
+	// t2 will succeed
+	t2 := ma.StringCast("/ip4/127.0.0.1/tcp/10003")
+	require.NoError(t, s2.AddListenAddr(t2))
+
+	const stagger = 200 * time.Millisecond
+	ctx := network.WithExtraDialAddrs(context.Background(), []ma.Multiaddr{t1, t2}, stagger)
+	start := time.Now()
+	c, err := s1.DialPeer(ctx, s2.LocalPeer())
+	require.NoError(t, err)
+	require.True(t, c.RemoteMultiaddr().Equal(t2))
+	<-recvCh
+	// t2 is dialed after t1
+	require.GreaterOrEqual(t, time.Since(start), stagger)
+	// the extra addresses aren't added to the peerstore
+	require.Empty(t, s1.Peerstore().Addrs(s2.LocalPeer()))
+}
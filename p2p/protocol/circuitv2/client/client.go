@@ -5,6 +5,7 @@ import (
 	"io"
 	"sync"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/transport"
@@ -34,6 +35,8 @@ type Client struct {
 	mx          sync.Mutex
 	activeDials map[peer.ID]*completion
 	hopCount    map[peer.ID]int
+
+	emitGoingAway event.Emitter
 }
 
 var _ io.Closer = &Client{}
@@ -66,11 +69,22 @@ func New(h host.Host, upgrader transport.Upgrader) (*Client, error) {
 
 // Start registers the circuit (client) protocol stream handlers
 func (c *Client) Start() {
+	emitGoingAway, err := c.host.EventBus().Emitter(new(event.EvtRelayGoingAway))
+	if err != nil {
+		log.Errorf("failed to create relay going away emitter: %s", err)
+	} else {
+		c.emitGoingAway = emitGoingAway
+	}
 	c.host.SetStreamHandler(proto.ProtoIDv2Stop, c.handleStreamV2)
+	c.host.SetStreamHandler(proto.ProtoIDv2GoAway, c.handleGoAway)
 }
 
 func (c *Client) Close() error {
 	c.ctxCancel()
 	c.host.RemoveStreamHandler(proto.ProtoIDv2Stop)
+	c.host.RemoveStreamHandler(proto.ProtoIDv2GoAway)
+	if c.emitGoingAway != nil {
+		c.emitGoingAway.Close()
+	}
 	return nil
 }
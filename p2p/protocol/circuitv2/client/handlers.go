@@ -3,6 +3,7 @@ package client
 import (
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/network"
 	pbv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/pb"
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/util"
@@ -88,3 +89,26 @@ func (c *Client) handleStreamV2(s network.Stream) {
 		handleError(pbv2.Status_CONNECTION_FAILED)
 	}
 }
+
+func (c *Client) handleGoAway(s network.Stream) {
+	defer s.Close()
+
+	s.SetReadDeadline(time.Now().Add(StreamTimeout))
+	rd := util.NewDelimitedReader(s, maxMessageSize)
+	defer rd.Close()
+
+	var msg pbv2.GoAway
+	if err := rd.ReadMsg(&msg); err != nil {
+		log.Debugf("error reading go away notice from %s: %s", s.Conn().RemotePeer(), err)
+		s.Reset()
+		return
+	}
+	log.Debugf("relay %s is going away", s.Conn().RemotePeer())
+	if c.emitGoingAway == nil {
+		return
+	}
+	c.emitGoingAway.Emit(event.EvtRelayGoingAway{
+		Relay:    s.Conn().RemotePeer(),
+		Downtime: time.Duration(msg.GetDowntime()) * time.Second,
+	})
+}
@@ -35,6 +35,35 @@ type Reservation struct {
 
 	// Voucher is a signed reservation voucher provided by the relay
 	Voucher *proto.ReservationVoucher
+
+	// Load is the utilisation of the relay at the time of the reservation.
+	// It is nil if the relay doesn't report it.
+	Load *RelayLoad
+}
+
+// RelayLoad is the utilisation of a relay, as reported in the reservation
+// response. A zero maximum means that the relay is unlimited.
+type RelayLoad struct {
+	// Reservations is the number of active reservations
+	Reservations int
+	// MaxReservations is the maximum number of reservations
+	MaxReservations int
+	// Circuits is the number of active relayed connections
+	Circuits int
+	// MaxCircuits is the maximum number of relayed connections
+	MaxCircuits int
+}
+
+// Utilization returns the occupancy of the most utilised relay resource,
+// between 0 (idle or unlimited) and 1 (full).
+func (l *RelayLoad) Utilization() float64 {
+	ratio := func(n, limit int) float64 {
+		if limit <= 0 {
+			return 0
+		}
+		return min(float64(n)/float64(limit), 1)
+	}
+	return max(ratio(l.Reservations, l.MaxReservations), ratio(l.Circuits, l.MaxCircuits))
 }
 
 // ReservationError is the error returned on failure to reserve a slot in the relay
@@ -173,5 +202,14 @@ func Reserve(ctx context.Context, h host.Host, ai peer.AddrInfo) (*Reservation,
 		result.LimitData = limit.GetData()
 	}
 
+	if load := rsvp.GetLoad(); load != nil {
+		result.Load = &RelayLoad{
+			Reservations:    int(load.GetReservations()),
+			MaxReservations: int(load.GetMaxReservations()),
+			Circuits:        int(load.GetCircuits()),
+			MaxCircuits:     int(load.GetMaxCircuits()),
+		}
+	}
+
 	return result, nil
 }
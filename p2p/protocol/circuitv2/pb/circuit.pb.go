@@ -332,6 +332,52 @@ func (x *StopMessage) GetStatus() Status {
 	return Status_UNUSED
 }
 
+// GoAway is sent by a relay to the peers holding a reservation when it is
+// going away, e.g. to restart.
+type GoAway struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Downtime      *uint32                `protobuf:"varint,1,opt,name=downtime,proto3,oneof" json:"downtime,omitempty"` // expected downtime in seconds, 0 if unknown
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GoAway) Reset() {
+	*x = GoAway{}
+	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GoAway) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GoAway) ProtoMessage() {}
+
+func (x *GoAway) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GoAway.ProtoReflect.Descriptor instead.
+func (*GoAway) Descriptor() ([]byte, []int) {
+	return file_p2p_protocol_circuitv2_pb_circuit_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GoAway) GetDowntime() uint32 {
+	if x != nil && x.Downtime != nil {
+		return *x.Downtime
+	}
+	return 0
+}
+
 type Peer struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// This field is marked optional for backwards compatibility with proto2.
@@ -344,7 +390,7 @@ type Peer struct {
 
 func (x *Peer) Reset() {
 	*x = Peer{}
-	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[2]
+	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -356,7 +402,7 @@ func (x *Peer) String() string {
 func (*Peer) ProtoMessage() {}
 
 func (x *Peer) ProtoReflect() protoreflect.Message {
-	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[2]
+	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -369,7 +415,7 @@ func (x *Peer) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Peer.ProtoReflect.Descriptor instead.
 func (*Peer) Descriptor() ([]byte, []int) {
-	return file_p2p_protocol_circuitv2_pb_circuit_proto_rawDescGZIP(), []int{2}
+	return file_p2p_protocol_circuitv2_pb_circuit_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Peer) GetId() []byte {
@@ -393,13 +439,14 @@ type Reservation struct {
 	Expire        *uint64  `protobuf:"varint,1,opt,name=expire,proto3,oneof" json:"expire,omitempty"`  // Unix expiration time (UTC)
 	Addrs         [][]byte `protobuf:"bytes,2,rep,name=addrs,proto3" json:"addrs,omitempty"`           // relay addrs for reserving peer
 	Voucher       []byte   `protobuf:"bytes,3,opt,name=voucher,proto3,oneof" json:"voucher,omitempty"` // reservation voucher
+	Load          *Load    `protobuf:"bytes,4,opt,name=load,proto3,oneof" json:"load,omitempty"`       // relay utilisation, not sent by older relays
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Reservation) Reset() {
 	*x = Reservation{}
-	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[3]
+	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -411,7 +458,7 @@ func (x *Reservation) String() string {
 func (*Reservation) ProtoMessage() {}
 
 func (x *Reservation) ProtoReflect() protoreflect.Message {
-	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[3]
+	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -424,7 +471,7 @@ func (x *Reservation) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Reservation.ProtoReflect.Descriptor instead.
 func (*Reservation) Descriptor() ([]byte, []int) {
-	return file_p2p_protocol_circuitv2_pb_circuit_proto_rawDescGZIP(), []int{3}
+	return file_p2p_protocol_circuitv2_pb_circuit_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *Reservation) GetExpire() uint64 {
@@ -448,6 +495,83 @@ func (x *Reservation) GetVoucher() []byte {
 	return nil
 }
 
+func (x *Reservation) GetLoad() *Load {
+	if x != nil {
+		return x.Load
+	}
+	return nil
+}
+
+// Load is the utilisation of a relay at the time of the reservation, so that
+// clients can prefer less loaded relays. A zero maximum means unlimited.
+type Load struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Reservations    *uint32                `protobuf:"varint,1,opt,name=reservations,proto3,oneof" json:"reservations,omitempty"`                              // active reservations
+	MaxReservations *uint32                `protobuf:"varint,2,opt,name=max_reservations,json=maxReservations,proto3,oneof" json:"max_reservations,omitempty"` // maximum number of reservations
+	Circuits        *uint32                `protobuf:"varint,3,opt,name=circuits,proto3,oneof" json:"circuits,omitempty"`                                      // active relayed connections
+	MaxCircuits     *uint32                `protobuf:"varint,4,opt,name=max_circuits,json=maxCircuits,proto3,oneof" json:"max_circuits,omitempty"`             // maximum number of relayed connections
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Load) Reset() {
+	*x = Load{}
+	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Load) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Load) ProtoMessage() {}
+
+func (x *Load) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Load.ProtoReflect.Descriptor instead.
+func (*Load) Descriptor() ([]byte, []int) {
+	return file_p2p_protocol_circuitv2_pb_circuit_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Load) GetReservations() uint32 {
+	if x != nil && x.Reservations != nil {
+		return *x.Reservations
+	}
+	return 0
+}
+
+func (x *Load) GetMaxReservations() uint32 {
+	if x != nil && x.MaxReservations != nil {
+		return *x.MaxReservations
+	}
+	return 0
+}
+
+func (x *Load) GetCircuits() uint32 {
+	if x != nil && x.Circuits != nil {
+		return *x.Circuits
+	}
+	return 0
+}
+
+func (x *Load) GetMaxCircuits() uint32 {
+	if x != nil && x.MaxCircuits != nil {
+		return *x.MaxCircuits
+	}
+	return 0
+}
+
 type Limit struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Duration      *uint32                `protobuf:"varint,1,opt,name=duration,proto3,oneof" json:"duration,omitempty"` // seconds
@@ -458,7 +582,7 @@ type Limit struct {
 
 func (x *Limit) Reset() {
 	*x = Limit{}
-	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[4]
+	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -470,7 +594,7 @@ func (x *Limit) String() string {
 func (*Limit) ProtoMessage() {}
 
 func (x *Limit) ProtoReflect() protoreflect.Message {
-	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[4]
+	mi := &file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -483,7 +607,7 @@ func (x *Limit) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Limit.ProtoReflect.Descriptor instead.
 func (*Limit) Descriptor() ([]byte, []int) {
-	return file_p2p_protocol_circuitv2_pb_circuit_proto_rawDescGZIP(), []int{4}
+	return file_p2p_protocol_circuitv2_pb_circuit_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *Limit) GetDuration() uint32 {
@@ -535,18 +659,32 @@ const file_p2p_protocol_circuitv2_pb_circuit_proto_rawDesc = "" +
 	"\x05_typeB\a\n" +
 	"\x05_peerB\b\n" +
 	"\x06_limitB\t\n" +
-	"\a_status\"8\n" +
+	"\a_status\"6\n" +
+	"\x06GoAway\x12\x1f\n" +
+	"\bdowntime\x18\x01 \x01(\rH\x00R\bdowntime\x88\x01\x01B\v\n" +
+	"\t_downtime\"8\n" +
 	"\x04Peer\x12\x13\n" +
 	"\x02id\x18\x01 \x01(\fH\x00R\x02id\x88\x01\x01\x12\x14\n" +
 	"\x05addrs\x18\x02 \x03(\fR\x05addrsB\x05\n" +
-	"\x03_id\"v\n" +
+	"\x03_id\"\xaa\x01\n" +
 	"\vReservation\x12\x1b\n" +
 	"\x06expire\x18\x01 \x01(\x04H\x00R\x06expire\x88\x01\x01\x12\x14\n" +
 	"\x05addrs\x18\x02 \x03(\fR\x05addrs\x12\x1d\n" +
-	"\avoucher\x18\x03 \x01(\fH\x01R\avoucher\x88\x01\x01B\t\n" +
+	"\avoucher\x18\x03 \x01(\fH\x01R\avoucher\x88\x01\x01\x12)\n" +
+	"\x04load\x18\x04 \x01(\v2\x10.circuit.pb.LoadH\x02R\x04load\x88\x01\x01B\t\n" +
 	"\a_expireB\n" +
 	"\n" +
-	"\b_voucher\"W\n" +
+	"\b_voucherB\a\n" +
+	"\x05_load\"\xec\x01\n" +
+	"\x04Load\x12'\n" +
+	"\freservations\x18\x01 \x01(\rH\x00R\freservations\x88\x01\x01\x12.\n" +
+	"\x10max_reservations\x18\x02 \x01(\rH\x01R\x0fmaxReservations\x88\x01\x01\x12\x1f\n" +
+	"\bcircuits\x18\x03 \x01(\rH\x02R\bcircuits\x88\x01\x01\x12&\n" +
+	"\fmax_circuits\x18\x04 \x01(\rH\x03R\vmaxCircuits\x88\x01\x01B\x0f\n" +
+	"\r_reservationsB\x13\n" +
+	"\x11_max_reservationsB\v\n" +
+	"\t_circuitsB\x0f\n" +
+	"\r_max_circuits\"W\n" +
 	"\x05Limit\x12\x1f\n" +
 	"\bduration\x18\x01 \x01(\rH\x00R\bduration\x88\x01\x01\x12\x17\n" +
 	"\x04data\x18\x02 \x01(\x04H\x01R\x04data\x88\x01\x01B\v\n" +
@@ -577,32 +715,35 @@ func file_p2p_protocol_circuitv2_pb_circuit_proto_rawDescGZIP() []byte {
 }
 
 var file_p2p_protocol_circuitv2_pb_circuit_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
-var file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_p2p_protocol_circuitv2_pb_circuit_proto_goTypes = []any{
 	(Status)(0),           // 0: circuit.pb.Status
 	(HopMessage_Type)(0),  // 1: circuit.pb.HopMessage.Type
 	(StopMessage_Type)(0), // 2: circuit.pb.StopMessage.Type
 	(*HopMessage)(nil),    // 3: circuit.pb.HopMessage
 	(*StopMessage)(nil),   // 4: circuit.pb.StopMessage
-	(*Peer)(nil),          // 5: circuit.pb.Peer
-	(*Reservation)(nil),   // 6: circuit.pb.Reservation
-	(*Limit)(nil),         // 7: circuit.pb.Limit
+	(*GoAway)(nil),        // 5: circuit.pb.GoAway
+	(*Peer)(nil),          // 6: circuit.pb.Peer
+	(*Reservation)(nil),   // 7: circuit.pb.Reservation
+	(*Load)(nil),          // 8: circuit.pb.Load
+	(*Limit)(nil),         // 9: circuit.pb.Limit
 }
 var file_p2p_protocol_circuitv2_pb_circuit_proto_depIdxs = []int32{
-	1, // 0: circuit.pb.HopMessage.type:type_name -> circuit.pb.HopMessage.Type
-	5, // 1: circuit.pb.HopMessage.peer:type_name -> circuit.pb.Peer
-	6, // 2: circuit.pb.HopMessage.reservation:type_name -> circuit.pb.Reservation
-	7, // 3: circuit.pb.HopMessage.limit:type_name -> circuit.pb.Limit
-	0, // 4: circuit.pb.HopMessage.status:type_name -> circuit.pb.Status
-	2, // 5: circuit.pb.StopMessage.type:type_name -> circuit.pb.StopMessage.Type
-	5, // 6: circuit.pb.StopMessage.peer:type_name -> circuit.pb.Peer
-	7, // 7: circuit.pb.StopMessage.limit:type_name -> circuit.pb.Limit
-	0, // 8: circuit.pb.StopMessage.status:type_name -> circuit.pb.Status
-	9, // [9:9] is the sub-list for method output_type
-	9, // [9:9] is the sub-list for method input_type
-	9, // [9:9] is the sub-list for extension type_name
-	9, // [9:9] is the sub-list for extension extendee
-	0, // [0:9] is the sub-list for field type_name
+	1,  // 0: circuit.pb.HopMessage.type:type_name -> circuit.pb.HopMessage.Type
+	6,  // 1: circuit.pb.HopMessage.peer:type_name -> circuit.pb.Peer
+	7,  // 2: circuit.pb.HopMessage.reservation:type_name -> circuit.pb.Reservation
+	9,  // 3: circuit.pb.HopMessage.limit:type_name -> circuit.pb.Limit
+	0,  // 4: circuit.pb.HopMessage.status:type_name -> circuit.pb.Status
+	2,  // 5: circuit.pb.StopMessage.type:type_name -> circuit.pb.StopMessage.Type
+	6,  // 6: circuit.pb.StopMessage.peer:type_name -> circuit.pb.Peer
+	9,  // 7: circuit.pb.StopMessage.limit:type_name -> circuit.pb.Limit
+	0,  // 8: circuit.pb.StopMessage.status:type_name -> circuit.pb.Status
+	8,  // 9: circuit.pb.Reservation.load:type_name -> circuit.pb.Load
+	10, // [10:10] is the sub-list for method output_type
+	10, // [10:10] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_p2p_protocol_circuitv2_pb_circuit_proto_init() }
@@ -615,13 +756,15 @@ func file_p2p_protocol_circuitv2_pb_circuit_proto_init() {
 	file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[2].OneofWrappers = []any{}
 	file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[3].OneofWrappers = []any{}
 	file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[4].OneofWrappers = []any{}
+	file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[5].OneofWrappers = []any{}
+	file_p2p_protocol_circuitv2_pb_circuit_proto_msgTypes[6].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_p2p_protocol_circuitv2_pb_circuit_proto_rawDesc), len(file_p2p_protocol_circuitv2_pb_circuit_proto_rawDesc)),
 			NumEnums:      3,
-			NumMessages:   5,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -3,4 +3,7 @@ package proto
 const (
 	ProtoIDv2Hop  = "/libp2p/circuit/relay/0.2.0/hop"
 	ProtoIDv2Stop = "/libp2p/circuit/relay/0.2.0/stop"
+	// ProtoIDv2GoAway is the protocol used by relays to notify the peers
+	// holding a reservation that they are going away.
+	ProtoIDv2GoAway = "/libp2p/circuit/relay/0.2.0/goaway"
 )
@@ -84,6 +84,14 @@ var (
 		},
 	)
 
+	dataThrottledSecondsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "data_throttled_seconds_total",
+			Help:      "Time Relayed Connections Waited for Bandwidth",
+		},
+	)
+
 	collectors = []prometheus.Collector{
 		status,
 		reservationsTotal,
@@ -94,6 +102,7 @@ var (
 		connectionRejectionsTotal,
 		connectionDurationSeconds,
 		dataTransferredBytesTotal,
+		dataThrottledSecondsTotal,
 	}
 )
 
@@ -126,9 +135,19 @@ type MetricsTracer interface {
 	BytesTransferred(cnt int)
 }
 
+// BandwidthMetricsTracer is an optional interface of a MetricsTracer that
+// tracks the bandwidth limits of the relay service.
+type BandwidthMetricsTracer interface {
+	// BandwidthThrottled tracks the time relayed connections waited for bandwidth
+	BandwidthThrottled(d time.Duration)
+}
+
 type metricsTracer struct{}
 
-var _ MetricsTracer = &metricsTracer{}
+var (
+	_ MetricsTracer          = &metricsTracer{}
+	_ BandwidthMetricsTracer = &metricsTracer{}
+)
 
 type metricsTracerSetting struct {
 	reg prometheus.Registerer
@@ -232,6 +251,10 @@ func (mt *metricsTracer) BytesTransferred(cnt int) {
 	dataTransferredBytesTotal.Add(float64(cnt))
 }
 
+func (mt *metricsTracer) BandwidthThrottled(d time.Duration) {
+	dataThrottledSecondsTotal.Add(d.Seconds())
+}
+
 func getResponseStatus(status pbv2.Status) string {
 	responseStatus := "unknown"
 	switch status {
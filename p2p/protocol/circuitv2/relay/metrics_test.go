@@ -18,6 +18,7 @@ func TestNoCoverNoAlloc(t *testing.T) {
 		pbv2.Status_PERMISSION_DENIED,
 	}
 	mt := NewMetricsTracer()
+	bmt := mt.(BandwidthMetricsTracer)
 	tests := map[string]func(){
 		"RelayStatus":               func() { mt.RelayStatus(rand.Intn(2) == 1) },
 		"ConnectionOpened":          func() { mt.ConnectionOpened() },
@@ -27,6 +28,7 @@ func TestNoCoverNoAlloc(t *testing.T) {
 		"ReservationClosed":         func() { mt.ReservationClosed(rand.Intn(10)) },
 		"ReservationRequestHandled": func() { mt.ReservationRequestHandled(statuses[rand.Intn(len(statuses))]) },
 		"BytesTransferred":          func() { mt.BytesTransferred(rand.Intn(1000)) },
+		"BandwidthThrottled":        func() { bmt.BandwidthThrottled(time.Duration(rand.Intn(1000)) * time.Millisecond) },
 	}
 	for method, f := range tests {
 		allocs := testing.AllocsPerRun(1000, f)
@@ -34,6 +34,15 @@ func WithACL(acl ACLFilter) Option {
 	}
 }
 
+// WithPolicy is a Relay option that supplies a RelayPolicy deciding the limits
+// of reservations and relayed connections per peer.
+func WithPolicy(p RelayPolicy) Option {
+	return func(r *Relay) error {
+		r.policy = p
+		return nil
+	}
+}
+
 // WithMetricsTracer is a Relay option that supplies a MetricsTracer for metrics
 func WithMetricsTracer(mt MetricsTracer) Option {
 	return func(r *Relay) error {
@@ -0,0 +1,222 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	pbv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/pb"
+	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/proto"
+	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/util"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var reservationsKey = ds.NewKey("/relay/reservations")
+
+// maxGoAwayConcurrency is the maximum number of go away notices sent concurrently.
+const maxGoAwayConcurrency = 16
+
+// WithDatastore is a Relay option that persists the reservations in the
+// datastore, so that they survive restarts of the relay. The reservations are
+// reloaded when the relay is constructed.
+func WithDatastore(d ds.Datastore) Option {
+	return func(r *Relay) error {
+		r.ds = d
+		return nil
+	}
+}
+
+// persistedReservation is the datastore record of a reservation. The voucher
+// isn't stored: peers keep the voucher they got when they reserved.
+type persistedReservation struct {
+	Expire      int64 // Unix time
+	Addr        []byte
+	MaxCircuits int
+}
+
+func reservationKey(p peer.ID) ds.Key {
+	return reservationsKey.ChildString(p.String())
+}
+
+// queuePersist queues storing the reservation of p, or deleting it if rsvp is
+// nil. It must be called with r.mx held, so that the persisted reservations
+// follow r.rsvp.
+//
+// The writes are done by persistLoop, outside of the lock, so that hop
+// requests don't wait on the datastore. Only the last queued write of each
+// peer is done.
+func (r *Relay) queuePersist(p peer.ID, a ma.Multiaddr, rsvp *reservation) {
+	if r.ds == nil || r.closed {
+		return
+	}
+	var pr *persistedReservation
+	if rsvp != nil {
+		pr = &persistedReservation{
+			Expire:      rsvp.expire.Unix(),
+			Addr:        a.Bytes(),
+			MaxCircuits: rsvp.maxCircuits,
+		}
+	}
+	r.pendingWrites[p] = pr
+	select {
+	case r.persistSignal <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Relay) persistLoop() {
+	defer close(r.persistDone)
+	for {
+		select {
+		case <-r.persistSignal:
+			r.flushPersist(r.ctx)
+		case <-r.ctx.Done():
+			// persisted reservations survive closing the relay, so finish the
+			// queued writes
+			r.flushPersist(context.Background())
+			return
+		}
+	}
+}
+
+func (r *Relay) flushPersist(ctx context.Context) {
+	r.mx.Lock()
+	writes := r.pendingWrites
+	r.pendingWrites = make(map[peer.ID]*persistedReservation)
+	r.mx.Unlock()
+
+	for p, pr := range writes {
+		if pr == nil {
+			r.deletePersistedReservation(ctx, p)
+			continue
+		}
+		b, err := json.Marshal(pr)
+		if err != nil {
+			log.Errorf("error marshalling reservation for %s: %s", p, err)
+			continue
+		}
+		if err := r.ds.Put(ctx, reservationKey(p), b); err != nil {
+			log.Errorf("error persisting reservation for %s: %s", p, err)
+		}
+	}
+}
+
+func (r *Relay) deletePersistedReservation(ctx context.Context, p peer.ID) {
+	if err := r.ds.Delete(ctx, reservationKey(p)); err != nil {
+		log.Errorf("error deleting persisted reservation for %s: %s", p, err)
+	}
+}
+
+// loadReservations restores the unexpired persisted reservations.
+func (r *Relay) loadReservations() error {
+	if r.ds == nil {
+		return nil
+	}
+	res, err := r.ds.Query(r.ctx, query.Query{Prefix: reservationsKey.String()})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	now := time.Now()
+	for e := range res.Next() {
+		if e.Error != nil {
+			return e.Error
+		}
+		k := ds.RawKey(e.Key)
+		p, err := peer.Decode(k.BaseNamespace())
+		if err != nil {
+			log.Debugf("ignoring persisted reservation with invalid key %s", k)
+			continue
+		}
+		var pr persistedReservation
+		if err := json.Unmarshal(e.Value, &pr); err != nil {
+			log.Debugf("ignoring invalid persisted reservation for %s: %s", p, err)
+			continue
+		}
+		expire := time.Unix(pr.Expire, 0)
+		if expire.Before(now) {
+			r.deletePersistedReservation(r.ctx, p)
+			continue
+		}
+		a, err := ma.NewMultiaddrBytes(pr.Addr)
+		if err != nil {
+			log.Debugf("ignoring persisted reservation for %s with invalid address: %s", p, err)
+			continue
+		}
+		if err := r.constraints.Reserve(p, a, expire); err != nil {
+			log.Debugf("dropping persisted reservation for %s: %s", p, err)
+			r.deletePersistedReservation(r.ctx, p)
+			continue
+		}
+		r.rsvp[p] = reservation{expire: expire, maxCircuits: pr.MaxCircuits}
+		r.host.ConnManager().TagPeer(p, "relay-reservation", ReservationTagWeight)
+	}
+	log.Debugf("restored %d reservations", len(r.rsvp))
+	return nil
+}
+
+// GoAway notifies the peers holding a reservation that the relay is going
+// away, e.g. to restart, and refuses new reservations. downtime is the
+// expected downtime, 0 if unknown. It returns when all peers were notified,
+// or when ctx is done.
+//
+// The reservations are kept, so that peers can reconnect to a restarted relay
+// with a datastore without making a new reservation.
+func (r *Relay) GoAway(ctx context.Context, downtime time.Duration) error {
+	r.mx.Lock()
+	r.goingAway = true
+	peers := make([]peer.ID, 0, len(r.rsvp))
+	for p := range r.rsvp {
+		peers = append(peers, p)
+	}
+	r.mx.Unlock()
+
+	secs := uint32(downtime / time.Second)
+	msg := &pbv2.GoAway{Downtime: &secs}
+	sem := make(chan struct{}, maxGoAwayConcurrency)
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := r.sendGoAway(ctx, p, msg); err != nil {
+				log.Debugf("error sending go away notice to %s: %s", p, err)
+			}
+		}(p)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (r *Relay) sendGoAway(ctx context.Context, p peer.ID, msg *pbv2.GoAway) error {
+	ctx = network.WithNoDial(ctx, "relay go away")
+	s, err := r.host.NewStream(ctx, p, proto.ProtoIDv2GoAway)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := s.Scope().SetService(ServiceName); err != nil {
+		s.Reset()
+		return err
+	}
+	s.SetWriteDeadline(time.Now().Add(StreamTimeout))
+	if err := util.NewDelimitedWriter(s).WriteMsg(msg); err != nil {
+		s.Reset()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package relay
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// RelayPolicy decides the limits of reservations and relayed connections per
+// peer, e.g. to offer tiered service. It is consulted after the ACLFilter.
+//
+// The default policy applies the limits of the relay Resources to all peers.
+type RelayPolicy interface {
+	// ReservationLimit returns the limits of a reservation by peer p, connected
+	// from multiaddr a. Returning false refuses the reservation.
+	ReservationLimit(p peer.ID, a ma.Multiaddr) (ReservationLimit, bool)
+	// CircuitLimit returns the limits of a relayed connection from src,
+	// connected from srcAddr, to dest, which holds a reservation. A nil limit
+	// means that the connection is unlimited. Returning false refuses the
+	// connection.
+	CircuitLimit(src peer.ID, srcAddr ma.Multiaddr, dest peer.ID) (*RelayLimit, bool)
+	// CircuitClosed is called when a relayed connection closes, for
+	// accounting. It must not block.
+	CircuitClosed(stats CircuitStats)
+}
+
+// ReservationLimit are the per reservation resource limits.
+type ReservationLimit struct {
+	// TTL is the duration of the reservation; defaults to
+	// Resources.ReservationTTL.
+	TTL time.Duration
+	// MaxCircuits is the maximum number of open relayed connections to the
+	// reserving peer; defaults to Resources.MaxCircuits.
+	MaxCircuits int
+	// Limit is the limit of relayed connections to the reserving peer
+	// advertised in the reservation response. The actual limit of each
+	// connection is decided by RelayPolicy.CircuitLimit.
+	Limit *RelayLimit
+}
+
+// CircuitStats describes a closed relayed connection.
+type CircuitStats struct {
+	// Src is the peer that initiated the connection.
+	Src peer.ID
+	// Dest is the peer that holds the reservation.
+	Dest peer.ID
+	// Limit is the limit that applied to the connection, nil if unlimited.
+	Limit *RelayLimit
+	// Opened is the time the connection was opened.
+	Opened time.Time
+	// Duration is how long the connection stayed open.
+	Duration time.Duration
+	// BytesFromSrc is the number of bytes relayed from Src to Dest.
+	BytesFromSrc int64
+	// BytesFromDest is the number of bytes relayed from Dest to Src.
+	BytesFromDest int64
+}
+
+// resourcesPolicy is the default RelayPolicy, applying the relay Resources to
+// all peers.
+type resourcesPolicy struct {
+	rc *Resources
+}
+
+var _ RelayPolicy = resourcesPolicy{}
+
+func (p resourcesPolicy) ReservationLimit(_ peer.ID, _ ma.Multiaddr) (ReservationLimit, bool) {
+	return ReservationLimit{
+		TTL:         p.rc.ReservationTTL,
+		MaxCircuits: p.rc.MaxCircuits,
+		Limit:       p.rc.Limit,
+	}, true
+}
+
+func (p resourcesPolicy) CircuitLimit(_ peer.ID, _ ma.Multiaddr, _ peer.ID) (*RelayLimit, bool) {
+	return p.rc.Limit, true
+}
+
+func (p resourcesPolicy) CircuitClosed(_ CircuitStats) {}
@@ -18,10 +18,12 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/proto"
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/util"
 
+	ds "github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log/v2"
 	pool "github.com/libp2p/go-buffer-pool"
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -49,20 +51,39 @@ type Relay struct {
 	host        host.Host
 	rc          Resources
 	acl         ACLFilter
+	policy      RelayPolicy
 	constraints *constraints
 	scope       network.ResourceScopeSpan
 	notifiee    network.Notifiee
 
-	mx     sync.Mutex
-	rsvp   map[peer.ID]time.Time
-	conns  map[peer.ID]int
-	closed bool
+	mx    sync.Mutex
+	rsvp  map[peer.ID]reservation
+	conns map[peer.ID]int
+	// circuits is the number of open relayed connections
+	circuits int
+	closed   bool
+	// goingAway is set by GoAway; new reservations are refused
+	goingAway bool
+
+	// ds persists the reservations, nil if they are not persisted
+	ds ds.Datastore
+	// pendingWrites are the reservations to persist, nil to delete them
+	pendingWrites map[peer.ID]*persistedReservation
+	persistSignal chan struct{}
+	persistDone   chan struct{}
 
 	selfAddr ma.Multiaddr
+	// bw limits the bandwidth of the relay, nil if unlimited
+	bw *rate.Limiter
 
 	metricsTracer MetricsTracer
 }
 
+type reservation struct {
+	expire      time.Time
+	maxCircuits int
+}
+
 // New constructs a new limited relay that can provide relay services in the given host.
 func New(h host.Host, opts ...Option) (*Relay, error) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -73,7 +94,7 @@ func New(h host.Host, opts ...Option) (*Relay, error) {
 		host:   h,
 		rc:     DefaultResources(),
 		acl:    nil,
-		rsvp:   make(map[peer.ID]time.Time),
+		rsvp:   make(map[peer.ID]reservation),
 		conns:  make(map[peer.ID]int),
 	}
 
@@ -84,6 +105,13 @@ func New(h host.Host, opts ...Option) (*Relay, error) {
 		}
 	}
 
+	if r.policy == nil {
+		r.policy = resourcesPolicy{rc: &r.rc}
+	}
+	if r.rc.MaxBandwidth > 0 {
+		r.bw = rate.NewLimiter(rate.Limit(r.rc.MaxBandwidth), r.rc.BufferSize)
+	}
+
 	// get a scope for memory reservations at service level
 	err := h.Network().ResourceManager().ViewService(ServiceName,
 		func(s network.ServiceScope) error {
@@ -96,7 +124,17 @@ func New(h host.Host, opts ...Option) (*Relay, error) {
 	}
 
 	r.constraints = newConstraints(&r.rc)
+	if err := r.loadReservations(); err != nil {
+		r.scope.Done()
+		return nil, fmt.Errorf("error loading persisted reservations: %w", err)
+	}
 	r.selfAddr = ma.StringCast(fmt.Sprintf("/p2p/%s", h.ID()))
+	if r.ds != nil {
+		r.pendingWrites = make(map[peer.ID]*persistedReservation)
+		r.persistSignal = make(chan struct{}, 1)
+		r.persistDone = make(chan struct{})
+		go r.persistLoop()
+	}
 
 	h.SetStreamHandler(proto.ProtoIDv2Hop, r.handleStream)
 	r.notifiee = &network.NotifyBundle{DisconnectedF: r.disconnected}
@@ -121,6 +159,9 @@ func (r *Relay) Close() error {
 		defer r.scope.Done()
 		r.cancel()
 		r.gc()
+		if r.persistDone != nil {
+			<-r.persistDone
+		}
 		if r.metricsTracer != nil {
 			r.metricsTracer.RelayStatus(false)
 		}
@@ -193,6 +234,19 @@ func (r *Relay) handleReserve(s network.Stream) pbv2.Status {
 		return pbv2.Status_PERMISSION_DENIED
 	}
 
+	limit, ok := r.policy.ReservationLimit(p, a)
+	if !ok {
+		log.Debugf("refusing relay reservation for %s; refused by policy", p)
+		r.handleError(s, pbv2.Status_RESERVATION_REFUSED)
+		return pbv2.Status_RESERVATION_REFUSED
+	}
+	if limit.TTL <= 0 {
+		limit.TTL = r.rc.ReservationTTL
+	}
+	if limit.MaxCircuits <= 0 {
+		limit.MaxCircuits = r.rc.MaxCircuits
+	}
+
 	r.mx.Lock()
 	// Check if relay is still active. Otherwise ConnManager.UnTagPeer will not be called if this block runs after
 	// Close() call
@@ -202,8 +256,14 @@ func (r *Relay) handleReserve(s network.Stream) pbv2.Status {
 		r.handleError(s, pbv2.Status_PERMISSION_DENIED)
 		return pbv2.Status_PERMISSION_DENIED
 	}
+	if r.goingAway {
+		r.mx.Unlock()
+		log.Debugf("refusing relay reservation for %s; relay going away", p)
+		r.handleError(s, pbv2.Status_RESERVATION_REFUSED)
+		return pbv2.Status_RESERVATION_REFUSED
+	}
 	now := time.Now()
-	expire := now.Add(r.rc.ReservationTTL)
+	expire := now.Add(limit.TTL)
 
 	_, exists := r.rsvp[p]
 	if err := r.constraints.Reserve(p, a, expire); err != nil {
@@ -213,8 +273,11 @@ func (r *Relay) handleReserve(s network.Stream) pbv2.Status {
 		return pbv2.Status_RESERVATION_REFUSED
 	}
 
-	r.rsvp[p] = expire
+	res := reservation{expire: expire, maxCircuits: limit.MaxCircuits}
+	r.rsvp[p] = res
+	r.queuePersist(p, a, &res)
 	r.host.ConnManager().TagPeer(p, "relay-reservation", ReservationTagWeight)
+	load := r.makeLoadMsg()
 	r.mx.Unlock()
 	if r.metricsTracer != nil {
 		r.metricsTracer.ReservationAllowed(exists)
@@ -231,7 +294,8 @@ func (r *Relay) handleReserve(s network.Stream) pbv2.Status {
 		r.host.Addrs(),
 		p,
 		expire)
-	if err := r.writeResponse(s, pbv2.Status_OK, rsvp, r.makeLimitMsg(p)); err != nil {
+	rsvp.Load = load
+	if err := r.writeResponse(s, pbv2.Status_OK, rsvp, makeLimitMsg(limit.Limit)); err != nil {
 		log.Debugf("error writing reservation response; retracting reservation for %s", p)
 		s.Reset()
 		return pbv2.Status_CONNECTION_FAILED
@@ -280,9 +344,16 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 		return pbv2.Status_PERMISSION_DENIED
 	}
 
+	limit, ok := r.policy.CircuitLimit(src, a, dest.ID)
+	if !ok {
+		log.Debugf("refusing connection from %s to %s; refused by policy", src, dest.ID)
+		fail(pbv2.Status_PERMISSION_DENIED)
+		return pbv2.Status_PERMISSION_DENIED
+	}
+
 	r.mx.Lock()
-	_, rsvp := r.rsvp[dest.ID]
-	if !rsvp {
+	rsvp, ok := r.rsvp[dest.ID]
+	if !ok {
 		r.mx.Unlock()
 		log.Debugf("refusing connection from %s to %s; no reservation", src, dest.ID)
 		fail(pbv2.Status_NO_RESERVATION)
@@ -298,15 +369,23 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 	}
 
 	destConns := r.conns[dest.ID]
-	if destConns >= r.rc.MaxCircuits {
+	if destConns >= rsvp.maxCircuits {
 		r.mx.Unlock()
 		log.Debugf("refusing connection from %s to %s; too many connections to %s", src, dest.ID, dest.ID)
 		fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
 		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
 	}
 
+	if r.rc.MaxTotalCircuits > 0 && r.circuits >= r.rc.MaxTotalCircuits {
+		r.mx.Unlock()
+		log.Debugf("refusing connection from %s to %s; too many relayed connections", src, dest.ID)
+		fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
+		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
+	}
+
 	r.addConn(src)
 	r.addConn(dest.ID)
+	r.circuits++
 	r.mx.Unlock()
 
 	if r.metricsTracer != nil {
@@ -319,6 +398,7 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 		r.mx.Lock()
 		r.rmConn(src)
 		r.rmConn(dest.ID)
+		r.circuits--
 		r.mx.Unlock()
 		if r.metricsTracer != nil {
 			r.metricsTracer.ConnectionClosed(time.Since(connStTime))
@@ -365,7 +445,7 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 	var stopmsg pbv2.StopMessage
 	stopmsg.Type = pbv2.StopMessage_CONNECT.Enum()
 	stopmsg.Peer = util.PeerInfoToPeerV2(peer.AddrInfo{ID: src})
-	stopmsg.Limit = r.makeLimitMsg(dest.ID)
+	stopmsg.Limit = makeLimitMsg(limit)
 
 	bs.SetDeadline(time.Now().Add(HandshakeTimeout))
 
@@ -400,7 +480,7 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 	var response pbv2.HopMessage
 	response.Type = pbv2.HopMessage_STATUS.Enum()
 	response.Status = pbv2.Status_OK.Enum()
-	response.Limit = r.makeLimitMsg(dest.ID)
+	response.Limit = makeLimitMsg(limit)
 
 	wr = util.NewDelimitedWriter(s)
 	err = wr.WriteMsg(&response)
@@ -417,26 +497,54 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 
 	log.Infof("relaying connection from %s to %s", src, dest.ID)
 
+	var deadline time.Time
+	if limit != nil && limit.Duration > 0 {
+		deadline = time.Now().Add(limit.Duration)
+		s.SetDeadline(deadline)
+		bs.SetDeadline(deadline)
+	}
+	// The circuit context stops the bandwidth waits of both directions when
+	// the circuit fails or reaches its time limit, so that they don't hold on
+	// to their share of the relay bandwidth.
+	var circuitCtx context.Context
+	var circuitCancel context.CancelFunc
+	if deadline.IsZero() {
+		circuitCtx, circuitCancel = context.WithCancel(r.ctx)
+	} else {
+		circuitCtx, circuitCancel = context.WithDeadline(r.ctx, deadline)
+	}
+
 	var goroutines atomic.Int32
 	goroutines.Store(2)
-
-	done := func() {
-		if goroutines.Add(-1) == 0 {
-			s.Close()
-			bs.Close()
-			cleanup()
+	var fromSrc, fromDest atomic.Int64
+
+	done := func(relayed *atomic.Int64) func(int64) {
+		return func(n int64) {
+			relayed.Store(n)
+			if goroutines.Add(-1) == 0 {
+				circuitCancel()
+				s.Close()
+				bs.Close()
+				cleanup()
+				r.policy.CircuitClosed(CircuitStats{
+					Src:           src,
+					Dest:          dest.ID,
+					Limit:         limit,
+					Opened:        connStTime,
+					Duration:      time.Since(connStTime),
+					BytesFromSrc:  fromSrc.Load(),
+					BytesFromDest: fromDest.Load(),
+				})
+			}
 		}
 	}
 
-	if r.rc.Limit != nil {
-		deadline := time.Now().Add(r.rc.Limit.Duration)
-		s.SetDeadline(deadline)
-		bs.SetDeadline(deadline)
-		go r.relayLimited(s, bs, src, dest.ID, r.rc.Limit.Data, done)
-		go r.relayLimited(bs, s, dest.ID, src, r.rc.Limit.Data, done)
+	if limit != nil {
+		go r.relayLimited(circuitCtx, circuitCancel, s, bs, src, dest.ID, limit, done(&fromSrc))
+		go r.relayLimited(circuitCtx, circuitCancel, bs, s, dest.ID, src, limit, done(&fromDest))
 	} else {
-		go r.relayUnlimited(s, bs, src, dest.ID, done)
-		go r.relayUnlimited(bs, s, dest.ID, src, done)
+		go r.relayUnlimited(circuitCtx, circuitCancel, s, bs, src, dest.ID, done(&fromSrc))
+		go r.relayUnlimited(circuitCtx, circuitCancel, bs, s, dest.ID, src, done(&fromDest))
 	}
 
 	return pbv2.Status_OK
@@ -462,24 +570,34 @@ func (r *Relay) rmConn(p peer.ID) {
 	}
 }
 
-func (r *Relay) relayLimited(src, dest network.Stream, srcID, destID peer.ID, limit int64, done func()) {
-	defer done()
+func (r *Relay) relayLimited(ctx context.Context, cancel context.CancelFunc, src, dest network.Stream, srcID, destID peer.ID, limit *RelayLimit, done func(int64)) {
+	var count int64
+	defer func() { done(count) }()
 
 	buf := pool.Get(r.rc.BufferSize)
 	defer pool.Put(buf)
 
-	limitedSrc := io.LimitReader(src, limit)
+	var limitedSrc io.Reader = src
+	if limit.Data > 0 {
+		limitedSrc = io.LimitReader(src, limit.Data)
+	}
 
-	count, err := r.copyWithBuffer(dest, limitedSrc, buf)
+	var bw *rate.Limiter
+	if limit.Bandwidth > 0 {
+		bw = rate.NewLimiter(rate.Limit(limit.Bandwidth), len(buf))
+	}
+
+	count, err := r.copyWithBuffer(ctx, dest, limitedSrc, buf, bw)
 	if err != nil {
 		log.Debugf("relay copy error: %s", err)
-		// Reset both.
+		// Reset both, and stop the other direction.
 		src.Reset()
 		dest.Reset()
+		cancel()
 	} else {
 		// propagate the close
 		dest.CloseWrite()
-		if count == limit {
+		if limit.Data > 0 && count == limit.Data {
 			// we've reached the limit, discard further input
 			src.CloseRead()
 		}
@@ -488,18 +606,20 @@ func (r *Relay) relayLimited(src, dest network.Stream, srcID, destID peer.ID, li
 	log.Debugf("relayed %d bytes from %s to %s", count, srcID, destID)
 }
 
-func (r *Relay) relayUnlimited(src, dest network.Stream, srcID, destID peer.ID, done func()) {
-	defer done()
+func (r *Relay) relayUnlimited(ctx context.Context, cancel context.CancelFunc, src, dest network.Stream, srcID, destID peer.ID, done func(int64)) {
+	var count int64
+	defer func() { done(count) }()
 
 	buf := pool.Get(r.rc.BufferSize)
 	defer pool.Put(buf)
 
-	count, err := r.copyWithBuffer(dest, src, buf)
+	count, err := r.copyWithBuffer(ctx, dest, src, buf, nil)
 	if err != nil {
 		log.Debugf("relay copy error: %s", err)
-		// Reset both.
+		// Reset both, and stop the other direction.
 		src.Reset()
 		dest.Reset()
+		cancel()
 	} else {
 		// propagate the close
 		dest.CloseWrite()
@@ -512,13 +632,64 @@ func (r *Relay) relayUnlimited(src, dest network.Stream, srcID, destID peer.ID,
 // copied from io.errInvalidWrite
 var errInvalidWrite = errors.New("invalid write result")
 
+// waitBandwidth waits until n bytes can be relayed within the bandwidth of the
+// relayed connection, bw, and the bandwidth of the relay.
+//
+// The rate limiters serve the waiting connections in order, and each relayed
+// direction waits for at most one buffer at a time, so the relay bandwidth is
+// shared fairly between the active connections. The wait ends early when ctx,
+// the context of the relayed connection, is done.
+func (r *Relay) waitBandwidth(ctx context.Context, n int, bw *rate.Limiter) error {
+	var throttled time.Duration
+	for _, l := range []*rate.Limiter{bw, r.bw} {
+		if l == nil {
+			continue
+		}
+		d, err := r.waitN(ctx, l, n)
+		if err != nil {
+			return err
+		}
+		throttled += d
+	}
+	if bmt, ok := r.metricsTracer.(BandwidthMetricsTracer); ok && throttled > 0 {
+		bmt.BandwidthThrottled(throttled)
+	}
+	return nil
+}
+
+// waitN is like l.WaitN, and returns how long it waited. If ctx is done, the
+// reserved tokens are given back to the limiter.
+func (r *Relay) waitN(ctx context.Context, l *rate.Limiter, n int) (time.Duration, error) {
+	res := l.ReserveN(time.Now(), n)
+	if !res.OK() {
+		return 0, fmt.Errorf("cannot relay %d bytes within the burst of the bandwidth limit", n)
+	}
+	d := res.Delay()
+	if d == 0 {
+		return 0, nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return d, nil
+	case <-ctx.Done():
+		res.Cancel()
+		return 0, ctx.Err()
+	}
+}
+
 // copyWithBuffer copies from src to dst using the provided buf until either EOF is reached
 // on src or an error occurs. It reports the number of bytes transferred to metricsTracer.
+// If bw is not nil, it limits the rate of the copy; its burst must be at least len(buf).
 // The implementation is a modified form of io.CopyBuffer to support metrics tracking.
-func (r *Relay) copyWithBuffer(dst io.Writer, src io.Reader, buf []byte) (written int64, err error) {
+func (r *Relay) copyWithBuffer(ctx context.Context, dst io.Writer, src io.Reader, buf []byte, bw *rate.Limiter) (written int64, err error) {
 	for {
 		nr, er := src.Read(buf)
 		if nr > 0 {
+			if err = r.waitBandwidth(ctx, nr, bw); err != nil {
+				break
+			}
 			nw, ew := dst.Write(buf[0:nr])
 			if nw < 0 || nr < nw {
 				nw = 0
@@ -574,6 +745,21 @@ func (r *Relay) writeResponse(s network.Stream, status pbv2.Status, rsvp *pbv2.R
 	return wr.WriteMsg(&msg)
 }
 
+// makeLoadMsg returns the current utilisation of the relay. It must be called
+// with the mutex held.
+func (r *Relay) makeLoadMsg() *pbv2.Load {
+	rsvps := uint32(len(r.rsvp))
+	maxRsvps := uint32(max(r.rc.MaxReservations, 0))
+	circuits := uint32(r.circuits)
+	maxCircuits := uint32(max(r.rc.MaxTotalCircuits, 0))
+	return &pbv2.Load{
+		Reservations:    &rsvps,
+		MaxReservations: &maxRsvps,
+		Circuits:        &circuits,
+		MaxCircuits:     &maxCircuits,
+	}
+}
+
 func makeReservationMsg(
 	signingKey crypto.PrivKey,
 	selfID peer.ID,
@@ -638,13 +824,13 @@ func makeReservationMsg(
 	return rsvp
 }
 
-func (r *Relay) makeLimitMsg(_ peer.ID) *pbv2.Limit {
-	if r.rc.Limit == nil {
+func makeLimitMsg(limit *RelayLimit) *pbv2.Limit {
+	if limit == nil {
 		return nil
 	}
 
-	duration := uint32(r.rc.Limit.Duration / time.Second)
-	data := uint64(r.rc.Limit.Data)
+	duration := uint32(limit.Duration / time.Second)
+	data := uint64(limit.Data)
 
 	return &pbv2.Limit{
 		Duration: &duration,
@@ -672,10 +858,14 @@ func (r *Relay) gc() {
 
 	now := time.Now()
 	cnt := 0
-	for p, expire := range r.rsvp {
-		if r.closed || expire.Before(now) {
+	for p, rsvp := range r.rsvp {
+		if r.closed || rsvp.expire.Before(now) {
 			delete(r.rsvp, p)
 			r.host.ConnManager().UntagPeer(p, "relay-reservation")
+			// persisted reservations survive closing the relay
+			if !r.closed {
+				r.queuePersist(p, nil, nil)
+			}
 			cnt++
 		}
 	}
@@ -702,6 +892,11 @@ func (r *Relay) disconnected(n network.Network, c network.Conn) {
 		delete(r.rsvp, p)
 	}
 	r.constraints.cleanupPeer(p)
+	// peers disconnect from a relay going away; keep their reservations for
+	// the restarted relay
+	if ok && !r.closed && !r.goingAway {
+		r.queuePersist(p, nil, nil)
+	}
 	r.mx.Unlock()
 
 	if ok && r.metricsTracer != nil {
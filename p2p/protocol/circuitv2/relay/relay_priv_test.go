@@ -1,6 +1,7 @@
 package relay
 
 import (
+	"context"
 	"crypto/rand"
 	"testing"
 	"time"
@@ -8,6 +9,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
 
 	ma "github.com/multiformats/go-multiaddr"
 )
@@ -51,3 +53,16 @@ func TestMakeReservationWithP2PAddrs(t *testing.T) {
 
 	require.Equal(t, expectedAddrs, addrsFromRsvp)
 }
+
+func TestWaitNReturnsTokens(t *testing.T) {
+	r := &Relay{ctx: context.Background()}
+	l := rate.NewLimiter(100, 100)
+	require.True(t, l.AllowN(time.Now(), 100))
+
+	// the wait of a closed circuit gives back its tokens
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := r.waitN(ctx, l, 100)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Greater(t, l.Tokens(), 0.0)
+}
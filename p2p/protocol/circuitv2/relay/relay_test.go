@@ -27,6 +27,8 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
 	"github.com/stretchr/testify/require"
 
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
 	ma "github.com/multiformats/go-multiaddr"
 )
 
@@ -382,3 +384,350 @@ func TestRelayLimitData(t *testing.T) {
 	}
 
 }
+
+type testPolicy struct {
+	denied peer.ID
+	limit  *relay.RelayLimit
+	closed chan relay.CircuitStats
+}
+
+func (p *testPolicy) ReservationLimit(id peer.ID, _ ma.Multiaddr) (relay.ReservationLimit, bool) {
+	if id == p.denied {
+		return relay.ReservationLimit{}, false
+	}
+	return relay.ReservationLimit{TTL: time.Minute, Limit: p.limit}, true
+}
+
+func (p *testPolicy) CircuitLimit(_ peer.ID, _ ma.Multiaddr, _ peer.ID) (*relay.RelayLimit, bool) {
+	return p.limit, true
+}
+
+func (p *testPolicy) CircuitClosed(stats relay.CircuitStats) {
+	p.closed <- stats
+}
+
+func TestRelayPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 4)
+	addTransport(t, hosts[0], upgraders[0])
+	addTransport(t, hosts[2], upgraders[2])
+
+	hosts[0].SetStreamHandler("test", func(s network.Stream) {
+		defer s.Close()
+		buf, _ := io.ReadAll(s)
+		s.Write(buf[:len(buf)/2])
+	})
+
+	policy := &testPolicy{
+		denied: hosts[3].ID(),
+		limit:  &relay.RelayLimit{Duration: time.Minute, Data: 1 << 20},
+		closed: make(chan relay.CircuitStats, 1),
+	}
+	r, err := relay.New(hosts[1], relay.WithPolicy(policy))
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+	connect(t, hosts[1], hosts[3])
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	_, err = client.Reserve(ctx, hosts[3], rinfo)
+	require.Error(t, err)
+
+	rsvp, err := client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+	require.Equal(t, uint64(policy.limit.Data), rsvp.LimitData)
+	require.WithinDuration(t, time.Now().Add(time.Minute), rsvp.Expiration, 5*time.Second)
+
+	raddr := ma.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), hosts[0].ID()))
+	require.NoError(t, hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+	s, err := hosts[2].NewStream(network.WithAllowLimitedConn(ctx, "test"), hosts[0].ID(), "test")
+	require.NoError(t, err)
+	msg := make([]byte, 1000)
+	_, err = s.Write(msg)
+	require.NoError(t, err)
+	require.NoError(t, s.CloseWrite())
+	resp, err := io.ReadAll(s)
+	require.NoError(t, err)
+	require.Len(t, resp, 500)
+	s.Close()
+
+	// closing the relayed connection closes the circuit
+	for _, c := range hosts[2].Network().ConnsToPeer(hosts[0].ID()) {
+		c.Close()
+	}
+	select {
+	case stats := <-policy.closed:
+		require.Equal(t, hosts[2].ID(), stats.Src)
+		require.Equal(t, hosts[0].ID(), stats.Dest)
+		require.Equal(t, policy.limit, stats.Limit)
+		require.Greater(t, stats.BytesFromSrc, int64(len(msg)))
+		require.Greater(t, stats.BytesFromDest, int64(len(resp)))
+		require.Positive(t, stats.Duration)
+	case <-time.After(5 * time.Second):
+		t.Fatal("circuit not closed")
+	}
+
+	// zero limits mean no limit
+	policy.limit = &relay.RelayLimit{}
+	s, err = hosts[2].NewStream(network.WithAllowLimitedConn(ctx, "test"), hosts[0].ID(), "test")
+	require.NoError(t, err)
+	msg = make([]byte, 1<<21)
+	_, err = s.Write(msg)
+	require.NoError(t, err)
+	require.NoError(t, s.CloseWrite())
+	resp, err = io.ReadAll(s)
+	require.NoError(t, err)
+	require.Len(t, resp, 1<<20)
+	s.Close()
+}
+
+func TestRelayLimitBandwidth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 3)
+	addTransport(t, hosts[0], upgraders[0])
+	addTransport(t, hosts[2], upgraders[2])
+
+	rch := make(chan int, 1)
+	hosts[0].SetStreamHandler("test", func(s network.Stream) {
+		defer s.Close()
+		buf, _ := io.ReadAll(s)
+		rch <- len(buf)
+	})
+
+	rc := relay.DefaultResources()
+	rc.Limit.Bandwidth = 4096
+	r, err := relay.New(hosts[1], relay.WithResources(rc))
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	_, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+
+	raddr := ma.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), hosts[0].ID()))
+	require.NoError(t, hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+	s, err := hosts[2].NewStream(network.WithAllowLimitedConn(ctx, "test"), hosts[0].ID(), "test")
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = s.Write(make([]byte, 8192))
+	require.NoError(t, err)
+	require.NoError(t, s.CloseWrite())
+	select {
+	case n := <-rch:
+		require.Equal(t, 8192, n)
+	case <-time.After(10 * time.Second):
+		t.Fatal("data not relayed")
+	}
+	// the first BufferSize bytes are a burst, the rest is relayed at 4096 bytes/s
+	require.Greater(t, time.Since(start), time.Second)
+}
+
+func TestRelayMaxBandwidth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 4)
+	addTransport(t, hosts[0], upgraders[0])
+	addTransport(t, hosts[2], upgraders[2])
+	addTransport(t, hosts[3], upgraders[3])
+
+	rch := make(chan int, 2)
+	hosts[0].SetStreamHandler("test", func(s network.Stream) {
+		defer s.Close()
+		buf, _ := io.ReadAll(s)
+		rch <- len(buf)
+	})
+
+	rc := relay.DefaultResources()
+	rc.MaxBandwidth = 4096
+	r, err := relay.New(hosts[1], relay.WithResources(rc))
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+	connect(t, hosts[1], hosts[3])
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	_, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+
+	raddr := ma.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), hosts[0].ID()))
+	var streams []network.Stream
+	for _, h := range hosts[2:] {
+		require.NoError(t, h.Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+		s, err := h.NewStream(network.WithAllowLimitedConn(ctx, "test"), hosts[0].ID(), "test")
+		require.NoError(t, err)
+		streams = append(streams, s)
+	}
+
+	start := time.Now()
+	for _, s := range streams {
+		go func(s network.Stream) {
+			s.Write(make([]byte, 6144))
+			s.CloseWrite()
+		}(s)
+	}
+	var finished []time.Duration
+	for range streams {
+		select {
+		case n := <-rch:
+			require.Equal(t, 6144, n)
+			finished = append(finished, time.Since(start))
+		case <-time.After(10 * time.Second):
+			t.Fatal("data not relayed")
+		}
+	}
+	// the relay bandwidth is shared, so both connections finish after the
+	// first BufferSize bytes burst and ~10KiB at 4096 bytes/s
+	require.Greater(t, finished[0], 1500*time.Millisecond)
+	require.Greater(t, finished[1], 2*time.Second)
+}
+
+func TestRelayPersistReservations(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 3)
+	addTransport(t, hosts[0], upgraders[0])
+	addTransport(t, hosts[2], upgraders[2])
+
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	r, err := relay.New(hosts[1], relay.WithDatastore(d))
+	require.NoError(t, err)
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	_, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+
+	// restart the relay; the reservation is restored from the datastore
+	require.NoError(t, r.Close())
+	r, err = relay.New(hosts[1], relay.WithDatastore(d))
+	require.NoError(t, err)
+	defer r.Close()
+
+	raddr, err := ma.NewMultiaddr(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), hosts[0].ID()))
+	require.NoError(t, err)
+	err = hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}})
+	require.NoError(t, err)
+}
+
+// blockingDatastore blocks writes until unblock is closed.
+type blockingDatastore struct {
+	ds.Batching
+	unblock chan struct{}
+}
+
+func (d *blockingDatastore) Put(ctx context.Context, k ds.Key, v []byte) error {
+	<-d.unblock
+	return d.Batching.Put(ctx, k, v)
+}
+
+func TestRelayPersistDoesntBlockReservations(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	hosts, _ := getNetHosts(t, ctx, 2)
+	d := &blockingDatastore{Batching: dssync.MutexWrap(ds.NewMapDatastore()), unblock: make(chan struct{})}
+	r, err := relay.New(hosts[1], relay.WithDatastore(d))
+	require.NoError(t, err)
+	connect(t, hosts[0], hosts[1])
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	_, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+
+	// the queued write is done when the relay closes
+	close(d.unblock)
+	require.NoError(t, r.Close())
+	ok, err := d.Has(ctx, ds.NewKey("/relay/reservations").ChildString(hosts[0].ID().String()))
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestRelayGoAway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 3)
+	addTransport(t, hosts[0], upgraders[0])
+
+	r, err := relay.New(hosts[1])
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+
+	sub, err := hosts[0].EventBus().Subscribe(new(event.EvtRelayGoingAway))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	_, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+
+	require.NoError(t, r.GoAway(ctx, time.Minute))
+	select {
+	case e := <-sub.Out():
+		evt := e.(event.EvtRelayGoingAway)
+		require.Equal(t, hosts[1].ID(), evt.Relay)
+		require.Equal(t, time.Minute, evt.Downtime)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected go away event")
+	}
+
+	// new reservations are refused
+	_, err = client.Reserve(ctx, hosts[2], rinfo)
+	require.Error(t, err)
+}
+
+func TestRelayLoad(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 4)
+	addTransport(t, hosts[0], upgraders[0])
+	addTransport(t, hosts[2], upgraders[2])
+	addTransport(t, hosts[3], upgraders[3])
+
+	rc := relay.DefaultResources()
+	rc.MaxTotalCircuits = 1
+	r, err := relay.New(hosts[1], relay.WithResources(rc))
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+	connect(t, hosts[1], hosts[3])
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	rsvp, err := client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+	require.Equal(t, &client.RelayLoad{Reservations: 1, MaxReservations: rc.MaxReservations, MaxCircuits: 1}, rsvp.Load)
+	require.Less(t, rsvp.Load.Utilization(), 0.5)
+
+	raddr := ma.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), hosts[0].ID()))
+	require.NoError(t, hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+
+	rsvp, err = client.Reserve(ctx, hosts[3], rinfo)
+	require.NoError(t, err)
+	require.Equal(t, &client.RelayLoad{Reservations: 2, MaxReservations: rc.MaxReservations, Circuits: 1, MaxCircuits: 1}, rsvp.Load)
+	require.Equal(t, 1.0, rsvp.Load.Utilization())
+
+	// the relay is full
+	require.Error(t, hosts[3].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+}
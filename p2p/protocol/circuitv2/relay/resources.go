@@ -17,8 +17,16 @@ type Resources struct {
 	MaxReservations int
 	// MaxCircuits is the maximum number of open relay connections for each peer; defaults to 16.
 	MaxCircuits int
+	// MaxTotalCircuits is the maximum number of open relay connections over
+	// all peers; defaults to 0, which means no limit. It is advertised to
+	// clients along with the current number of relayed connections.
+	MaxTotalCircuits int
 	// BufferSize is the size of the relayed connection buffers; defaults to 2048.
 	BufferSize int
+	// MaxBandwidth is the maximum number of bytes relayed per second, over all
+	// relayed connections and in both directions; defaults to 0, which means
+	// no limit. The bandwidth is shared fairly between the active connections.
+	MaxBandwidth int64
 
 	// MaxReservationsPerPeer is the maximum number of reservations originating from the same
 	// peer; default is 4.
@@ -36,10 +44,14 @@ type Resources struct {
 // RelayLimit are the per relayed connection resource limits.
 type RelayLimit struct {
 	// Duration is the time limit before resetting a relayed connection; defaults to 2min.
+	// 0 means no time limit.
 	Duration time.Duration
 	// Data is the limit of data relayed (on each direction) before resetting the connection.
-	// Defaults to 128KB
+	// Defaults to 128KB. 0 means no data limit.
 	Data int64
+	// Bandwidth is the limit of data relayed (on each direction) per second.
+	// Defaults to 0, which means no limit.
+	Bandwidth int64
 }
 
 // DefaultResources returns a Resources object with the default filled in.
@@ -2,6 +2,7 @@ package holepunch_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"slices"
@@ -635,6 +636,16 @@ func (m *MockSourceIPSelector) PreferredSourceIPForDestination(_ *net.UDPAddr) (
 }
 
 func quicSimConn(isPubliclyReachably bool, router *simconn.SimpleFirewallRouter) libp2p.Option {
+	return quicSimConnWithRouter(router, func(address *net.UDPAddr, c *simconn.SimConn) {
+		if isPubliclyReachably {
+			router.AddPubliclyReachableNode(address, c)
+		} else {
+			router.AddNode(address, c)
+		}
+	})
+}
+
+func quicSimConnWithRouter(router simconn.Router, addNode func(*net.UDPAddr, *simconn.SimConn)) libp2p.Option {
 	m := &MockSourceIPSelector{}
 	return libp2p.QUICReuse(
 		quicreuse.NewConnManager,
@@ -644,15 +655,196 @@ func quicSimConn(isPubliclyReachably bool, router *simconn.SimpleFirewallRouter)
 		quicreuse.OverrideListenUDP(func(_ string, address *net.UDPAddr) (net.PacketConn, error) {
 			m.ip.Store(&address.IP)
 			c := simconn.NewSimConn(address, router)
-			if isPubliclyReachably {
-				router.AddPubliclyReachableNode(address, c)
-			} else {
-				router.AddNode(address, c)
-			}
+			addNode(address, c)
 			return c, nil
 		}))
 }
 
+// symmetricNATRouter routes packets between public nodes, firewalled like with
+// simconn.SimpleFirewallRouter, and a node behind a NAT that allocates the
+// next external port for every new destination, with endpoint dependent
+// filtering.
+type symmetricNATRouter struct {
+	mu    sync.Mutex
+	nodes map[string]*natRouterNode
+
+	natted     *simconn.SimConn
+	nattedAddr string
+	externalIP net.IP
+	nextPort   int
+	// mappings maps destinations to external addresses, reverse maps
+	// external addresses to destinations
+	mappings map[string]*net.UDPAddr
+	reverse  map[string]string
+}
+
+type natRouterNode struct {
+	conn              *simconn.SimConn
+	publiclyReachable bool
+	sentTo            map[string]struct{}
+}
+
+func newSymmetricNATRouter(externalIP net.IP, firstPort int) *symmetricNATRouter {
+	return &symmetricNATRouter{
+		nodes:      make(map[string]*natRouterNode),
+		externalIP: externalIP,
+		nextPort:   firstPort,
+		mappings:   make(map[string]*net.UDPAddr),
+		reverse:    make(map[string]string),
+	}
+}
+
+func (r *symmetricNATRouter) addNode(publiclyReachable bool) func(*net.UDPAddr, *simconn.SimConn) {
+	return func(addr *net.UDPAddr, c *simconn.SimConn) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.nodes[addr.String()] = &natRouterNode{conn: c, publiclyReachable: publiclyReachable, sentTo: make(map[string]struct{})}
+	}
+}
+
+func (r *symmetricNATRouter) addNattedNode(addr *net.UDPAddr, c *simconn.SimConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.natted = c
+	r.nattedAddr = addr.String()
+}
+
+func (r *symmetricNATRouter) SendPacket(p simconn.Packet) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p.From.String() == r.nattedAddr {
+		ext, ok := r.mappings[p.To.String()]
+		if !ok {
+			ext = &net.UDPAddr{IP: r.externalIP, Port: r.nextPort}
+			r.nextPort++
+			r.mappings[p.To.String()] = ext
+			r.reverse[ext.String()] = p.To.String()
+		}
+		p.From = ext
+	} else if from, ok := r.nodes[p.From.String()]; ok {
+		from.sentTo[p.To.String()] = struct{}{}
+	} else {
+		return errors.New("unknown source")
+	}
+
+	if dst, ok := r.reverse[p.To.String()]; ok {
+		if dst == p.From.String() {
+			r.natted.RecvPacket(p)
+		}
+		return nil
+	}
+	to, ok := r.nodes[p.To.String()]
+	if !ok {
+		// like a real network, silently drop packets to unallocated ports
+		return nil
+	}
+	if _, ok := to.sentTo[p.From.String()]; ok || to.publiclyReachable {
+		to.conn.RecvPacket(p)
+	}
+	return nil
+}
+
+// TestEndToEndSimConnectSymmetricNAT tests that port prediction establishes a
+// direct connection with a peer behind a NAT with endpoint dependent mappings.
+func TestEndToEndSimConnectSymmetricNAT(t *testing.T) {
+	for _, useLegacyHolePunchingBehavior := range []bool{true, false} {
+		t.Run(fmt.Sprintf("legacy=%t", useLegacyHolePunchingBehavior), func(t *testing.T) {
+			h2tr := &mockEventTracer{}
+			router := newSymmetricNATRouter(net.IPv4(3, 3, 3, 3), 40000)
+
+			newPublicHost := func(addr string) host.Host {
+				return MustNewHost(t,
+					quicSimConnWithRouter(router, router.addNode(true)),
+					libp2p.ListenAddrs(ma.StringCast(addr)),
+					libp2p.DisableRelay(),
+					libp2p.ResourceManager(&network.NullResourceManager{}),
+				)
+			}
+			relay := MustNewHost(t,
+				quicSimConnWithRouter(router, router.addNode(true)),
+				libp2p.ListenAddrs(ma.StringCast("/ip4/1.2.0.1/udp/8000/quic-v1")),
+				libp2p.DisableRelay(),
+				libp2p.ResourceManager(&network.NullResourceManager{}),
+				libp2p.WithFxOption(fx.Invoke(func(h host.Host) {
+					// Setup relay service
+					_, err := relayv2.New(h)
+					require.NoError(t, err)
+				})),
+			)
+			defer relay.Close()
+			// peers reporting the addresses the NAT allocated for them
+			var observers []host.Host
+			for i := 2; i <= 3; i++ {
+				o := newPublicHost(fmt.Sprintf("/ip4/1.2.0.%d/udp/8000/quic-v1", i))
+				defer o.Close()
+				observers = append(observers, o)
+			}
+
+			// h1 is behind the symmetric NAT
+			h1 := MustNewHost(t,
+				quicSimConnWithRouter(router, router.addNattedNode),
+				libp2p.EnableHolePunching(holepunch.DirectDialTimeout(time.Second), holepunch.WithPortPrediction(4), SetLegacyBehavior(useLegacyHolePunchingBehavior)),
+				libp2p.ListenAddrs(ma.StringCast("/ip4/10.0.0.1/udp/8000/quic-v1")),
+				libp2p.ResourceManager(&network.NullResourceManager{}),
+				libp2p.ForceReachabilityPrivate(),
+			)
+			defer h1.Close()
+			// h2 is behind a firewall
+			h2 := MustNewHost(t,
+				quicSimConnWithRouter(router, router.addNode(false)),
+				libp2p.ListenAddrs(ma.StringCast("/ip4/2.2.0.2/udp/8001/quic-v1")),
+				libp2p.ResourceManager(&network.NullResourceManager{}),
+				connectToRelay(&relay),
+				libp2p.EnableHolePunching(holepunch.WithTracer(h2tr), holepunch.DirectDialTimeout(time.Second), holepunch.WithPortPrediction(4), SetLegacyBehavior(useLegacyHolePunchingBehavior)),
+				libp2p.ForceReachabilityPrivate(),
+			)
+			defer h2.Close()
+
+			for _, o := range append([]host.Host{relay}, observers...) {
+				require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: o.ID(), Addrs: o.Addrs()}))
+			}
+			// h1 starts the hole punching service once it learnt the external
+			// ports of its NAT
+			require.Eventually(t, func() bool {
+				return slices.Contains(h1.Mux().Protocols(), holepunch.Protocol)
+			}, 10*time.Second, 100*time.Millisecond)
+			waitForHolePunchingSvcActive(t, h2)
+
+			var relayAddrs []ma.Multiaddr
+			require.Eventually(t, func() bool {
+				relayAddrs = slices.DeleteFunc(h2.Addrs(), func(a ma.Multiaddr) bool { return !isRelayAddr(a) })
+				return len(relayAddrs) > 0
+			}, 5*time.Second, 50*time.Millisecond)
+			require.NoError(t, h1.Connect(network.WithAllowLimitedConn(context.Background(), "test"), peer.AddrInfo{ID: h2.ID(), Addrs: relayAddrs}))
+
+			ensureDirectConn(t, h1, h2)
+			var end *holepunch.EndHolePunchEvt
+			require.Eventually(t, func() bool {
+				for _, e := range h2tr.getEvents() {
+					if e.Type == holepunch.EndHolePunchEvtT {
+						end = e.Evt.(*holepunch.EndHolePunchEvt)
+						return true
+					}
+				}
+				return false
+			}, 5*time.Second, 50*time.Millisecond)
+			require.True(t, end.Success)
+			require.Equal(t, holepunch.StrategyPortPrediction, end.Strategy)
+			// the predicted address isn't added to the peerstore
+			for _, c := range h2.Network().ConnsToPeer(h1.ID()) {
+				if !isRelayAddr(c.RemoteMultiaddr()) {
+					require.False(t, ma.Contains(h2.Peerstore().Addrs(h1.ID()), c.RemoteMultiaddr()))
+				}
+			}
+		})
+	}
+}
+
+func isRelayAddr(a ma.Multiaddr) bool {
+	_, err := a.ValueForProtocol(ma.P_CIRCUIT)
+	return err == nil
+}
+
 func addHolePunchService(t *testing.T, h host.Host, extraAddrs []ma.Multiaddr, opts ...holepunch.Option) *holepunch.Service {
 	t.Helper()
 	hps, err := holepunch.NewService(h, newMockIDService(t, h), func() []ma.Multiaddr {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -20,6 +21,10 @@ import (
 // ErrHolePunchActive is returned from DirectConnect when another hole punching attempt is currently running
 var ErrHolePunchActive = errors.New("another hole punching attempt to this peer is active")
 
+// ErrEndpointDependentNAT is returned from DirectConnect when all our NAT
+// mappings are endpoint dependent and port prediction is disabled.
+var ErrEndpointDependentNAT = errors.New("NAT mappings are endpoint dependent, hole punching is not possible")
+
 const maxRetries = 3
 
 // The holePuncher is run on the peer that's behind a NAT / Firewall.
@@ -49,6 +54,11 @@ type holePuncher struct {
 	tracer *tracer
 	filter AddrFilter
 
+	portPredictionProbes int
+	// endpointDependentNAT reports whether all our NAT mappings are endpoint
+	// dependent. May be nil.
+	endpointDependentNAT func() bool
+
 	// Prior to https://github.com/libp2p/go-libp2p/pull/3044, go-libp2p would
 	// pick the opposite roles for client/server a hole punch. Setting this to
 	// true preserves that behavior
@@ -137,6 +147,13 @@ func (hp *holePuncher) directConnect(rp peer.ID) error {
 		}
 	}
 
+	// Behind a symmetric NAT the remote peer dials external ports that our NAT
+	// won't use for it. Don't waste a relayed stream on a hopeless hole punch.
+	if hp.portPredictionProbes == 0 && hp.endpointDependentNAT != nil && hp.endpointDependentNAT() {
+		log.Debugw("not hole punching: our NAT mappings are endpoint dependent", "peer", rp)
+		return ErrEndpointDependentNAT
+	}
+
 	log.Debugw("got inbound proxy conn", "peer", rp)
 
 	// hole punch
@@ -154,24 +171,30 @@ func (hp *holePuncher) directConnect(rp peer.ID) error {
 		timer := time.NewTimer(synTime)
 		select {
 		case start := <-timer.C:
+			var predicted []ma.Multiaddr
+			if hp.portPredictionProbes > 0 {
+				predicted = predictAddrs(addrs, hp.portPredictionProbes)
+			}
 			pi := peer.AddrInfo{
 				ID:    rp,
 				Addrs: addrs,
 			}
-			hp.tracer.StartHolePunch(rp, addrs, rtt)
+			hp.tracer.StartHolePunch(rp, append(slices.Clip(addrs), predicted...), rtt)
 			hp.tracer.HolePunchAttempt(pi.ID)
 			ctx, cancel := context.WithTimeout(hp.ctx, hp.directDialTimeout)
 			isClient := true
 			if hp.legacyBehavior {
 				isClient = false
 			}
-			err := holePunchConnect(ctx, hp.host, pi, isClient)
+			err := holePunchConnect(ctx, hp.host, pi, predicted, isClient)
 			cancel()
 			dt := time.Since(start)
-			hp.tracer.EndHolePunch(rp, dt, err)
+			directConn := getDirectConnection(hp.host, rp)
+			strategy := holePunchStrategy(remoteAddr(directConn), predicted)
+			hp.tracer.EndHolePunch(rp, dt, err, strategy)
 			if err == nil {
-				log.Debugw("hole punching with successful", "peer", rp, "time", dt)
-				hp.tracer.HolePunchFinished("initiator", i, addrs, obsAddrs, getDirectConnection(hp.host, rp))
+				log.Debugw("hole punching with successful", "peer", rp, "time", dt, "strategy", strategy)
+				hp.tracer.HolePunchFinished("initiator", i, addrs, obsAddrs, directConn)
 				return nil
 			}
 		case <-hp.ctx.Done():
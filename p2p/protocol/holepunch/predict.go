@@ -0,0 +1,186 @@
+package holepunch
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// maxPortPredictionProbes is the maximum number of predicted addresses dialed
+// in a single hole punch attempt. Every probe is a connection attempt counted
+// by the resource manager, which allows 8 connections per peer by default,
+// including the relayed connection and the dials to the exchanged addresses.
+const maxPortPredictionProbes = 4
+
+// portPredictionStagger is the interval between the dials to the predicted
+// addresses.
+const portPredictionStagger = 20 * time.Millisecond
+
+// maxPredictablePortDelta is the largest step between the external ports of
+// consecutive mappings for which we try to predict the next mapping. Larger
+// steps indicate a NAT that allocates ports randomly.
+const maxPredictablePortDelta = 64
+
+// Hole punching strategies, reported in EndHolePunchEvt.
+const (
+	// StrategyObservedAddrs is dialing the addresses exchanged in the CONNECT messages.
+	StrategyObservedAddrs = "observed-addrs"
+	// StrategyPortPrediction is dialing the addresses predicted from the
+	// external ports observed for the remote peer.
+	StrategyPortPrediction = "port-prediction"
+)
+
+// WithPortPrediction enables hole punching through NATs with endpoint
+// dependent mappings, e.g. symmetric carrier grade NATs.
+//
+// Unless our NAT mappings are known to be endpoint independent, we add the
+// external ports identify observed for different destinations to the addresses
+// we send to the remote peer. When the addresses of the remote peer contain
+// several external UDP ports for the same IP, we guess the ports its NAT
+// allocates next and dial up to maxProbes predicted QUIC addresses, one every
+// 20ms, along with the exchanged addresses. The predicted addresses aren't
+// added to the peerstore. The probes are sent from the QUIC listening socket
+// shared by quicreuse.
+//
+// maxProbes must be between 1 and 4, to stay well below the connection limits
+// of the resource manager.
+func WithPortPrediction(maxProbes int) Option {
+	return func(s *Service) error {
+		if maxProbes <= 0 || maxProbes > maxPortPredictionProbes {
+			return fmt.Errorf("port prediction probes must be between 1 and %d", maxPortPredictionProbes)
+		}
+		s.portPredictionProbes = maxProbes
+		return nil
+	}
+}
+
+// mappedAddrs returns the QUIC addresses for the external ports observed for
+// different destinations in the UDP mappings that aren't known to be endpoint
+// independent. The remote peer predicts the port our NAT allocates for it
+// from them.
+func mappedAddrs(mappings []network.NATMapping) []ma.Multiaddr {
+	var res []ma.Multiaddr
+	for _, m := range mappings {
+		if m.TransportProtocol != network.NATTransportUDP || m.DeviceType == network.NATDeviceTypeCone {
+			continue
+		}
+		for _, a := range m.ObservedAddrs {
+			if manet.IsPublicAddr(a) {
+				res = append(res, a.Encapsulate(quicV1))
+			}
+		}
+	}
+	return res
+}
+
+var quicV1 = ma.StringCast("/quic-v1")
+
+// predictAddrs predicts the next external addresses of the NAT of a peer with
+// the given addresses, returning at most maxProbes addresses.
+//
+// Addresses that only differ by their UDP port are grouped. For every group
+// with at least two ports, the most common step between consecutive ports is
+// used to extrapolate the following ports.
+func predictAddrs(addrs []ma.Multiaddr, maxProbes int) []ma.Multiaddr {
+	type group struct {
+		ip, rest ma.Multiaddr
+		ports    []int
+	}
+	var groups []*group
+	byKey := make(map[string]*group)
+	for _, a := range addrs {
+		ip, rest := ma.SplitFirst(a)
+		if ip == nil || (ip.Code() != ma.P_IP4 && ip.Code() != ma.P_IP6) {
+			continue
+		}
+		udp, rest := ma.SplitFirst(rest)
+		if udp == nil || udp.Code() != ma.P_UDP {
+			continue
+		}
+		if _, err := rest.ValueForProtocol(ma.P_QUIC_V1); err != nil {
+			continue
+		}
+		port, err := strconv.Atoi(udp.Value())
+		if err != nil {
+			continue
+		}
+		key := string(ip.Bytes()) + string(rest.Bytes())
+		g, ok := byKey[key]
+		if !ok {
+			g = &group{ip: ma.Multiaddr{*ip}, rest: rest}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		if !slices.Contains(g.ports, port) {
+			g.ports = append(g.ports, port)
+		}
+	}
+
+	type prediction struct {
+		*group
+		delta int
+	}
+	var predictable []prediction
+	for _, g := range groups {
+		if len(g.ports) < 2 {
+			continue
+		}
+		slices.Sort(g.ports)
+		delta := portDelta(g.ports)
+		if delta <= 0 || delta > maxPredictablePortDelta {
+			continue
+		}
+		predictable = append(predictable, prediction{group: g, delta: delta})
+	}
+	if len(predictable) == 0 {
+		return nil
+	}
+
+	// spread the probes over the groups
+	perGroup := (maxProbes + len(predictable) - 1) / len(predictable)
+	var res []ma.Multiaddr
+	for _, g := range predictable {
+		p := g.ports[len(g.ports)-1]
+		for i := 0; i < perGroup && len(res) < maxProbes; i++ {
+			p += g.delta
+			if p > 65535 {
+				break
+			}
+			udp, err := ma.NewComponent("udp", strconv.Itoa(p))
+			if err != nil {
+				break
+			}
+			res = append(res, ma.Join(g.ip, ma.Multiaddr{*udp}, g.rest))
+		}
+	}
+	return res
+}
+
+// portDelta returns the most common step between consecutive sorted ports,
+// preferring the smallest step in case of a tie.
+func portDelta(ports []int) int {
+	counts := make(map[int]int)
+	best := 0
+	for i := 1; i < len(ports); i++ {
+		d := ports[i] - ports[i-1]
+		counts[d]++
+		if counts[d] > counts[best] || (counts[d] == counts[best] && d < best) {
+			best = d
+		}
+	}
+	return best
+}
+
+// holePunchStrategy returns the strategy that established the direct connection.
+func holePunchStrategy(remote ma.Multiaddr, predicted []ma.Multiaddr) string {
+	if remote != nil && ma.Contains(predicted, remote) {
+		return StrategyPortPrediction
+	}
+	return StrategyObservedAddrs
+}
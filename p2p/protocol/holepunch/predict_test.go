@@ -0,0 +1,109 @@
+package holepunch
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPredictAddrs(t *testing.T) {
+	toAddrs := func(ss ...string) []ma.Multiaddr {
+		addrs := make([]ma.Multiaddr, 0, len(ss))
+		for _, s := range ss {
+			addrs = append(addrs, ma.StringCast(s))
+		}
+		return addrs
+	}
+
+	testCases := []struct {
+		name      string
+		addrs     []ma.Multiaddr
+		maxProbes int
+		expected  []ma.Multiaddr
+	}{
+		{
+			name:      "single port",
+			addrs:     toAddrs("/ip4/1.2.3.4/udp/1000/quic-v1"),
+			maxProbes: 4,
+		},
+		{
+			name:      "sequential ports",
+			addrs:     toAddrs("/ip4/1.2.3.4/udp/1004/quic-v1", "/ip4/1.2.3.4/udp/1000/quic-v1", "/ip4/1.2.3.4/udp/1002/quic-v1"),
+			maxProbes: 3,
+			expected:  toAddrs("/ip4/1.2.3.4/udp/1006/quic-v1", "/ip4/1.2.3.4/udp/1008/quic-v1", "/ip4/1.2.3.4/udp/1010/quic-v1"),
+		},
+		{
+			name: "most common step",
+			addrs: toAddrs("/ip4/1.2.3.4/udp/1000/quic-v1", "/ip4/1.2.3.4/udp/1001/quic-v1",
+				"/ip4/1.2.3.4/udp/1010/quic-v1", "/ip4/1.2.3.4/udp/1011/quic-v1"),
+			maxProbes: 2,
+			expected:  toAddrs("/ip4/1.2.3.4/udp/1012/quic-v1", "/ip4/1.2.3.4/udp/1013/quic-v1"),
+		},
+		{
+			name:      "random ports",
+			addrs:     toAddrs("/ip4/1.2.3.4/udp/1000/quic-v1", "/ip4/1.2.3.4/udp/31337/quic-v1"),
+			maxProbes: 4,
+		},
+		{
+			name:      "different IPs",
+			addrs:     toAddrs("/ip4/1.2.3.4/udp/1000/quic-v1", "/ip4/5.6.7.8/udp/1001/quic-v1"),
+			maxProbes: 4,
+		},
+		{
+			name:      "TCP and webtransport ignored",
+			addrs:     toAddrs("/ip4/1.2.3.4/tcp/1000", "/ip4/1.2.3.4/tcp/1001", "/ip4/1.2.3.4/udp/1000/quic-v1/webtransport", "/ip4/1.2.3.4/udp/1001/quic-v1"),
+			maxProbes: 4,
+		},
+		{
+			name:      "port range end",
+			addrs:     toAddrs("/ip4/1.2.3.4/udp/65533/quic-v1", "/ip4/1.2.3.4/udp/65534/quic-v1"),
+			maxProbes: 4,
+			expected:  toAddrs("/ip4/1.2.3.4/udp/65535/quic-v1"),
+		},
+		{
+			name: "probes spread over groups",
+			addrs: toAddrs("/ip4/1.2.3.4/udp/1000/quic-v1", "/ip4/1.2.3.4/udp/1001/quic-v1",
+				"/ip6/2001:db8::1/udp/2000/quic-v1", "/ip6/2001:db8::1/udp/2002/quic-v1"),
+			maxProbes: 3,
+			expected:  toAddrs("/ip4/1.2.3.4/udp/1002/quic-v1", "/ip4/1.2.3.4/udp/1003/quic-v1", "/ip6/2001:db8::1/udp/2004/quic-v1"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, predictAddrs(tc.addrs, tc.maxProbes))
+		})
+	}
+}
+
+func TestHolePunchStrategy(t *testing.T) {
+	predicted := []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/udp/1006/quic-v1")}
+	require.Equal(t, StrategyPortPrediction, holePunchStrategy(ma.StringCast("/ip4/1.2.3.4/udp/1006/quic-v1"), predicted))
+	require.Equal(t, StrategyObservedAddrs, holePunchStrategy(ma.StringCast("/ip4/1.2.3.4/udp/1004/quic-v1"), predicted))
+	require.Equal(t, StrategyObservedAddrs, holePunchStrategy(nil, predicted))
+}
+
+func TestMappedAddrs(t *testing.T) {
+	mappings := []network.NATMapping{
+		{
+			TransportProtocol: network.NATTransportUDP,
+			DeviceType:        network.NATDeviceTypeSymmetric,
+			ObservedAddrs:     []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/udp/1000"), ma.StringCast("/ip4/1.2.3.4/udp/1001"), ma.StringCast("/ip4/192.168.1.1/udp/1002")},
+		},
+		{
+			TransportProtocol: network.NATTransportUDP,
+			DeviceType:        network.NATDeviceTypeCone,
+			ObservedAddrs:     []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.5/udp/2000")},
+		},
+		{
+			TransportProtocol: network.NATTransportTCP,
+			DeviceType:        network.NATDeviceTypeSymmetric,
+			ObservedAddrs:     []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/3000")},
+		},
+	}
+	require.Equal(t,
+		[]ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/udp/1000/quic-v1"), ma.StringCast("/ip4/1.2.3.4/udp/1001/quic-v1")},
+		mappedAddrs(mappings))
+}
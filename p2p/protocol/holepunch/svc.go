@@ -4,14 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
 	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch/pb"
 	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
 	"github.com/libp2p/go-msgio/pbio"
@@ -69,6 +72,14 @@ type Service struct {
 
 	tracer *tracer
 	filter AddrFilter
+	// portPredictionProbes is the maximum number of predicted addresses dialed
+	// per hole punch attempt. Port prediction is disabled when 0.
+	portPredictionProbes int
+
+	// natMappings are the NAT mappings of our listen addresses, as reported
+	// by identify in EvtNATMappingsChanged.
+	natMappingsMx sync.Mutex
+	natMappings   []network.NATMapping
 
 	refCount sync.WaitGroup
 
@@ -114,10 +125,17 @@ func NewService(h host.Host, ids identify.IDService, listenAddrs func() []ma.Mul
 			return nil, err
 		}
 	}
+
+	sub, err := h.EventBus().Subscribe(new(event.EvtNATMappingsChanged), eventbus.Name("holepunch"))
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to subscribe to NAT mapping events: %w", err)
+	}
 	s.tracer.Start()
 
-	s.refCount.Add(1)
+	s.refCount.Add(2)
 	go s.waitForPublicAddr()
+	go s.watchNATMappings(sub)
 
 	return s, nil
 }
@@ -136,7 +154,7 @@ func (s *Service) waitForPublicAddr() {
 	t := time.NewTimer(duration)
 	defer t.Stop()
 	for {
-		if len(s.listenAddrs()) > 0 {
+		if len(s.ownAddrs()) > 0 {
 			log.Debugf("Host %s now has a public address (%s). Starting holepunch protocol.", s.host.ID(), s.host.Addrs())
 			s.host.SetStreamHandler(Protocol, s.handleNewStream)
 			break
@@ -159,13 +177,73 @@ func (s *Service) waitForPublicAddr() {
 		// service is closed
 		return
 	}
-	s.holePuncher = newHolePuncher(s.host, s.ids, s.listenAddrs, s.tracer, s.filter)
+	s.holePuncher = newHolePuncher(s.host, s.ids, s.ownAddrs, s.tracer, s.filter)
 	s.holePuncher.directDialTimeout = s.directDialTimeout
 	s.holePuncher.legacyBehavior = s.legacyBehavior
+	s.holePuncher.portPredictionProbes = s.portPredictionProbes
+	s.holePuncher.endpointDependentNAT = s.endpointDependentNAT
 	s.holePuncherMx.Unlock()
 	close(s.hasPublicAddrsChan)
 }
 
+func (s *Service) watchNATMappings(sub event.Subscription) {
+	defer s.refCount.Done()
+	defer sub.Close()
+
+	for {
+		select {
+		case e, ok := <-sub.Out():
+			if !ok {
+				return
+			}
+			s.natMappingsMx.Lock()
+			s.natMappings = e.(event.EvtNATMappingsChanged).Mappings
+			s.natMappingsMx.Unlock()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// currentNATMappings returns the NAT mappings of our listen addresses. We ask
+// identify for them if we can, as EvtNATMappingsChanged is rate limited.
+func (s *Service) currentNATMappings() []network.NATMapping {
+	if nms, ok := s.ids.(identify.NATMappingsService); ok {
+		return nms.NATMappings()
+	}
+	s.natMappingsMx.Lock()
+	defer s.natMappingsMx.Unlock()
+	return s.natMappings
+}
+
+// ownAddrs returns the addresses we send to the remote peer in the CONNECT
+// message. With port prediction, they include the external ports our NAT
+// allocated for different destinations, see mappedAddrs.
+func (s *Service) ownAddrs() []ma.Multiaddr {
+	addrs := s.listenAddrs()
+	if s.portPredictionProbes == 0 {
+		return addrs
+	}
+	return ma.Unique(append(slices.Clip(addrs), mappedAddrs(s.currentNATMappings())...))
+}
+
+// endpointDependentNAT returns true if we know the NAT mappings of our listen
+// addresses and all of them are endpoint dependent. The external ports we
+// advertise are then useless to the remote peer, and hole punching can only
+// succeed with port prediction.
+func (s *Service) endpointDependentNAT() bool {
+	mappings := s.currentNATMappings()
+	if len(mappings) == 0 {
+		return false
+	}
+	for _, m := range mappings {
+		if m.DeviceType != network.NATDeviceTypeSymmetric {
+			return false
+		}
+	}
+	return true
+}
+
 // Close closes the Hole Punch Service.
 func (s *Service) Close() error {
 	var err error
@@ -186,7 +264,7 @@ func (s *Service) incomingHolePunch(str network.Stream) (rtt time.Duration, remo
 	if !isRelayAddress(str.Conn().RemoteMultiaddr()) {
 		return 0, nil, nil, fmt.Errorf("received hole punch stream: %s", str.Conn().RemoteMultiaddr())
 	}
-	ownAddrs = s.listenAddrs()
+	ownAddrs = s.ownAddrs()
 	if s.filter != nil {
 		ownAddrs = s.filter.FilterLocal(str.Conn().RemotePeer(), ownAddrs)
 	}
@@ -275,11 +353,15 @@ func (s *Service) handleNewStream(str network.Stream) {
 	str.Close()
 
 	// Hole punch now by forcing a connect
+	var predicted []ma.Multiaddr
+	if s.portPredictionProbes > 0 {
+		predicted = predictAddrs(addrs, s.portPredictionProbes)
+	}
 	pi := peer.AddrInfo{
 		ID:    rp,
 		Addrs: addrs,
 	}
-	s.tracer.StartHolePunch(rp, addrs, rtt)
+	s.tracer.StartHolePunch(rp, append(slices.Clip(addrs), predicted...), rtt)
 	log.Debugw("starting hole punch", "peer", rp)
 	start := time.Now()
 	s.tracer.HolePunchAttempt(pi.ID)
@@ -288,11 +370,12 @@ func (s *Service) handleNewStream(str network.Stream) {
 	if s.legacyBehavior {
 		isClient = true
 	}
-	err = holePunchConnect(ctx, s.host, pi, isClient)
+	err = holePunchConnect(ctx, s.host, pi, predicted, isClient)
 	cancel()
 	dt := time.Since(start)
-	s.tracer.EndHolePunch(rp, dt, err)
-	s.tracer.HolePunchFinished("receiver", 1, addrs, ownAddrs, getDirectConnection(s.host, rp))
+	directConn := getDirectConnection(s.host, rp)
+	s.tracer.EndHolePunch(rp, dt, err, holePunchStrategy(remoteAddr(directConn), predicted))
+	s.tracer.HolePunchFinished("receiver", 1, addrs, ownAddrs, directConn)
 }
 
 // DirectConnect is only exposed for testing purposes.
@@ -0,0 +1,73 @@
+package holepunch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	bhost "github.com/libp2p/go-libp2p/p2p/host/blank"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func natMapping(deviceType network.NATDeviceType) network.NATMapping {
+	return network.NATMapping{
+		LocalAddr:         ma.StringCast("/ip4/192.168.1.2/udp/4001"),
+		TransportProtocol: network.NATTransportUDP,
+		DeviceType:        deviceType,
+	}
+}
+
+func TestEndpointDependentNAT(t *testing.T) {
+	h := bhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h.Close()
+
+	em, err := h.EventBus().Emitter(new(event.EvtNATMappingsChanged), eventbus.Stateful)
+	require.NoError(t, err)
+	defer em.Close()
+	require.NoError(t, em.Emit(event.EvtNATMappingsChanged{Mappings: []network.NATMapping{
+		natMapping(network.NATDeviceTypeSymmetric),
+	}}))
+
+	s, err := NewService(h, struct{ identify.IDService }{}, func() []ma.Multiaddr { return nil })
+	require.NoError(t, err)
+	defer s.Close()
+	require.Eventually(t, s.endpointDependentNAT, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, em.Emit(event.EvtNATMappingsChanged{Mappings: []network.NATMapping{
+		natMapping(network.NATDeviceTypeSymmetric),
+		natMapping(network.NATDeviceTypeCone),
+	}}))
+	require.Eventually(t, func() bool { return !s.endpointDependentNAT() }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, em.Emit(event.EvtNATMappingsChanged{}))
+	require.Eventually(t, func() bool {
+		s.natMappingsMx.Lock()
+		defer s.natMappingsMx.Unlock()
+		return s.natMappings == nil
+	}, time.Second, 10*time.Millisecond)
+	require.False(t, s.endpointDependentNAT())
+}
+
+func TestNoHolePunchBehindSymmetricNAT(t *testing.T) {
+	h := bhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h.Close()
+	p := peer.ID("remote")
+
+	hp := newHolePuncher(h, struct{ identify.IDService }{}, func() []ma.Multiaddr { return nil }, nil, nil)
+	defer hp.Close()
+	hp.endpointDependentNAT = func() bool { return true }
+	require.ErrorIs(t, hp.DirectConnect(p), ErrEndpointDependentNAT)
+
+	// with port prediction, we try anyway
+	hp.portPredictionProbes = maxPortPredictionProbes
+	err := hp.DirectConnect(p)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrEndpointDependentNAT)
+}
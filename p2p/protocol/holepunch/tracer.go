@@ -114,6 +114,9 @@ type EndHolePunchEvt struct {
 	Success      bool
 	EllapsedTime time.Duration
 	Error        string `json:",omitempty"`
+	// Strategy is the strategy that established the direct connection, see
+	// StrategyObservedAddrs and StrategyPortPrediction.
+	Strategy string `json:",omitempty"`
 }
 
 type HolePunchAttemptEvt struct {
@@ -202,7 +205,7 @@ func (t *tracer) StartHolePunch(p peer.ID, obsAddrs []ma.Multiaddr, rtt time.Dur
 	}
 }
 
-func (t *tracer) EndHolePunch(p peer.ID, dt time.Duration, err error) {
+func (t *tracer) EndHolePunch(p peer.ID, dt time.Duration, err error, strategy string) {
 	if t != nil && t.et != nil {
 		evt := &EndHolePunchEvt{
 			Success:      err == nil,
@@ -210,6 +213,8 @@ func (t *tracer) EndHolePunch(p peer.ID, dt time.Duration, err error) {
 		}
 		if err != nil {
 			evt.Error = err.Error()
+		} else {
+			evt.Strategy = strategy
 		}
 
 		t.et.Trace(&Event{
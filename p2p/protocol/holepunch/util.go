@@ -48,11 +48,23 @@ func getDirectConnection(h host.Host, p peer.ID) network.Conn {
 	return nil
 }
 
-func holePunchConnect(ctx context.Context, host host.Host, pi peer.AddrInfo, isClient bool) error {
+func remoteAddr(c network.Conn) ma.Multiaddr {
+	if c == nil {
+		return nil
+	}
+	return c.RemoteMultiaddr()
+}
+
+// holePunchConnect dials the exchanged addresses in pi and the predicted
+// addresses, without adding the latter to the peerstore.
+func holePunchConnect(ctx context.Context, host host.Host, pi peer.AddrInfo, predicted []ma.Multiaddr, isClient bool) error {
 	holePunchCtx := network.WithSimultaneousConnect(ctx, isClient, "hole-punching")
 	forceDirectConnCtx := network.WithForceDirectDial(holePunchCtx, "hole-punching")
+	if len(predicted) > 0 {
+		forceDirectConnCtx = network.WithExtraDialAddrs(forceDirectConnCtx, predicted, portPredictionStagger)
+	}
 
-	log.Debugw("holepunchConnect", "host", host.ID(), "peer", pi.ID, "addrs", pi.Addrs)
+	log.Debugw("holepunchConnect", "host", host.ID(), "peer", pi.ID, "addrs", pi.Addrs, "predicted", predicted)
 	if err := host.Connect(forceDirectConnCtx, pi); err != nil {
 		log.Debugw("hole punch attempt with peer failed", "peer ID", pi.ID, "error", err)
 		return err
@@ -0,0 +1,270 @@
+package identify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/record"
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify/pb"
+
+	"github.com/libp2p/go-msgio/pbio"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// diffAddrs computes which addresses were added and removed in b.
+func diffAddrs(a, b []ma.Multiaddr) (added, removed []ma.Multiaddr) {
+	inA := make(map[string]struct{}, len(a))
+	for _, x := range a {
+		inA[string(x.Bytes())] = struct{}{}
+	}
+	inB := make(map[string]struct{}, len(b))
+	for _, x := range b {
+		inB[string(x.Bytes())] = struct{}{}
+		if _, ok := inA[string(x.Bytes())]; !ok {
+			added = append(added, x)
+		}
+	}
+	for _, x := range a {
+		if _, ok := inB[string(x.Bytes())]; !ok {
+			removed = append(removed, x)
+		}
+	}
+	return added, removed
+}
+
+// createDelta creates the delta message from the base snapshot, which the
+// peer on the other side of the connection acknowledged, to the snapshot.
+func (ids *idService) createDelta(conn network.Conn, base, snapshot *identifySnapshot) *pb.Delta {
+	mes := &pb.Delta{}
+
+	added, removed := diff(base.protocols, snapshot.protocols)
+	mes.AddedProtocols = protocol.ConvertToStrings(added)
+	mes.RemovedProtocols = protocol.ConvertToStrings(removed)
+
+	// Same filtering as in createBaseIdentifyResponse.
+	viaLoopback := manet.IsIPLoopback(conn.LocalMultiaddr()) || manet.IsIPLoopback(conn.RemoteMultiaddr())
+	addedAddrs, removedAddrs := diffAddrs(base.addrs, snapshot.addrs)
+	for _, a := range addedAddrs {
+		if viaLoopback || !manet.IsIPLoopback(a) {
+			mes.AddedListenAddrs = append(mes.AddedListenAddrs, a.Bytes())
+		}
+	}
+	for _, a := range removedAddrs {
+		mes.RemovedListenAddrs = append(mes.RemovedListenAddrs, a.Bytes())
+	}
+
+	if snapshot.record != nil && (base.record == nil || !base.record.Equal(snapshot.record)) {
+		mes.SignedPeerRecord = ids.getSignedRecord(snapshot)
+	}
+
+	for k, v := range snapshot.extensions {
+		if old, ok := base.extensions[k]; !ok || !bytes.Equal(old, v) {
+			if mes.Extensions == nil {
+				mes.Extensions = make(map[string][]byte)
+			}
+			mes.Extensions[k] = v
+		}
+	}
+	for k := range base.extensions {
+		if _, ok := snapshot.extensions[k]; !ok {
+			mes.RemovedExtensions = append(mes.RemovedExtensions, k)
+		}
+	}
+	return mes
+}
+
+// sendDelta sends the changes since the base snapshot to the peer, and waits
+// for the peer to acknowledge them.
+func (ids *idService) sendDelta(ctx context.Context, c network.Conn, base, snapshot *identifySnapshot) error {
+	s, err := newStreamAndNegotiate(ctx, c, IDDelta, ids.timeout)
+	if err != nil {
+		return err
+	}
+	if err := s.Scope().SetService(ServiceName); err != nil {
+		s.Reset()
+		return fmt.Errorf("failed to attaching stream to identify service: %w", err)
+	}
+
+	mes := ids.createDelta(c, base, snapshot)
+	if err := pbio.NewDelimitedWriter(s).WriteMsg(mes); err != nil {
+		s.Reset()
+		return err
+	}
+	if err := s.CloseWrite(); err != nil {
+		s.Reset()
+		return err
+	}
+	// The peer closes the stream once it applied the delta.
+	if _, err := s.Read(make([]byte, 1)); err != io.EOF {
+		s.Reset()
+		if err == nil {
+			err = errors.New("unexpected data")
+		}
+		return fmt.Errorf("delta not acknowledged: %w", err)
+	}
+	s.Close()
+
+	if dmt, ok := ids.metricsTracer.(DeltaMetricsTracer); ok {
+		dmt.DeltaSent(len(mes.AddedProtocols)+len(mes.RemovedProtocols), len(mes.AddedListenAddrs)+len(mes.RemovedListenAddrs))
+	}
+
+	ids.connsMu.Lock()
+	defer ids.connsMu.Unlock()
+	if e, ok := ids.conns[c]; ok {
+		e.Sequence = snapshot.seq
+		e.Snapshot = snapshot
+		ids.conns[c] = e
+	}
+	return nil
+}
+
+// handleDelta handles incoming identify delta streams.
+func (ids *idService) handleDelta(s network.Stream) {
+	_ = s.SetDeadline(time.Now().Add(ids.timeout))
+	if err := s.Scope().SetService(ServiceName); err != nil {
+		log.Warnf("error attaching stream to identify service: %s", err)
+		s.Reset()
+		return
+	}
+	if err := s.Scope().ReserveMemory(signedIDSize, network.ReservationPriorityAlways); err != nil {
+		log.Warnf("error reserving memory for identify stream: %s", err)
+		s.Reset()
+		return
+	}
+	defer s.Scope().ReleaseMemory(signedIDSize)
+
+	mes := &pb.Delta{}
+	if err := pbio.NewDelimitedReader(s, signedIDSize).ReadMsg(mes); err != nil {
+		log.Debugw("error reading identify delta", "peer", s.Conn().RemotePeer(), "error", err)
+		s.Reset()
+		return
+	}
+	if err := ids.consumeDelta(mes, s.Conn()); err != nil {
+		// Don't acknowledge the delta, so that the peer sends a full push.
+		log.Debugw("error consuming identify delta", "peer", s.Conn().RemotePeer(), "error", err)
+		s.Reset()
+		return
+	}
+	s.Close()
+
+	if dmt, ok := ids.metricsTracer.(DeltaMetricsTracer); ok {
+		dmt.DeltaReceived(len(mes.AddedProtocols)+len(mes.RemovedProtocols), len(mes.AddedListenAddrs)+len(mes.RemovedListenAddrs))
+	}
+}
+
+func parseAddrs(addrs [][]byte) ([]ma.Multiaddr, error) {
+	res := make([]ma.Multiaddr, 0, len(addrs))
+	for _, b := range addrs {
+		a, err := ma.NewMultiaddrBytes(b)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	return res, nil
+}
+
+// applyAddrsDelta returns a copy of addrs without the removed addresses, and
+// with the added ones.
+func applyAddrsDelta(addrs, added, removed []ma.Multiaddr) []ma.Multiaddr {
+	res := make([]ma.Multiaddr, 0, len(addrs)+len(added))
+	for _, a := range addrs {
+		if !slices.ContainsFunc(removed, a.Equal) {
+			res = append(res, a)
+		}
+	}
+	for _, a := range added {
+		if !slices.ContainsFunc(res, a.Equal) {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+func (ids *idService) consumeDelta(mes *pb.Delta, c network.Conn) error {
+	p := c.RemotePeer()
+	ps := ids.Host.Peerstore()
+
+	addedAddrs, err := parseAddrs(mes.AddedListenAddrs)
+	if err != nil {
+		return err
+	}
+	removedAddrs, err := parseAddrs(mes.RemovedListenAddrs)
+	if err != nil {
+		return err
+	}
+	var signedAddrs []ma.Multiaddr
+	if len(mes.SignedPeerRecord) > 0 {
+		env, _, err := record.ConsumeEnvelope(mes.SignedPeerRecord, peer.PeerRecordEnvelopeDomain)
+		if err != nil {
+			return fmt.Errorf("invalid signed peer record: %w", err)
+		}
+		if signedAddrs, err = ids.consumeSignedPeerRecord(p, env); err != nil {
+			return err
+		}
+	}
+
+	added := protocol.ConvertFromStrings(mes.AddedProtocols)
+	removed := protocol.ConvertFromStrings(mes.RemovedProtocols)
+	if len(added) > 0 {
+		ps.AddProtocols(p, added...)
+	}
+	if len(removed) > 0 {
+		ps.RemoveProtocols(p, removed...)
+	}
+	if len(added) > 0 || len(removed) > 0 {
+		ids.emitters.evtPeerProtocolsUpdated.Emit(event.EvtPeerProtocolsUpdated{
+			Peer:    p,
+			Added:   added,
+			Removed: removed,
+		})
+	}
+
+	if signedAddrs != nil || len(addedAddrs) > 0 || len(removedAddrs) > 0 {
+		ids.connsMu.Lock()
+		e, ok := ids.conns[c]
+		if !ok {
+			ids.connsMu.Unlock()
+			return errors.New("delta received on an unknown connection")
+		}
+		e.ListenAddrs = applyAddrsDelta(e.ListenAddrs, addedAddrs, removedAddrs)
+		e.SignedAddrs = e.SignedAddrs || signedAddrs != nil
+		ids.conns[c] = e
+		ids.connsMu.Unlock()
+
+		// Replace the addresses as a full identify message would. Without a
+		// new signed peer record, the addresses of the last one still hold.
+		switch {
+		case signedAddrs != nil:
+			ids.setPeerAddrs(c, signedAddrs, pstore.AddrSourceSignedRecord)
+		case !e.SignedAddrs:
+			ids.setPeerAddrs(c, e.ListenAddrs, pstore.AddrSourceIdentify)
+		}
+	}
+
+	if len(mes.Extensions) > 0 || len(mes.RemovedExtensions) > 0 {
+		exts := ids.registeredExtensions()
+		for k, v := range mes.Extensions {
+			if ext, ok := exts[k]; ok {
+				ids.consumeExtension(p, k, ext, v)
+			}
+		}
+		for _, k := range mes.RemovedExtensions {
+			if ext, ok := exts[k]; ok {
+				ids.consumeExtension(p, k, ext, nil)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package identify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// maxExtensionsSize is the maximum total size of the keys and values of the
+// extensions we advertise. It keeps our identify message within
+// maxOwnIdentifyMsgSize.
+const maxExtensionsSize = 1024
+
+// ErrExtensionNotRegistered is returned when setting the value of an
+// extension that wasn't registered.
+var ErrExtensionNotRegistered = errors.New("identify extension not registered")
+
+// ExtensionHandler is called when the identify message of a peer carries a
+// value for a registered extension, and with a nil value when the peer stops
+// advertising the extension.
+type ExtensionHandler func(p peer.ID, value []byte)
+
+// ExtensionMetadataKey returns the peerstore metadata key under which the
+// values received for an extension are stored.
+func ExtensionMetadataKey(key string) string {
+	return "identify/ext/" + key
+}
+
+// ExtensionService manages identify extensions. It is implemented by the
+// service returned by NewIDService, and can be obtained from an IDService, such
+// as the one of a BasicHost, with a type assertion.
+type ExtensionService interface {
+	// RegisterExtension registers an extension key, accepting values of up
+	// to maxSize bytes from peers. See ExtensionHandler.
+	RegisterExtension(key string, maxSize int, handler ExtensionHandler) error
+	// SetExtension sets the value we advertise for a registered extension.
+	SetExtension(key string, value []byte) error
+}
+
+var _ ExtensionService = &idService{}
+
+type extension struct {
+	maxSize int
+	handler ExtensionHandler
+	// value is the value we advertise, nil if we don't
+	value []byte
+}
+
+type extensions struct {
+	sync.Mutex
+	m map[string]*extension
+}
+
+// RegisterExtension registers an extension key. Values received from peers
+// for registered keys are stored in the peerstore and passed to the handler,
+// which may be nil. Values for unregistered keys, and values larger than
+// maxSize, are ignored.
+//
+// Extensions should be registered before connecting to peers, as only the
+// identify messages received after registration are processed.
+func (ids *idService) RegisterExtension(key string, maxSize int, handler ExtensionHandler) error {
+	if key == "" {
+		return errors.New("empty extension key")
+	}
+	if maxSize <= 0 || len(key)+maxSize > maxExtensionsSize {
+		return fmt.Errorf("extension size must be between 1 and %d bytes", maxExtensionsSize-len(key))
+	}
+
+	ids.extensions.Lock()
+	defer ids.extensions.Unlock()
+
+	if _, ok := ids.extensions.m[key]; ok {
+		return fmt.Errorf("extension %q already registered", key)
+	}
+	ids.extensions.m[key] = &extension{maxSize: maxSize, handler: handler}
+	return nil
+}
+
+// SetExtension sets the value we advertise for a registered extension, and
+// pushes it to connected peers. An empty value stops advertising the
+// extension.
+func (ids *idService) SetExtension(key string, value []byte) error {
+	ids.extensions.Lock()
+	ext, ok := ids.extensions.m[key]
+	if !ok {
+		ids.extensions.Unlock()
+		return fmt.Errorf("%w: %q", ErrExtensionNotRegistered, key)
+	}
+	if len(value) > ext.maxSize {
+		ids.extensions.Unlock()
+		return fmt.Errorf("extension %q value too large: %d bytes, limit is %d", key, len(value), ext.maxSize)
+	}
+	total := len(key) + len(value)
+	for k, e := range ids.extensions.m {
+		if k != key && e.value != nil {
+			total += len(k) + len(e.value)
+		}
+	}
+	if total > maxExtensionsSize {
+		ids.extensions.Unlock()
+		return fmt.Errorf("extensions too large: %d bytes, limit is %d", total, maxExtensionsSize)
+	}
+	if len(value) == 0 {
+		ext.value = nil
+	} else {
+		ext.value = bytes.Clone(value)
+	}
+	ids.extensions.Unlock()
+
+	if ids.updateSnapshot() {
+		select {
+		case ids.triggerPush <- struct{}{}:
+		default: // we already have one more push queued
+		}
+	}
+	return nil
+}
+
+// ownExtensions returns the extensions we advertise.
+func (ids *idService) ownExtensions() map[string][]byte {
+	ids.extensions.Lock()
+	defer ids.extensions.Unlock()
+
+	var res map[string][]byte
+	for k, e := range ids.extensions.m {
+		if e.value == nil {
+			continue
+		}
+		if res == nil {
+			res = make(map[string][]byte)
+		}
+		res[k] = e.value
+	}
+	return res
+}
+
+// registeredExtensions returns the registered extensions. The returned
+// extensions must not be modified.
+func (ids *idService) registeredExtensions() map[string]*extension {
+	ids.extensions.Lock()
+	defer ids.extensions.Unlock()
+	return maps.Clone(ids.extensions.m)
+}
+
+// consumeExtensions stores the registered extensions received from the peer
+// in a full identify message, and calls their handlers.
+func (ids *idService) consumeExtensions(p peer.ID, received map[string][]byte) {
+	for key, ext := range ids.registeredExtensions() {
+		ids.consumeExtension(p, key, ext, received[key])
+	}
+}
+
+// consumeExtension stores the value of an extension received from the peer,
+// and calls its handler. An empty value means that the peer doesn't advertise
+// the extension.
+func (ids *idService) consumeExtension(p peer.ID, key string, ext *extension, value []byte) {
+	if len(value) > ext.maxSize {
+		log.Debugw("ignoring oversized identify extension", "peer", p, "key", key, "size", len(value))
+		value = nil
+	}
+
+	ps := ids.Host.Peerstore()
+	mkey := ExtensionMetadataKey(key)
+	if len(value) == 0 {
+		// The peer stopped advertising the extension, if it ever did.
+		old, err := ps.Get(p, mkey)
+		if err != nil {
+			return
+		}
+		if b, _ := old.([]byte); len(b) == 0 {
+			return
+		}
+		// Peerstores can't delete metadata, store an empty value.
+		ps.Put(p, mkey, []byte{})
+		value = nil
+	} else {
+		ps.Put(p, mkey, value)
+	}
+	if ext.handler != nil {
+		ext.handler(p, value)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/netip"
 	"slices"
 	"sync"
@@ -20,6 +21,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/core/record"
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
 	useragent "github.com/libp2p/go-libp2p/p2p/protocol/identify/internal/user-agent"
 	"github.com/libp2p/go-libp2p/p2p/protocol/identify/pb"
 	"github.com/libp2p/go-libp2p/x/rate"
@@ -40,6 +42,11 @@ const (
 	// IDPush is the protocol.ID of the Identify push protocol.
 	// It sends full identify messages containing the current state of the peer.
 	IDPush = "/ipfs/id/push/1.0.0"
+	// IDDelta is the protocol.ID of the Identify delta protocol.
+	// It sends the changes since the last state acknowledged by the peer.
+	// Older releases used /p2p/id/delta/1.0.0 for an incompatible message
+	// format, so this protocol must not reuse that ID.
+	IDDelta = "/libp2p/id/delta/2.0.0"
 	// DefaultTimeout for all id interactions, incoming / outgoing, id / id-push.
 	DefaultTimeout = 5 * time.Second
 	// ServiceName is the default identify service name
@@ -73,10 +80,11 @@ var (
 )
 
 type identifySnapshot struct {
-	seq       uint64
-	protocols []protocol.ID
-	addrs     []ma.Multiaddr
-	record    *record.Envelope
+	seq        uint64
+	protocols  []protocol.ID
+	addrs      []ma.Multiaddr
+	record     *record.Envelope
+	extensions map[string][]byte
 }
 
 // Equal says if two snapshots are identical.
@@ -93,6 +101,9 @@ func (s identifySnapshot) Equal(other *identifySnapshot) bool {
 	if !slices.Equal(s.protocols, other.protocols) {
 		return false
 	}
+	if !maps.EqualFunc(s.extensions, other.extensions, bytes.Equal) {
+		return false
+	}
 	if len(s.addrs) != len(other.addrs) {
 		return false
 	}
@@ -123,6 +134,19 @@ type IDService interface {
 	io.Closer
 }
 
+// NATMappingsService reports the analysis of the NAT mappings of our listen
+// addresses. It is implemented by the service returned by NewIDService, and can
+// be obtained from an IDService with a type assertion. Unlike
+// EvtNATMappingsChanged, which is rate limited, it reflects the latest
+// observations.
+type NATMappingsService interface {
+	// NATMappings returns the NAT mappings of our listen addresses, or nil
+	// if the observed address manager is disabled.
+	NATMappings() []network.NATMapping
+}
+
+var _ NATMappingsService = &idService{}
+
 type identifyPushSupport uint8
 
 const (
@@ -141,6 +165,16 @@ type entry struct {
 	PushSupport identifyPushSupport
 	// Sequence is the sequence number of the last snapshot we sent to this peer.
 	Sequence uint64
+	// Snapshot is the last snapshot we sent to this peer, the base of the next delta.
+	Snapshot *identifySnapshot
+	// DeltaSupport says if the peer supports the Identify Delta protocol.
+	DeltaSupport bool
+	// ListenAddrs are the listen addresses the peer last advertised on this
+	// connection, the base of the deltas it sends.
+	ListenAddrs []ma.Multiaddr
+	// SignedAddrs says if we took the peer's addresses from its signed peer
+	// record rather than from ListenAddrs.
+	SignedAddrs bool
 }
 
 // idService is a structure that implements ProtocolIdentify.
@@ -165,6 +199,7 @@ type idService struct {
 	refCount sync.WaitGroup
 
 	disableSignedPeerRecord bool
+	disableDeltaPush        bool
 	timeout                 time.Duration
 
 	connsMu sync.RWMutex
@@ -189,6 +224,10 @@ type idService struct {
 		sync.Mutex
 		snapshot identifySnapshot
 	}
+	// triggerPush queues a push of the current snapshot
+	triggerPush chan struct{}
+
+	extensions extensions
 
 	natEmitter *natEmitter
 
@@ -223,7 +262,9 @@ func NewIDService(h host.Host, opts ...Option) (*idService, error) {
 		ctxCancel:               cancel,
 		conns:                   make(map[network.Conn]entry),
 		disableSignedPeerRecord: cfg.disableSignedPeerRecord,
+		disableDeltaPush:        cfg.disableDeltaPush,
 		setupCompleted:          make(chan struct{}),
+		triggerPush:             make(chan struct{}, 1),
 		metricsTracer:           cfg.metricsTracer,
 		timeout:                 cfg.timeout,
 		rateLimiter: &rate.Limiter{
@@ -236,6 +277,7 @@ func NewIDService(h host.Host, opts ...Option) (*idService, error) {
 			},
 		},
 	}
+	s.extensions.m = make(map[string]*extension)
 
 	var normalize func(ma.Multiaddr) ma.Multiaddr
 	if hn, ok := h.(normalizer); ok {
@@ -278,6 +320,9 @@ func (ids *idService) Start() {
 	ids.Host.Network().Notify((*netNotifiee)(ids))
 	ids.Host.SetStreamHandler(ID, ids.handleIdentifyRequest)
 	ids.Host.SetStreamHandler(IDPush, ids.rateLimiter.Limit(ids.handlePush))
+	if !ids.disableDeltaPush {
+		ids.Host.SetStreamHandler(IDDelta, ids.rateLimiter.Limit(ids.handleDelta))
+	}
 	ids.updateSnapshot()
 	close(ids.setupCompleted)
 
@@ -303,7 +348,6 @@ func (ids *idService) loop(ctx context.Context) {
 	// That way, we can end up with
 	// * this Go routine busy looping over all peers in sendPushes
 	// * another push being queued in the triggerPush channel
-	triggerPush := make(chan struct{}, 1)
 	ids.refCount.Add(1)
 	go func() {
 		defer ids.refCount.Done()
@@ -312,7 +356,7 @@ func (ids *idService) loop(ctx context.Context) {
 			select {
 			case <-ctx.Done():
 				return
-			case <-triggerPush:
+			case <-ids.triggerPush:
 				ids.sendPushes(ctx)
 			}
 		}
@@ -331,7 +375,7 @@ func (ids *idService) loop(ctx context.Context) {
 				ids.metricsTracer.TriggeredPushes(e)
 			}
 			select {
-			case triggerPush <- struct{}{}:
+			case ids.triggerPush <- struct{}{}:
 			default: // we already have one more push queued, no need to queue another one
 			}
 		case <-ctx.Done():
@@ -373,12 +417,20 @@ func (ids *idService) sendPushes(ctx context.Context) {
 		// we haven't, send it now
 		sem <- struct{}{}
 		wg.Add(1)
-		go func(c network.Conn) {
+		go func(c network.Conn, e entry) {
 			defer wg.Done()
 			defer func() { <-sem }()
 			ctx, cancel := context.WithTimeout(ctx, ids.timeout)
 			defer cancel()
 
+			if !ids.disableDeltaPush && e.DeltaSupport && e.Snapshot != nil {
+				err := ids.sendDelta(ctx, c, e.Snapshot, &snapshot)
+				if err == nil {
+					return
+				}
+				log.Debugw("failed to send identify delta, falling back to full push", "peer", c.RemotePeer(), "error", err)
+			}
+
 			str, err := newStreamAndNegotiate(ctx, c, IDPush, ids.timeout)
 			if err != nil { // connection might have been closed recently
 				return
@@ -388,7 +440,7 @@ func (ids *idService) sendPushes(ctx context.Context) {
 				log.Debugw("failed to send identify push", "peer", c.RemotePeer(), "error", err)
 				return
 			}
-		}(c)
+		}(c, e)
 	}
 	wg.Wait()
 }
@@ -418,6 +470,13 @@ func (ids *idService) ObservedAddrsFor(local ma.Multiaddr) []ma.Multiaddr {
 	return ids.observedAddrMgr.AddrsFor(local)
 }
 
+func (ids *idService) NATMappings() []network.NATMapping {
+	if ids.disableObservedAddrManager {
+		return nil
+	}
+	return ids.observedAddrMgr.NATMappings()
+}
+
 // IdentifyConn runs the Identify protocol on a connection.
 // It returns when we've received the peer's Identify message (or the request fails).
 // If successful, the peer store will contain the peer's addresses and supported protocols.
@@ -555,6 +614,7 @@ func (ids *idService) sendIdentifyResp(s network.Stream, isPush bool) error {
 		return nil
 	}
 	e.Sequence = snapshot.seq
+	e.Snapshot = &snapshot
 	ids.conns[s.Conn()] = e
 	return nil
 }
@@ -610,6 +670,8 @@ func (ids *idService) handleIdentifyResponse(s network.Stream, isPush bool) erro
 	if ids.metricsTracer != nil {
 		ids.metricsTracer.ConnPushSupport(e.PushSupport)
 	}
+	sup, err = ids.Host.Peerstore().SupportsProtocols(c.RemotePeer(), IDDelta)
+	e.DeltaSupport = err == nil && len(sup) > 0
 
 	ids.conns[c] = e
 	return nil
@@ -638,15 +700,21 @@ func (ids *idService) updateSnapshot() (updated bool) {
 	addrs := ids.Host.Addrs()
 	slices.SortFunc(addrs, func(a, b ma.Multiaddr) int { return bytes.Compare(a.Bytes(), b.Bytes()) })
 
+	exts := ids.ownExtensions()
+
 	usedSpace := len(ids.ProtocolVersion) + len(ids.UserAgent)
 	for i := 0; i < len(protos); i++ {
 		usedSpace += len(protos[i])
 	}
+	for k, v := range exts {
+		usedSpace += len(k) + len(v)
+	}
 	addrs = trimHostAddrList(addrs, maxOwnIdentifyMsgSize-usedSpace-256) // 256 bytes of buffer
 
 	snapshot := identifySnapshot{
-		addrs:     addrs,
-		protocols: protos,
+		addrs:      addrs,
+		protocols:  protos,
+		extensions: exts,
 	}
 
 	if !ids.disableSignedPeerRecord {
@@ -734,6 +802,8 @@ func (ids *idService) createBaseIdentifyResponse(conn network.Conn, snapshot *id
 	mes.ProtocolVersion = &ids.ProtocolVersion
 	mes.AgentVersion = &ids.UserAgent
 
+	mes.Extensions = snapshot.extensions
+
 	return mes
 }
 
@@ -835,23 +905,6 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 		log.Debugf("error getting peer record from Identify message: %v", err)
 	}
 
-	// Extend the TTLs on the known (probably) good addresses.
-	// Taking the lock ensures that we don't concurrently process a disconnect.
-	ids.addrMu.Lock()
-	ttl := peerstore.RecentlyConnectedAddrTTL
-	switch ids.Host.Network().Connectedness(p) {
-	case network.Limited, network.Connected:
-		ttl = peerstore.ConnectedAddrTTL
-	}
-
-	// Downgrade connected and recently connected addrs to a temporary TTL.
-	for _, ttl := range []time.Duration{
-		peerstore.RecentlyConnectedAddrTTL,
-		peerstore.ConnectedAddrTTL,
-	} {
-		ids.Host.Peerstore().UpdateAddrs(p, ttl, peerstore.TempAddrTTL)
-	}
-
 	var addrs []ma.Multiaddr
 	if signedPeerRecord != nil {
 		signedAddrs, err := ids.consumeSignedPeerRecord(c.RemotePeer(), signedPeerRecord)
@@ -864,18 +917,20 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 	} else {
 		addrs = lmaddrs
 	}
-	addrs = filterAddrs(addrs, c.RemoteMultiaddr())
-	if len(addrs) > connectedPeerMaxAddrs {
-		addrs = addrs[:connectedPeerMaxAddrs]
-	}
 
-	ids.Host.Peerstore().AddAddrs(p, addrs, ttl)
-
-	// Finally, expire all temporary addrs.
-	ids.Host.Peerstore().UpdateAddrs(p, peerstore.TempAddrTTL, 0)
-	ids.addrMu.Unlock()
+	src := pstore.AddrSourceIdentify
+	if signedPeerRecord != nil {
+		src = pstore.AddrSourceSignedRecord
+	}
+	ids.setPeerAddrs(c, addrs, src)
 
-	log.Debugf("%s received listen addrs for %s: %s", c.LocalPeer(), c.RemotePeer(), addrs)
+	ids.connsMu.Lock()
+	if e, ok := ids.conns[c]; ok {
+		e.ListenAddrs = lmaddrs
+		e.SignedAddrs = signedPeerRecord != nil
+		ids.conns[c] = e
+	}
+	ids.connsMu.Unlock()
 
 	// get protocol versions
 	pv := mes.GetProtocolVersion()
@@ -884,6 +939,8 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 	ids.Host.Peerstore().Put(p, "ProtocolVersion", pv)
 	ids.Host.Peerstore().Put(p, "AgentVersion", av)
 
+	ids.consumeExtensions(p, mes.GetExtensions())
+
 	// get the key from the other side. we may not have it (no-auth transport)
 	ids.consumeReceivedPubKey(c, mes.PublicKey)
 
@@ -899,6 +956,42 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 	})
 }
 
+// setPeerAddrs replaces the addresses of the peer on the other side of the
+// connection by the addresses it advertised.
+func (ids *idService) setPeerAddrs(c network.Conn, addrs []ma.Multiaddr, src pstore.AddrSource) {
+	p := c.RemotePeer()
+
+	// Extend the TTLs on the known (probably) good addresses.
+	// Taking the lock ensures that we don't concurrently process a disconnect.
+	ids.addrMu.Lock()
+	defer ids.addrMu.Unlock()
+
+	ttl := peerstore.RecentlyConnectedAddrTTL
+	switch ids.Host.Network().Connectedness(p) {
+	case network.Limited, network.Connected:
+		ttl = peerstore.ConnectedAddrTTL
+	}
+
+	// Downgrade connected and recently connected addrs to a temporary TTL.
+	for _, ttl := range []time.Duration{
+		peerstore.RecentlyConnectedAddrTTL,
+		peerstore.ConnectedAddrTTL,
+	} {
+		ids.Host.Peerstore().UpdateAddrs(p, ttl, peerstore.TempAddrTTL)
+	}
+
+	addrs = filterAddrs(addrs, c.RemoteMultiaddr())
+	if len(addrs) > connectedPeerMaxAddrs {
+		addrs = addrs[:connectedPeerMaxAddrs]
+	}
+	pstore.AddAddrsWithSource(ids.Host.Peerstore(), p, addrs, ttl, src)
+
+	// Finally, expire all temporary addrs.
+	ids.Host.Peerstore().UpdateAddrs(p, peerstore.TempAddrTTL, 0)
+
+	log.Debugf("%s received listen addrs for %s: %s", c.LocalPeer(), c.RemotePeer(), addrs)
+}
+
 func (ids *idService) consumeSignedPeerRecord(p peer.ID, signedPeerRecord *record.Envelope) ([]ma.Multiaddr, error) {
 	if signedPeerRecord.PublicKey == nil {
 		return nil, errors.New("missing pubkey")
@@ -12,6 +12,7 @@ import (
 	recordPb "github.com/libp2p/go-libp2p/core/record/pb"
 	blhost "github.com/libp2p/go-libp2p/p2p/host/blank"
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify/pb"
 	ma "github.com/multiformats/go-multiaddr"
 	"google.golang.org/protobuf/proto"
 
@@ -206,3 +207,51 @@ func TestIncomingAddrFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestDeltaAddrLimit(t *testing.T) {
+	h1 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC))
+	h2 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC))
+	defer h1.Close()
+	defer h2.Close()
+
+	ids, err := NewIDService(h1)
+	require.NoError(t, err)
+	defer ids.Close()
+
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	conn := h1.Network().ConnsToPeer(h2.ID())[0]
+
+	mes := &pb.Delta{AddedListenAddrs: [][]byte{ma.StringCast("/ip4/1.2.3.4/tcp/1").Bytes()}}
+	// deltas apply to the addresses received on the connection by identify
+	require.Error(t, ids.consumeDelta(mes, conn))
+	ids.connsMu.Lock()
+	ids.conns[conn] = entry{}
+	ids.connsMu.Unlock()
+
+	mes.AddedListenAddrs = nil
+	for i := 0; i < 2*connectedPeerMaxAddrs; i++ {
+		mes.AddedListenAddrs = append(mes.AddedListenAddrs, ma.StringCast(fmt.Sprintf("/ip4/1.2.3.4/tcp/%d", i+1)).Bytes())
+	}
+	require.NoError(t, ids.consumeDelta(mes, conn))
+	require.Len(t, h1.Peerstore().Addrs(h2.ID()), connectedPeerMaxAddrs)
+
+	// further deltas don't grow the address set beyond the limit
+	mes.AddedListenAddrs = [][]byte{ma.StringCast("/ip4/1.2.3.5/tcp/1").Bytes()}
+	require.NoError(t, ids.consumeDelta(mes, conn))
+	require.Len(t, h1.Peerstore().Addrs(h2.ID()), connectedPeerMaxAddrs)
+
+	// removing addresses makes room for the others the peer advertised, and
+	// addresses the peer didn't advertise are expired, as for a full identify.
+	unadvertised := ma.StringCast("/ip4/1.2.3.6/tcp/1")
+	h1.Peerstore().AddAddr(h2.ID(), unadvertised, peerstore.ConnectedAddrTTL)
+	mes = &pb.Delta{}
+	for i := 0; i < connectedPeerMaxAddrs; i++ {
+		mes.RemovedListenAddrs = append(mes.RemovedListenAddrs, ma.StringCast(fmt.Sprintf("/ip4/1.2.3.4/tcp/%d", i+1)).Bytes())
+	}
+	require.NoError(t, ids.consumeDelta(mes, conn))
+	addrs := h1.Peerstore().Addrs(h2.ID())
+	require.Len(t, addrs, connectedPeerMaxAddrs)
+	require.NotContains(t, addrs, unadvertised)
+	require.NotContains(t, addrs, ma.StringCast("/ip4/1.2.3.4/tcp/1"))
+	require.Contains(t, addrs, ma.StringCast(fmt.Sprintf("/ip4/1.2.3.4/tcp/%d", connectedPeerMaxAddrs+1)))
+}
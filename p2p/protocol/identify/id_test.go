@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -616,6 +617,144 @@ func TestSendPush(t *testing.T) {
 	}, time.Second, 10*time.Millisecond)
 }
 
+func TestIdentifyExtensions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h1 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	h2 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	defer h2.Close()
+	defer h1.Close()
+
+	ids1, err := identify.NewIDService(h1)
+	require.NoError(t, err)
+	defer ids1.Close()
+	ids1.Start()
+
+	ids2, err := identify.NewIDService(h2)
+	require.NoError(t, err)
+	defer ids2.Close()
+	ids2.Start()
+
+	type received struct {
+		p     peer.ID
+		value string
+	}
+	roles := make(chan received, 10)
+	require.NoError(t, ids1.RegisterExtension("role", 16, func(p peer.ID, value []byte) {
+		roles <- received{p: p, value: string(value)}
+	}))
+	require.Error(t, ids1.RegisterExtension("role", 16, nil))
+	require.NoError(t, ids1.RegisterExtension("small", 2, nil))
+
+	require.NoError(t, ids2.RegisterExtension("role", 16, nil))
+	require.NoError(t, ids2.RegisterExtension("small", 16, nil))
+	require.NoError(t, ids2.RegisterExtension("region", 16, nil))
+	require.NoError(t, ids2.SetExtension("role", []byte("relay")))
+	require.NoError(t, ids2.SetExtension("small", []byte("too large")))
+	require.NoError(t, ids2.SetExtension("region", []byte("eu")))
+	require.ErrorIs(t, ids2.SetExtension("unknown", []byte("foo")), identify.ErrExtensionNotRegistered)
+	require.Error(t, ids2.SetExtension("role", []byte(randString(17))))
+
+	require.NoError(t, h1.Connect(ctx, peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	ids1.IdentifyConn(h1.Network().ConnsToPeer(h2.ID())[0])
+
+	nextRole := func() received {
+		t.Helper()
+		select {
+		case r := <-roles:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for extension")
+			return received{}
+		}
+	}
+	require.Equal(t, received{p: h2.ID(), value: "relay"}, nextRole())
+	v, err := h1.Peerstore().Get(h2.ID(), identify.ExtensionMetadataKey("role"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("relay"), v)
+	// unregistered and oversized extensions are ignored
+	_, err = h1.Peerstore().Get(h2.ID(), identify.ExtensionMetadataKey("region"))
+	require.ErrorIs(t, err, peerstore.ErrNotFound)
+	_, err = h1.Peerstore().Get(h2.ID(), identify.ExtensionMetadataKey("small"))
+	require.ErrorIs(t, err, peerstore.ErrNotFound)
+
+	// changes are pushed
+	ids2.IdentifyConn(h2.Network().ConnsToPeer(h1.ID())[0])
+	require.NoError(t, ids2.SetExtension("role", []byte("client")))
+	require.Equal(t, received{p: h2.ID(), value: "client"}, nextRole())
+
+	require.NoError(t, ids2.SetExtension("role", nil))
+	require.Equal(t, received{p: h2.ID(), value: ""}, nextRole())
+	v, err = h1.Peerstore().Get(h2.ID(), identify.ExtensionMetadataKey("role"))
+	require.NoError(t, err)
+	require.Empty(t, v)
+}
+
+func TestDeltaPush(t *testing.T) {
+	for _, disableDelta := range []bool{false, true} {
+		t.Run(fmt.Sprintf("delta disabled: %t", disableDelta), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			h1 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+			h2 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+			defer h2.Close()
+			defer h1.Close()
+
+			ids1, err := identify.NewIDService(h1)
+			require.NoError(t, err)
+			defer ids1.Close()
+			ids1.Start()
+
+			var opts []identify.Option
+			if disableDelta {
+				opts = append(opts, identify.DisableDeltaPush())
+			}
+			ids2, err := identify.NewIDService(h2, opts...)
+			require.NoError(t, err)
+			defer ids2.Close()
+			ids2.Start()
+
+			require.NoError(t, ids1.RegisterExtension("role", 16, nil))
+			require.NoError(t, ids2.RegisterExtension("role", 16, nil))
+
+			require.NoError(t, h1.Connect(ctx, peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+			ids1.IdentifyConn(h1.Network().ConnsToPeer(h2.ID())[0])
+			ids2.IdentifyConn(h2.Network().ConnsToPeer(h1.ID())[0])
+
+			// count the full pushes h2 receives
+			var fullPushes atomic.Int32
+			h2.SetStreamHandler(identify.IDPush, func(s network.Stream) {
+				fullPushes.Add(1)
+				s.Reset()
+			})
+
+			h1.SetStreamHandler("/rand/1.0.0", func(network.Stream) {})
+			require.NoError(t, ids1.SetExtension("role", []byte("relay")))
+			if disableDelta {
+				require.Eventually(t, func() bool { return fullPushes.Load() > 0 }, 5*time.Second, 10*time.Millisecond)
+				return
+			}
+			require.Eventually(t, func() bool {
+				sup, err := h2.Peerstore().SupportsProtocols(h1.ID(), "/rand/1.0.0")
+				if err != nil || len(sup) == 0 {
+					return false
+				}
+				v, err := h2.Peerstore().Get(h1.ID(), identify.ExtensionMetadataKey("role"))
+				return err == nil && string(v.([]byte)) == "relay"
+			}, 5*time.Second, 10*time.Millisecond)
+
+			h1.RemoveStreamHandler("/rand/1.0.0")
+			require.Eventually(t, func() bool {
+				sup, err := h2.Peerstore().SupportsProtocols(h1.ID(), "/rand/1.0.0")
+				return err == nil && len(sup) == 0
+			}, 5*time.Second, 10*time.Millisecond)
+			require.Zero(t, fullPushes.Load())
+		})
+	}
+}
+
 func TestLargeIdentifyMessage(t *testing.T) {
 	if race.WithRace() {
 		t.Skip("setting peerstore.RecentlyConnectedAddrTTL is racy")
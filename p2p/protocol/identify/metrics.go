@@ -35,6 +35,14 @@ var (
 		},
 		[]string{"dir"},
 	)
+	identifyDelta = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "identify_delta_total",
+			Help:      "Identify Delta",
+		},
+		[]string{"dir"},
+	)
 	connPushSupportTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: metricNamespace,
@@ -77,6 +85,7 @@ var (
 		pushesTriggered,
 		identify,
 		identifyPush,
+		identifyDelta,
 		connPushSupportTotal,
 		protocolsCount,
 		addrsCount,
@@ -104,9 +113,22 @@ type MetricsTracer interface {
 	IdentifySent(isPush bool, numProtocols int, numAddrs int)
 }
 
+// DeltaMetricsTracer tracks metrics on identify deltas. It is optional: the
+// identify service uses it if its MetricsTracer implements it.
+type DeltaMetricsTracer interface {
+	// DeltaSent tracks metrics on sending an identify delta
+	DeltaSent(numProtocols int, numAddrs int)
+
+	// DeltaReceived tracks metrics on receiving an identify delta
+	DeltaReceived(numProtocols int, numAddrs int)
+}
+
 type metricsTracer struct{}
 
-var _ MetricsTracer = &metricsTracer{}
+var (
+	_ MetricsTracer      = &metricsTracer{}
+	_ DeltaMetricsTracer = &metricsTracer{}
+)
 
 type metricsTracerSetting struct {
 	reg prometheus.Registerer
@@ -186,6 +208,28 @@ func (t *metricsTracer) IdentifyReceived(isPush bool, numProtocols int, numAddrs
 	numAddrsReceived.Observe(float64(numAddrs))
 }
 
+func (t *metricsTracer) DeltaSent(numProtocols int, numAddrs int) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, metricshelper.GetDirection(network.DirOutbound))
+	identifyDelta.WithLabelValues(*tags...).Inc()
+
+	protocolsCount.Set(float64(numProtocols))
+	addrsCount.Set(float64(numAddrs))
+}
+
+func (t *metricsTracer) DeltaReceived(numProtocols int, numAddrs int) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, metricshelper.GetDirection(network.DirInbound))
+	identifyDelta.WithLabelValues(*tags...).Inc()
+
+	numProtocolsReceived.Observe(float64(numProtocols))
+	numAddrsReceived.Observe(float64(numAddrs))
+}
+
 func (t *metricsTracer) ConnPushSupport(support identifyPushSupport) {
 	tags := metricshelper.GetStringSlice()
 	defer metricshelper.PutStringSlice(tags)
@@ -23,11 +23,14 @@ func TestMetricsNoAllocNoCover(t *testing.T) {
 	}
 
 	tr := NewMetricsTracer()
+	dtr := tr.(DeltaMetricsTracer)
 	tests := map[string]func(){
 		"TriggeredPushes":  func() { tr.TriggeredPushes(events[rand.Intn(len(events))]) },
 		"ConnPushSupport":  func() { tr.ConnPushSupport(pushSupport[rand.Intn(len(pushSupport))]) },
 		"IdentifyReceived": func() { tr.IdentifyReceived(rand.Intn(2) == 0, rand.Intn(20), rand.Intn(20)) },
 		"IdentifySent":     func() { tr.IdentifySent(rand.Intn(2) == 0, rand.Intn(20), rand.Intn(20)) },
+		"DeltaReceived":    func() { dtr.DeltaReceived(rand.Intn(20), rand.Intn(20)) },
+		"DeltaSent":        func() { dtr.DeltaSent(rand.Intn(20), rand.Intn(20)) },
 	}
 	for method, f := range tests {
 		allocs := testing.AllocsPerRun(1000, f)
@@ -3,6 +3,7 @@ package identify
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -10,6 +11,8 @@ import (
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+
+	ma "github.com/multiformats/go-multiaddr"
 )
 
 type natEmitter struct {
@@ -24,6 +27,9 @@ type natEmitter struct {
 	currentTCPNATDeviceType  network.NATDeviceType
 	emitNATDeviceTypeChanged event.Emitter
 
+	currentNATMappings     []network.NATMapping
+	emitNATMappingsChanged event.Emitter
+
 	observedAddrMgr *ObservedAddrManager
 }
 
@@ -47,6 +53,12 @@ func newNATEmitter(h host.Host, o *ObservedAddrManager, eventInterval time.Durat
 	}
 	n.emitNATDeviceTypeChanged = emitter
 
+	emitter, err = h.EventBus().Emitter(new(event.EvtNATMappingsChanged), eventbus.Stateful)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create emitter for NATMappings: %s", err)
+	}
+	n.emitNATMappingsChanged = emitter
+
 	n.wg.Add(1)
 	go n.worker()
 	return n, nil
@@ -92,6 +104,10 @@ func (n *natEmitter) worker() {
 }
 
 func (n *natEmitter) maybeNotify() {
+	if mappings := n.observedAddrMgr.NATMappings(); !slices.EqualFunc(mappings, n.currentNATMappings, natMappingEqual) {
+		n.currentNATMappings = mappings
+		n.emitNATMappingsChanged.Emit(event.EvtNATMappingsChanged{Mappings: mappings})
+	}
 	if n.reachability == network.ReachabilityPrivate {
 		tcpNATType, udpNATType := n.observedAddrMgr.getNATType()
 		if tcpNATType != n.currentTCPNATDeviceType {
@@ -111,9 +127,29 @@ func (n *natEmitter) maybeNotify() {
 	}
 }
 
+func natMappingEqual(a, b network.NATMapping) bool {
+	if !a.LocalAddr.Equal(b.LocalAddr) {
+		return false
+	}
+	if (a.ExternalAddr == nil) != (b.ExternalAddr == nil) ||
+		(a.ExternalAddr != nil && !a.ExternalAddr.Equal(b.ExternalAddr)) {
+		return false
+	}
+	return a.TransportProtocol == b.TransportProtocol &&
+		a.IPv6 == b.IPv6 &&
+		a.DeviceType == b.DeviceType &&
+		a.Behavior == b.Behavior &&
+		a.Observations == b.Observations &&
+		a.Confidence == b.Confidence &&
+		a.Hairpinning == b.Hairpinning &&
+		a.CGNAT == b.CGNAT &&
+		slices.EqualFunc(a.ObservedAddrs, b.ObservedAddrs, ma.Multiaddr.Equal)
+}
+
 func (n *natEmitter) Close() {
 	n.cancel()
 	n.wg.Wait()
 	n.reachabilitySub.Close()
 	n.emitNATDeviceTypeChanged.Close()
+	n.emitNATMappingsChanged.Close()
 }
@@ -7,6 +7,7 @@ import (
 	"slices"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
 
@@ -27,6 +28,15 @@ var observedAddrManagerWorkerChannelSize = 16
 
 const maxExternalThinWaistAddrsPerLocalAddr = 3
 
+// hairpinObserverThresh is the number of distinct hairpinned connections, i.e.
+// connections from distinct external addresses of our NAT device, needed
+// within hairpinTTL to report that the NAT device supports hairpinning.
+const hairpinObserverThresh = 3
+
+// hairpinTTL is how long a hairpinned connection counts towards
+// hairpinObserverThresh.
+var hairpinTTL = 30 * time.Minute
+
 // thinWaist is a struct that stores the address along with it's thin waist prefix and rest of the multiaddr
 type thinWaist struct {
 	Addr, TW, Rest ma.Multiaddr
@@ -166,6 +176,8 @@ type ObservedAddrManager struct {
 	// localMultiaddr => thin waist form with the count of the connections the multiaddr
 	// was seen on for tracking our local listen addresses
 	localAddrs map[string]*thinWaistWithCount
+	// local thin waist => remote addr of a hairpinned connection => when it was seen
+	hairpinned map[string]map[string]time.Time
 }
 
 // NewObservedAddrManager returns a new address manager using peerstore.OwnObservedAddressTTL as the TTL.
@@ -178,6 +190,7 @@ func NewObservedAddrManager(listenAddrs, hostAddrs func() []ma.Multiaddr,
 		externalAddrs:        make(map[string]map[string]*observerSet),
 		connObservedTWAddrs:  make(map[connMultiaddrs]ma.Multiaddr),
 		localAddrs:           make(map[string]*thinWaistWithCount),
+		hairpinned:           make(map[string]map[string]time.Time),
 		wch:                  make(chan observation, observedAddrManagerWorkerChannelSize),
 		addrRecordedNotif:    make(chan struct{}, 1),
 		listenAddrs:          listenAddrs,
@@ -466,6 +479,50 @@ func (o *ObservedAddrManager) recordObservationUnlocked(conn connMultiaddrs, loc
 	}
 	o.connObservedTWAddrs[conn] = observedTW.TW
 	o.addExternalAddrsUnlocked(observedTW.TW, observer, localTWStr, observedTWStr)
+	if isHairpinned(conn, observedTW.TW) {
+		o.recordHairpinUnlocked(localTWStr, string(conn.RemoteMultiaddr().Bytes()), time.Now())
+	}
+}
+
+func (o *ObservedAddrManager) recordHairpinUnlocked(localTWStr, remote string, now time.Time) {
+	seen, ok := o.hairpinned[localTWStr]
+	if !ok {
+		seen = make(map[string]time.Time)
+		o.hairpinned[localTWStr] = seen
+	}
+	for r, t := range seen {
+		if now.Sub(t) >= hairpinTTL {
+			delete(seen, r)
+		}
+	}
+	seen[remote] = now
+}
+
+// hairpinningUnlocked returns whether enough distinct hairpinned connections
+// were recently seen for the local thin waist address.
+func (o *ObservedAddrManager) hairpinningUnlocked(localTWStr string, now time.Time) bool {
+	n := 0
+	for _, t := range o.hairpinned[localTWStr] {
+		if now.Sub(t) < hairpinTTL {
+			n++
+		}
+	}
+	return n >= hairpinObserverThresh
+}
+
+// isHairpinned returns true if the connection went out and came back in
+// through the NAT device: the peer connected from the external IP it observed
+// for us, so it is behind the same NAT device.
+func isHairpinned(conn connMultiaddrs, observedTW ma.Multiaddr) bool {
+	remoteIP, err := manet.ToIP(conn.RemoteMultiaddr())
+	if err != nil {
+		return false
+	}
+	observedIP, err := manet.ToIP(observedTW)
+	if err != nil {
+		return false
+	}
+	return remoteIP.Equal(observedIP)
 }
 
 func (o *ObservedAddrManager) removeExternalAddrsUnlocked(observer, localTWStr, observedTWStr string) {
@@ -482,6 +539,7 @@ func (o *ObservedAddrManager) removeExternalAddrsUnlocked(observer, localTWStr,
 	}
 	if len(o.externalAddrs[localTWStr]) == 0 {
 		delete(o.externalAddrs, localTWStr)
+		delete(o.hairpinned, localTWStr)
 	}
 }
 
@@ -545,7 +603,6 @@ func (o *ObservedAddrManager) getNATType() (tcpNATType, udpNATType network.NATDe
 	defer o.mu.RUnlock()
 
 	var tcpCounts, udpCounts []int
-	var tcpTotal, udpTotal int
 	for _, m := range o.externalAddrs {
 		isTCP := false
 		for _, v := range m {
@@ -557,42 +614,125 @@ func (o *ObservedAddrManager) getNATType() (tcpNATType, udpNATType network.NATDe
 		for _, v := range m {
 			if isTCP {
 				tcpCounts = append(tcpCounts, len(v.ObservedBy))
-				tcpTotal += len(v.ObservedBy)
 			} else {
 				udpCounts = append(udpCounts, len(v.ObservedBy))
-				udpTotal += len(v.ObservedBy)
 			}
 		}
 	}
+	return classifyNATDeviceType(tcpCounts), classifyNATDeviceType(udpCounts)
+}
 
-	sort.Sort(sort.Reverse(sort.IntSlice(tcpCounts)))
-	sort.Sort(sort.Reverse(sort.IntSlice(udpCounts)))
+// classifyNATDeviceType classifies the NAT device from the number of observers
+// of each of the external addresses.
+func classifyNATDeviceType(counts []int) network.NATDeviceType {
+	sort.Sort(sort.Reverse(sort.IntSlice(counts)))
 
-	tcpTopCounts, udpTopCounts := 0, 0
-	for i := 0; i < maxExternalThinWaistAddrsPerLocalAddr && i < len(tcpCounts); i++ {
-		tcpTopCounts += tcpCounts[i]
+	total, topCounts := 0, 0
+	for i, c := range counts {
+		total += c
+		if i < maxExternalThinWaistAddrsPerLocalAddr {
+			topCounts += c
+		}
 	}
-	for i := 0; i < maxExternalThinWaistAddrsPerLocalAddr && i < len(udpCounts); i++ {
-		udpTopCounts += udpCounts[i]
+	if total < 3*maxExternalThinWaistAddrsPerLocalAddr {
+		return network.NATDeviceTypeUnknown
 	}
-
 	// If the top elements cover more than 1/2 of all the observations, there's a > 50% chance that
 	// hole punching based on outputs of observed address manager will succeed
-	if tcpTotal >= 3*maxExternalThinWaistAddrsPerLocalAddr {
-		if tcpTopCounts >= tcpTotal/2 {
-			tcpNATType = network.NATDeviceTypeCone
-		} else {
-			tcpNATType = network.NATDeviceTypeSymmetric
+	if topCounts >= total/2 {
+		return network.NATDeviceTypeCone
+	}
+	return network.NATDeviceTypeSymmetric
+}
+
+// cgnatNet is the shared address space used by carrier grade NATs, RFC 6598.
+var cgnatNet = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isCGNAT(a ma.Multiaddr) bool {
+	ip, err := manet.ToIP(a)
+	if err != nil {
+		return false
+	}
+	return cgnatNet.Contains(ip)
+}
+
+func portOf(tw ma.Multiaddr) string {
+	if p, err := tw.ValueForProtocol(ma.P_TCP); err == nil {
+		return p
+	}
+	p, _ := tw.ValueForProtocol(ma.P_UDP)
+	return p
+}
+
+// NATMappings returns the analysis of the NAT mappings of the listen addresses
+// peers observed, sorted by local address.
+func (o *ObservedAddrManager) NATMappings() []network.NATMapping {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	now := time.Now()
+	res := make([]network.NATMapping, 0, len(o.externalAddrs))
+	for localTWStr, m := range o.externalAddrs {
+		localTW, err := ma.NewMultiaddrBytes([]byte(localTWStr))
+		if err != nil {
+			continue
 		}
+		res = append(res, natMapping(localTW, m, o.hairpinningUnlocked(localTWStr, now)))
 	}
-	if udpTotal >= 3*maxExternalThinWaistAddrsPerLocalAddr {
-		if udpTopCounts >= udpTotal/2 {
-			udpNATType = network.NATDeviceTypeCone
-		} else {
-			udpNATType = network.NATDeviceTypeSymmetric
+	slices.SortFunc(res, func(a, b network.NATMapping) int { return a.LocalAddr.Compare(b.LocalAddr) })
+	return res
+}
+
+func natMapping(localTW ma.Multiaddr, observerSets map[string]*observerSet, hairpinned bool) network.NATMapping {
+	mapping := network.NATMapping{
+		LocalAddr:         localTW,
+		TransportProtocol: network.NATTransportUDP,
+		Hairpinning:       hairpinned,
+	}
+	if _, err := localTW.ValueForProtocol(ma.P_TCP); err == nil {
+		mapping.TransportProtocol = network.NATTransportTCP
+	}
+	if _, err := localTW.ValueForProtocol(ma.P_IP6); err == nil {
+		mapping.IPv6 = true
+	}
+
+	localPort := portOf(localTW)
+	counts := make([]int, 0, len(observerSets))
+	var top *observerSet
+	preserved := 0
+	for _, s := range observerSets {
+		n := len(s.ObservedBy)
+		counts = append(counts, n)
+		mapping.Observations += n
+		mapping.ObservedAddrs = append(mapping.ObservedAddrs, s.ObservedTWAddr)
+		if portOf(s.ObservedTWAddr) == localPort {
+			preserved += n
+		}
+		// keep the result stable for equal counts by using the smaller address
+		if top == nil || n > len(top.ObservedBy) ||
+			(n == len(top.ObservedBy) && s.ObservedTWAddr.Compare(top.ObservedTWAddr) < 0) {
+			top = s
 		}
 	}
-	return
+	slices.SortFunc(mapping.ObservedAddrs, func(a, b ma.Multiaddr) int { return a.Compare(b) })
+	if top != nil {
+		mapping.ExternalAddr = top.ObservedTWAddr
+		mapping.Confidence = float64(len(top.ObservedBy)) / float64(mapping.Observations)
+	}
+
+	mapping.DeviceType = classifyNATDeviceType(counts)
+	switch {
+	case mapping.DeviceType == network.NATDeviceTypeUnknown:
+	case 2*preserved >= mapping.Observations:
+		mapping.Behavior = network.NATMappingPortPreserving
+	case mapping.DeviceType == network.NATDeviceTypeCone:
+		mapping.Behavior = network.NATMappingConsistent
+	default:
+		mapping.Behavior = network.NATMappingRandom
+	}
+
+	mapping.CGNAT = isCGNAT(localTW) || (mapping.ExternalAddr != nil && isCGNAT(mapping.ExternalAddr))
+	return mapping
 }
 
 func (o *ObservedAddrManager) Close() error {
@@ -466,6 +466,98 @@ func TestObservedAddrManager(t *testing.T) {
 			return checkAllEntriesRemoved(o)
 		}, 1*time.Second, 100*time.Millisecond)
 	})
+	t.Run("NATMappings", func(t *testing.T) {
+		o := newObservedAddrMgr()
+		defer o.Close()
+		const N = 20
+		for i := 0; i < N; i++ {
+			// port preserving
+			o.Record(newConn(tcp4ListenAddr, ma.StringCast(fmt.Sprintf("/ip4/1.2.3.%d/tcp/1", i))),
+				ma.StringCast("/ip4/2.2.2.2/tcp/1"))
+			// consistent port
+			o.Record(newConn(quic4ListenAddr, ma.StringCast(fmt.Sprintf("/ip4/1.2.3.%d/udp/1/quic-v1", i))),
+				ma.StringCast("/ip4/2.2.2.2/udp/2/quic-v1"))
+			// a different port for every destination
+			o.Record(newConn(tcp6ListenAddr, ma.StringCast(fmt.Sprintf("/ip6/2001:db8:%x::1/tcp/1", i))),
+				ma.StringCast(fmt.Sprintf("/ip6/2005::1/tcp/%d", 1000+i)))
+			time.Sleep(10 * time.Millisecond)
+		}
+		var mappings []network.NATMapping
+		require.Eventually(t, func() bool {
+			mappings = o.NATMappings()
+			return len(mappings) == 3 && mappings[0].Observations+mappings[1].Observations+mappings[2].Observations == 3*N
+		}, 1*time.Second, 10*time.Millisecond)
+
+		tcp4 := mappings[slices.IndexFunc(mappings, func(m network.NATMapping) bool { return m.LocalAddr.Equal(tcp4ListenAddr) })]
+		require.Equal(t, network.NATTransportTCP, tcp4.TransportProtocol)
+		require.False(t, tcp4.IPv6)
+		require.Equal(t, network.NATDeviceTypeCone, tcp4.DeviceType)
+		require.Equal(t, network.NATMappingPortPreserving, tcp4.Behavior)
+		require.True(t, tcp4.ExternalAddr.Equal(ma.StringCast("/ip4/2.2.2.2/tcp/1")))
+		require.Equal(t, 1.0, tcp4.Confidence)
+		require.False(t, tcp4.Hairpinning)
+		require.False(t, tcp4.CGNAT)
+
+		quic4 := mappings[slices.IndexFunc(mappings, func(m network.NATMapping) bool { return m.LocalAddr.Equal(ma.StringCast("/ip4/0.0.0.0/udp/1")) })]
+		require.Equal(t, network.NATTransportUDP, quic4.TransportProtocol)
+		require.Equal(t, network.NATDeviceTypeCone, quic4.DeviceType)
+		require.Equal(t, network.NATMappingConsistent, quic4.Behavior)
+
+		tcp6 := mappings[slices.IndexFunc(mappings, func(m network.NATMapping) bool { return m.LocalAddr.Equal(tcp6ListenAddr) })]
+		require.True(t, tcp6.IPv6)
+		require.Equal(t, network.NATDeviceTypeSymmetric, tcp6.DeviceType)
+		require.Equal(t, network.NATMappingRandom, tcp6.Behavior)
+		require.Equal(t, 1.0/N, tcp6.Confidence)
+		// the external port allocated for every destination
+		require.Len(t, tcp6.ObservedAddrs, N)
+		require.True(t, tcp6.ObservedAddrs[0].Equal(ma.StringCast("/ip6/2005::1/tcp/1000")))
+		require.Equal(t, []ma.Multiaddr{ma.StringCast("/ip4/2.2.2.2/tcp/1")}, tcp4.ObservedAddrs)
+	})
+	t.Run("NATMappings hairpinning and CGNAT", func(t *testing.T) {
+		o := newObservedAddrMgr()
+		defer o.Close()
+		// peers behind the same NAT connected through the external address
+		for i := 0; i < hairpinObserverThresh; i++ {
+			o.Record(newConn(tcp4ListenAddr, ma.StringCast(fmt.Sprintf("/ip4/2.2.2.2/tcp/%d", 5+i))), ma.StringCast("/ip4/2.2.2.2/tcp/1"))
+		}
+		o.Record(newConn(quic4ListenAddr, ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")), ma.StringCast("/ip4/100.64.0.1/udp/1/quic-v1"))
+		var mappings []network.NATMapping
+		require.Eventually(t, func() bool {
+			mappings = o.NATMappings()
+			return len(mappings) == 2
+		}, 1*time.Second, 10*time.Millisecond)
+		// sorted by local address
+		require.True(t, mappings[0].LocalAddr.Equal(ma.StringCast("/ip4/0.0.0.0/udp/1")))
+		require.True(t, mappings[0].CGNAT)
+		require.False(t, mappings[0].Hairpinning)
+		require.Equal(t, network.NATDeviceTypeUnknown, mappings[0].DeviceType)
+		require.Equal(t, network.NATMappingUnknown, mappings[0].Behavior)
+		require.True(t, mappings[1].LocalAddr.Equal(tcp4ListenAddr))
+		require.False(t, mappings[1].CGNAT)
+		require.True(t, mappings[1].Hairpinning)
+	})
+	t.Run("NATMappings hairpinning needs several recent observers", func(t *testing.T) {
+		o := newObservedAddrMgr()
+		defer o.Close()
+		local := string(tcp4ListenAddr.Bytes())
+		now := time.Now()
+
+		o.mu.Lock()
+		// the same connection seen repeatedly counts once
+		for i := 0; i < hairpinObserverThresh; i++ {
+			o.recordHairpinUnlocked(local, "a", now)
+		}
+		require.False(t, o.hairpinningUnlocked(local, now))
+		for i := 1; i < hairpinObserverThresh; i++ {
+			o.recordHairpinUnlocked(local, fmt.Sprint(i), now)
+		}
+		require.True(t, o.hairpinningUnlocked(local, now))
+		// the observations expire
+		require.False(t, o.hairpinningUnlocked(local, now.Add(hairpinTTL)))
+		o.recordHairpinUnlocked(local, "b", now.Add(hairpinTTL))
+		require.Len(t, o.hairpinned[local], 1)
+		o.mu.Unlock()
+	})
 	t.Run("Nil Input", func(_ *testing.T) {
 		o := newObservedAddrMgr()
 		defer o.Close()
@@ -497,6 +589,8 @@ func TestObservedAddrManager(t *testing.T) {
 
 		sub, err := bus.Subscribe(new(event.EvtNATDeviceTypeChanged))
 		require.NoError(t, err)
+		mappingsSub, err := bus.Subscribe(new(event.EvtNATMappingsChanged), eventbus.BufSize(64))
+		require.NoError(t, err)
 		observedWebTransport := ma.StringCast("/ip4/2.2.2.2/udp/1/quic-v1/webtransport")
 		inferredQUIC := ma.StringCast("/ip4/2.2.2.2/udp/1/quic-v1")
 		var udpConns [5 * maxExternalThinWaistAddrsPerLocalAddr]connMultiaddrs
@@ -519,6 +613,23 @@ func TestObservedAddrManager(t *testing.T) {
 		evt := e.(event.EvtNATDeviceTypeChanged)
 		require.Equal(t, evt.TransportProtocol, network.NATTransportUDP)
 		require.Equal(t, evt.NatDeviceType, network.NATDeviceTypeCone)
+
+		// the mappings are updated as observations come in
+		timeout := time.After(2 * time.Second)
+		for {
+			var mappings []network.NATMapping
+			select {
+			case e := <-mappingsSub.Out():
+				mappings = e.(event.EvtNATMappingsChanged).Mappings
+			case <-timeout:
+				t.Fatalf("expected NAT mappings event")
+			}
+			require.Len(t, mappings, 1)
+			if mappings[0].DeviceType == network.NATDeviceTypeCone {
+				require.Equal(t, network.NATMappingPortPreserving, mappings[0].Behavior)
+				break
+			}
+		}
 	})
 	t.Run("Many connection many observations IP4 And IP6", func(t *testing.T) {
 		o := newObservedAddrMgr()
@@ -6,6 +6,7 @@ type config struct {
 	protocolVersion            string
 	userAgent                  string
 	disableSignedPeerRecord    bool
+	disableDeltaPush           bool
 	metricsTracer              MetricsTracer
 	disableObservedAddrManager bool
 	timeout                    time.Duration
@@ -37,6 +38,15 @@ func DisableSignedPeerRecord() Option {
 	}
 }
 
+// DisableDeltaPush disables the Identify Delta protocol. Changes are then
+// always pushed as full identify messages, and deltas from peers are not
+// accepted.
+func DisableDeltaPush() Option {
+	return func(cfg *config) {
+		cfg.disableDeltaPush = true
+	}
+}
+
 func WithMetricsTracer(tr MetricsTracer) Option {
 	return func(cfg *config) {
 		cfg.metricsTracer = tr
@@ -46,8 +46,13 @@ type Identify struct {
 	// see github.com/libp2p/go-libp2p/core/record/pb/envelope.proto and
 	// github.com/libp2p/go-libp2p/core/peer/pb/peer_record.proto for message definitions.
 	SignedPeerRecord []byte `protobuf:"bytes,8,opt,name=signedPeerRecord" json:"signedPeerRecord,omitempty"`
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+	// extensions are small application defined values, keyed by names
+	// registered by the applications on both sides.
+	// This field is specific to go-libp2p, hence the field number far from
+	// the ones defined by the spec.
+	Extensions    map[string][]byte `protobuf:"bytes,100,rep,name=extensions" json:"extensions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Identify) Reset() {
@@ -129,11 +134,115 @@ func (x *Identify) GetSignedPeerRecord() []byte {
 	return nil
 }
 
+func (x *Identify) GetExtensions() map[string][]byte {
+	if x != nil {
+		return x.Extensions
+	}
+	return nil
+}
+
+// Delta is sent on the identify delta protocol. It contains the changes since
+// the last state acknowledged by the receiver.
+type Delta struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	AddedProtocols     []string               `protobuf:"bytes,1,rep,name=addedProtocols" json:"addedProtocols,omitempty"`
+	RemovedProtocols   []string               `protobuf:"bytes,2,rep,name=removedProtocols" json:"removedProtocols,omitempty"`
+	AddedListenAddrs   [][]byte               `protobuf:"bytes,3,rep,name=addedListenAddrs" json:"addedListenAddrs,omitempty"`
+	RemovedListenAddrs [][]byte               `protobuf:"bytes,4,rep,name=removedListenAddrs" json:"removedListenAddrs,omitempty"`
+	// signedPeerRecord is the sender's current signed peer record, set if it
+	// changed. Its addresses replace the addresses of the sender.
+	SignedPeerRecord []byte `protobuf:"bytes,5,opt,name=signedPeerRecord" json:"signedPeerRecord,omitempty"`
+	// extensions contains the extensions that were added or changed.
+	Extensions        map[string][]byte `protobuf:"bytes,6,rep,name=extensions" json:"extensions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	RemovedExtensions []string          `protobuf:"bytes,7,rep,name=removedExtensions" json:"removedExtensions,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Delta) Reset() {
+	*x = Delta{}
+	mi := &file_p2p_protocol_identify_pb_identify_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Delta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Delta) ProtoMessage() {}
+
+func (x *Delta) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_protocol_identify_pb_identify_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Delta.ProtoReflect.Descriptor instead.
+func (*Delta) Descriptor() ([]byte, []int) {
+	return file_p2p_protocol_identify_pb_identify_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Delta) GetAddedProtocols() []string {
+	if x != nil {
+		return x.AddedProtocols
+	}
+	return nil
+}
+
+func (x *Delta) GetRemovedProtocols() []string {
+	if x != nil {
+		return x.RemovedProtocols
+	}
+	return nil
+}
+
+func (x *Delta) GetAddedListenAddrs() [][]byte {
+	if x != nil {
+		return x.AddedListenAddrs
+	}
+	return nil
+}
+
+func (x *Delta) GetRemovedListenAddrs() [][]byte {
+	if x != nil {
+		return x.RemovedListenAddrs
+	}
+	return nil
+}
+
+func (x *Delta) GetSignedPeerRecord() []byte {
+	if x != nil {
+		return x.SignedPeerRecord
+	}
+	return nil
+}
+
+func (x *Delta) GetExtensions() map[string][]byte {
+	if x != nil {
+		return x.Extensions
+	}
+	return nil
+}
+
+func (x *Delta) GetRemovedExtensions() []string {
+	if x != nil {
+		return x.RemovedExtensions
+	}
+	return nil
+}
+
 var File_p2p_protocol_identify_pb_identify_proto protoreflect.FileDescriptor
 
 const file_p2p_protocol_identify_pb_identify_proto_rawDesc = "" +
 	"\n" +
-	"'p2p/protocol/identify/pb/identify.proto\x12\videntify.pb\"\x86\x02\n" +
+	"'p2p/protocol/identify/pb/identify.proto\x12\videntify.pb\"\x8c\x03\n" +
 	"\bIdentify\x12(\n" +
 	"\x0fprotocolVersion\x18\x05 \x01(\tR\x0fprotocolVersion\x12\"\n" +
 	"\fagentVersion\x18\x06 \x01(\tR\fagentVersion\x12\x1c\n" +
@@ -141,7 +250,26 @@ const file_p2p_protocol_identify_pb_identify_proto_rawDesc = "" +
 	"\vlistenAddrs\x18\x02 \x03(\fR\vlistenAddrs\x12\"\n" +
 	"\fobservedAddr\x18\x04 \x01(\fR\fobservedAddr\x12\x1c\n" +
 	"\tprotocols\x18\x03 \x03(\tR\tprotocols\x12*\n" +
-	"\x10signedPeerRecord\x18\b \x01(\fR\x10signedPeerRecordB6Z4github.com/libp2p/go-libp2p/p2p/protocol/identify/pb"
+	"\x10signedPeerRecord\x18\b \x01(\fR\x10signedPeerRecord\x12E\n" +
+	"\n" +
+	"extensions\x18d \x03(\v2%.identify.pb.Identify.ExtensionsEntryR\n" +
+	"extensions\x1a=\n" +
+	"\x0fExtensionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\fR\x05value:\x028\x01\"\x94\x03\n" +
+	"\x05Delta\x12&\n" +
+	"\x0eaddedProtocols\x18\x01 \x03(\tR\x0eaddedProtocols\x12*\n" +
+	"\x10removedProtocols\x18\x02 \x03(\tR\x10removedProtocols\x12*\n" +
+	"\x10addedListenAddrs\x18\x03 \x03(\fR\x10addedListenAddrs\x12.\n" +
+	"\x12removedListenAddrs\x18\x04 \x03(\fR\x12removedListenAddrs\x12*\n" +
+	"\x10signedPeerRecord\x18\x05 \x01(\fR\x10signedPeerRecord\x12B\n" +
+	"\n" +
+	"extensions\x18\x06 \x03(\v2\".identify.pb.Delta.ExtensionsEntryR\n" +
+	"extensions\x12,\n" +
+	"\x11removedExtensions\x18\a \x03(\tR\x11removedExtensions\x1a=\n" +
+	"\x0fExtensionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\fR\x05value:\x028\x01B6Z4github.com/libp2p/go-libp2p/p2p/protocol/identify/pb"
 
 var (
 	file_p2p_protocol_identify_pb_identify_proto_rawDescOnce sync.Once
@@ -155,16 +283,21 @@ func file_p2p_protocol_identify_pb_identify_proto_rawDescGZIP() []byte {
 	return file_p2p_protocol_identify_pb_identify_proto_rawDescData
 }
 
-var file_p2p_protocol_identify_pb_identify_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_p2p_protocol_identify_pb_identify_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_p2p_protocol_identify_pb_identify_proto_goTypes = []any{
 	(*Identify)(nil), // 0: identify.pb.Identify
+	(*Delta)(nil),    // 1: identify.pb.Delta
+	nil,              // 2: identify.pb.Identify.ExtensionsEntry
+	nil,              // 3: identify.pb.Delta.ExtensionsEntry
 }
 var file_p2p_protocol_identify_pb_identify_proto_depIdxs = []int32{
-	0, // [0:0] is the sub-list for method output_type
-	0, // [0:0] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	2, // 0: identify.pb.Identify.extensions:type_name -> identify.pb.Identify.ExtensionsEntry
+	3, // 1: identify.pb.Delta.extensions:type_name -> identify.pb.Delta.ExtensionsEntry
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_p2p_protocol_identify_pb_identify_proto_init() }
@@ -178,7 +311,7 @@ func file_p2p_protocol_identify_pb_identify_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_p2p_protocol_identify_pb_identify_proto_rawDesc), len(file_p2p_protocol_identify_pb_identify_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   1,
+			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
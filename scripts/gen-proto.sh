@@ -17,6 +17,9 @@ proto_array=(
   p2p/protocol/autonatv2/pb/autonatv2.proto
   p2p/protocol/holepunch/pb/holepunch.proto
   p2p/host/peerstore/pstoreds/pb/pstore.proto
+  p2p/host/peerstore/pb/snapshot.proto
+  p2p/host/eventbus/exporter/pb/exporter.proto
+  p2p/discovery/rendezvous/pb/rendezvous.proto
 )
 
 proto_paths=""